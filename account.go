@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"go.uber.org/zap"
+)
+
+// getAccountName returns a friendly name for the account. Names configured in the settings
+// file are used first, then the IAM account alias, then the account ID.
+func getAccountName(ctx context.Context, log *zap.Logger, cfg aws.Config, settings Settings, accountID string) string {
+	if name, ok := settings.AccountNames[accountID]; ok && name != "" {
+		return name
+	}
+	aliases, err := iam.NewFromConfig(cfg).ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		log.Warn("could not list account aliases, using the account ID", zap.Error(err))
+		return accountID
+	}
+	if len(aliases.AccountAliases) > 0 {
+		return aliases.AccountAliases[0]
+	}
+	return accountID
+}
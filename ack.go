@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultAcksFile is where acknowledgements are stored, unless -acks is set.
+const defaultAcksFile = "acks.json"
+
+// Acknowledgement hides a function's recommendations until it expires, e.g. for an accepted risk
+// or a known exception, so that the report is focused on new findings.
+type Acknowledgement struct {
+	Function string `json:"function"`
+	// Account and Region restrict the acknowledgement to the function in one account, or region.
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region,omitempty"`
+	// Type is the type of recommendation that's acknowledged, or empty for all types.
+	Type   string `json:"type,omitempty"`
+	Reason string `json:"reason"`
+	// Until is when the acknowledgement expires, and the recommendations are shown again.
+	Until     time.Time `json:"until"`
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+// Expired is true once the acknowledgement's expiry time has passed.
+func (a Acknowledgement) Expired(now time.Time) bool {
+	return !now.Before(a.Until)
+}
+
+// Matches is true if the acknowledgement applies to the function's recommendation.
+func (a Acknowledgement) Matches(fr FunctionReports, rec Recommendation) bool {
+	return a.Function == fr.Name &&
+		(a.Account == "" || a.Account == fr.Account) &&
+		(a.Region == "" || a.Region == fr.Region) &&
+		(a.Type == "" || a.Type == rec.Type)
+}
+
+// Acknowledgements are read from, and written to, the acknowledgements file.
+type Acknowledgements []Acknowledgement
+
+// Find returns the acknowledgement that hides the function's recommendation, if there's one that
+// hasn't expired.
+func (acks Acknowledgements) Find(fr FunctionReports, rec Recommendation, now time.Time) (ack Acknowledgement, ok bool) {
+	for _, a := range acks {
+		if a.Matches(fr, rec) && !a.Expired(now) {
+			return a, true
+		}
+	}
+	return ack, false
+}
+
+// AcknowledgedRecommendation is a recommendation that's hidden by an acknowledgement.
+type AcknowledgedRecommendation struct {
+	Recommendation  Recommendation
+	Acknowledgement Acknowledgement
+}
+
+// readAcknowledgements reads the acknowledgements file. A file that doesn't exist has no
+// acknowledgements.
+func readAcknowledgements(fileName string) (acks Acknowledgements, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("readAcknowledgements: could not open %q: %w", fileName, err)
+	}
+	defer f.Close()
+	if err = json.NewDecoder(f).Decode(&acks); err != nil {
+		return nil, fmt.Errorf("readAcknowledgements: could not decode %q: %w", fileName, err)
+	}
+	return acks, nil
+}
+
+// writeAcknowledgements writes the acknowledgements as indented JSON, so that the file can be
+// reviewed and kept in source control.
+func writeAcknowledgements(fileName string, acks Acknowledgements) (err error) {
+	err = writeFileAtomic(fileName, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(acks)
+	})
+	if err != nil {
+		return fmt.Errorf("writeAcknowledgements: %w", err)
+	}
+	return nil
+}
+
+// parseUntil parses the expiry date of an acknowledgement, e.g. 2025-09-01, which expires at the
+// start of the day (UTC), or an RFC 3339 time.
+func parseUntil(s string) (until time.Time, err error) {
+	if until, err = time.Parse("2006-01-02", s); err == nil {
+		return until, nil
+	}
+	if until, err = time.Parse(time.RFC3339, s); err == nil {
+		return until.UTC(), nil
+	}
+	return until, fmt.Errorf("could not parse %q, expected a date, e.g. 2025-09-01", s)
+}
+
+func ackCmd(args []string) {
+	cmd := flag.NewFlagSet("ack", flag.ExitOnError)
+	fileName := cmd.String("acks", defaultAcksFile, "Path to the acknowledgements file")
+	reason := cmd.String("reason", "", "Why the recommendations are accepted, e.g. the risk is accepted until the migration")
+	until := cmd.String("until", "", "Date the acknowledgement expires, and the recommendations are shown again, e.g. 2025-09-01")
+	recType := cmd.String("type", "", "Only acknowledge this type of recommendation: "+strings.Join(recommenderNames(), ", ")+", defaults to all")
+	account := cmd.String("account", "", "Only acknowledge the function in this account")
+	region := cmd.String("region", "", "Only acknowledge the function in this region")
+	list := cmd.Bool("list", false, "List the acknowledgements, instead of adding one")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost ack [flags] <function>")
+		fmt.Fprintln(cmd.Output(), "       lambdacost ack -list [-acks acks.json]")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if *list {
+		acks, err := readAcknowledgements(*fileName)
+		if err != nil {
+			log.Fatal("could not read acknowledgements", zap.Error(err))
+		}
+		displayAcknowledgements(os.Stdout, acks, time.Now())
+		return
+	}
+	// The function name can be given before the flags, e.g. ack orders-api -reason "...".
+	if cmd.NArg() < 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	function := cmd.Arg(0)
+	cmd.Parse(cmd.Args()[1:])
+	if cmd.NArg() != 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	if *reason == "" {
+		log.Fatal("-reason is required, so that the exception can be reviewed")
+	}
+	if *until == "" {
+		log.Fatal("-until is required, so that acknowledgements expire and are reviewed")
+	}
+	expires, err := parseUntil(*until)
+	if err != nil {
+		log.Fatal("could not parse -until", zap.Error(err))
+	}
+	now := time.Now()
+	if !expires.After(now) {
+		log.Fatal("-until must be in the future", zap.Time("until", expires))
+	}
+	if *recType != "" {
+		if _, ok := recommenderFactories[*recType]; !ok {
+			log.Fatal("unknown recommendation type", zap.String("type", *recType), zap.Strings("expected", recommenderNames()))
+		}
+	}
+	acks, err := readAcknowledgements(*fileName)
+	if err != nil {
+		log.Fatal("could not read acknowledgements", zap.Error(err))
+	}
+	ack := Acknowledgement{
+		Function:  function,
+		Account:   *account,
+		Region:    *region,
+		Type:      *recType,
+		Reason:    *reason,
+		Until:     expires,
+		Created:   now.UTC(),
+		CreatedBy: localActor(),
+	}
+	// An acknowledgement of the same function, account, region and type replaces the existing one.
+	var replaced bool
+	for i, a := range acks {
+		if a.Function == ack.Function && a.Account == ack.Account && a.Region == ack.Region && a.Type == ack.Type {
+			acks[i], replaced = ack, true
+		}
+	}
+	if !replaced {
+		acks = append(acks, ack)
+	}
+	if err = writeAcknowledgements(*fileName, acks); err != nil {
+		log.Fatal("could not write acknowledgements", zap.Error(err))
+	}
+	log.Info("acknowledged recommendations", zap.String("functionName", function), zap.String("type", ack.Type), zap.Time("until", expires), zap.Bool("replaced", replaced), zap.String("filename", *fileName))
+}
+
+func displayAcknowledgements(w io.Writer, acks Acknowledgements, now time.Time) {
+	if len(acks) == 0 {
+		fmt.Fprintln(w, "No acknowledgements.")
+		return
+	}
+	acks = append(Acknowledgements{}, acks...)
+	sort.SliceStable(acks, func(i, j int) bool {
+		return acks[i].Until.Before(acks[j].Until)
+	})
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Account", "Region", "Type", "Until", "Status", "Created By", "Reason"}, "\t"))
+	for _, a := range acks {
+		status := "active"
+		if a.Expired(now) {
+			status = "expired"
+		}
+		recType := a.Type
+		if recType == "" {
+			recType = "all"
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			a.Function,
+			a.Account,
+			a.Region,
+			recType,
+			a.Until.Format("2006-01-02"),
+			status,
+			a.CreatedBy,
+			a.Reason,
+		}, "\t"))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	aastypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+)
+
+// Application Auto Scaling identifiers of Lambda provisioned concurrency.
+const (
+	provisionedConcurrencyDimension  = aastypes.ScalableDimensionLambdaFunctionProvisionedConcurrency
+	provisionedConcurrencyResourceID = "function:"
+)
+
+// getProvisionedConcurrencyActions returns the scheduled actions that scale the provisioned
+// concurrency of functions in the region, by function name, then qualifier.
+func getProvisionedConcurrencyActions(ctx context.Context, client *applicationautoscaling.Client, region string) (actions map[string]map[string][]ScheduledCapacity, err error) {
+	paginator := applicationautoscaling.NewDescribeScheduledActionsPaginator(client, &applicationautoscaling.DescribeScheduledActionsInput{
+		ServiceNamespace:  aastypes.ServiceNamespaceLambda,
+		ScalableDimension: provisionedConcurrencyDimension,
+	})
+	actions = map[string]map[string][]ScheduledCapacity{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, func(o *applicationautoscaling.Options) {
+			o.Region = region
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getProvisionedConcurrencyActions: failed to describe scheduled actions: %w", err)
+		}
+		for _, a := range page.ScheduledActions {
+			// Resource IDs are function:{name}:{qualifier}.
+			resourceID := aws.ToString(a.ResourceId)
+			name, qualifier, ok := strings.Cut(strings.TrimPrefix(resourceID, provisionedConcurrencyResourceID), ":")
+			if !ok || !strings.HasPrefix(resourceID, provisionedConcurrencyResourceID) {
+				continue
+			}
+			sc := ScheduledCapacity{
+				Name:     aws.ToString(a.ScheduledActionName),
+				Schedule: aws.ToString(a.Schedule),
+				Timezone: aws.ToString(a.Timezone),
+				Created:  aws.ToTime(a.CreationTime).UTC(),
+			}
+			if a.StartTime != nil {
+				start := a.StartTime.UTC()
+				sc.Start = &start
+			}
+			if a.EndTime != nil {
+				end := a.EndTime.UTC()
+				sc.End = &end
+			}
+			if a.ScalableTargetAction != nil {
+				sc.MinCapacity, sc.MaxCapacity = a.ScalableTargetAction.MinCapacity, a.ScalableTargetAction.MaxCapacity
+			}
+			if actions[name] == nil {
+				actions[name] = map[string][]ScheduledCapacity{}
+			}
+			actions[name][qualifier] = append(actions[name][qualifier], sc)
+		}
+	}
+	return actions, nil
+}
+
+// setProvisionedConcurrencySchedules adds the scheduled actions that scale the provisioned
+// concurrency of the functions. The actions of each region are listed once. A function's
+// schedules are invalid if their expressions can't be parsed, so they're recorded as an error,
+// and its allocation is assumed to be constant.
+func setProvisionedConcurrencySchedules(ctx context.Context, client *applicationautoscaling.Client, functionReports []FunctionReports, allocations []map[string]int32) (errs map[string]error) {
+	errs = map[string]error{}
+	byRegion := map[string][]int{}
+	for i, fr := range functionReports {
+		byRegion[fr.Region] = append(byRegion[fr.Region], i)
+	}
+	for region, indexes := range byRegion {
+		actions, err := getProvisionedConcurrencyActions(ctx, client, region)
+		if err != nil {
+			errs[region] = err
+			continue
+		}
+		for _, i := range indexes {
+			fr := &functionReports[i]
+			for qualifier, qualifierActions := range actions[fr.Name] {
+				s := ProvisionedConcurrencySchedule{
+					Qualifier: qualifier,
+					Allocated: allocations[i][qualifier],
+					Actions:   qualifierActions,
+				}
+				if err := s.validate(); err != nil {
+					fr.addError(errorKindOther, "parseSchedule", err)
+					continue
+				}
+				fr.ProvisionedConcurrencySchedules = append(fr.ProvisionedConcurrencySchedules, s)
+			}
+			sort.Slice(fr.ProvisionedConcurrencySchedules, func(i, j int) bool {
+				return fr.ProvisionedConcurrencySchedules[i].Qualifier < fr.ProvisionedConcurrencySchedules[j].Qualifier
+			})
+		}
+	}
+	return errs
+}
+
+// validate returns an error if any of the schedule's expressions can't be parsed.
+func (s ProvisionedConcurrencySchedule) validate() error {
+	for _, a := range s.Actions {
+		if _, err := parseScheduleExpression(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
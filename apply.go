@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"go.uber.org/zap"
+)
+
+const applyViaCloudFormation = "cloudformation"
+
+// Tags added by CloudFormation to the resources it manages.
+const (
+	tagCloudFormationStackName = "aws:cloudformation:stack-name"
+	tagCloudFormationLogicalID = "aws:cloudformation:logical-id"
+)
+
+// TemplateBody is limited to 51,200 bytes, larger templates must be uploaded to S3.
+const cloudFormationMaxTemplateBody = 51200
+
+// functionChange is a change to a function's configuration.
+type functionChange struct {
+	Function  FunctionReports
+	Stack     string
+	LogicalID string
+	// MemorySize is zero if the memory isn't changing.
+	MemorySize int64
+	// Architecture is empty if the architecture isn't changing.
+	Architecture Architecture
+}
+
+func (fc functionChange) String() string {
+	var changes []string
+	if fc.MemorySize > 0 {
+		changes = append(changes, fmt.Sprintf("MemorySize %d -> %d", fc.Function.MemoryAssigned(), fc.MemorySize))
+	}
+	if fc.Architecture != "" {
+		changes = append(changes, fmt.Sprintf("Architectures %s -> %s", fc.Function.Architecture, fc.Architecture))
+	}
+	return strings.Join(changes, ", ")
+}
+
+func applyCmd(args []string) {
+	cmd := flag.NewFlagSet("apply", flag.ExitOnError)
+	via := cmd.String("via", applyViaCloudFormation, "How changes are applied, only cloudformation is supported")
+	changeTypes := cmd.String("changes", recommendationMemory+","+recommendationArchitecture, "Comma separated list of changes to apply: memory, architecture")
+	execute := cmd.Bool("execute", false, "Execute the change sets once they're created, and wait for the stack updates to complete, instead of leaving them for review")
+	auditLogFile := cmd.String("audit-log", "", "Path to append a JSON lines record of the changes that were applied to, e.g. audit.jsonl")
+	auditTable := cmd.String("audit-table", "", "DynamoDB table to write a record of the changes that were applied to, e.g. the pipeline's AuditTable")
+	af := newAWSFlags(cmd)
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost apply -via cloudformation [flags] <file.json>")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	if *via != applyViaCloudFormation {
+		log.Fatal("unsupported apply method", zap.String("via", *via))
+	}
+	types := splitList(*changeTypes)
+	for _, t := range types {
+		if t != recommendationMemory && t != recommendationArchitecture {
+			log.Fatal("unsupported change type", zap.String("change", t))
+		}
+	}
+	functionReports, err := readFunctionReports(cmd.Arg(0))
+	if err != nil {
+		log.Fatal("could not read report data", zap.Error(err))
+	}
+
+	// Handle Ctrl-C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cfg, err := loadAWSConfig(ctx, af.options())
+	if err != nil {
+		log.Fatal("could not load AWS config", zap.Error(err))
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Fatal("could not get current identity, are you logged in?", zap.Error(err))
+	}
+
+	audit := newAuditLog(*auditLogFile, aws.ToString(identity.Arn)).WithTable(dynamodb.NewFromConfig(cfg), *auditTable)
+	defer audit.Close()
+	addAuditEntry := func(e AuditEntry) {
+		if err := audit.Add(e); err != nil {
+			log.Fatal("could not write audit log", zap.Error(err))
+		}
+	}
+
+	changes, skipped := planChanges(functionReports, *identity.Account, types)
+	for _, s := range skipped {
+		log.Warn("function skipped", zap.String("functionName", s.Function.Name), zap.String("reason", s.Reason))
+		addAuditEntry(AuditEntry{
+			Action:   auditActionApply,
+			Account:  s.Function.Account,
+			Region:   s.Function.Region,
+			Function: s.Function.Name,
+			Status:   auditStatusSkipped,
+			Details:  s.Reason,
+		})
+	}
+	if len(changes) == 0 {
+		log.Info("no changes to apply")
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Region", "Stack", "Change Set", "Status", "Changes"}, "\t"))
+	for _, stackChanges := range groupChangesByStack(changes) {
+		region, stack := stackChanges[0].Function.Region, stackChanges[0].Stack
+		log := log.With(zap.String("region", region), zap.String("stack", stack))
+		client := cloudformation.NewFromConfig(cfg, func(o *cloudformation.Options) {
+			o.Region = region
+		})
+		changeSetID, err := createChangeSet(ctx, client, stack, stackChanges)
+		if err != nil {
+			log.Error("could not create change set", zap.Error(err))
+			fmt.Fprintln(tw, strings.Join([]string{region, stack, "", "failed", err.Error()}, "\t"))
+			for _, fc := range stackChanges {
+				addAuditEntry(fc.auditEntry(auditStatusFailed, "", err.Error()))
+			}
+			continue
+		}
+		status, auditStatus, reason := "created", auditStatusCreated, ""
+		if *execute {
+			status, auditStatus = "executed", auditStatusExecuted
+			if err = executeChangeSet(ctx, client, changeSetID); err != nil {
+				log.Error("could not execute change set", zap.Error(err))
+				status, auditStatus, reason = "failed to execute", auditStatusFailed, err.Error()
+			} else if auditStatus, err = waitForStackUpdate(ctx, client, stack); err != nil {
+				log.Error("stack update failed", zap.Error(err))
+				status, reason = "failed to update", err.Error()
+			} else if auditStatus == auditStatusRolledBack {
+				log.Error("stack update was rolled back")
+				status = auditStatusRolledBack
+			}
+		}
+		for _, fc := range stackChanges {
+			fmt.Fprintln(tw, strings.Join([]string{region, stack, changeSetID, status, fc.LogicalID + ": " + fc.String()}, "\t"))
+			addAuditEntry(fc.auditEntry(auditStatus, changeSetID, reason))
+		}
+	}
+	tw.Flush()
+	if !*execute {
+		fmt.Println()
+		fmt.Println("Review the change sets in the CloudFormation console, or run again with -execute to apply them.")
+	}
+}
+
+// auditEntry records the change in the audit log, with the reason if it failed.
+func (fc functionChange) auditEntry(status, changeSetID, reason string) AuditEntry {
+	details := fc.String()
+	if reason != "" {
+		details += ": " + reason
+	}
+	return AuditEntry{
+		Action:    auditActionApply,
+		Account:   fc.Function.Account,
+		Region:    fc.Function.Region,
+		Function:  fc.Function.Name,
+		Stack:     fc.Stack,
+		ChangeSet: changeSetID,
+		Status:    status,
+		Details:   details,
+	}
+}
+
+type skippedFunction struct {
+	Function FunctionReports
+	Reason   string
+}
+
+// planChanges returns the memory and architecture changes for functions in the account.
+func planChanges(functionReports []FunctionReports, accountID string, types []string) (changes []functionChange, skipped []skippedFunction) {
+	for _, fr := range functionReports {
+		fc := functionChange{
+			Function:  fr,
+			Stack:     fr.Tags[tagCloudFormationStackName],
+			LogicalID: fr.Tags[tagCloudFormationLogicalID],
+		}
+		if contains(types, recommendationMemory) {
+			if _, ok := memoryRecommendation(fr); ok {
+				memorySize, _ := fr.OptimisedCost()
+				if configured, logged, mismatch := fr.MemoryMismatch(); mismatch {
+					skipped = append(skipped, skippedFunction{Function: fr, Reason: fmt.Sprintf("memory change skipped, configured memory of %d MB doesn't match the %d MB in the REPORT lines", configured, logged)})
+				} else if projected, risk := fr.TimeoutRisk(memorySize); risk {
+					skipped = append(skipped, skippedFunction{Function: fr, Reason: fmt.Sprintf("memory change skipped, projected p99 duration of %v is close to the %v timeout", projected.Round(time.Millisecond), fr.Timeout)})
+				} else {
+					fc.MemorySize = memorySize
+				}
+			}
+		}
+		if contains(types, recommendationArchitecture) {
+			if _, ok := architectureRecommendation(fr); ok {
+				fc.Architecture = ArchitectureARM64
+			}
+		}
+		if fc.MemorySize == 0 && fc.Architecture == "" {
+			continue
+		}
+		switch {
+		case fr.Account != accountID:
+			skipped = append(skipped, skippedFunction{Function: fr, Reason: fmt.Sprintf("function is in account %s", fr.Account)})
+		case fc.Stack == "" || fc.LogicalID == "":
+			skipped = append(skipped, skippedFunction{Function: fr, Reason: "function is not managed by CloudFormation"})
+		default:
+			changes = append(changes, fc)
+		}
+	}
+	return changes, skipped
+}
+
+// groupChangesByStack groups changes by region and stack, sorted by stack name.
+func groupChangesByStack(changes []functionChange) (groups [][]functionChange) {
+	indexes := map[string]int{}
+	for _, fc := range changes {
+		key := fc.Function.Region + "/" + fc.Stack
+		index, ok := indexes[key]
+		if !ok {
+			index = len(groups)
+			indexes[key] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], fc)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0].Function.Region+"/"+groups[i][0].Stack < groups[j][0].Function.Region+"/"+groups[j][0].Stack
+	})
+	return groups
+}
+
+// createChangeSet creates a change set that updates the stack's template with the changes, keeping
+// the stack's existing parameter values.
+func createChangeSet(ctx context.Context, client *cloudformation.Client, stack string, changes []functionChange) (changeSetID string, err error) {
+	template, err := client.GetTemplate(ctx, &cloudformation.GetTemplateInput{
+		StackName:     &stack,
+		TemplateStage: cfntypes.TemplateStageOriginal,
+	})
+	if err != nil {
+		return "", fmt.Errorf("createChangeSet: failed to get template: %w", err)
+	}
+	body, err := updateTemplate(aws.ToString(template.TemplateBody), changes)
+	if err != nil {
+		return "", fmt.Errorf("createChangeSet: %w", err)
+	}
+	if len(body) > cloudFormationMaxTemplateBody {
+		return "", fmt.Errorf("createChangeSet: updated template is %d bytes, which is larger than the %d byte limit", len(body), cloudFormationMaxTemplateBody)
+	}
+	stacks, err := client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: &stack,
+	})
+	if err != nil {
+		return "", fmt.Errorf("createChangeSet: failed to describe stack: %w", err)
+	}
+	if len(stacks.Stacks) == 0 {
+		return "", fmt.Errorf("createChangeSet: stack %q not found", stack)
+	}
+	var parameters []cfntypes.Parameter
+	for _, p := range stacks.Stacks[0].Parameters {
+		parameters = append(parameters, cfntypes.Parameter{
+			ParameterKey:     p.ParameterKey,
+			UsePreviousValue: aws.Bool(true),
+		})
+	}
+	output, err := client.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
+		StackName:     &stack,
+		ChangeSetName: aws.String(fmt.Sprintf("lambdacost-%s", time.Now().UTC().Format("20060102150405"))),
+		ChangeSetType: cfntypes.ChangeSetTypeUpdate,
+		Description:   aws.String("Memory and architecture changes recommended by lambdacost"),
+		TemplateBody:  &body,
+		Parameters:    parameters,
+		Capabilities: []cfntypes.Capability{
+			cfntypes.CapabilityCapabilityIam,
+			cfntypes.CapabilityCapabilityNamedIam,
+			cfntypes.CapabilityCapabilityAutoExpand,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("createChangeSet: failed to create change set: %w", err)
+	}
+	return aws.ToString(output.Id), nil
+}
+
+// How long to wait for a change set to be created before executing it.
+const changeSetCreateTimeout = 5 * time.Minute
+
+func executeChangeSet(ctx context.Context, client *cloudformation.Client, changeSetID string) (err error) {
+	waiter := cloudformation.NewChangeSetCreateCompleteWaiter(client)
+	err = waiter.Wait(ctx, &cloudformation.DescribeChangeSetInput{
+		ChangeSetName: &changeSetID,
+	}, changeSetCreateTimeout)
+	if err != nil {
+		return fmt.Errorf("executeChangeSet: change set was not created: %w", err)
+	}
+	_, err = client.ExecuteChangeSet(ctx, &cloudformation.ExecuteChangeSetInput{
+		ChangeSetName: &changeSetID,
+	})
+	if err != nil {
+		return fmt.Errorf("executeChangeSet: failed to execute change set: %w", err)
+	}
+	return nil
+}
+
+// How long to wait for a stack update to complete after its change set is executed.
+const stackUpdateTimeout = 30 * time.Minute
+
+// waitForStackUpdate waits for the update started by executing a change set, and returns whether
+// the stack was updated, or the update failed and was rolled back.
+func waitForStackUpdate(ctx context.Context, client *cloudformation.Client, stack string) (status string, err error) {
+	waiter := cloudformation.NewStackUpdateCompleteWaiter(client)
+	err = waiter.Wait(ctx, &cloudformation.DescribeStacksInput{
+		StackName: &stack,
+	}, stackUpdateTimeout)
+	if err == nil {
+		return auditStatusExecuted, nil
+	}
+	stacks, describeErr := client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: &stack,
+	})
+	if describeErr == nil && len(stacks.Stacks) > 0 && stacks.Stacks[0].StackStatus == cfntypes.StackStatusUpdateRollbackComplete {
+		return auditStatusRolledBack, nil
+	}
+	return auditStatusFailed, fmt.Errorf("waitForStackUpdate: %w", err)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/a-h/lambdacost/pricing"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Architecture is the instruction set architecture of a function.
+type Architecture = pricing.Architecture
+
+const (
+	ArchitectureX86_64 = pricing.ArchitectureX86_64
+	ArchitectureARM64  = pricing.ArchitectureARM64
+)
+
+// parseArchitecture returns the architecture of a function. Lambda functions have a single
+// architecture, and default to x86_64 if none is set. Unknown or multiple values are returned
+// along with an error, and are priced as x86_64.
+func parseArchitecture(values []types.Architecture) (a Architecture, err error) {
+	if len(values) == 0 {
+		return ArchitectureX86_64, nil
+	}
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = strings.ToLower(strings.TrimSpace(string(v)))
+	}
+	a = Architecture(strings.Join(names, " "))
+	if len(values) > 1 {
+		return a, fmt.Errorf("parseArchitecture: expected a single architecture, got %q", a)
+	}
+	if !a.Known() {
+		return a, fmt.Errorf("parseArchitecture: unknown architecture %q", a)
+	}
+	return a, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Actions recorded in the audit log.
+const (
+	auditActionScan      = "scan"
+	auditActionRecommend = "recommend"
+	auditActionApply     = "apply"
+)
+
+// Status of an action.
+const (
+	auditStatusComplete   = "complete"
+	auditStatusPartial    = "partial"
+	auditStatusCreated    = "created"
+	auditStatusExecuted   = "executed"
+	auditStatusRolledBack = "rolled back"
+	auditStatusFailed     = "failed"
+	auditStatusSkipped    = "skipped"
+)
+
+// AuditEntry records what a run of lambdacost scanned, recommended or applied, and who ran it,
+// for change management.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// RunID is shared by the entries written by the same run.
+	RunID  string `json:"runId"`
+	Action string `json:"action"`
+	// Actor is the AWS identity that ran the action, or the local user if AWS wasn't used.
+	Actor          string  `json:"actor"`
+	Account        string  `json:"account,omitempty"`
+	Region         string  `json:"region,omitempty"`
+	Function       string  `json:"function,omitempty"`
+	Functions      int     `json:"functions,omitempty"`
+	Stack          string  `json:"stack,omitempty"`
+	ChangeSet      string  `json:"changeSet,omitempty"`
+	Status         string  `json:"status,omitempty"`
+	Details        string  `json:"details,omitempty"`
+	MonthlySavings float64 `json:"monthlySavings,omitempty"`
+}
+
+// newAuditID returns an ID for a run or an entry, starting with the time so that IDs sort in order.
+func newAuditID(now time.Time) string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return now.UTC().Format("20060102T150405Z")
+	}
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// localActor returns the user running lambdacost, and the host they're running it on.
+func localActor() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return name + "@" + host
+	}
+	return name
+}
+
+// auditLog appends entries to a JSON lines file, a DynamoDB table, or both. Existing entries are
+// never modified, and the file is only created when the first entry is added.
+type auditLog struct {
+	fileName string
+	table    *auditTable
+	runID    string
+	actor    string
+	f        *os.File
+	enc      *json.Encoder
+}
+
+func newAuditLog(fileName, actor string) *auditLog {
+	return &auditLog{
+		fileName: fileName,
+		runID:    newAuditID(time.Now()),
+		actor:    actor,
+	}
+}
+
+// WithTable also writes entries to the DynamoDB table, if the table name isn't empty.
+func (a *auditLog) WithTable(client *dynamodb.Client, tableName string) *auditLog {
+	if tableName != "" {
+		a.table = newAuditTable(client, tableName)
+	}
+	return a
+}
+
+// Add writes the entry, with the time, run ID and actor of the run.
+func (a *auditLog) Add(e AuditEntry) (err error) {
+	if a == nil || (a.fileName == "" && a.table == nil) {
+		return nil
+	}
+	e.Time = time.Now().UTC()
+	e.RunID = a.runID
+	e.Actor = a.actor
+	if a.table != nil {
+		if err = a.table.Put(context.Background(), e); err != nil {
+			return fmt.Errorf("auditLog: %w", err)
+		}
+	}
+	if a.fileName == "" {
+		return nil
+	}
+	if a.f == nil {
+		a.f, err = os.OpenFile(a.fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("auditLog: could not open %q: %w", a.fileName, err)
+		}
+		a.enc = json.NewEncoder(a.f)
+	}
+	if err = a.enc.Encode(e); err != nil {
+		return fmt.Errorf("auditLog: could not write to %q: %w", a.fileName, err)
+	}
+	return nil
+}
+
+func (a *auditLog) Close() error {
+	if a == nil || a.f == nil {
+		return nil
+	}
+	return a.f.Close()
+}
+
+// Key attributes of the audit table. Entries are partitioned by run, and sorted by time.
+const (
+	auditTablePartitionKey = "runId"
+	auditTableSortKey      = "entryId"
+)
+
+// auditTable writes entries to a DynamoDB table, for runs that can't append to a file, e.g. the
+// pipeline function. Each entry is written with a condition that it doesn't already exist, so
+// existing entries are never modified.
+type auditTable struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func newAuditTable(client *dynamodb.Client, tableName string) *auditTable {
+	return &auditTable{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// Put writes the entry. The time, run ID and actor must already be set.
+func (t *auditTable) Put(ctx context.Context, e AuditEntry) (err error) {
+	item, err := auditItem(e)
+	if err != nil {
+		return fmt.Errorf("auditTable: could not encode entry: %w", err)
+	}
+	item[auditTableSortKey] = &types.AttributeValueMemberS{Value: newAuditID(e.Time)}
+	_, err = t.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           &t.tableName,
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(" + auditTableSortKey + ")"),
+	})
+	if err != nil {
+		return fmt.Errorf("auditTable: could not write to %q: %w", t.tableName, err)
+	}
+	return nil
+}
+
+// auditItem converts the entry to a DynamoDB item, with an attribute for each field of its JSON
+// encoding, so that empty fields are left out in the same way as the JSON lines file.
+func auditItem(e AuditEntry) (item map[string]types.AttributeValue, err error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err = dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	item = make(map[string]types.AttributeValue, len(fields)+1)
+	for k, v := range fields {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				item[k] = &types.AttributeValueMemberS{Value: v}
+			}
+		case json.Number:
+			item[k] = &types.AttributeValueMemberN{Value: v.String()}
+		default:
+			return nil, fmt.Errorf("unexpected type %T for %q", v, k)
+		}
+	}
+	return item, nil
+}
+
+// recommendationAuditEntries returns an entry for each recommendation made for the functions.
+func recommendationAuditEntries(functionReports []FunctionReports, recommenders *Recommenders) (entries []AuditEntry) {
+	for _, fr := range functionReports {
+		for _, rec := range recommenders.Recommend(fr) {
+			entries = append(entries, AuditEntry{
+				Action:         auditActionRecommend,
+				Account:        fr.Account,
+				Region:         fr.Region,
+				Function:       fr.Name,
+				Details:        rec.Type + ": " + rec.Description,
+				MonthlySavings: rec.MonthlySavings,
+			})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func TestAuditTable(t *testing.T) {
+	f := newFakeAWS(t, "eu-west-1")
+	audit := newAuditLog("", "arn:aws:iam::123456789012:user/test").WithTable(dynamodb.NewFromConfig(f.Config()), "audit")
+	entries := []AuditEntry{
+		{Action: auditActionRecommend, Function: "api", Details: "memory: reduce memory from 2048 MB to 1024 MB", MonthlySavings: 12.5},
+		{Action: auditActionApply, Function: "api", Status: auditStatusExecuted},
+	}
+	for _, e := range entries {
+		if err := audit.Add(e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	items := f.Items("audit")
+	if len(items) != len(entries) {
+		t.Fatalf("expected %d items, got %d", len(entries), len(items))
+	}
+	if items[0][auditTableSortKey]["S"] == items[1][auditTableSortKey]["S"] {
+		t.Errorf("expected each entry to have its own ID, got %v", items[0][auditTableSortKey])
+	}
+	for i, item := range items {
+		if item[auditTablePartitionKey]["S"] != audit.runID {
+			t.Errorf("item %d: expected run ID %q, got %v", i, audit.runID, item[auditTablePartitionKey])
+		}
+		if item["actor"]["S"] != "arn:aws:iam::123456789012:user/test" {
+			t.Errorf("item %d: expected the actor to be set, got %v", i, item["actor"])
+		}
+		if item["action"]["S"] != entries[i].Action {
+			t.Errorf("item %d: expected action %q, got %v", i, entries[i].Action, item["action"])
+		}
+	}
+	if items[0]["monthlySavings"]["N"] != "12.5" {
+		t.Errorf("expected monthly savings to be a number, got %v", items[0]["monthlySavings"])
+	}
+	if _, ok := items[1]["monthlySavings"]; ok {
+		t.Errorf("expected empty fields to be left out, got %v", items[1])
+	}
+}
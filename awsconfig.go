@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Environment variables used to set custom endpoints, with the same names as the AWS CLI.
+// Service specific endpoints are set with AWS_ENDPOINT_URL_{SERVICE}, where {SERVICE} is the
+// SDK service ID in upper case, with spaces replaced by underscores, e.g. AWS_ENDPOINT_URL_CLOUDWATCH_LOGS.
+const envEndpointURL = "AWS_ENDPOINT_URL"
+
+// awsFlags are shared by the commands that call AWS.
+type awsFlags struct {
+	endpointURL *string
+	proxy       *string
+	apiTimeout  *time.Duration
+}
+
+func newAWSFlags(fs *flag.FlagSet) awsFlags {
+	return awsFlags{
+		endpointURL: fs.String("endpoint-url", "", "Custom endpoint URL used for all AWS services, e.g. http://localhost:4566 for LocalStack, overrides "+envEndpointURL),
+		apiTimeout:  fs.Duration("api-timeout", 0, "Timeout for each AWS API request, e.g. 30s, so that a stuck request fails instead of hanging, or 0 for no limit"),
+		proxy:       fs.String("proxy", "", "HTTP proxy URL used for AWS requests, e.g. http://proxy.example.com:3128, overrides HTTPS_PROXY"),
+	}
+}
+
+func (af awsFlags) options() awsOptions {
+	return awsOptions{
+		EndpointURL: *af.endpointURL,
+		ProxyURL:    *af.proxy,
+		APITimeout:  *af.apiTimeout,
+	}
+}
+
+// awsOptions customise how AWS is called, e.g. in locked-down networks, unattended runs, or
+// integration tests.
+type awsOptions struct {
+	// EndpointURL is used for all services, unless a service specific endpoint is set in
+	// the environment. If empty, AWS_ENDPOINT_URL is used.
+	EndpointURL string
+	// ProxyURL is the HTTP proxy used for all requests. If empty, the HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY environment variables are used.
+	ProxyURL string
+	// APITimeout is the timeout of each request attempt. Failed attempts are retried by the SDK.
+	APITimeout time.Duration
+}
+
+// loadAWSConfig loads the default AWS config, with any custom endpoints and proxy.
+func loadAWSConfig(ctx context.Context, opts awsOptions) (cfg aws.Config, err error) {
+	var loadOptions []func(*config.LoadOptions) error
+	if opts.ProxyURL != "" || opts.APITimeout > 0 {
+		client := awshttp.NewBuildableClient()
+		if opts.ProxyURL != "" {
+			proxyURL, err := url.Parse(opts.ProxyURL)
+			if err != nil || proxyURL.Host == "" {
+				return cfg, fmt.Errorf("loadAWSConfig: invalid proxy URL %q", opts.ProxyURL)
+			}
+			client = client.WithTransportOptions(func(t *http.Transport) {
+				t.Proxy = http.ProxyURL(proxyURL)
+			})
+		}
+		if opts.APITimeout > 0 {
+			client = client.WithTimeout(opts.APITimeout)
+		}
+		loadOptions = append(loadOptions, config.WithHTTPClient(client))
+	}
+	if opts.EndpointURL != "" {
+		if _, err = url.ParseRequestURI(opts.EndpointURL); err != nil {
+			return cfg, fmt.Errorf("loadAWSConfig: invalid endpoint URL %q: %w", opts.EndpointURL, err)
+		}
+	}
+	loadOptions = append(loadOptions, config.WithEndpointResolverWithOptions(endpointResolver(opts.EndpointURL)))
+	cfg, err = config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return cfg, fmt.Errorf("loadAWSConfig: %w", err)
+	}
+	return cfg, nil
+}
+
+// endpointResolver uses the service specific endpoint from the environment, then the endpoint
+// URL, then AWS_ENDPOINT_URL. If none are set, the SDK's default endpoint is used.
+func endpointResolver(endpointURL string) aws.EndpointResolverWithOptions {
+	return aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		u := os.Getenv(envEndpointURL + "_" + strings.ToUpper(strings.ReplaceAll(service, " ", "_")))
+		if u == "" {
+			u = endpointURL
+		}
+		if u == "" {
+			u = os.Getenv(envEndpointURL)
+		}
+		if u == "" {
+			return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+		}
+		return aws.Endpoint{
+			URL: u,
+			// Don't prefix the host with the bucket name, since custom S3 endpoints, e.g.
+			// LocalStack, usually expect path style requests.
+			HostnameImmutable: true,
+			SigningRegion:     region,
+			Source:            aws.EndpointSourceCustom,
+		}, nil
+	})
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Default percentage increase in a function's monthly cost, compared to the baseline, that fails the run.
+const defaultBaselineThreshold = 10.0
+
+// CostRegression is a function whose monthly cost increased compared to the baseline.
+type CostRegression struct {
+	Function        FunctionReports
+	BaselineMonthly float64
+	CurrentMonthly  float64
+	// PercentChange is the increase in monthly cost as a percentage of the baseline.
+	PercentChange float64
+}
+
+// findRegressions returns the functions whose monthly cost increased by more than the threshold
+// percentage compared to the baseline. Functions costing less than a cent per month are ignored,
+// as are functions without a baseline.
+func findRegressions(baseline, current []FunctionReports, thresholdPercent float64) (regressions []CostRegression) {
+	baselineCosts := map[string]float64{}
+	for _, fr := range baseline {
+		if fr.LogGroupMissing {
+			continue
+		}
+		baselineCosts[fr.Account+"/"+fr.Region+"/"+fr.Name] = fr.DailyCost() * 30
+	}
+	for _, fr := range current {
+		if fr.LogGroupMissing {
+			continue
+		}
+		baselineMonthly, ok := baselineCosts[fr.Account+"/"+fr.Region+"/"+fr.Name]
+		if !ok || baselineMonthly <= 0 {
+			continue
+		}
+		currentMonthly := fr.DailyCost() * 30
+		if currentMonthly < lowMonthlyCostThreshold {
+			continue
+		}
+		change := (currentMonthly - baselineMonthly) / baselineMonthly * 100
+		if change > thresholdPercent {
+			regressions = append(regressions, CostRegression{
+				Function:        fr,
+				BaselineMonthly: baselineMonthly,
+				CurrentMonthly:  currentMonthly,
+				PercentChange:   change,
+			})
+		}
+	}
+	sort.Slice(regressions, func(i, j int) bool {
+		return regressions[i].CurrentMonthly-regressions[i].BaselineMonthly > regressions[j].CurrentMonthly-regressions[j].BaselineMonthly
+	})
+	return regressions
+}
+
+func displayRegressions(w io.Writer, regressions []CostRegression, thresholdPercent float64, format displayFormat) {
+	fmt.Fprintln(w)
+	if len(regressions) == 0 {
+		fmt.Fprintf(w, "Baseline: no functions increased in cost by more than %.0f%%\n", thresholdPercent)
+		return
+	}
+	fmt.Fprintf(w, "Baseline: %d functions increased in cost by more than %.0f%%\n", len(regressions), thresholdPercent)
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Baseline Monthly", "Current Monthly", "Change"}, "\t"))
+	for _, r := range regressions {
+		fmt.Fprintln(tw, strings.Join([]string{
+			r.Function.Name,
+			r.Function.Region,
+			format.Money(r.BaselineMonthly, r.Function.Currency(), 2),
+			format.Money(r.CurrentMonthly, r.Function.Currency(), 2),
+			"+" + format.Percent(r.PercentChange, 1),
+		}, "\t"))
+	}
+	tw.Flush()
+}
+
+// Default monthly cost, in USD, above which functions that aren't in the baseline are flagged.
+const defaultNewFunctionThreshold = 10.0
+
+// NewFunction is a function that isn't in the baseline, and already costs more than the threshold.
+type NewFunction struct {
+	Account     string  `json:"account"`
+	Region      string  `json:"region"`
+	Name        string  `json:"name"`
+	MonthlyCost float64 `json:"monthlyCost"`
+}
+
+// findNewFunctions returns the functions that aren't in the baseline, e.g. because they were
+// deployed since, whose monthly cost is above the threshold, most expensive first.
+func findNewFunctions(baseline, current []FunctionReports, threshold float64) (functions []NewFunction) {
+	if len(baseline) == 0 {
+		return nil
+	}
+	inBaseline := map[string]bool{}
+	for _, fr := range baseline {
+		inBaseline[fr.Account+"/"+fr.Region+"/"+fr.Name] = true
+	}
+	for _, fr := range current {
+		if fr.LogGroupMissing || fr.PreselectionSkipped || inBaseline[fr.Account+"/"+fr.Region+"/"+fr.Name] {
+			continue
+		}
+		if monthly := fr.DailyCost() * 30; monthly > threshold {
+			functions = append(functions, NewFunction{
+				Account:     fr.DisplayAccount(),
+				Region:      fr.Region,
+				Name:        fr.Name,
+				MonthlyCost: monthly,
+			})
+		}
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].MonthlyCost > functions[j].MonthlyCost
+	})
+	return functions
+}
+
+func displayNewFunctions(w io.Writer, functions []NewFunction, threshold float64, format displayFormat) {
+	fmt.Fprintln(w)
+	if len(functions) == 0 {
+		fmt.Fprintf(w, "Baseline: no new functions cost more than %s per month\n", format.Money(threshold, currencyUSD, 2))
+		return
+	}
+	fmt.Fprintf(w, "Baseline: %d new functions, not in the baseline, cost more than %s per month\n", len(functions), format.Money(threshold, currencyUSD, 2))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Account", "Region", "Monthly"}, "\t"))
+	for _, f := range functions {
+		fmt.Fprintln(tw, strings.Join([]string{
+			f.Name,
+			f.Account,
+			f.Region,
+			format.Money(f.MonthlyCost, regionCurrency(f.Region), 2),
+		}, "\t"))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// budgetTag is the tag that sets a function's monthly cost budget, e.g. lambdacost:budget-monthly=25.
+const budgetTag = "lambdacost:budget-monthly"
+
+// MonthlyBudget returns the function's monthly cost budget, from its budget tag. Budgets are in
+// the currency that the function is priced in, e.g. CNY in China regions. The tag can name the
+// currency, e.g. 180 CNY, which must be the function's currency, since costs aren't converted.
+func (fr FunctionReports) MonthlyBudget() (budget float64, ok bool, err error) {
+	v, ok := fr.Tags[budgetTag]
+	if !ok {
+		return 0, false, nil
+	}
+	currency := priceForRegion(fr.Region).CurrencyCode()
+	amount, code, hasCode := strings.Cut(strings.TrimSpace(v), " ")
+	if hasCode && !strings.EqualFold(strings.TrimSpace(code), currency) {
+		return 0, false, fmt.Errorf("%s tag value %q isn't in %s, the currency of %s", budgetTag, v, currency, fr.Region)
+	}
+	if strings.HasPrefix(amount, "$") && currency != currencyUSD {
+		return 0, false, fmt.Errorf("%s tag value %q isn't in %s, the currency of %s", budgetTag, v, currency, fr.Region)
+	}
+	budget, err = strconv.ParseFloat(strings.TrimPrefix(amount, "$"), 64)
+	if err != nil || budget < 0 {
+		return 0, false, fmt.Errorf("invalid %s tag value %q", budgetTag, v)
+	}
+	return budget, true, nil
+}
+
+// BudgetViolation is a function whose monthly cost is over the budget set by its tag.
+type BudgetViolation struct {
+	Account     string  `json:"account"`
+	Region      string  `json:"region"`
+	Name        string  `json:"name"`
+	Budget      float64 `json:"budget"`
+	MonthlyCost float64 `json:"monthlyCost"`
+	// Currency is the currency of the budget and cost.
+	Currency string `json:"currency"`
+}
+
+// findBudgetViolations returns the functions whose monthly cost is over budget, by the most
+// over budget first, and the functions whose budget tag can't be parsed.
+func findBudgetViolations(reportContent []FunctionReports) (violations []BudgetViolation, invalid []FunctionReports) {
+	for _, fr := range reportContent {
+		budget, ok, err := fr.MonthlyBudget()
+		if err != nil {
+			invalid = append(invalid, fr)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if monthly := fr.DailyCost() * 30; monthly > budget {
+			violations = append(violations, BudgetViolation{
+				Account:     fr.DisplayAccount(),
+				Region:      fr.Region,
+				Name:        fr.Name,
+				Budget:      budget,
+				MonthlyCost: monthly,
+				Currency:    priceForRegion(fr.Region).CurrencyCode(),
+			})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].MonthlyCost-violations[i].Budget > violations[j].MonthlyCost-violations[j].Budget
+	})
+	return violations, invalid
+}
+
+func displayBudgetViolations(w io.Writer, violations []BudgetViolation, invalid []FunctionReports, format displayFormat) {
+	if len(violations) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Budgets: %d functions are over the monthly budget set by their %s tag\n", len(violations), budgetTag)
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+		fmt.Fprintln(tw, strings.Join([]string{"Name", "Account", "Region", "Budget", "Monthly", "Over"}, "\t"))
+		for _, v := range violations {
+			fmt.Fprintln(tw, strings.Join([]string{
+				v.Name,
+				v.Account,
+				v.Region,
+				format.Money(v.Budget, v.Currency, 2),
+				format.Money(v.MonthlyCost, v.Currency, 2),
+				format.Money(v.MonthlyCost-v.Budget, v.Currency, 2),
+			}, "\t"))
+		}
+		tw.Flush()
+	}
+	if len(invalid) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Budgets: %d functions have a %s tag that isn't an amount in their currency\n", len(invalid), budgetTag)
+		fmt.Fprintln(w)
+		for _, fr := range invalid {
+			fmt.Fprintf(w, "  %s (%s): %q\n", fr.Name, fr.Region, fr.Tags[budgetTag])
+		}
+	}
+}
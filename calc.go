@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// parseCount parses a count with an optional K, M or B suffix, e.g. 5M.
+func parseCount(v string) (n float64, err error) {
+	multiplier := 1.0
+	s := strings.TrimSpace(v)
+	if len(s) > 0 {
+		switch strings.ToUpper(s[len(s)-1:]) {
+		case "K":
+			multiplier = 1e3
+		case "M":
+			multiplier = 1e6
+		case "B":
+			multiplier = 1e9
+		}
+		if multiplier != 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err = strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count %q, expected a number, e.g. 5M", v)
+	}
+	return n * multiplier, nil
+}
+
+// calcInput describes a function design to price.
+type calcInput struct {
+	Region       string
+	Architecture Architecture
+	MemorySize   int64
+	// Invocations is the number of invocations per month.
+	Invocations float64
+	AvgDuration time.Duration
+}
+
+// calcMonthlyCost prices a function design with the same pricing as the report, by costing a
+// single invocation of the average duration, and multiplying it by the monthly invocations.
+func calcMonthlyCost(in calcInput, architecture Architecture) (requests, compute float64) {
+	billed := time.Duration(math.Ceil(float64(in.AvgDuration)/float64(time.Millisecond))) * time.Millisecond
+	fr := FunctionReports{
+		Region:       in.Region,
+		Architecture: in.Architecture,
+		Reports: []Report{
+			{Duration: in.AvgDuration, BilledDuration: billed, MemorySize: in.MemorySize},
+		},
+	}
+	requests, compute = fr.CostBreakdown(architecture, in.MemorySize)
+	return requests * in.Invocations, compute * in.Invocations
+}
+
+func displayCalc(w io.Writer, in calcInput) {
+	currency := priceForRegion(in.Region).CurrencyCode()
+	requests, compute := calcMonthlyCost(in, in.Architecture)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	row := func(k string, v interface{}) {
+		fmt.Fprintf(tw, "%s\t%v\n", k, v)
+	}
+	row("Region", in.Region)
+	row("Architecture", in.Architecture)
+	row("Memory", fmt.Sprintf("%d MB", in.MemorySize))
+	row("Invocations per month", strconv.FormatFloat(in.Invocations, 'f', -1, 64))
+	row("Average duration", in.AvgDuration)
+	row("", "")
+	row("Monthly requests", fmt.Sprintf("%.2f %s", requests, currency))
+	row("Monthly compute", fmt.Sprintf("%.2f %s", compute, currency))
+	row("Monthly total", fmt.Sprintf("%.2f %s", requests+compute, currency))
+	other := ArchitectureARM64
+	if in.Architecture == ArchitectureARM64 {
+		other = ArchitectureX86_64
+	}
+	otherRequests, otherCompute := calcMonthlyCost(in, other)
+	row(fmt.Sprintf("Monthly total on %s", other), fmt.Sprintf("%.2f %s", otherRequests+otherCompute, currency))
+	tw.Flush()
+}
+
+func calcCmd(args []string) {
+	cmd := flag.NewFlagSet("calc", flag.ExitOnError)
+	config := cmd.String("config", "", "Path to a JSON settings file, e.g. to override region prices")
+	invocations := cmd.String("invocations", "", "Invocations per month, e.g. 5M")
+	avgMS := cmd.Float64("avg-ms", 0, "Average duration of an invocation in milliseconds, e.g. 120")
+	memory := cmd.Int64("memory", 128, "Memory size in MB, e.g. 512")
+	arch := cmd.String("arch", string(ArchitectureX86_64), "Architecture: x86_64 or arm64")
+	region := cmd.String("region", "us-east-1", "Region, e.g. eu-west-1")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost calc -invocations 5M -avg-ms 120 [flags]")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if *invocations == "" || *avgMS <= 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	settings, err := loadSettings(*config)
+	if err != nil {
+		log.Fatal("could not load settings", zap.Error(err))
+	}
+	setRegionPrices(settings.RegionPrices)
+	in := calcInput{
+		Region:       *region,
+		Architecture: Architecture(strings.ToLower(*arch)),
+		MemorySize:   *memory,
+		AvgDuration:  time.Duration(*avgMS * float64(time.Millisecond)),
+	}
+	if !in.Architecture.Known() {
+		log.Fatal("unknown architecture", zap.String("arch", *arch))
+	}
+	if in.MemorySize < 128 || in.MemorySize > 10240 {
+		log.Fatal("memory must be between 128 and 10240 MB", zap.Int64("memory", in.MemorySize))
+	}
+	if in.Invocations, err = parseCount(*invocations); err != nil {
+		log.Fatal("could not parse invocations", zap.Error(err))
+	}
+	displayCalc(os.Stdout, in)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// updateTemplate sets the MemorySize and Architectures properties of the functions in a
+// CloudFormation or SAM template. JSON templates are valid YAML, so both are parsed as YAML, but
+// JSON templates are written as JSON, keeping the order of keys and the indentation of the
+// original template. Properties that are set using intrinsic functions, e.g. !Ref or
+// {"Ref": ...}, are not overwritten, and return an error.
+func updateTemplate(body string, changes []functionChange) (updated string, err error) {
+	var doc yaml.Node
+	if err = yaml.Unmarshal([]byte(body), &doc); err != nil {
+		return "", fmt.Errorf("updateTemplate: could not parse template: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("updateTemplate: template is not a mapping")
+	}
+	resources := mappingValue(doc.Content[0], "Resources")
+	if resources == nil || resources.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("updateTemplate: template has no Resources")
+	}
+	for _, fc := range changes {
+		resource := mappingValue(resources, fc.LogicalID)
+		if resource == nil || resource.Kind != yaml.MappingNode {
+			return "", fmt.Errorf("updateTemplate: resource %q not found", fc.LogicalID)
+		}
+		resourceType := mappingValue(resource, "Type")
+		if resourceType == nil || (resourceType.Value != "AWS::Lambda::Function" && resourceType.Value != "AWS::Serverless::Function") {
+			return "", fmt.Errorf("updateTemplate: resource %q is not a Lambda function", fc.LogicalID)
+		}
+		properties := mappingValue(resource, "Properties")
+		if properties == nil {
+			properties = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(resource, "Properties", properties)
+		}
+		if fc.MemorySize > 0 {
+			if existing := mappingValue(properties, "MemorySize"); existing != nil && !isLiteral(existing) {
+				return "", fmt.Errorf("updateTemplate: %s MemorySize is not a literal value", fc.LogicalID)
+			}
+			setMappingValue(properties, "MemorySize", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(fc.MemorySize, 10)})
+		}
+		if fc.Architecture != "" {
+			if existing := mappingValue(properties, "Architectures"); existing != nil {
+				if existing.Kind != yaml.SequenceNode {
+					return "", fmt.Errorf("updateTemplate: %s Architectures is not a literal value", fc.LogicalID)
+				}
+				for _, item := range existing.Content {
+					if !isLiteral(item) {
+						return "", fmt.Errorf("updateTemplate: %s Architectures is not a literal value", fc.LogicalID)
+					}
+				}
+			}
+			setMappingValue(properties, "Architectures", &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(fc.Architecture)},
+			}})
+		}
+	}
+	var buf bytes.Buffer
+	if isJSONTemplate(body) {
+		if err = writeJSONNode(&buf, &doc); err != nil {
+			return "", fmt.Errorf("updateTemplate: could not write template: %w", err)
+		}
+		return indentJSONTemplate(buf.Bytes(), body)
+	}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		return "", fmt.Errorf("updateTemplate: could not write template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// isJSONTemplate returns true if the template is JSON rather than YAML.
+func isJSONTemplate(body string) bool {
+	return strings.HasPrefix(strings.TrimSpace(body), "{")
+}
+
+// writeJSONNode writes a YAML node that was parsed from JSON as compact JSON, keeping the order of
+// the keys in mappings.
+func writeJSONNode(buf *bytes.Buffer, n *yaml.Node) (err error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) != 1 {
+			return fmt.Errorf("expected a single document, got %d", len(n.Content))
+		}
+		return writeJSONNode(buf, n.Content[0])
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err = writeJSONString(buf, n.Content[i].Value); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err = writeJSONNode(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err = writeJSONNode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!int", "!!float", "!!bool", "!!null":
+			buf.WriteString(n.Value)
+		default:
+			return writeJSONString(buf, n.Value)
+		}
+	default:
+		return fmt.Errorf("unexpected node %q at line %d", n.Tag, n.Line)
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	// Encode adds a newline.
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// indentJSONTemplate indents compact JSON in the same way as the original template. Templates that
+// were written on a single line stay on a single line.
+func indentJSONTemplate(compact []byte, original string) (updated string, err error) {
+	trailingNewline := strings.HasSuffix(original, "\n")
+	var indent string
+	if _, rest, ok := strings.Cut(strings.TrimSpace(original), "\n"); ok {
+		indent = rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+	}
+	var buf bytes.Buffer
+	if indent == "" {
+		buf.Write(compact)
+	} else if err = json.Indent(&buf, compact, "", indent); err != nil {
+		return "", fmt.Errorf("updateTemplate: could not indent template: %w", err)
+	}
+	if trailingNewline {
+		buf.WriteByte('\n')
+	}
+	return buf.String(), nil
+}
+
+// mappingValue returns the value of the key in a YAML mapping, or nil if it's not present.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// isLiteral is true for plain scalar values, rather than intrinsic functions like !Ref.
+func isLiteral(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && (n.Tag == "!!int" || n.Tag == "!!str")
+}
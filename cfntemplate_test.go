@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateTemplate(t *testing.T) {
+	changes := []functionChange{{LogicalID: "Api", MemorySize: 512, Architecture: ArchitectureARM64}}
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+		err      bool
+	}{
+		{
+			name: "YAML",
+			body: `Resources:
+  Api:
+    Type: AWS::Serverless::Function
+    Properties:
+      Handler: bootstrap
+      MemorySize: 1024
+`,
+			expected: `Resources:
+  Api:
+    Type: AWS::Serverless::Function
+    Properties:
+      Handler: bootstrap
+      MemorySize: 512
+      Architectures:
+        - arm64
+`,
+		},
+		{
+			name: "JSON is written as JSON, in the original order and indentation",
+			body: `{
+    "AWSTemplateFormatVersion": "2010-09-09",
+    "Resources": {
+        "Api": {
+            "Type": "AWS::Lambda::Function",
+            "Properties": {
+                "Timeout": 30,
+                "MemorySize": 1024,
+                "Description": "<api> & \"more\"",
+                "Tracing": null,
+                "Enabled": true,
+                "Architectures": ["x86_64"]
+            }
+        }
+    }
+}
+`,
+			expected: `{
+    "AWSTemplateFormatVersion": "2010-09-09",
+    "Resources": {
+        "Api": {
+            "Type": "AWS::Lambda::Function",
+            "Properties": {
+                "Timeout": 30,
+                "MemorySize": 512,
+                "Description": "<api> & \"more\"",
+                "Tracing": null,
+                "Enabled": true,
+                "Architectures": [
+                    "arm64"
+                ]
+            }
+        }
+    }
+}
+`,
+		},
+		{
+			name:     "JSON on a single line",
+			body:     `{"Resources":{"Api":{"Type":"AWS::Lambda::Function","Properties":{"MemorySize":"1024"}}}}`,
+			expected: `{"Resources":{"Api":{"Type":"AWS::Lambda::Function","Properties":{"MemorySize":512,"Architectures":["arm64"]}}}}`,
+		},
+		{
+			name:     "JSON indented with tabs",
+			body:     "{\n\t\"Resources\": {\n\t\t\"Api\": {\n\t\t\t\"Type\": \"AWS::Lambda::Function\"\n\t\t}\n\t}\n}\n",
+			expected: "{\n\t\"Resources\": {\n\t\t\"Api\": {\n\t\t\t\"Type\": \"AWS::Lambda::Function\",\n\t\t\t\"Properties\": {\n\t\t\t\t\"MemorySize\": 512,\n\t\t\t\t\"Architectures\": [\n\t\t\t\t\t\"arm64\"\n\t\t\t\t]\n\t\t\t}\n\t\t}\n\t}\n}\n",
+		},
+		{
+			name: "JSON intrinsic functions aren't overwritten",
+			body: `{"Resources":{"Api":{"Type":"AWS::Lambda::Function","Properties":{"MemorySize":{"Ref":"Memory"}}}}}`,
+			err:  true,
+		},
+		{
+			name: "YAML intrinsic functions aren't overwritten",
+			body: "Resources:\n  Api:\n    Type: AWS::Lambda::Function\n    Properties:\n      MemorySize: !Ref Memory\n",
+			err:  true,
+		},
+		{
+			name: "resource isn't a function",
+			body: `{"Resources":{"Api":{"Type":"AWS::SQS::Queue"}}}`,
+			err:  true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			updated, err := updateTemplate(test.body, changes)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", updated)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated != test.expected {
+				t.Errorf("unexpected template\nexpected:\n%s\ngot:\n%s", test.expected, updated)
+			}
+			if isJSONTemplate(test.body) != strings.HasPrefix(updated, "{") {
+				t.Errorf("expected the template format to be kept")
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Functions with an average init duration of at least this are reported as having slow cold starts.
+const slowColdStartThreshold = time.Second
+
+// Deployment packages and layers larger than this are reported as a possible cause of slow cold starts.
+const largePackageThreshold = 50 * 1024 * 1024
+
+// ColdStarts is the number of invocations that included an init phase.
+func (fr FunctionReports) ColdStarts() (count int) {
+	for _, r := range fr.Reports {
+		if r.IsColdStart {
+			count++
+		}
+	}
+	return count
+}
+
+func (fr FunctionReports) ColdStartRate() float64 {
+	if len(fr.Reports) == 0 {
+		return 0
+	}
+	return float64(fr.ColdStarts()) / float64(len(fr.Reports))
+}
+
+func (fr FunctionReports) AvgInitDuration() time.Duration {
+	var total time.Duration
+	var count int
+	for _, r := range fr.Reports {
+		if r.IsColdStart {
+			total += r.InitDuration
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+// InitMemoryUsed is the most memory used (MB) by invocations that included an init phase. The
+// memory used by a cold start includes the init phase, which can briefly use more memory than
+// warm invocations, e.g. while loading dependencies. It returns false if there were no cold starts.
+func (fr FunctionReports) InitMemoryUsed() (mb int64, ok bool) {
+	for _, r := range fr.Reports {
+		if r.IsColdStart {
+			ok = true
+			if r.MaxMemoryUsed > mb {
+				mb = r.MaxMemoryUsed
+			}
+		}
+	}
+	return mb, ok
+}
+
+// WarmMemoryUsed is the most memory used (MB) by invocations that didn't include an init phase.
+func (fr FunctionReports) WarmMemoryUsed() (mb int64) {
+	for _, r := range fr.Reports {
+		if !r.IsColdStart && r.MaxMemoryUsed > mb {
+			mb = r.MaxMemoryUsed
+		}
+	}
+	return mb
+}
+
+// Lambda fails the init phase of a cold start if it takes longer than this.
+const initDurationLimit = 10 * time.Second
+
+// initMemoryFloors returns the smallest memory sizes (MB), in 256 MB steps, that allow for the init
+// phase of cold starts. The memory floor gives the most memory used by a cold start the same headroom
+// as the max memory used, but rounds up rather than down, so that a brief spike during the init phase
+// isn't rounded away. The init phase is mostly CPU-bound, so the duration floor projects each init
+// duration in proportion to CPU, up to one vCPU, and keeps it below timeoutRiskProportion of the init
+// limit. If the init phase is already close to the limit, the duration floor is the memory size it ran
+// with. Both are zero if there were no cold starts.
+func (fr FunctionReports) initMemoryFloors(headroom float64) (memoryFloor, durationFloor int64) {
+	maxInit := time.Duration(float64(initDurationLimit) * timeoutRiskProportion)
+	for _, r := range fr.Reports {
+		if !r.IsColdStart {
+			continue
+		}
+		if mb := int64(math.Ceil(float64(r.MaxMemoryUsed)*headroom/256)) * 256; mb > memoryFloor {
+			memoryFloor = mb
+		}
+		if r.InitDuration <= 0 || r.MemorySize <= 0 {
+			continue
+		}
+		required := float64(r.InitDuration) / float64(maxInit) * singleThreadCPU(r.MemorySize)
+		mb := int64(math.Ceil(required/256)) * 256
+		if required > singleVCPUMemory {
+			mb = r.MemorySize
+		}
+		if mb > durationFloor {
+			durationFloor = mb
+		}
+	}
+	return memoryFloor, durationFloor
+}
+
+// InitMemoryFloor is the smallest memory size (MB) that allows for the memory used and the duration
+// of the init phase of cold starts, see initMemoryFloors. It's zero if there were no cold starts.
+func (fr FunctionReports) InitMemoryFloor(headroom float64) int64 {
+	memoryFloor, durationFloor := fr.initMemoryFloors(headroom)
+	if durationFloor > memoryFloor {
+		return durationFloor
+	}
+	return memoryFloor
+}
+
+// MaxInitDuration is the longest init duration of the cold starts in the window.
+func (fr FunctionReports) MaxInitDuration() (max time.Duration) {
+	for _, r := range fr.Reports {
+		if r.IsColdStart && r.InitDuration > max {
+			max = r.InitDuration
+		}
+	}
+	return max
+}
+
+// initMemoryRationale explains how the init phase of cold starts affects the memory recommendation.
+// Cold starts can fail at a size that is fine for warm invocations, so the recommendation is raised
+// to the InitMemoryFloor. If there were no cold starts, the init phase wasn't seen.
+func (fr FunctionReports) initMemoryRationale(headroom float64) string {
+	initMB, ok := fr.InitMemoryUsed()
+	if !ok {
+		return "no cold starts in the window, so memory used during the init phase is unknown, test a cold start before applying"
+	}
+	warmMB := fr.WarmMemoryUsed()
+	warmSize := proposedMemorySize(warmMB, headroom)
+	memoryFloor, durationFloor := fr.initMemoryFloors(headroom)
+	switch {
+	case durationFloor > warmSize && durationFloor >= memoryFloor:
+		return fmt.Sprintf("sized for cold starts, which took up to %v to init, to keep the projected init duration below %.0f%% of the %v init limit", fr.MaxInitDuration().Round(time.Millisecond), timeoutRiskProportion*100, initDurationLimit)
+	case memoryFloor > warmSize && warmMB > 0:
+		return fmt.Sprintf("sized for cold starts, which used up to %d MB including the init phase, compared to %d MB for warm invocations", initMB, warmMB)
+	}
+	return ""
+}
+
+// AvgWarmDuration is the average duration of invocations that didn't include an init phase.
+func (fr FunctionReports) AvgWarmDuration() time.Duration {
+	return fr.avgDuration(false)
+}
+
+// AvgColdDuration is the average duration of cold start invocations, excluding the init duration.
+func (fr FunctionReports) AvgColdDuration() time.Duration {
+	return fr.avgDuration(true)
+}
+
+func (fr FunctionReports) avgDuration(coldStart bool) time.Duration {
+	var total time.Duration
+	var count int
+	for _, r := range fr.Reports {
+		if r.IsColdStart == coldStart {
+			total += r.Duration
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+// ColdStartFinding describes slow cold starts, with hints about the likely causes.
+func (fr FunctionReports) ColdStartFinding() (finding string, ok bool) {
+	avgInit := fr.AvgInitDuration()
+	if avgInit < slowColdStartThreshold {
+		return
+	}
+	finding = fmt.Sprintf("%d cold starts (%.1f%%) averaging %v init", fr.ColdStarts(), fr.ColdStartRate()*100, avgInit.Round(time.Millisecond))
+	if hints := fr.ColdStartHints(); len(hints) > 0 {
+		finding += " - " + strings.Join(hints, ", ")
+	}
+	return finding, true
+}
+
+// ColdStartHints suggests likely causes of slow cold starts based on the function configuration.
+func (fr FunctionReports) ColdStartHints() (hints []string) {
+	switch {
+	case strings.HasPrefix(fr.Runtime, "java"):
+		hints = append(hints, "JVM runtime, consider SnapStart")
+	case strings.HasPrefix(fr.Runtime, "dotnet"):
+		hints = append(hints, ".NET runtime, consider ReadyToRun or Native AOT")
+	}
+	if fr.PackageType == "Image" {
+		hints = append(hints, "container image package")
+	}
+	if fr.CodeSize >= largePackageThreshold {
+		hints = append(hints, fmt.Sprintf("large deployment package (%d MB)", fr.CodeSize/1024/1024))
+	}
+	var layersSize int64
+	for _, l := range fr.Layers {
+		layersSize += l.CodeSize
+	}
+	if layersSize >= largePackageThreshold {
+		hints = append(hints, fmt.Sprintf("%d layers totalling %d MB", len(fr.Layers), layersSize/1024/1024))
+	}
+	return hints
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptimisedCostAllowsForInitPhase(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	function := func(reports ...Report) FunctionReports {
+		return FunctionReports{Name: "api", Region: "eu-west-1", Architecture: ArchitectureX86_64, Timeout: time.Minute, Start: start, End: start.Add(24 * time.Hour), Reports: reports}
+	}
+	warm := func(maxMemoryUsed int64) Report {
+		return Report{Duration: 200 * time.Millisecond, BilledDuration: 200 * time.Millisecond, MemorySize: 4096, MaxMemoryUsed: maxMemoryUsed}
+	}
+	cold := func(maxMemoryUsed int64, initDuration time.Duration) Report {
+		r := warm(maxMemoryUsed)
+		r.IsColdStart = true
+		r.InitDuration = initDuration
+		return r
+	}
+	tests := []struct {
+		name      string
+		function  FunctionReports
+		expected  int64
+		rationale string
+	}{
+		{
+			name:      "warm invocations only",
+			function:  function(warm(300), warm(300)),
+			expected:  1024,
+			rationale: "no cold starts in the window",
+		},
+		{
+			name:     "a fast init phase within the warm size",
+			function: function(cold(300, 200*time.Millisecond), warm(300)),
+			expected: 1024,
+		},
+		{
+			name:      "init phase memory is rounded up",
+			function:  function(cold(700, 200*time.Millisecond), warm(300)),
+			expected:  1536,
+			rationale: "used up to 700 MB including the init phase, compared to 300 MB for warm invocations",
+		},
+		{
+			name:      "slow init phases keep enough CPU to stay within the init limit",
+			function:  function(cold(300, 5*time.Second), warm(300)),
+			expected:  1280,
+			rationale: "took up to 5s to init",
+		},
+		{
+			name:      "init phases close to the limit keep the current size",
+			function:  function(cold(300, 9*time.Second), warm(300)),
+			expected:  4096,
+			rationale: "took up to 9s to init",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			memSize, _ := test.function.OptimisedCost()
+			if memSize != test.expected {
+				t.Errorf("expected %d MB, got %d MB", test.expected, memSize)
+			}
+			rationale := test.function.initMemoryRationale(memoryHeadroom)
+			if test.rationale == "" && rationale != "" {
+				t.Errorf("expected no rationale, got %q", rationale)
+			}
+			if !strings.Contains(rationale, test.rationale) {
+				t.Errorf("expected rationale to contain %q, got %q", test.rationale, rationale)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Collector collects the log events of a function.
+type Collector interface {
+	// Collect calls onEvent for each log event in the target window. It returns
+	// errLogGroupNotFound if there are no logs for the function.
+	Collect(ctx context.Context, target CollectTarget, onEvent func(e LogEvent)) error
+}
+
+// CollectTarget is the function and time window to collect log events for.
+type CollectTarget struct {
+	FunctionName string
+	Region       string
+	LogGroupName string
+	// LogGroup is nil if the log group could not be described.
+	LogGroup *cwtypes.LogGroup
+	Start    time.Time
+	End      time.Time
+	// MaxPages is the maximum number of pages, or queries, used to collect the function's logs.
+	// Zero is unlimited.
+	MaxPages int
+	// Version restricts collection to the log streams of a function version, e.g. 3 or $LATEST.
+	// Empty collects all log streams.
+	Version string
+}
+
+type LogEvent struct {
+	// Timestamp is zero if the collector doesn't have timestamps.
+	Timestamp time.Time
+	Message   string
+}
+
+var errLogGroupNotFound = errors.New("log group not found")
+
+// errCollectionCapped is returned when collection stops early due to a limit. The events
+// collected before the limit was reached are kept.
+var errCollectionCapped = errors.New("collection capped")
+
+// pageLimit counts the pages, or queries, used to collect a function's logs.
+type pageLimit struct {
+	Max   int
+	Count int
+}
+
+// Next returns errCollectionCapped if another page would exceed the limit.
+func (l *pageLimit) Next() error {
+	if l.Max > 0 && l.Count >= l.Max {
+		return fmt.Errorf("%w after %d pages", errCollectionCapped, l.Count)
+	}
+	l.Count++
+	return nil
+}
+
+// collectorDependencies are available to collectors when they're created.
+type collectorDependencies struct {
+	Config         aws.Config
+	CloudWatchLogs *cloudwatchlogs.Client
+	Stats          *scanStats
+	// LogsDir is the directory used by the file collector.
+	LogsDir string
+}
+
+type CollectorFactory func(deps collectorDependencies) (Collector, error)
+
+var collectorFactories = map[string]CollectorFactory{}
+
+// registerCollector makes a collector available by name, for use in the -collector flag and settings.
+func registerCollector(name string, factory CollectorFactory) {
+	if _, exists := collectorFactories[name]; exists {
+		panic(fmt.Sprintf("collector %q is already registered", name))
+	}
+	collectorFactories[name] = factory
+}
+
+// collectorAuto uses Logs Insights for Infrequent Access log groups, and FilterLogEvents for others.
+const collectorAuto = "auto"
+
+func collectorNames() (names []string) {
+	names = append(names, collectorAuto)
+	for name := range collectorFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// collectorSet creates collectors on first use.
+type collectorSet struct {
+	deps collectorDependencies
+	// defaultName is the collector used for functions that aren't listed in perFunction.
+	defaultName string
+	perFunction map[string]string
+	created     map[string]Collector
+}
+
+func newCollectorSet(deps collectorDependencies, defaultName string, perFunction map[string]string) (cs *collectorSet, err error) {
+	cs = &collectorSet{
+		deps:        deps,
+		defaultName: defaultName,
+		perFunction: perFunction,
+		created:     map[string]Collector{},
+	}
+	if err = cs.validate(defaultName); err != nil {
+		return nil, err
+	}
+	for _, name := range perFunction {
+		if err = cs.validate(name); err != nil {
+			return nil, err
+		}
+	}
+	return cs, nil
+}
+
+func (cs *collectorSet) validate(name string) error {
+	if name == collectorAuto {
+		return nil
+	}
+	if _, ok := collectorFactories[name]; !ok {
+		return fmt.Errorf("unknown collector %q, expected one of: %s", name, strings.Join(collectorNames(), ", "))
+	}
+	return nil
+}
+
+// For returns the name of the collector to use for the target, and the collector.
+func (cs *collectorSet) For(target CollectTarget) (name string, c Collector, err error) {
+	name = cs.defaultName
+	if n, ok := cs.perFunction[target.FunctionName]; ok {
+		name = n
+	}
+	if name == collectorAuto {
+		name = collectorFilter
+		if target.LogGroup != nil && target.LogGroup.LogGroupClass == cwtypes.LogGroupClassInfrequentAccess {
+			name = collectorInsights
+		}
+	}
+	if c, ok := cs.created[name]; ok {
+		return name, c, nil
+	}
+	c, err = collectorFactories[name](cs.deps)
+	if err != nil {
+		return name, nil, fmt.Errorf("could not create collector %q: %w", name, err)
+	}
+	cs.created[name] = c
+	return name, c, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const collectorFile = "file"
+
+func init() {
+	registerCollector(collectorFile, func(deps collectorDependencies) (Collector, error) {
+		if deps.LogsDir == "" {
+			return nil, errors.New("the file collector requires -logs-dir to be set")
+		}
+		return fileCollector{dir: deps.LogsDir}, nil
+	})
+}
+
+// fileCollector reads log messages from {dir}/{functionName}.log, one message per line.
+// It's useful for logs that have been exported from CloudWatch by other means.
+type fileCollector struct {
+	dir string
+}
+
+func (c fileCollector) Collect(ctx context.Context, target CollectTarget, onEvent func(e LogEvent)) error {
+	if target.Version != "" {
+		return errors.New("fileCollector: log files don't include log stream names, so they can't be restricted to a version")
+	}
+	f, err := os.Open(filepath.Join(c.dir, target.FunctionName+".log"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errLogGroupNotFound
+		}
+		return fmt.Errorf("fileCollector: %w", err)
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64*1024)
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		line, err := readLine(r, maxFileLineLength)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("fileCollector: %w", err)
+		}
+		onEvent(LogEvent{Message: string(line)})
+	}
+}
+
+// maxFileLineLength is the most of a line that's kept. Longer lines are cut, rather than stopping
+// the rest of the file from being read.
+const maxFileLineLength = 1024 * 1024
+
+// readLine reads a line without its line ending, keeping at most max bytes.
+func readLine(r *bufio.Reader, max int) (line []byte, err error) {
+	for {
+		part, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if room := max - len(line); room > 0 {
+			if len(part) > room {
+				part = part[:room]
+			}
+			line = append(line, part...)
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
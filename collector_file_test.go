@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		max      int
+		expected []string
+	}{
+		{
+			name:     "lines are read without their line endings",
+			input:    "START\nREPORT\r\nEND\n",
+			max:      64,
+			expected: []string{"START", "REPORT", "END"},
+		},
+		{
+			name:     "a truncated final line without a line ending is read",
+			input:    "START\nREPORT RequestId: 1\tDura",
+			max:      64,
+			expected: []string{"START", "REPORT RequestId: 1\tDura"},
+		},
+		{
+			name:     "lines longer than the reader's buffer are joined",
+			input:    strings.Repeat("a", 40) + "\nb\n",
+			max:      64,
+			expected: []string{strings.Repeat("a", 40), "b"},
+		},
+		{
+			name:     "oversized lines are cut, and the next line is still read",
+			input:    strings.Repeat("a", 100) + "\nb\n",
+			max:      64,
+			expected: []string{strings.Repeat("a", 64), "b"},
+		},
+		{
+			name:     "invalid UTF-8 is passed through",
+			input:    "REPORT \xff\xfe\nb\n",
+			max:      64,
+			expected: []string{"REPORT \xff\xfe", "b"},
+		},
+		{
+			name:     "empty lines are kept",
+			input:    "\n\nb\n",
+			max:      64,
+			expected: []string{"", "", "b"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// The smallest buffer, so that long lines are returned in parts.
+			r := bufio.NewReaderSize(strings.NewReader(test.input), 16)
+			var actual []string
+			for {
+				line, err := readLine(r, test.max)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				actual = append(actual, string(line))
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
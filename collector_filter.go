@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+const collectorFilter = "filter"
+
+func init() {
+	registerCollector(collectorFilter, func(deps collectorDependencies) (Collector, error) {
+		return filterCollector{client: deps.CloudWatchLogs, stats: deps.Stats}, nil
+	})
+}
+
+// filterCollector downloads all log events with FilterLogEvents. Events from the log streams of
+// other versions are downloaded, and then ignored.
+type filterCollector struct {
+	client *cloudwatchlogs.Client
+	stats  *scanStats
+}
+
+func (c filterCollector) Collect(ctx context.Context, target CollectTarget, onEvent func(e LogEvent)) error {
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(c.client, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: &target.LogGroupName,
+		StartTime:    aws.Int64(target.Start.UnixMilli()),
+		EndTime:      aws.Int64(target.End.UnixMilli()),
+	})
+	limit := &pageLimit{Max: target.MaxPages}
+	for paginator.HasMorePages() {
+		if err := limit.Next(); err != nil {
+			return err
+		}
+		page, err := paginator.NextPage(ctx, func(o *cloudwatchlogs.Options) {
+			o.Region = target.Region
+		})
+		if err != nil {
+			var notFound *cwtypes.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				return errLogGroupNotFound
+			}
+			return err
+		}
+		for _, event := range page.Events {
+			message := aws.ToString(event.Message)
+			c.stats.FilterLogEventsBytes += int64(len(message))
+			if !target.InVersion(aws.ToString(event.LogStreamName)) {
+				continue
+			}
+			onEvent(LogEvent{
+				Timestamp: time.UnixMilli(aws.ToInt64(event.Timestamp)),
+				Message:   message,
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+)
+
+type severity int
+
+const (
+	severityNone severity = iota
+	// severityLow is used for functions that cost less than a cent per month.
+	severityLow
+	// severityWarning is used for functions with a large savings opportunity.
+	severityWarning
+	// severityCritical is used for functions at risk of running out of memory or timing out.
+	severityCritical
+)
+
+// Thresholds used to determine severity.
+const (
+	riskThreshold           = 0.9
+	savingsThreshold        = 0.3
+	lowMonthlyCostThreshold = 0.01
+)
+
+// ANSI escape codes are all the same length, because tabwriter counts them as part of the cell width.
+const (
+	colorDefault = "\x1b[39m"
+	colorDim     = "\x1b[02m"
+	colorYellow  = "\x1b[33m"
+	colorRed     = "\x1b[31m"
+	colorReset   = "\x1b[0m"
+)
+
+func (s severity) Color() string {
+	switch s {
+	case severityLow:
+		return colorDim
+	case severityWarning:
+		return colorYellow
+	case severityCritical:
+		return colorRed
+	}
+	return colorDefault
+}
+
+// Severity is used to highlight the function in the report.
+func (fr FunctionReports) Severity() severity {
+	if fr.MemoryAssigned() > 0 && float64(fr.MaxMemoryUsed()) >= float64(fr.MemoryAssigned())*riskThreshold {
+		return severityCritical
+	}
+	if fr.Timeout > 0 && float64(fr.MaxDuration()) >= float64(fr.Timeout)*riskThreshold {
+		return severityCritical
+	}
+	monthlyCost := fr.DailyCost() * 30
+	if monthlyCost > 0 && fr.MonthlySavings() > monthlyCost*savingsThreshold {
+		return severityWarning
+	}
+	if monthlyCost < lowMonthlyCostThreshold {
+		return severityLow
+	}
+	return severityNone
+}
+
+// shouldUseColor returns true if stdout is a terminal, and color hasn't been disabled.
+func shouldUseColor(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
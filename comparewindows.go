@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Functions where the monthly cost, daily invocations or average duration differ between windows
+// by more than this percentage are listed as changed.
+const windowChangeThreshold = 25.0
+
+// parseCompareWindows parses a comma separated pair of windows, e.g. "7d,30d", sorted from
+// shortest to longest.
+func parseCompareWindows(v string) (names []string, windows []time.Duration, err error) {
+	names = splitList(v)
+	if len(names) != 2 {
+		return nil, nil, fmt.Errorf("parseCompareWindows: expected two windows, e.g. 7d,30d, got %q", v)
+	}
+	windows = make([]time.Duration, len(names))
+	for i, name := range names {
+		if windows[i], err = parseWindow(name); err != nil {
+			return nil, nil, fmt.Errorf("parseCompareWindows: %w", err)
+		}
+	}
+	if windows[0] == windows[1] {
+		return nil, nil, fmt.Errorf("parseCompareWindows: windows must be different, got %q", v)
+	}
+	if windows[0] > windows[1] {
+		names[0], names[1] = names[1], names[0]
+		windows[0], windows[1] = windows[1], windows[0]
+	}
+	return names, windows, nil
+}
+
+// collectionWindow returns the window to collect. When windows are compared, the longer window is
+// collected, and the shorter window is taken from the end of it, so -window can only be passed if
+// it's the same as the longer window.
+func collectionWindow(window string, windowPassed bool, compare string) (name string, d time.Duration, err error) {
+	if compare == "" {
+		d, err = parseWindow(window)
+		return window, d, err
+	}
+	names, windows, err := parseCompareWindows(compare)
+	if err != nil {
+		return "", 0, err
+	}
+	if windowPassed {
+		d, err = parseWindow(window)
+		if err != nil {
+			return "", 0, err
+		}
+		if d != windows[1] {
+			return "", 0, fmt.Errorf("-window=%s can't be used with -compare-windows=%s, which collects the longer window, %s", window, compare, names[1])
+		}
+	}
+	return names[1], windows[1], nil
+}
+
+// flagPassed returns true if the flag was passed on the command line, rather than left as the default.
+func flagPassed(fs *flag.FlagSet, name string) (passed bool) {
+	fs.Visit(func(f *flag.Flag) {
+		passed = passed || f.Name == name
+	})
+	return passed
+}
+
+// trimToWindow returns the function's reports from the end of the window. It returns false if
+// the reports don't have timestamps, e.g. because they were collected by an older version.
+func (fr FunctionReports) trimToWindow(window time.Duration) (trimmed FunctionReports, ok bool) {
+	if fr.End.IsZero() {
+		return fr, false
+	}
+	trimmed = fr
+	start := fr.End.Add(-window)
+	if start.After(fr.Start) {
+		trimmed.Start = start
+	}
+	trimmed.Reports = nil
+	for _, r := range fr.Reports {
+		if r.Timestamp.IsZero() {
+			return fr, false
+		}
+		if !r.Timestamp.Before(trimmed.Start) {
+			trimmed.Reports = append(trimmed.Reports, r)
+		}
+	}
+	// The metrics cover the full window.
+	trimmed.MetricInvocations = nil
+	trimmed.MetricErrors = nil
+	return trimmed, true
+}
+
+// WindowChange compares a function over a recent, shorter window, with a longer window.
+type WindowChange struct {
+	Function FunctionReports
+	// Recent is the function trimmed to the shorter window.
+	Recent FunctionReports
+	// Changes describe the differences beyond the threshold, e.g. "invocations +40%".
+	Changes []string
+}
+
+func percentChange(from, to float64) float64 {
+	if from == 0 {
+		if to == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return (to - from) / from * 100
+}
+
+func formatPercentChange(v float64, format displayFormat) string {
+	if math.IsInf(v, 1) {
+		return "new"
+	}
+	return format.SignedDecimal(v, 0) + "%"
+}
+
+// findWindowChanges compares each function's monthly cost, daily invocations and average duration
+// over the recent window with the full window. The full window is used as the baseline, since
+// it's less affected by short term variation. Functions costing less than a cent per month in both
+// windows are ignored.
+func findWindowChanges(reportContent []FunctionReports, recentWindow time.Duration) (changes []WindowChange) {
+	for _, fr := range reportContent {
+		recent, ok := fr.trimToWindow(recentWindow)
+		if !ok {
+			continue
+		}
+		fullMonthly, recentMonthly := fr.DailyCost()*30, recent.DailyCost()*30
+		if fullMonthly < lowMonthlyCostThreshold && recentMonthly < lowMonthlyCostThreshold {
+			continue
+		}
+		wc := WindowChange{Function: fr, Recent: recent}
+		// The changes are whole percentages, so they don't depend on the decimal separator.
+		compare := func(name string, from, to float64) {
+			if change := percentChange(from, to); math.Abs(change) > windowChangeThreshold {
+				wc.Changes = append(wc.Changes, fmt.Sprintf("%s %s", name, formatPercentChange(change, displayFormat{})))
+			}
+		}
+		compare("cost", fullMonthly, recentMonthly)
+		compare("invocations", fr.DailyInvocations(), recent.DailyInvocations())
+		compare("duration", float64(fr.AvgDuration()), float64(recent.AvgDuration()))
+		if len(wc.Changes) > 0 {
+			changes = append(changes, wc)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return math.Abs(changes[i].monthlyDelta()) > math.Abs(changes[j].monthlyDelta())
+	})
+	return changes
+}
+
+func (wc WindowChange) monthlyDelta() float64 {
+	return (wc.Recent.DailyCost() - wc.Function.DailyCost()) * 30
+}
+
+func displayWindowChanges(w io.Writer, reportContent []FunctionReports, windows []time.Duration, format displayFormat) {
+	if len(windows) != 2 {
+		return
+	}
+	changes := findWindowChanges(reportContent, windows[0])
+	recentName, fullName := formatWindow(windows[0]), formatWindow(windows[1])
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Window comparison: last %s compared to last %s\n", recentName, fullName)
+	fmt.Fprintln(w)
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "No functions changed by more than %.0f%%.\n", windowChangeThreshold)
+		return
+	}
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{
+		"Name", "Region",
+		"Monthly (" + fullName + ")", "Monthly (" + recentName + ")", "Delta",
+		"Daily Invocations (" + fullName + ")", "Daily Invocations (" + recentName + ")",
+		"Avg Duration (" + fullName + ")", "Avg Duration (" + recentName + ")",
+		"Changes",
+	}, "\t"))
+	for _, wc := range changes {
+		fmt.Fprintln(tw, strings.Join([]string{
+			wc.Function.Name,
+			wc.Function.Region,
+			format.Money(wc.Function.DailyCost()*30, wc.Function.Currency(), 2),
+			format.Money(wc.Recent.DailyCost()*30, wc.Function.Currency(), 2),
+			format.SignedDecimal(wc.monthlyDelta(), 2),
+			fmt.Sprintf("%.0f", wc.Function.DailyInvocations()),
+			fmt.Sprintf("%.0f", wc.Recent.DailyInvocations()),
+			format.Duration(wc.Function.AvgDuration().Round(time.Millisecond)),
+			format.Duration(wc.Recent.AvgDuration().Round(time.Millisecond)),
+			strings.Join(wc.Changes, ", "),
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Functions whose cost, invocations or average duration in the last %s differ from the last %s by more than %.0f%%: %d\n", recentName, fullName, windowChangeThreshold, len(changes))
+}
+
+// formatWindow formats a window in days if it's a whole number of days, e.g. 7d.
+func formatWindow(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
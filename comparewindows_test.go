@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectionWindow(t *testing.T) {
+	tests := []struct {
+		name         string
+		window       string
+		windowPassed bool
+		compare      string
+		expected     string
+		duration     time.Duration
+		err          string
+	}{
+		{name: "window", window: "7d", windowPassed: true, expected: "7d", duration: 7 * 24 * time.Hour},
+		{name: "the longer window is collected", window: "1d", compare: "7d,30d", expected: "30d", duration: 30 * 24 * time.Hour},
+		{name: "the windows can be in any order", window: "1d", compare: "30d,7d", expected: "30d", duration: 30 * 24 * time.Hour},
+		{name: "a window that matches the longer window", window: "30d", windowPassed: true, compare: "7d,30d", expected: "30d", duration: 30 * 24 * time.Hour},
+		{name: "a different window is rejected", window: "14d", windowPassed: true, compare: "7d,30d", err: "can't be used with -compare-windows"},
+		{name: "invalid windows to compare", window: "1d", compare: "7d", err: "expected two windows"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			name, d, err := collectionWindow(test.window, test.windowPassed, test.compare)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != test.expected || d != test.duration {
+				t.Errorf("expected %s (%v), got %s (%v)", test.expected, test.duration, name, d)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+)
+
+// Savings are extrapolated from the window, so each figure has a margin at this confidence level.
+const (
+	savingsConfidenceLevel = 0.95
+	// savingsConfidenceZ is the z-score for the confidence level.
+	savingsConfidenceZ = 1.96
+)
+
+// partialDataError is the proportion added to the margin of functions whose collection was
+// incomplete, or had errors, since an unknown amount of data is missing.
+const partialDataError = 0.25
+
+// Confidence in a savings figure, from the size of its margin.
+const (
+	confidenceHigh   = "high"
+	confidenceMedium = "medium"
+	confidenceLow    = "low"
+)
+
+// Savings with a margin of at most these proportions have high and medium confidence.
+const (
+	confidenceHighMargin   = 0.1
+	confidenceMediumMargin = 0.3
+)
+
+// costRelativeError estimates the proportion by which the function's monthly cost may be wrong.
+// The window is treated as a sample of the month, so the error of the mean cost per invocation
+// depends on the number of invocations, and the variance of their memory and duration. REPORT
+// lines that differ from the Invocations metric, and incomplete collection, add to the error.
+func (fr FunctionReports) costRelativeError() float64 {
+	n := len(fr.Reports)
+	if n < 2 {
+		return 1
+	}
+	var sum, sumSquares float64
+	for _, r := range fr.Reports {
+		gbs := float64(r.MemorySize) / 1024 * r.BilledDuration.Seconds()
+		sum += gbs
+		sumSquares += gbs * gbs
+	}
+	mean := sum / float64(n)
+	if mean <= 0 {
+		return 1
+	}
+	variance := math.Max(0, (sumSquares-float64(n)*mean*mean)/float64(n-1))
+	relativeError := savingsConfidenceZ * math.Sqrt(variance) / mean / math.Sqrt(float64(n))
+	if fr.MetricInvocations != nil && *fr.MetricInvocations > 0 {
+		metric := float64(*fr.MetricInvocations)
+		relativeError += math.Abs(float64(n)-metric) / metric
+	}
+	if fr.Incomplete || len(fr.Errors) > 0 {
+		relativeError += partialDataError
+	}
+	return math.Min(relativeError, 1)
+}
+
+// SavingsMargin is the margin of a monthly savings figure for the function, from the error in
+// its monthly cost.
+func (fr FunctionReports) SavingsMargin(savings float64) float64 {
+	return savings * fr.costRelativeError()
+}
+
+// memoryProjectionMargin is the amount by which savings from running with less memory may be
+// overstated. Savings are calculated with the current durations, but durations increase when
+// memory is reduced, so if the share of the duration spent using CPU is known, the difference
+// between the current and projected durations is added to the margin.
+func (fr FunctionReports) memoryProjectionMargin(architecture Architecture, memorySize int64) float64 {
+	if _, _, ok := fr.CPUShare(); !ok || memorySize >= fr.MemoryAssigned() {
+		return 0
+	}
+	projected := fr.withMemorySize(memorySize).CostForArchitecture(architecture, 0)
+	current := fr.CostForArchitecture(architecture, memorySize)
+	return math.Max(0, (projected-current)/fr.Days()*30)
+}
+
+// MonthlySavingsMargin is the margin of MonthlySavings.
+func (fr FunctionReports) MonthlySavingsMargin() float64 {
+	savings := fr.MonthlySavings()
+	if savings <= 0 {
+		return 0
+	}
+	optimisedRAM, _ := fr.OptimisedCost()
+	return math.Min(savings, fr.SavingsMargin(savings)+fr.memoryProjectionMargin(ArchitectureARM64, optimisedRAM))
+}
+
+// savingsConfidence returns the confidence in a savings figure with the margin.
+func savingsConfidence(savings, margin float64) string {
+	if savings <= 0 {
+		return ""
+	}
+	switch {
+	case margin <= savings*confidenceHighMargin:
+		return confidenceHigh
+	case margin <= savings*confidenceMediumMargin:
+		return confidenceMedium
+	}
+	return confidenceLow
+}
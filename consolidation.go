@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+const (
+	// Functions invoked at most this many times a day are rarely invoked.
+	consolidationMaxDailyInvocations = 100
+	// Functions with a deployment package of at most this size are tiny.
+	consolidationMaxCodeSize = 10 * 1024 * 1024
+	// Groups need at least this many functions to be worth consolidating.
+	consolidationMinFunctions = 3
+)
+
+// ConsolidationGroup is a group of tiny, rarely invoked functions in the same stack, with the
+// same runtime language, that could be consolidated into a single function, or disabled when
+// they're not needed.
+type ConsolidationGroup struct {
+	Region            string   `json:"region"`
+	Stack             string   `json:"stack"`
+	Runtime           string   `json:"runtime"`
+	Functions         []string `json:"functions"`
+	DailyInvocations  float64  `json:"dailyInvocations"`
+	MonthlyCost       float64  `json:"monthlyCost"`
+	MonthlyColdStarts float64  `json:"monthlyColdStarts"`
+	// MonthlyHousekeepingCost is the cost of keeping the functions separate: the init duration
+	// of their cold starts, and the ingestion of their log groups, if it was collected.
+	MonthlyHousekeepingCost float64 `json:"monthlyHousekeepingCost"`
+	LogGroups               int     `json:"logGroups"`
+	Suggestion              string  `json:"suggestion"`
+}
+
+// runtimeLanguage returns the runtime without its version, e.g. python for python3.12, so that
+// functions on slightly different versions of the same language are grouped together.
+func runtimeLanguage(runtime string) string {
+	if index := strings.IndexFunc(runtime, func(r rune) bool { return !unicode.IsLetter(r) }); index > 0 {
+		return runtime[:index]
+	}
+	return runtime
+}
+
+// findConsolidationGroups returns groups of tiny, rarely invoked functions in the same stack and
+// runtime language, with the highest housekeeping cost first.
+func findConsolidationGroups(reportContent []FunctionReports) (groups []ConsolidationGroup) {
+	candidates := map[string][]FunctionReports{}
+	for _, fr := range reportContent {
+		stack := fr.Tags[tagCloudFormationStackName]
+		if stack == "" || fr.Runtime == "" || fr.PreselectionSkipped || fr.HasProvisionedConcurrency() {
+			continue
+		}
+		if fr.CodeSize > consolidationMaxCodeSize || fr.DailyInvocations() > consolidationMaxDailyInvocations {
+			continue
+		}
+		key := fr.Region + "/" + stack + "/" + runtimeLanguage(fr.Runtime)
+		candidates[key] = append(candidates[key], fr)
+	}
+	for _, functions := range candidates {
+		if len(functions) < consolidationMinFunctions {
+			continue
+		}
+		g := ConsolidationGroup{
+			Region:  functions[0].Region,
+			Stack:   functions[0].Tags[tagCloudFormationStackName],
+			Runtime: runtimeLanguage(functions[0].Runtime),
+		}
+		scheduled := true
+		runtimes := map[string]struct{}{}
+		for _, fr := range functions {
+			g.Functions = append(g.Functions, fr.Name)
+			g.DailyInvocations += fr.DailyInvocations()
+			g.MonthlyCost += fr.DailyCost() * 30
+			g.MonthlyColdStarts += float64(fr.ColdStarts()) / fr.Days() * 30
+			g.MonthlyHousekeepingCost += fr.MonthlyInitCost() + fr.MonthlyLogIngestionCost()
+			if !fr.LogGroupMissing {
+				g.LogGroups++
+			}
+			scheduled = scheduled && len(fr.Triggers) == 1 && fr.Triggers[0] == triggerSchedule
+			runtimes[fr.Runtime] = struct{}{}
+		}
+		sort.Strings(g.Functions)
+		g.Suggestion = fmt.Sprintf("consolidate %d functions into one %s function that routes on the event", len(functions), g.Runtime)
+		if len(runtimes) > 1 {
+			g.Suggestion += fmt.Sprintf(", after moving them to the same %s version", g.Runtime)
+		}
+		if scheduled {
+			g.Suggestion = fmt.Sprintf("all %d functions run on a schedule, disable the schedules when they're not needed, or %s", len(functions), g.Suggestion)
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].MonthlyHousekeepingCost == groups[j].MonthlyHousekeepingCost {
+			return groups[i].Region+groups[i].Stack+groups[i].Runtime < groups[j].Region+groups[j].Stack+groups[j].Runtime
+		}
+		return groups[i].MonthlyHousekeepingCost > groups[j].MonthlyHousekeepingCost
+	})
+	return groups
+}
+
+func displayConsolidationGroups(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	groups := findConsolidationGroups(reportContent)
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Consolidation: %d groups of tiny, rarely invoked functions in the same stack\n", len(groups))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Region", "Stack", "Runtime", "Functions", "Daily Invocations", "Monthly", "Monthly Cold Starts", "Log Groups", "Monthly Housekeeping"}, "\t"))
+	for _, g := range groups {
+		fmt.Fprintln(tw, strings.Join([]string{
+			g.Region,
+			g.Stack,
+			g.Runtime,
+			fmt.Sprintf("%d", len(g.Functions)),
+			fmt.Sprintf("%.0f", g.DailyInvocations),
+			format.Money(g.MonthlyCost, regionCurrency(g.Region), 2),
+			fmt.Sprintf("%.0f", g.MonthlyColdStarts),
+			fmt.Sprintf("%d", g.LogGroups),
+			format.Money(g.MonthlyHousekeepingCost, regionCurrency(g.Region), 2),
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	for _, g := range groups {
+		fmt.Fprintf(w, "  %s/%s: %s (%s)\n", g.Region, g.Stack, g.Suggestion, strings.Join(g.Functions, ", "))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Housekeeping is the cost of keeping the functions separate: the init duration of their cold starts,")
+	fmt.Fprintln(w, "and the ingestion of their log groups.")
+}
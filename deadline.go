@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errFunctionListPartial is returned with the functions that were listed before the deadline
+// expired, so that their data can still be written.
+var errFunctionListPartial = errors.New("the deadline expired before every function was listed")
+
+// deadlineExceeded returns true if the context's deadline has expired, rather than it being cancelled,
+// e.g. with Ctrl-C.
+func deadlineExceeded(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// markDeadlineExceeded flags that the function's data is partial, because the overall deadline
+// expired before its collection was complete.
+func (fr *FunctionReports) markDeadlineExceeded(warning string) {
+	fr.DeadlineExceeded = true
+	fr.Incomplete = true
+	fr.Warnings = append(fr.Warnings, warning)
+}
+
+func countDeadlineExceeded(reportContent []FunctionReports) (count int) {
+	for _, fr := range reportContent {
+		if fr.DeadlineExceeded {
+			count++
+		}
+	}
+	return count
+}
+
+func displayDeadlineExceeded(w io.Writer, reportContent []FunctionReports) {
+	count := countDeadlineExceeded(reportContent)
+	if count == 0 {
+		return
+	}
+	fmt.Fprintf(w, "PARTIAL REPORT: the deadline expired before collection was complete, %d of %d functions have partial or no data.\n", count, len(reportContent))
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestDeadlineDuringMetadataReturnsPartialData(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	// The deadline expires while the first function's metadata is read.
+	var once sync.Once
+	fake.BeforeServe = func(operation string) {
+		if operation == "Lambda:ListProvisionedConcurrencyConfigs" {
+			once.Do(func() { <-ctx.Done() })
+		}
+	}
+	var stats scanStats
+	functionReports, err := getFunctionReports(ctx, zap.NewNop(), fake.Config(), &stats, fakeAccountID, "test", collectOptions{
+		Window:    24 * time.Hour,
+		Collector: collectorAuto,
+	})
+	if err != nil {
+		t.Fatalf("expected partial data rather than an error, got %v", err)
+	}
+	if len(functionReports) != len(fake.Functions) {
+		t.Fatalf("expected every listed function to be returned, got %d", len(functionReports))
+	}
+	if count := countDeadlineExceeded(functionReports); count != len(fake.Functions) {
+		t.Errorf("expected every function to be partial, got %d", count)
+	}
+	written, err := writeMetadataCache(filepath.Join(t.TempDir(), "metadata.json"), functionReports, false, time.Now())
+	if err != nil || written {
+		t.Errorf("expected partial metadata not to be cached, got %v, %v", written, err)
+	}
+	recommenders, err := newRecommenders(Settings{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := newRunStatus(functionReports, recommenders); !status.Partial {
+		t.Error("expected the status to be partial")
+	}
+}
+
+func TestDeadlineBeforeFunctionsAreListed(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	var stats scanStats
+	functionReports, err := getFunctionReports(ctx, zap.NewNop(), fake.Config(), &stats, fakeAccountID, "test", collectOptions{
+		Window:    24 * time.Hour,
+		Collector: collectorAuto,
+	})
+	if !errors.Is(err, errFunctionListPartial) {
+		t.Fatalf("expected the function list to be partial, got %v", err)
+	}
+	if len(functionReports) != 0 {
+		t.Errorf("expected no functions, got %d", len(functionReports))
+	}
+}
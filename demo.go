@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// demoFunction describes the behaviour of a synthetic function.
+type demoFunction struct {
+	Name          string
+	Architecture  Architecture
+	Runtime       string
+	MemorySize    int64
+	Timeout       time.Duration
+	DailyInvokes  int
+	AvgDuration   time.Duration
+	MaxMemoryUsed int64
+	ColdStartRate float64
+	InitDuration  time.Duration
+	CodeSize      int64
+	Layers        []Layer
+	Tags          map[string]string
+	Triggers      []string
+	// ConfiguredMemorySize is set if the configuration was changed after the window, and
+	// differs from MemorySize.
+	ConfiguredMemorySize int64
+	// SubscriptionFilters and LogBytesPerInvocation describe the function's log group.
+	SubscriptionFilters   []SubscriptionFilter
+	LogBytesPerInvocation int64
+	// LogRetentionDays is the log group's retention period, or zero if it never expires, and
+	// LogStoredBytes is the size of its stored data.
+	LogRetentionDays int32
+	LogStoredBytes   int64
+	// InitMemoryUsed is set if the init phase uses more memory than warm invocations.
+	InitMemoryUsed int64
+	// AvgVCPUs is set if the function has the Lambda Insights extension, and is the average
+	// number of vCPUs used while invocations are running.
+	AvgVCPUs float64
+	// LogFormatJSON is true if the function uses the JSON log format, so writes platform.report
+	// events instead of REPORT lines.
+	LogFormatJSON bool
+	// EphemeralStorage is set if the function has more than the default 512 MB of /tmp.
+	EphemeralStorage int64
+	// MemoryLeakPerDay is how much max memory used by warm invocations rises each day, in MB, to
+	// simulate a leak.
+	MemoryLeakPerDay float64
+}
+
+var demoLogForwarder = SubscriptionFilter{Name: "log-forwarder", DestinationARN: "arn:aws:lambda:eu-west-1:123456789012:function:log-forwarder"}
+
+var demoObservabilityLayer = Layer{ARN: "arn:aws:lambda:eu-west-1:123456789012:layer:observability:12", CodeSize: 38 * 1024 * 1024}
+
+var demoLambdaInsightsLayer = Layer{ARN: "arn:aws:lambda:eu-west-1:580247275435:layer:LambdaInsightsExtension:38", CodeSize: 5 * 1024 * 1024}
+
+var demoFunctions = []demoFunction{
+	{Name: "orders-api", Architecture: ArchitectureX86_64, Runtime: "nodejs18.x", MemorySize: 3072, Timeout: 30 * time.Second, DailyInvokes: 60000, AvgDuration: 950 * time.Millisecond, MaxMemoryUsed: 180, ColdStartRate: 0.02, InitDuration: 400 * time.Millisecond, CodeSize: 4 * 1024 * 1024, Layers: []Layer{demoObservabilityLayer}, Tags: map[string]string{"team": "orders"}, Triggers: []string{triggerAPI}, SubscriptionFilters: []SubscriptionFilter{demoLogForwarder, {Name: "siem", DestinationARN: "arn:aws:firehose:eu-west-1:123456789012:deliverystream/siem"}}, LogBytesPerInvocation: 2400, LogStoredBytes: 310 * 1024 * 1024 * 1024, AvgVCPUs: 0.6},
+	{Name: "payments-processor", Architecture: ArchitectureX86_64, Runtime: "java17", MemorySize: 2048, Timeout: 60 * time.Second, DailyInvokes: 20000, AvgDuration: 1200 * time.Millisecond, MaxMemoryUsed: 420, ColdStartRate: 0.05, InitDuration: 4500 * time.Millisecond, CodeSize: 62 * 1024 * 1024, Layers: []Layer{demoObservabilityLayer}, Tags: map[string]string{"team": "payments"}, Triggers: []string{triggerQueue}, InitMemoryUsed: 610},
+	{Name: "image-resizer", Architecture: ArchitectureARM64, Runtime: "provided.al2", MemorySize: 1536, Timeout: 15 * time.Second, DailyInvokes: 8000, AvgDuration: 2 * time.Second, MaxMemoryUsed: 1450, ColdStartRate: 0.1, InitDuration: 150 * time.Millisecond, CodeSize: 12 * 1024 * 1024, Tags: map[string]string{"team": "media"}, Triggers: []string{triggerEvent}, EphemeralStorage: 4096},
+	{Name: "event-router", Architecture: ArchitectureX86_64, Runtime: "go1.x", MemorySize: 128, Timeout: 3 * time.Second, DailyInvokes: 150000, AvgDuration: 4 * time.Millisecond, MaxMemoryUsed: 45, ColdStartRate: 0.001, InitDuration: 90 * time.Millisecond, CodeSize: 8 * 1024 * 1024, Tags: map[string]string{"team": "platform"}, Triggers: []string{triggerStream}},
+	{Name: "nightly-export", Architecture: ArchitectureX86_64, Runtime: "python3.12", MemorySize: 4096, Timeout: 15 * time.Minute, DailyInvokes: 24, AvgDuration: 9 * time.Minute, MaxMemoryUsed: 900, ColdStartRate: 0.5, InitDuration: 800 * time.Millisecond, CodeSize: 30 * 1024 * 1024, Tags: map[string]string{"team": "data", defaultWorkloadTag: "batch"}, Triggers: []string{triggerSchedule}, AvgVCPUs: 2},
+	{Name: "report-generator", Architecture: ArchitectureX86_64, Runtime: "dotnet8", MemorySize: 1024, Timeout: 10 * time.Second, DailyInvokes: 3000, AvgDuration: 8500 * time.Millisecond, MaxMemoryUsed: 310, ColdStartRate: 0.08, InitDuration: 1800 * time.Millisecond, CodeSize: 25 * 1024 * 1024, Layers: []Layer{demoObservabilityLayer}, Triggers: []string{triggerAPI, triggerQueue}, SubscriptionFilters: []SubscriptionFilter{demoLogForwarder}, LogBytesPerInvocation: 48 * 1024, LogRetentionDays: 14, LogStoredBytes: 2 * 1024 * 1024 * 1024, MemoryLeakPerDay: 60},
+	{Name: "auth-authorizer", Architecture: ArchitectureARM64, Runtime: "nodejs20.x", MemorySize: 256, Timeout: 5 * time.Second, DailyInvokes: 90000, AvgDuration: 35 * time.Millisecond, MaxMemoryUsed: 88, ColdStartRate: 0.01, InitDuration: 250 * time.Millisecond, CodeSize: 2 * 1024 * 1024, Tags: map[string]string{"team": "identity"}, Triggers: []string{triggerAPI}, ConfiguredMemorySize: 512, LogFormatJSON: true},
+	{Name: "custom-resource-handler", Architecture: ArchitectureX86_64, Runtime: "python3.9", MemorySize: 128, Timeout: 5 * time.Minute, DailyInvokes: 3, AvgDuration: 1500 * time.Millisecond, MaxMemoryUsed: 70, ColdStartRate: 1, InitDuration: 300 * time.Millisecond, CodeSize: 1024 * 1024},
+	{Name: "ops-rotate-keys", Architecture: ArchitectureX86_64, Runtime: "python3.12", MemorySize: 256, Timeout: time.Minute, DailyInvokes: 24, AvgDuration: 900 * time.Millisecond, MaxMemoryUsed: 80, ColdStartRate: 0.9, InitDuration: 600 * time.Millisecond, CodeSize: 2 * 1024 * 1024, Tags: map[string]string{"team": "platform", tagCloudFormationStackName: "ops-tools"}, Triggers: []string{triggerSchedule}, LogBytesPerInvocation: 6 * 1024},
+	{Name: "ops-snapshot-cleanup", Architecture: ArchitectureX86_64, Runtime: "python3.12", MemorySize: 256, Timeout: 5 * time.Minute, DailyInvokes: 4, AvgDuration: 6 * time.Second, MaxMemoryUsed: 95, ColdStartRate: 1, InitDuration: 700 * time.Millisecond, CodeSize: 3 * 1024 * 1024, Tags: map[string]string{"team": "platform", tagCloudFormationStackName: "ops-tools"}, Triggers: []string{triggerSchedule}, LogBytesPerInvocation: 20 * 1024},
+	{Name: "ops-tag-audit", Architecture: ArchitectureX86_64, Runtime: "python3.11", MemorySize: 256, Timeout: 5 * time.Minute, DailyInvokes: 12, AvgDuration: 3 * time.Second, MaxMemoryUsed: 110, ColdStartRate: 0.95, InitDuration: 650 * time.Millisecond, CodeSize: 2 * 1024 * 1024, Tags: map[string]string{"team": "platform", tagCloudFormationStackName: "ops-tools"}, Triggers: []string{triggerSchedule}, LogBytesPerInvocation: 12 * 1024},
+}
+
+// generateDemoReports synthesises report data, so that the report can be explored without AWS credentials.
+// The same seed always produces the same data.
+func generateDemoReports(seed int64, end time.Time, window time.Duration) (functionReports []FunctionReports) {
+	rnd := rand.New(rand.NewSource(seed))
+	start := end.Add(-window)
+	days := window.Hours() / 24
+	for _, df := range demoFunctions {
+		fr := FunctionReports{
+			Account:          "123456789012",
+			AccountName:      "demo",
+			Name:             df.Name,
+			Region:           "eu-west-1",
+			Architecture:     df.Architecture,
+			Timeout:          df.Timeout,
+			Runtime:          df.Runtime,
+			PackageType:      "Zip",
+			CodeSize:         df.CodeSize,
+			Layers:           df.Layers,
+			EphemeralStorage: df.EphemeralStorage,
+			Tags:             df.Tags,
+			Triggers:         df.Triggers,
+			Start:            start,
+			End:              end,
+		}
+		fr.MemorySize = df.MemorySize
+		if df.ConfiguredMemorySize > 0 {
+			fr.MemorySize = df.ConfiguredMemorySize
+		}
+		invocations := int(float64(df.DailyInvokes) * days)
+		fr.SubscriptionFilters = df.SubscriptionFilters
+		if df.LogBytesPerInvocation > 0 {
+			incomingBytes := int64(invocations) * df.LogBytesPerInvocation
+			fr.LogIncomingBytes = &incomingBytes
+		}
+		if df.LogStoredBytes > 0 {
+			storedBytes := df.LogStoredBytes
+			fr.LogStoredBytes = &storedBytes
+			fr.LogRetentionDays = df.LogRetentionDays
+			fr.LogGroupNeverExpires = df.LogRetentionDays == 0
+		}
+		for i := 0; i < invocations; i++ {
+			r, _, err := getFunctionReport(demoReportLine(rnd, df))
+			if err != nil {
+				panic(fmt.Sprintf("demo: generated an invalid report: %v", err))
+			}
+			r.Timestamp = demoTimestamp(rnd, start, window)
+			if df.MemoryLeakPerDay > 0 && !r.IsColdStart {
+				r.MaxMemoryUsed += int64(df.MemoryLeakPerDay * r.Timestamp.Sub(start).Hours() / 24)
+				if r.MaxMemoryUsed > r.MemorySize {
+					r.MaxMemoryUsed = r.MemorySize
+				}
+			}
+			fr.Reports = append(fr.Reports, r)
+		}
+		sort.Slice(fr.Reports, func(i, j int) bool {
+			return fr.Reports[i].Timestamp.Before(fr.Reports[j].Timestamp)
+		})
+		if df.AvgVCPUs > 0 {
+			fr.Layers = append(append([]Layer(nil), fr.Layers...), demoLambdaInsightsLayer)
+			insights := LambdaInsights{NetworkBytes: int64(invocations) * 24 * 1024}
+			for _, r := range fr.Reports {
+				insights.CPUTime += time.Duration(float64(r.Duration) * df.AvgVCPUs)
+			}
+			fr.LambdaInsights = &insights
+		}
+		functionReports = append(functionReports, fr)
+	}
+	return functionReports
+}
+
+// demoTimestamp returns a time in the window, with more traffic during the working day (UTC).
+func demoTimestamp(rnd *rand.Rand, start time.Time, window time.Duration) time.Time {
+	for {
+		t := start.Add(time.Duration(rnd.Int63n(int64(window))))
+		if h := t.UTC().Hour(); (h >= 8 && h < 18) || rnd.Float64() < 0.3 {
+			return t
+		}
+	}
+}
+
+// demoReportLine generates a REPORT line in the format written by Lambda.
+func demoReportLine(rnd *rand.Rand, df demoFunction) string {
+	// Durations are exponentially distributed around the average, to produce a long tail.
+	duration := time.Duration(rnd.ExpFloat64() * float64(df.AvgDuration))
+	timedOut := duration >= df.Timeout
+	if timedOut {
+		duration = df.Timeout
+	}
+	if duration < time.Millisecond {
+		duration = time.Millisecond
+	}
+	maxMemoryUsed := df.MaxMemoryUsed - int64(rnd.Intn(int(df.MaxMemoryUsed/4)+1))
+	requestID := fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", rnd.Uint32(), rnd.Intn(0x10000), rnd.Intn(0x10000), rnd.Intn(0x10000), rnd.Int63n(0x1000000000000))
+	var initDuration time.Duration
+	if rnd.Float64() < df.ColdStartRate {
+		initDuration = time.Duration((0.5 + rnd.Float64()) * float64(df.InitDuration))
+		if df.InitMemoryUsed > maxMemoryUsed {
+			maxMemoryUsed = df.InitMemoryUsed
+		}
+	}
+	if df.LogFormatJSON {
+		return demoPlatformReport(requestID, duration, initDuration, maxMemoryUsed, timedOut, df)
+	}
+	line := fmt.Sprintf("REPORT RequestId: %s\tDuration: %.2f ms\tBilled Duration: %d ms\tMemory Size: %d MB\tMax Memory Used: %d MB\t",
+		requestID,
+		float64(duration.Microseconds())/1000,
+		duration.Milliseconds()+1,
+		df.MemorySize,
+		maxMemoryUsed)
+	if initDuration > 0 {
+		line += fmt.Sprintf("Init Duration: %.2f ms\t", float64(initDuration.Microseconds())/1000)
+	}
+	if timedOut {
+		line += "Status: timeout\t"
+	}
+	return line
+}
+
+// demoPlatformReport generates a platform.report event in the format written by Lambda for
+// functions that use the JSON log format.
+func demoPlatformReport(requestID string, duration, initDuration time.Duration, maxMemoryUsed int64, timedOut bool, df demoFunction) string {
+	metrics := fmt.Sprintf(`"durationMs":%.2f,"billedDurationMs":%d,"memorySizeMB":%d,"maxMemoryUsedMB":%d`,
+		float64(duration.Microseconds())/1000,
+		duration.Milliseconds()+1,
+		df.MemorySize,
+		maxMemoryUsed)
+	if initDuration > 0 {
+		metrics += fmt.Sprintf(`,"initDurationMs":%.2f`, float64(initDuration.Microseconds())/1000)
+	}
+	status := "success"
+	if timedOut {
+		status = "timeout"
+	}
+	return fmt.Sprintf(`{"type":"platform.report","record":{"requestId":"%s","metrics":{%s},"status":"%s"}}`, requestID, metrics, status)
+}
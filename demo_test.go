@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var demoTestEnd = time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC)
+
+func TestDemoReportsAreRepeatable(t *testing.T) {
+	a := generateDemoReports(1, demoTestEnd, 24*time.Hour)
+	b := generateDemoReports(1, demoTestEnd, 24*time.Hour)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("expected the same seed to generate the same data")
+	}
+	if c := generateDemoReports(2, demoTestEnd, 24*time.Hour); reflect.DeepEqual(a, c) {
+		t.Error("expected a different seed to generate different data")
+	}
+	if len(a) != len(demoFunctions) {
+		t.Fatalf("expected %d functions, got %d", len(demoFunctions), len(a))
+	}
+	for _, fr := range a {
+		if len(fr.Reports) == 0 {
+			t.Errorf("%s: expected reports", fr.Name)
+		}
+		for _, r := range fr.Reports {
+			if r.Timestamp.Before(fr.Start) || !r.Timestamp.Before(fr.End) {
+				t.Fatalf("%s: expected timestamps in the window, got %v", fr.Name, r.Timestamp)
+			}
+		}
+	}
+}
+
+// TestDemoReportOutputs writes the demo data in every output format, in the same way as -demo,
+// so that the outputs are tested without AWS credentials.
+func TestDemoReportOutputs(t *testing.T) {
+	functionReports := generateDemoReports(1, demoTestEnd, 24*time.Hour)
+	for _, name := range formatterNames() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("report", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			of := newOutputFlags(fs)
+			if err := fs.Parse([]string{"-output=" + name, "-no-color"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			opts, err := of.reportOptions(Settings{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var buf bytes.Buffer
+			if err = opts.Formatter.Format(&buf, functionReports, opts); err != nil {
+				t.Fatalf("failed to format: %v", err)
+			}
+			if buf.Len() == 0 {
+				t.Fatal("expected output")
+			}
+			if name != formatterJSON {
+				return
+			}
+			var output ReportOutput
+			if err = json.Unmarshal(buf.Bytes(), &output); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if len(output.Functions) != len(demoFunctions) {
+				t.Errorf("expected %d functions, got %d", len(demoFunctions), len(output.Functions))
+			}
+		})
+	}
+}
+
+func TestDemoSummary(t *testing.T) {
+	functionReports := generateDemoReports(1, demoTestEnd, 24*time.Hour)
+	recommenders, err := newRecommenders(Settings{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	owners, err := newOwnerResolver("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := newSummary(functionReports, reportOptions{Recommenders: recommenders, Owners: owners}, demoTestEnd)
+	if s.FunctionCount != len(demoFunctions) {
+		t.Errorf("expected %d functions, got %d", len(demoFunctions), s.FunctionCount)
+	}
+	if s.MonthlySavings <= 0 {
+		t.Errorf("expected savings, got %v", s.MonthlySavings)
+	}
+	for _, recType := range []string{recommendationMemory, recommendationArchitecture} {
+		if s.Recommendations[recType].Count == 0 {
+			t.Errorf("expected %s recommendations, got %+v", recType, s.Recommendations)
+		}
+	}
+	var found bool
+	for _, f := range s.Top {
+		if f.Name != "orders-api" {
+			continue
+		}
+		found = true
+		if !containsRecommendation(f.Recommendations, recommendationMemory) {
+			t.Errorf("expected orders-api, which uses 180 MB of 3072 MB, to have a memory recommendation, got %+v", f.Recommendations)
+		}
+	}
+	if !found {
+		t.Errorf("expected orders-api to be one of the most expensive functions, got %+v", s.Top)
+	}
+}
+
+func containsRecommendation(recs []Recommendation, recType string) bool {
+	for _, rec := range recs {
+		if rec.Type == recType {
+			return true
+		}
+	}
+	return false
+}
+
+// TestDemoCollectedFromFakeAWS serves demo log messages from the fake AWS APIs, so that
+// collection and parsing are tested end to end without AWS credentials.
+func TestDemoCollectedFromFakeAWS(t *testing.T) {
+	const invocations = 50
+	rnd := rand.New(rand.NewSource(1))
+	var functions []fakeFunction
+	for _, df := range demoFunctions {
+		fn := fakeFunction{Name: df.Name, MemorySize: int32(df.MemorySize), Architecture: string(df.Architecture)}
+		for i := 0; i < invocations; i++ {
+			fn.Messages = append(fn.Messages, demoReportLine(rnd, df))
+		}
+		functions = append(functions, fn)
+	}
+	fake := newFakeAWS(t, "eu-west-1", functions...)
+	fake.PageSize = 20
+	functionReports, _, quarantineFile := collectFromFake(t, fake, nil)
+	for i, fr := range functionReports {
+		fn := fake.Functions[i]
+		if fr.Name != fn.Name {
+			t.Fatalf("expected function %q, got %q", fn.Name, fr.Name)
+		}
+		if len(fr.Errors) > 0 || fr.Incomplete {
+			t.Errorf("%s: expected complete data, got errors %v, incomplete %v", fr.Name, fr.Errors, fr.Incomplete)
+		}
+		if len(fr.Reports) != len(fn.Messages) {
+			t.Fatalf("%s: expected %d reports, got %d", fr.Name, len(fn.Messages), len(fr.Reports))
+		}
+		for j, r := range fr.Reports {
+			expected, ok, err := getFunctionReport(fn.Messages[j])
+			if !ok || err != nil {
+				t.Fatalf("%s: invalid demo message %q: %v", fr.Name, fn.Messages[j], err)
+			}
+			if r.RequestID != expected.RequestID || r.Duration != expected.Duration || r.BilledDuration != expected.BilledDuration ||
+				r.MemorySize != expected.MemorySize || r.MaxMemoryUsed != expected.MaxMemoryUsed ||
+				r.InitDuration != expected.InitDuration || r.IsColdStart != expected.IsColdStart {
+				t.Errorf("%s: expected report %d to be %+v, got %+v", fr.Name, j, expected, r)
+			}
+		}
+	}
+	if entries := readQuarantine(t, quarantineFile); len(entries) > 0 {
+		t.Errorf("expected nothing to be quarantined, got %v", entries)
+	}
+}
+
+func TestJSONOutputIncludesCollectionErrors(t *testing.T) {
+	functionReports := generateDemoReports(1, demoTestEnd, 24*time.Hour)
+	functionReports[0].Incomplete = true
+	functionReports[0].Errors = []CollectionError{{Kind: errorKindThrottle, Operation: "collectLogs", Message: "rate exceeded", Count: 3}}
+	f, err := getFormatter(formatterJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err = f.Format(&buf, functionReports, reportOptions{}); err != nil {
+		t.Fatalf("failed to format: %v", err)
+	}
+	var output ReportOutput
+	if err = json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, row := range output.Functions {
+		if row.Name != functionReports[0].Name {
+			continue
+		}
+		if !row.Incomplete || !reflect.DeepEqual(row.Errors, functionReports[0].Errors) {
+			t.Errorf("expected the row to be incomplete, with the collection errors, got incomplete %v, errors %+v", row.Incomplete, row.Errors)
+		}
+		return
+	}
+	t.Errorf("expected a row for %s", functionReports[0].Name)
+}
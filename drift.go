@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Configuration settings that are tracked for drift.
+const (
+	settingMemory       = "memory"
+	settingTimeout      = "timeout"
+	settingArchitecture = "architecture"
+)
+
+// Sources of configuration changes.
+const (
+	// driftSourceLogs changes are inferred from the memory size in REPORT lines.
+	driftSourceLogs = "logs"
+	// driftSourceConfig changes are found by comparing the configuration collected in
+	// different runs, or with the REPORT lines.
+	driftSourceConfig = "config"
+)
+
+// ConfigChange is a change to a function's configuration.
+type ConfigChange struct {
+	// Time is when the change was first seen. Changes found by comparing runs were made some
+	// time after the end of the earlier run's window, which is used as the time.
+	Time    time.Time `json:"time"`
+	Setting string    `json:"setting"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Source  string    `json:"source"`
+}
+
+// configChanges compares the configuration collected in an earlier and a later run of the same
+// function. Settings that weren't collected, e.g. by older versions, are ignored.
+func configChanges(earlier, later FunctionReports) (changes []ConfigChange) {
+	add := func(setting, from, to string) {
+		if from != "" && to != "" && from != to {
+			changes = append(changes, ConfigChange{Time: earlier.End, Setting: setting, From: from, To: to, Source: driftSourceConfig})
+		}
+	}
+	formatMB := func(mb int64) string {
+		if mb == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d MB", mb)
+	}
+	formatTimeout := func(d time.Duration) string {
+		if d == 0 {
+			return ""
+		}
+		return d.String()
+	}
+	add(settingMemory, formatMB(earlier.MemorySize), formatMB(later.MemorySize))
+	add(settingTimeout, formatTimeout(earlier.Timeout), formatTimeout(later.Timeout))
+	add(settingArchitecture, string(earlier.Architecture), string(later.Architecture))
+	return changes
+}
+
+// configHistory returns the configuration changes between snapshots of the same function from
+// different runs, and any changes already found when the snapshots were themselves merged.
+func configHistory(snapshots []FunctionReports) (changes []ConfigChange) {
+	sorted := append([]FunctionReports(nil), snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].End.Before(sorted[j].End)
+	})
+	seen := map[ConfigChange]struct{}{}
+	add := func(c ConfigChange) {
+		if _, ok := seen[c]; ok {
+			return
+		}
+		seen[c] = struct{}{}
+		changes = append(changes, c)
+	}
+	for i, s := range sorted {
+		for _, c := range s.ConfigChanges {
+			add(c)
+		}
+		if i > 0 {
+			for _, c := range configChanges(sorted[i-1], s) {
+				add(c)
+			}
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Time.Before(changes[j].Time)
+	})
+	return changes
+}
+
+// memoryChangesFromLogs infers memory changes from the REPORT lines. A change is found when
+// invocations with one memory size stop before invocations with the next start. Memory sizes
+// that overlap in time are from versions or aliases with different settings, not changes.
+func (fr FunctionReports) memoryChangesFromLogs() (changes []ConfigChange) {
+	type span struct {
+		MemorySize  int64
+		First, Last time.Time
+	}
+	indexes := map[int64]int{}
+	var spans []span
+	for _, r := range fr.Reports {
+		if r.Timestamp.IsZero() {
+			return nil
+		}
+		index, ok := indexes[r.MemorySize]
+		if !ok {
+			index = len(spans)
+			indexes[r.MemorySize] = index
+			spans = append(spans, span{MemorySize: r.MemorySize, First: r.Timestamp, Last: r.Timestamp})
+		}
+		if r.Timestamp.Before(spans[index].First) {
+			spans[index].First = r.Timestamp
+		}
+		if r.Timestamp.After(spans[index].Last) {
+			spans[index].Last = r.Timestamp
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].First.Before(spans[j].First)
+	})
+	for i := 1; i < len(spans); i++ {
+		if spans[i-1].Last.Before(spans[i].First) {
+			changes = append(changes, ConfigChange{
+				Time:    spans[i].First,
+				Setting: settingMemory,
+				From:    fmt.Sprintf("%d MB", spans[i-1].MemorySize),
+				To:      fmt.Sprintf("%d MB", spans[i].MemorySize),
+				Source:  driftSourceLogs,
+			})
+		}
+	}
+	return changes
+}
+
+// ConfigDrift returns the configuration changes seen during the window, oldest first. Memory
+// changes inferred from the REPORT lines are combined with changes found by comparing runs, and
+// a change to the configured memory after the last invocation.
+func (fr FunctionReports) ConfigDrift() (changes []ConfigChange) {
+	changes = fr.memoryChangesFromLogs()
+	isNew := func(c ConfigChange) bool {
+		for _, existing := range changes {
+			if c.Setting == existing.Setting && c.From == existing.From && c.To == existing.To {
+				return false
+			}
+		}
+		return true
+	}
+	for _, c := range fr.ConfigChanges {
+		if isNew(c) {
+			changes = append(changes, c)
+		}
+	}
+	// A single logged memory size that differs from the configuration was changed after the
+	// last invocation. Multiple memory sizes that overlap are from versions or aliases.
+	if configured, logged, ok := fr.MemoryMismatch(); ok && len(fr.MemorySegments()) == 1 {
+		c := ConfigChange{
+			Time:    fr.End,
+			Setting: settingMemory,
+			From:    fmt.Sprintf("%d MB", logged),
+			To:      fmt.Sprintf("%d MB", configured),
+			Source:  driftSourceConfig,
+		}
+		if isNew(c) {
+			changes = append(changes, c)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Time.Before(changes[j].Time)
+	})
+	return changes
+}
+
+// dailyCostAround returns the average daily cost of the function before and after a time. It
+// returns false if either side of the window is less than an hour, or the reports don't have
+// timestamps.
+func (fr FunctionReports) dailyCostAround(t time.Time) (before, after float64, ok bool) {
+	if fr.Start.IsZero() || t.Sub(fr.Start) < time.Hour || fr.End.Sub(t) < time.Hour {
+		return 0, 0, false
+	}
+	b, a := fr, fr
+	b.Reports, a.Reports = nil, nil
+	b.End, a.Start = t, t
+	for _, r := range fr.Reports {
+		if r.Timestamp.IsZero() {
+			return 0, 0, false
+		}
+		if r.Timestamp.Before(t) {
+			b.Reports = append(b.Reports, r)
+			continue
+		}
+		a.Reports = append(a.Reports, r)
+	}
+	return b.DailyCost(), a.DailyCost(), true
+}
+
+// ConfigDrift is a configuration change to a function during the window, with its daily cost
+// before and after the change, so that cost changes can be tied to the change.
+type ConfigDrift struct {
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	Name    string `json:"name"`
+	ConfigChange
+	// DailyCostBefore and DailyCostAfter are nil if the change is too close to the edge of the
+	// window to compare costs.
+	DailyCostBefore *float64 `json:"dailyCostBefore,omitempty"`
+	DailyCostAfter  *float64 `json:"dailyCostAfter,omitempty"`
+}
+
+func findConfigDrift(reportContent []FunctionReports) (drift []ConfigDrift) {
+	for _, fr := range reportContent {
+		for _, c := range fr.ConfigDrift() {
+			d := ConfigDrift{
+				Account:      fr.DisplayAccount(),
+				Region:       fr.Region,
+				Name:         fr.Name,
+				ConfigChange: c,
+			}
+			if before, after, ok := fr.dailyCostAround(c.Time); ok {
+				d.DailyCostBefore, d.DailyCostAfter = &before, &after
+			}
+			drift = append(drift, d)
+		}
+	}
+	return drift
+}
+
+func displayConfigDrift(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	drift := findConfigDrift(reportContent)
+	if len(drift) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Configuration drift: %d changes during the window\n", len(drift))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Time", "Setting", "Before", "After", "Source", "Daily Before", "Daily After"}, "\t"))
+	for _, d := range drift {
+		dailyBefore, dailyAfter := "-", "-"
+		if d.DailyCostBefore != nil {
+			dailyBefore, dailyAfter = format.Money(*d.DailyCostBefore, regionCurrency(d.Region), 5), format.Money(*d.DailyCostAfter, regionCurrency(d.Region), 5)
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			d.Name,
+			d.Region,
+			format.Time(d.Time),
+			d.Setting,
+			d.From,
+			d.To,
+			d.Source,
+			dailyBefore,
+			dailyAfter,
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Changes from logs are inferred from the memory size in REPORT lines. Changes from config are found by")
+	fmt.Fprintln(w, "comparing the configuration collected in different runs, and were made after the time shown.")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Prices used to estimate the cost of log ingestion (us-east-1).
+const (
+	logIngestionPricePerGB                 = 0.50
+	infrequentAccessLogIngestionPricePerGB = 0.25
+)
+
+// Log groups that ingest more than this many bytes per invocation are flagged. The START, END and
+// REPORT lines written by Lambda are around 300 bytes, so this leaves plenty of room for
+// application logs, but catches every line being written more than once, e.g. by an extension
+// and the runtime.
+const highLogBytesPerInvocation = 16 * 1024
+
+// SubscriptionFilter sends a copy of every matching log event to a destination, e.g. a Kinesis
+// stream or a log forwarding function.
+type SubscriptionFilter struct {
+	Name           string `json:"name"`
+	DestinationARN string `json:"destinationArn"`
+}
+
+func getSubscriptionFilters(ctx context.Context, cwLogsClient *cloudwatchlogs.Client, region, logGroupName string) (filters []SubscriptionFilter, err error) {
+	paginator := cloudwatchlogs.NewDescribeSubscriptionFiltersPaginator(cwLogsClient, &cloudwatchlogs.DescribeSubscriptionFiltersInput{
+		LogGroupName: &logGroupName,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, func(o *cloudwatchlogs.Options) {
+			o.Region = region
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getSubscriptionFilters: failed to describe subscription filters: %w", err)
+		}
+		for _, f := range page.SubscriptionFilters {
+			filters = append(filters, SubscriptionFilter{
+				Name:           aws.ToString(f.FilterName),
+				DestinationARN: aws.ToString(f.DestinationArn),
+			})
+		}
+	}
+	return filters, nil
+}
+
+// getLogIncomingBytes returns the sum of the log group's IncomingBytes metric over the window.
+func getLogIncomingBytes(ctx context.Context, cwClient *cloudwatch.Client, region, logGroupName string, start, end time.Time) (total int64, err error) {
+	return sumMetric(ctx, cwClient, region, "AWS/Logs", "IncomingBytes", "LogGroupName", logGroupName, start, end)
+}
+
+// LogBytesPerInvocation returns the average number of bytes ingested into the log group per
+// invocation. It returns false if the IncomingBytes metric wasn't collected, or there were
+// no invocations.
+func (fr FunctionReports) LogBytesPerInvocation() (bytes float64, ok bool) {
+	if fr.LogIncomingBytes == nil || len(fr.Reports) == 0 {
+		return 0, false
+	}
+	return float64(*fr.LogIncomingBytes) / float64(len(fr.Reports)), true
+}
+
+// MonthlyLogIngestionCost is the monthly cost of ingesting the function's logs, from the
+// IncomingBytes metric.
+func (fr FunctionReports) MonthlyLogIngestionCost() float64 {
+	if fr.LogIncomingBytes == nil {
+		return 0
+	}
+	price := logIngestionPricePerGB
+	if fr.LogGroupClass == string(cwtypes.LogGroupClassInfrequentAccess) {
+		price = infrequentAccessLogIngestionPricePerGB
+	}
+	return float64(*fr.LogIncomingBytes) / bytesPerGB * price / fr.Days() * 30
+}
+
+// DuplicateLogging is a function whose log group looks like it's part of a duplicated logging
+// pipeline.
+type DuplicateLogging struct {
+	Account             string  `json:"account"`
+	Region              string  `json:"region"`
+	Name                string  `json:"name"`
+	SubscriptionFilters int     `json:"subscriptionFilters"`
+	BytesPerInvocation  float64 `json:"bytesPerInvocation,omitempty"`
+	// MonthlyIngestionCost is the cost of ingesting the log group's data, which doesn't include
+	// the cost of delivering it to each subscription filter's destination.
+	MonthlyIngestionCost float64  `json:"monthlyIngestionCost"`
+	Reasons              []string `json:"reasons"`
+}
+
+// findDuplicateLogging returns the functions whose log groups have more than one subscription
+// filter, or ingest an unusually large amount of data per invocation, most expensive first.
+func findDuplicateLogging(reportContent []FunctionReports) (duplicates []DuplicateLogging) {
+	for _, fr := range reportContent {
+		var reasons []string
+		if len(fr.SubscriptionFilters) > 1 {
+			reasons = append(reasons, fmt.Sprintf("%d subscription filters", len(fr.SubscriptionFilters)))
+			destinations := map[string]int{}
+			for _, f := range fr.SubscriptionFilters {
+				destinations[f.DestinationARN]++
+			}
+			var shared []string
+			for destination, count := range destinations {
+				if count > 1 {
+					shared = append(shared, fmt.Sprintf("%d filters send to %s", count, destination))
+				}
+			}
+			sort.Strings(shared)
+			reasons = append(reasons, shared...)
+		}
+		bytes, ok := fr.LogBytesPerInvocation()
+		if ok && bytes > highLogBytesPerInvocation {
+			reasons = append(reasons, fmt.Sprintf("%s logged per invocation", formatBytes(bytes)))
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		duplicates = append(duplicates, DuplicateLogging{
+			Account:              fr.DisplayAccount(),
+			Region:               fr.Region,
+			Name:                 fr.Name,
+			SubscriptionFilters:  len(fr.SubscriptionFilters),
+			BytesPerInvocation:   bytes,
+			MonthlyIngestionCost: fr.MonthlyLogIngestionCost(),
+			Reasons:              reasons,
+		})
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].MonthlyIngestionCost > duplicates[j].MonthlyIngestionCost
+	})
+	return duplicates
+}
+
+// formatBytes formats a number of bytes in KB or MB.
+func formatBytes(bytes float64) string {
+	if bytes >= bytesPerGB {
+		return fmt.Sprintf("%.1f GB", bytes/bytesPerGB)
+	}
+	if bytes >= 1024*1024 {
+		return fmt.Sprintf("%.1f MB", bytes/1024/1024)
+	}
+	return fmt.Sprintf("%.1f KB", bytes/1024)
+}
+
+func displayDuplicateLogging(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	duplicates := findDuplicateLogging(reportContent)
+	if len(duplicates) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Duplicate logging: %d log groups may be part of duplicated logging pipelines\n", len(duplicates))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Filters", "Per Invocation", "Monthly Ingestion", "Reasons"}, "\t"))
+	for _, d := range duplicates {
+		perInvocation := "-"
+		if d.BytesPerInvocation > 0 {
+			perInvocation = formatBytes(d.BytesPerInvocation)
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			d.Name,
+			d.Region,
+			fmt.Sprintf("%d", d.SubscriptionFilters),
+			perInvocation,
+			format.Money(d.MonthlyIngestionCost, regionCurrency(d.Region), 2),
+			strings.Join(d.Reasons, ", "),
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Each subscription filter delivers a copy of every log event, which is charged again at its")
+	fmt.Fprintln(w, "destination, e.g. Kinesis, Firehose or a forwarding function, on top of the ingestion cost.")
+}
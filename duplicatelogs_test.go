@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestIncomingBytesErrorDoesNotSkipLambdaInsights(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", fakeFunction{
+		Name:             "api",
+		MemorySize:       1024,
+		Architecture:     "x86_64",
+		Invocations:      5,
+		BilledDurationMS: 100,
+		Layers:           []string{"arn:aws:lambda:eu-west-1:580247275435:layer:LambdaInsightsExtension:38"},
+	})
+	fake.MetricErrors = map[string]string{"IncomingBytes": "InvalidParameterValue"}
+	functionReports, _, _ := collectFromFake(t, fake, nil)
+	fr := functionReports[0]
+	if fr.LogIncomingBytes != nil {
+		t.Errorf("expected the incoming bytes not to be set, got %d", *fr.LogIncomingBytes)
+	}
+	var recorded bool
+	for _, e := range fr.Errors {
+		recorded = recorded || e.Operation == "getLogIncomingBytes"
+	}
+	if !recorded {
+		t.Errorf("expected the incoming bytes error to be recorded, got %+v", fr.Errors)
+	}
+	if fr.LambdaInsights == nil {
+		t.Error("expected Lambda Insights metrics to be collected")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/a-h/lambdacost/report"
+	"github.com/aws/smithy-go"
+)
+
+// Kinds of collection error.
+const (
+	errorKindThrottle     = "throttle"
+	errorKindAccessDenied = "accessDenied"
+	errorKindParse        = "parse"
+	errorKindOther        = "other"
+)
+
+// CollectionError is an error that occurred while collecting data for a function. It's in the
+// report package, so that the errors are included in the rows of the output formats.
+type CollectionError = report.CollectionError
+
+// errorKind classifies an error, using the AWS API error code where available.
+func errorKind(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "Throttling", "TooManyRequestsException", "RequestLimitExceeded", "LimitExceededException":
+			return errorKindThrottle
+		case "AccessDeniedException", "AccessDenied", "UnauthorizedOperation", "UnrecognizedClientException":
+			return errorKindAccessDenied
+		}
+	}
+	return errorKindOther
+}
+
+// addError records an error against the function.
+func (fr *FunctionReports) addError(kind, operation string, err error) {
+	for i := range fr.Errors {
+		if fr.Errors[i].Kind == kind && fr.Errors[i].Operation == operation {
+			fr.Errors[i].Count++
+			return
+		}
+	}
+	fr.Errors = append(fr.Errors, CollectionError{
+		Kind:      kind,
+		Operation: operation,
+		Message:   err.Error(),
+		Count:     1,
+	})
+}
+
+// mergeErrors adds the counts of errors from another set of report data.
+func (fr *FunctionReports) mergeErrors(errs []CollectionError) {
+	for _, e := range errs {
+		found := false
+		for i := range fr.Errors {
+			if fr.Errors[i].Kind == e.Kind && fr.Errors[i].Operation == e.Operation {
+				fr.Errors[i].Count += e.Count
+				found = true
+				break
+			}
+		}
+		if !found {
+			fr.Errors = append(fr.Errors, e)
+		}
+	}
+}
+
+// displayErrors lists the errors that occurred during collection, so that incomplete
+// data isn't missed in the log output.
+func displayErrors(w io.Writer, reportContent []FunctionReports) {
+	counts := map[string]int{}
+	var withErrors []FunctionReports
+	for _, rc := range reportContent {
+		if len(rc.Errors) == 0 {
+			continue
+		}
+		withErrors = append(withErrors, rc)
+		for _, e := range rc.Errors {
+			counts[e.Kind] += e.Count
+		}
+	}
+	if len(withErrors) == 0 {
+		return
+	}
+	var kinds []string
+	for kind, count := range counts {
+		kinds = append(kinds, fmt.Sprintf("%d %s", count, kind))
+	}
+	sort.Strings(kinds)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Errors (%s)\n", strings.Join(kinds, ", "))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Kind", "Operation", "Count", "Message"}, "\t"))
+	for _, rc := range withErrors {
+		for _, e := range rc.Errors {
+			fmt.Fprintln(tw, strings.Join([]string{rc.Name, rc.Region, e.Kind, e.Operation, fmt.Sprintf("%d", e.Count), e.Message}, "\t"))
+		}
+	}
+	tw.Flush()
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+// The export format is a stream of JSON lines: a header, then each function's metadata, followed
+// by a line for each of its invocations. Functions are written and read one at a time, so that
+// exports of many accounts don't have to fit in memory.
+const (
+	exportFormat  = "lambdacost-export"
+	exportVersion = 1
+)
+
+// Compression of the export stream.
+const (
+	exportCompressionZstd = "zstd"
+	exportCompressionGzip = "gzip"
+	exportCompressionNone = "none"
+)
+
+// Magic numbers at the start of compressed streams.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// exportHeader is the first line of an export.
+type exportHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// exportRecord is a line of an export. Only one of the fields is set. Reports belong to the
+// function on the most recent function line.
+type exportRecord struct {
+	Header   *exportHeader    `json:"header,omitempty"`
+	Function *FunctionReports `json:"function,omitempty"`
+	Report   *Report          `json:"report,omitempty"`
+}
+
+// exportWriter writes functions to an export stream.
+type exportWriter struct {
+	enc *json.Encoder
+	bw  *bufio.Writer
+	// compressor is closed to flush the end of the compressed stream.
+	compressor io.Closer
+}
+
+func newExportWriter(w io.Writer, compression string) (ew *exportWriter, err error) {
+	ew = &exportWriter{}
+	switch compression {
+	case exportCompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("newExportWriter: could not create zstd writer: %w", err)
+		}
+		ew.compressor, w = zw, zw
+	case exportCompressionGzip:
+		gw := gzip.NewWriter(w)
+		ew.compressor, w = gw, gw
+	case exportCompressionNone:
+	default:
+		return nil, fmt.Errorf("newExportWriter: unsupported compression %q, expected %s, %s or %s", compression, exportCompressionZstd, exportCompressionGzip, exportCompressionNone)
+	}
+	ew.bw = bufio.NewWriterSize(w, 1024*1024)
+	ew.enc = json.NewEncoder(ew.bw)
+	if err = ew.enc.Encode(exportRecord{Header: &exportHeader{Format: exportFormat, Version: exportVersion}}); err != nil {
+		return nil, fmt.Errorf("newExportWriter: could not write header: %w", err)
+	}
+	return ew, nil
+}
+
+// Write writes the function's metadata, then its reports.
+func (ew *exportWriter) Write(fr FunctionReports) (err error) {
+	metadata := fr
+	metadata.Reports = nil
+	if err = ew.enc.Encode(exportRecord{Function: &metadata}); err != nil {
+		return fmt.Errorf("exportWriter: could not write %s: %w", fr.Name, err)
+	}
+	for i := range fr.Reports {
+		if err = ew.enc.Encode(exportRecord{Report: &fr.Reports[i]}); err != nil {
+			return fmt.Errorf("exportWriter: could not write %s: %w", fr.Name, err)
+		}
+	}
+	return nil
+}
+
+// Close flushes the stream. It doesn't close the underlying writer.
+func (ew *exportWriter) Close() (err error) {
+	if err = ew.bw.Flush(); err != nil {
+		return fmt.Errorf("exportWriter: could not flush: %w", err)
+	}
+	if ew.compressor != nil {
+		if err = ew.compressor.Close(); err != nil {
+			return fmt.Errorf("exportWriter: could not flush: %w", err)
+		}
+	}
+	return nil
+}
+
+// readExport reads an export stream, zstd or gzip compressed, or not, and calls f with each
+// function once all of its reports have been read.
+func readExport(r io.Reader, f func(fr FunctionReports) error) (err error) {
+	br := bufio.NewReaderSize(r, 1024*1024)
+	magic, _ := br.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("readExport: could not read gzip stream: %w", err)
+		}
+		defer gz.Close()
+		br = bufio.NewReaderSize(gz, 1024*1024)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("readExport: could not read zstd stream: %w", err)
+		}
+		defer zr.Close()
+		br = bufio.NewReaderSize(zr, 1024*1024)
+	}
+	dec := json.NewDecoder(br)
+	var header exportRecord
+	if err = dec.Decode(&header); err != nil {
+		return fmt.Errorf("readExport: could not read header: %w", err)
+	}
+	if header.Header == nil || header.Header.Format != exportFormat {
+		return fmt.Errorf("readExport: not a lambdacost export")
+	}
+	if header.Header.Version > exportVersion {
+		return fmt.Errorf("readExport: export version %d is newer than the supported version %d", header.Header.Version, exportVersion)
+	}
+	var current *FunctionReports
+	for line := 2; ; line++ {
+		var rec exportRecord
+		if err = dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("readExport: line %d: %w", line, err)
+		}
+		switch {
+		case rec.Function != nil:
+			if current != nil {
+				if err = f(*current); err != nil {
+					return err
+				}
+			}
+			current = rec.Function
+		case rec.Report != nil:
+			if current == nil {
+				return fmt.Errorf("readExport: line %d: report before the first function", line)
+			}
+			current.Reports = append(current.Reports, *rec.Report)
+		}
+	}
+	if current != nil {
+		return f(*current)
+	}
+	return nil
+}
+
+// writeFunctionReportsStream writes report data in the same format as writeFunctionReports, one
+// function at a time.
+func writeFunctionReportsStream(w io.Writer, next func(write func(fr FunctionReports) error) error) (count int, err error) {
+	bw := bufio.NewWriterSize(w, 1024*1024)
+	if _, err = bw.WriteString("["); err != nil {
+		return 0, err
+	}
+	err = next(func(fr FunctionReports) error {
+		if count > 0 {
+			if _, err := bw.WriteString(","); err != nil {
+				return err
+			}
+		}
+		count++
+		b, err := json.Marshal(fr)
+		if err != nil {
+			return fmt.Errorf("could not encode %s: %w", fr.Name, err)
+		}
+		_, err = bw.Write(b)
+		return err
+	})
+	if err != nil {
+		return count, err
+	}
+	if _, err = bw.WriteString("]\n"); err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// openInput opens a file, or stdin if the name is -.
+func openInput(fileName string) (io.ReadCloser, error) {
+	if fileName == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(fileName)
+}
+
+func exportCmd(args []string) {
+	cmd := flag.NewFlagSet("export", flag.ExitOnError)
+	output := cmd.String("o", "export.jsonl.zst", "Path to write the export to, or - for stdout")
+	compression := cmd.String("compression", exportCompressionZstd, "Compression of the export: zstd, gzip or none")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost export [-o export.jsonl.zst] [-compression zstd] <file.json>...")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() == 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	write := func(w io.Writer) (err error) {
+		ew, err := newExportWriter(w, *compression)
+		if err != nil {
+			return err
+		}
+		var functions, reports int
+		for _, fileName := range cmd.Args() {
+			functionReports, err := readFunctionReports(fileName)
+			if err != nil {
+				return err
+			}
+			for _, fr := range functionReports {
+				if err = ew.Write(fr); err != nil {
+					return err
+				}
+				functions++
+				reports += len(fr.Reports)
+			}
+		}
+		if err = ew.Close(); err != nil {
+			return err
+		}
+		log.Info("export complete", zap.Int("files", cmd.NArg()), zap.Int("functions", functions), zap.Int("reports", reports), zap.String("filename", *output))
+		return nil
+	}
+	var err error
+	if *output == "-" {
+		err = write(os.Stdout)
+	} else {
+		err = writeFileAtomic(*output, write)
+	}
+	if err != nil {
+		log.Fatal("could not write export", zap.Error(err))
+	}
+}
+
+func importCmd(args []string) {
+	cmd := flag.NewFlagSet("import", flag.ExitOnError)
+	output := cmd.String("o", "imported.json", "Path to write the report data to")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost import [-o imported.json] <export.jsonl.zst|->...")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() == 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	var count, duplicates int
+	err := writeFileAtomic(*output, func(w io.Writer) (err error) {
+		count, err = writeFunctionReportsStream(w, func(write func(fr FunctionReports) error) (err error) {
+			duplicates, err = importExports(cmd.Args(), write)
+			return err
+		})
+		return err
+	})
+	if err != nil {
+		log.Fatal("could not import", zap.Error(err))
+	}
+	log.Info("import complete", zap.Int("files", cmd.NArg()), zap.Int("functions", count), zap.Int("duplicateReports", duplicates), zap.String("filename", *output))
+}
+
+// importExports reads the exports, and calls write with each function. Functions that are only in
+// one export are written as they're read. Functions that are in several exports, e.g. from
+// different windows, are merged in the same way as the merge command, so they're held in memory
+// until all of their parts have been read. To find them, the exports are read twice, so stdin can
+// only be read on its own.
+func importExports(fileNames []string, write func(fr FunctionReports) error) (duplicates int, err error) {
+	readFile := func(fileName string, f func(fr FunctionReports) error) error {
+		r, err := openInput(fileName)
+		if err != nil {
+			return fmt.Errorf("could not open %q: %w", fileName, err)
+		}
+		defer r.Close()
+		if err = readExport(r, f); err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+		return nil
+	}
+	if len(fileNames) == 1 {
+		return 0, readFile(fileNames[0], write)
+	}
+	key := func(fr FunctionReports) string {
+		return fr.Account + "/" + fr.Region + "/" + fr.Name
+	}
+	parts := map[string]int{}
+	for _, fileName := range fileNames {
+		if fileName == "-" {
+			return 0, fmt.Errorf("importExports: stdin can't be imported with other exports")
+		}
+		err = readFile(fileName, func(fr FunctionReports) error {
+			parts[key(fr)]++
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	pending := map[string][][]FunctionReports{}
+	for _, fileName := range fileNames {
+		err = readFile(fileName, func(fr FunctionReports) error {
+			k := key(fr)
+			if parts[k] == 1 {
+				return write(fr)
+			}
+			pending[k] = append(pending[k], []FunctionReports{fr})
+			if len(pending[k]) < parts[k] {
+				return nil
+			}
+			merged, d := mergeFunctionReports(pending[k]...)
+			delete(pending, k)
+			duplicates += d
+			return write(merged[0])
+		})
+		if err != nil {
+			return duplicates, err
+		}
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Windows shorter than a week, where at least this proportion of the time is at the weekend,
+// aren't representative of a month.
+const weekendWindowProportion = 0.5
+
+// Windows where daily invocations are below this proportion of the baseline are a traffic trough.
+const lowTrafficProportion = 0.5
+
+// reportWindow returns the earliest start and latest end of the functions' windows.
+func reportWindow(reportContent []FunctionReports) (start, end time.Time) {
+	for _, fr := range reportContent {
+		if fr.Start.IsZero() || fr.End.IsZero() {
+			continue
+		}
+		if start.IsZero() || fr.Start.Before(start) {
+			start = fr.Start
+		}
+		if fr.End.After(end) {
+			end = fr.End
+		}
+	}
+	return start, end
+}
+
+// weekendProportion is the proportion of the window that's on a Saturday or Sunday (UTC).
+func weekendProportion(start, end time.Time) float64 {
+	window := end.Sub(start)
+	if window <= 0 {
+		return 0
+	}
+	var weekend time.Duration
+	for t := start.UTC(); t.Before(end); {
+		next := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		if next.After(end) {
+			next = end
+		}
+		if d := t.Weekday(); d == time.Saturday || d == time.Sunday {
+			weekend += next.Sub(t)
+		}
+		t = next
+	}
+	return float64(weekend) / float64(window)
+}
+
+// dailyInvocations is the total invocations per day across the functions.
+func dailyInvocations(reportContent []FunctionReports) (total float64) {
+	for _, fr := range reportContent {
+		if fr.LogGroupMissing || fr.PreselectionSkipped {
+			continue
+		}
+		total += fr.DailyInvocations()
+	}
+	return total
+}
+
+// extrapolationWarnings returns the reasons that monthly costs, which are extrapolated from the
+// window, may be misleading. The window is checked for weekends, and compared with the baseline
+// if there is one.
+func extrapolationWarnings(reportContent []FunctionReports, opts reportOptions) (warnings []string) {
+	if opts.LowTraffic {
+		warnings = append(warnings, "the window was marked as a traffic trough with -low-traffic")
+	}
+	start, end := reportWindow(reportContent)
+	if !start.IsZero() && end.Sub(start) < 7*24*time.Hour {
+		if p := weekendProportion(start, end); p >= weekendWindowProportion {
+			warnings = append(warnings, fmt.Sprintf("%.0f%% of the window (%s to %s) is at the weekend", p*100, start.UTC().Format("Mon 2006-01-02 15:04"), end.UTC().Format("Mon 2006-01-02 15:04")))
+		}
+	}
+	if len(opts.Baseline) > 0 {
+		current, baseline := dailyInvocations(reportContent), dailyInvocations(opts.Baseline)
+		if baseline > 0 && current < baseline*lowTrafficProportion {
+			warnings = append(warnings, fmt.Sprintf("daily invocations are %.0f%% of the baseline", current/baseline*100))
+		}
+	}
+	return warnings
+}
+
+func displayExtrapolationWarnings(w io.Writer, warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Low confidence: monthly costs are extrapolated from the window, which may not be typical")
+	fmt.Fprintln(w)
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "  %s\n", warning)
+	}
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// fakeAccountID is the account that the fake AWS API returns from GetCallerIdentity.
+const fakeAccountID = "123456789012"
+
+// fakeFunction is a function in the fake AWS API, which logs a REPORT line for each invocation.
+type fakeFunction struct {
+	Name         string
+	MemorySize   int32
+	Architecture string
+	// Invocations is the number of REPORT lines, and the value of the Invocations metric.
+	Invocations int
+	// BilledDurationMS of each invocation.
+	BilledDurationMS int
+	// ProvisionedConcurrency is allocated to the live alias.
+	ProvisionedConcurrency int32
+	Tags                   map[string]string
+	// NoLogGroup is set if the function has never logged.
+	NoLogGroup bool
+	// Layers are the ARNs of the function's layers.
+	Layers []string
+	// Messages are the log messages of each invocation, instead of generated REPORT lines. If
+	// set, Invocations is the number of messages.
+	Messages []string
+}
+
+// fakeAWS is an in-process fake of the AWS APIs used to collect report data, so that collection
+// can be tested without AWS credentials or network access. Requests that the SDK retries reach
+// the fake once for each attempt, so the number of requests of each operation is counted, in the
+// same way as scanStats.
+type fakeAWS struct {
+	Region    string
+	Functions []fakeFunction
+	// PageSize is the number of log events in each FilterLogEvents page.
+	PageSize int
+	// Now is the time that the functions were invoked.
+	Now time.Time
+	// OtherRegions are the other regions that DescribeRegions returns as enabled, and the number
+	// of functions that GetAccountSettings returns in each.
+	OtherRegions map[string]int64
+	// RegionErrors are the error codes that GetAccountSettings returns in regions.
+	RegionErrors map[string]string
+	// MetricErrors are the error codes that GetMetricStatistics returns for metrics, by name.
+	MetricErrors map[string]string
+	// BeforeServe is called before each request is served, e.g. to delay requests past a
+	// deadline.
+	BeforeServe func(operation string)
+
+	server   *httptest.Server
+	m        sync.Mutex
+	requests map[string]int
+	// queries are the results of each Logs Insights query, by query ID.
+	queries [][]any
+	items   map[string][]map[string]map[string]any
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func newFakeAWS(t *testing.T, region string, functions ...fakeFunction) *fakeAWS {
+	t.Helper()
+	f := &fakeAWS{
+		Region:    region,
+		Functions: append([]fakeFunction(nil), functions...),
+		PageSize:  10,
+		Now:       time.Now().Add(-time.Hour),
+		requests:  map[string]int{},
+		items:     map[string][]map[string]map[string]any{},
+		objects:   map[string][]byte{},
+		uploads:   map[string]map[int][]byte{},
+	}
+	for i, fn := range f.Functions {
+		if len(fn.Messages) > 0 {
+			f.Functions[i].Invocations = len(fn.Messages)
+		}
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// Config returns an AWS config that calls the fake, with retries that don't wait, so that
+// retried attempts don't slow the tests down.
+func (f *fakeAWS) Config() aws.Config {
+	return aws.Config{
+		Region:                      f.Region,
+		Credentials:                 credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+		EndpointResolverWithOptions: endpointResolver(f.server.URL),
+		Retryer: func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.MaxAttempts = 20
+				o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+				o.RateLimiter = ratelimit.NewTokenRateLimit(1000000)
+			})
+		},
+	}
+}
+
+// Requests returns the number of requests of each operation, keyed in the same way as
+// scanStats.APICalls.
+func (f *fakeAWS) Requests() map[string]int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	requests := make(map[string]int, len(f.requests))
+	for k, v := range f.requests {
+		requests[k] = v
+	}
+	return requests
+}
+
+func (f *fakeAWS) count(operation string) {
+	f.m.Lock()
+	f.requests[operation]++
+	f.m.Unlock()
+	if f.BeforeServe != nil {
+		f.BeforeServe(operation)
+	}
+}
+
+func (f *fakeAWS) function(name string) (fn fakeFunction, ok bool) {
+	name = strings.TrimPrefix(name, "/aws/lambda/")
+	for _, fn := range f.Functions {
+		if fn.Name == name {
+			return fn, true
+		}
+	}
+	return fn, false
+}
+
+func (f *fakeAWS) arn(name string) string {
+	return fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", f.Region, fakeAccountID, name)
+}
+
+// reportMessage is the REPORT line of the invocation.
+func (fn fakeFunction) reportMessage(i int) string {
+	if len(fn.Messages) > 0 {
+		return fn.Messages[i]
+	}
+	return fmt.Sprintf("REPORT RequestId: %s-%08d\tDuration: %d.00 ms\tBilled Duration: %d ms\tMemory Size: %d MB\tMax Memory Used: %d MB\t\n",
+		fn.Name, i, fn.BilledDurationMS, fn.BilledDurationMS, fn.MemorySize, fn.MemorySize/2)
+}
+
+func (f *fakeAWS) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		service, operation, _ := strings.Cut(target, ".")
+		switch service {
+		case "Logs_20140328":
+			f.count("CloudWatch Logs:" + operation)
+			f.serveLogs(w, operation, body)
+		case "DynamoDB_20120810":
+			f.count("DynamoDB:" + operation)
+			f.serveDynamoDB(w, operation, body)
+		case "AnyScaleFrontendService":
+			f.count("Application Auto Scaling:" + operation)
+			writeFakeJSON(w, map[string]any{"ScheduledActions": []any{}})
+		default:
+			fakeError(w, http.StatusBadRequest, "UnknownOperationException", target)
+		}
+		return
+	}
+	if requestService(r) == "s3" {
+		f.serveS3(w, r, body)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/20") {
+		f.serveLambda(w, r)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch action := form.Get("Action"); action {
+	case "GetCallerIdentity":
+		f.count("STS:" + action)
+		fmt.Fprintf(w, `<GetCallerIdentityResponse><GetCallerIdentityResult><Arn>arn:aws:iam::%[1]s:user/test</Arn><UserId>test</UserId><Account>%[1]s</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`, fakeAccountID)
+	case "GetMetricStatistics":
+		f.count("CloudWatch:" + action)
+		f.serveMetricStatistics(w, form)
+	case "DescribeRegions":
+		f.count("EC2:" + action)
+		var items strings.Builder
+		for _, region := range append([]string{f.Region}, mapKeys(f.OtherRegions)...) {
+			fmt.Fprintf(&items, "<item><regionName>%s</regionName><optInStatus>opt-in-not-required</optInStatus></item>", region)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<DescribeRegionsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><regionInfo>%s</regionInfo></DescribeRegionsResponse>`, items.String())
+	default:
+		fakeError(w, http.StatusBadRequest, "InvalidAction", action)
+	}
+}
+
+func (f *fakeAWS) serveLambda(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "functions":
+		f.count("Lambda:ListFunctions")
+		functions := make([]map[string]any, len(f.Functions))
+		for i, fn := range f.Functions {
+			functions[i] = map[string]any{
+				"FunctionName":  fn.Name,
+				"FunctionArn":   f.arn(fn.Name),
+				"MemorySize":    fn.MemorySize,
+				"Timeout":       30,
+				"Runtime":       "provided.al2023",
+				"Architectures": []string{fn.Architecture},
+			}
+			var layers []map[string]any
+			for _, arn := range fn.Layers {
+				layers = append(layers, map[string]any{"Arn": arn, "CodeSize": 1024})
+			}
+			functions[i]["Layers"] = layers
+		}
+		writeFakeJSON(w, map[string]any{"Functions": functions})
+	case len(parts) == 2 && parts[1] == "account-settings":
+		f.count("Lambda:GetAccountSettings")
+		region := requestRegion(r)
+		if code, ok := f.RegionErrors[region]; ok {
+			status := http.StatusBadRequest
+			if code == "AccessDeniedException" || code == "UnrecognizedClientException" {
+				status = http.StatusForbidden
+			}
+			fakeError(w, status, code, "region "+region)
+			return
+		}
+		count := int64(len(f.Functions))
+		if region != f.Region {
+			count = f.OtherRegions[region]
+		}
+		writeFakeJSON(w, map[string]any{"AccountUsage": map[string]any{"FunctionCount": count}})
+	case len(parts) == 4 && parts[3] == "provisioned-concurrency":
+		f.count("Lambda:ListProvisionedConcurrencyConfigs")
+		configs := []map[string]any{}
+		if fn, ok := f.function(parts[2]); ok && fn.ProvisionedConcurrency > 0 {
+			configs = append(configs, map[string]any{
+				"FunctionArn": f.arn(fn.Name) + ":live",
+				"AllocatedProvisionedConcurrentExecutions": fn.ProvisionedConcurrency,
+				"RequestedProvisionedConcurrentExecutions": fn.ProvisionedConcurrency,
+				"Status": "READY",
+			})
+		}
+		writeFakeJSON(w, map[string]any{"ProvisionedConcurrencyConfigs": configs})
+	case len(parts) == 3 && parts[1] == "tags":
+		f.count("Lambda:ListTags")
+		arn, _ := url.PathUnescape(parts[2])
+		fn, _ := f.function(arn[strings.LastIndex(arn, ":")+1:])
+		tags := fn.Tags
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		writeFakeJSON(w, map[string]any{"Tags": tags})
+	default:
+		fakeError(w, http.StatusNotFound, "ResourceNotFoundException", r.URL.Path)
+	}
+}
+
+func (f *fakeAWS) serveLogs(w http.ResponseWriter, operation string, body []byte) {
+	var in struct {
+		LogGroupName       string `json:"logGroupName"`
+		LogGroupNamePrefix string `json:"logGroupNamePrefix"`
+		NextToken          string `json:"nextToken"`
+		StartTime          int64  `json:"startTime"`
+		EndTime            int64  `json:"endTime"`
+		Limit              int    `json:"limit"`
+		QueryID            string `json:"queryId"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		fakeError(w, http.StatusBadRequest, "InvalidParameterException", err.Error())
+		return
+	}
+	switch operation {
+	case "DescribeLogGroups":
+		groups := []map[string]any{}
+		if fn, ok := f.function(in.LogGroupNamePrefix); ok && !fn.NoLogGroup {
+			groups = append(groups, map[string]any{
+				"logGroupName":    in.LogGroupNamePrefix,
+				"retentionInDays": 30,
+				"storedBytes":     fn.Invocations * 200,
+			})
+		}
+		writeFakeJSON(w, map[string]any{"logGroups": groups})
+	case "DescribeSubscriptionFilters":
+		writeFakeJSON(w, map[string]any{"subscriptionFilters": []any{}})
+	case "FilterLogEvents":
+		fn, ok := f.function(in.LogGroupName)
+		if !ok || fn.NoLogGroup {
+			fakeError(w, http.StatusBadRequest, "ResourceNotFoundException", "The specified log group does not exist.")
+			return
+		}
+		start, _ := strconv.Atoi(in.NextToken)
+		end := start + f.PageSize
+		if end > fn.Invocations {
+			end = fn.Invocations
+		}
+		events := []map[string]any{}
+		for i := start; i < end; i++ {
+			events = append(events, map[string]any{
+				"timestamp":     f.Now.Add(time.Duration(i) * time.Second).UnixMilli(),
+				"logStreamName": "2026/01/01/[$LATEST]0123456789abcdef",
+				"message":       fn.reportMessage(i),
+			})
+		}
+		out := map[string]any{"events": events}
+		if end < fn.Invocations {
+			out["nextToken"] = strconv.Itoa(end)
+		}
+		writeFakeJSON(w, out)
+	case "StartQuery":
+		fn, ok := f.function(in.LogGroupName)
+		if !ok || fn.NoLogGroup {
+			fakeError(w, http.StatusBadRequest, "ResourceNotFoundException", "The specified log group does not exist.")
+			return
+		}
+		// Logs Insights includes events in the start and end seconds.
+		results := []any{}
+		for i := 0; i < fn.Invocations && len(results) < in.Limit; i++ {
+			ts := f.Now.Add(time.Duration(i) * time.Second)
+			if ts.Unix() < in.StartTime || ts.Unix() > in.EndTime {
+				continue
+			}
+			results = append(results, []map[string]string{
+				{"field": "@timestamp", "value": ts.UTC().Format(insightsTimestampFormat)},
+				{"field": "@message", "value": fn.reportMessage(i)},
+			})
+		}
+		f.m.Lock()
+		f.queries = append(f.queries, results)
+		id := strconv.Itoa(len(f.queries) - 1)
+		f.m.Unlock()
+		writeFakeJSON(w, map[string]any{"queryId": id})
+	case "GetQueryResults":
+		index, _ := strconv.Atoi(in.QueryID)
+		f.m.Lock()
+		results := f.queries[index]
+		f.m.Unlock()
+		writeFakeJSON(w, map[string]any{"status": "Complete", "results": results})
+	default:
+		fakeError(w, http.StatusBadRequest, "UnknownOperationException", operation)
+	}
+}
+
+// Items returns the items written to the DynamoDB table, with each attribute in the DynamoDB JSON
+// format, e.g. {"S": "value"}.
+func (f *fakeAWS) Items(tableName string) []map[string]map[string]any {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return append([]map[string]map[string]any{}, f.items[tableName]...)
+}
+
+// serveDynamoDB supports PutItem, with the attribute_not_exists condition used by the audit log.
+func (f *fakeAWS) serveDynamoDB(w http.ResponseWriter, operation string, body []byte) {
+	var in struct {
+		TableName           string                    `json:"TableName"`
+		Item                map[string]map[string]any `json:"Item"`
+		ConditionExpression string                    `json:"ConditionExpression"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil || operation != "PutItem" {
+		fakeError(w, http.StatusBadRequest, "ValidationException", fmt.Sprintf("unsupported request %s: %v", operation, err))
+		return
+	}
+	f.m.Lock()
+	defer f.m.Unlock()
+	if strings.HasPrefix(in.ConditionExpression, "attribute_not_exists(") {
+		attribute := strings.TrimSuffix(strings.TrimPrefix(in.ConditionExpression, "attribute_not_exists("), ")")
+		for _, item := range f.items[in.TableName] {
+			if fmt.Sprint(item[attribute]) == fmt.Sprint(in.Item[attribute]) {
+				fakeError(w, http.StatusBadRequest, "ConditionalCheckFailedException", "The conditional request failed")
+				return
+			}
+		}
+	}
+	f.items[in.TableName] = append(f.items[in.TableName], in.Item)
+	writeFakeJSON(w, map[string]any{})
+}
+
+// PutObject adds an object to the fake S3, e.g. report data for a test to read.
+func (f *fakeAWS) PutObject(bucket, key string, body []byte) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.objects[bucket+"/"+key] = body
+}
+
+// Object returns an object written to the fake S3.
+func (f *fakeAWS) Object(bucket, key string) (body []byte, ok bool) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	body, ok = f.objects[bucket+"/"+key]
+	return body, ok
+}
+
+// Uploads is the number of multipart uploads that haven't been completed or aborted.
+func (f *fakeAWS) Uploads() int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return len(f.uploads)
+}
+
+// serveS3 supports the operations used by the pipeline, using path style requests, e.g.
+// /bucket/key. Multipart upload IDs are the bucket and key.
+func (f *fakeAWS) serveS3(w http.ResponseWriter, r *http.Request, body []byte) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	bucket, _, _ := strings.Cut(path, "/")
+	operation := "S3:" + r.Method
+	defer func() { f.count(operation) }()
+	f.m.Lock()
+	defer f.m.Unlock()
+	w.Header().Set("Content-Type", "application/xml")
+	switch {
+	case r.Method == http.MethodGet && query.Get("list-type") == "2":
+		operation = "S3:ListObjectsV2"
+		var contents strings.Builder
+		for _, key := range sortedKeys(f.objects) {
+			if k := strings.TrimPrefix(key, bucket+"/"); k != key && strings.HasPrefix(k, query.Get("prefix")) {
+				fmt.Fprintf(&contents, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", k, len(f.objects[key]))
+			}
+		}
+		fmt.Fprintf(w, `<ListBucketResult><Name>%s</Name><IsTruncated>false</IsTruncated>%s</ListBucketResult>`, bucket, contents.String())
+	case r.Method == http.MethodGet:
+		operation = "S3:GetObject"
+		object, ok := f.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(object)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		operation = "S3:CreateMultipartUpload"
+		f.uploads[path] = map[int][]byte{}
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, path)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		operation = "S3:UploadPart"
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][partNumber] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, partNumber))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		operation = "S3:CompleteMultipartUpload"
+		parts := f.uploads[query.Get("uploadId")]
+		var object []byte
+		for i := 1; i <= len(parts); i++ {
+			object = append(object, parts[i]...)
+		}
+		f.objects[path] = object
+		delete(f.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key></CompleteMultipartUploadResult>`, bucket, path)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		operation = "S3:AbortMultipartUpload"
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		operation = "S3:PutObject"
+		f.objects[path] = body
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<Error><Code>NotImplemented</Code><Message>%s %s</Message></Error>`, r.Method, r.URL)
+	}
+}
+
+// serveMetricStatistics returns the Invocations metric of the function as a single datapoint.
+// Other metrics are zero, apart from those in MetricErrors, which fail.
+func (f *fakeAWS) serveMetricStatistics(w http.ResponseWriter, form url.Values) {
+	if code, ok := f.MetricErrors[form.Get("MetricName")]; ok {
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>metric %s</Message></Error></ErrorResponse>`, code, form.Get("MetricName"))
+		return
+	}
+	var sum int
+	for i := 1; form.Has(fmt.Sprintf("Dimensions.member.%d.Name", i)); i++ {
+		fn, ok := f.function(form.Get(fmt.Sprintf("Dimensions.member.%d.Value", i)))
+		if ok && form.Get("MetricName") == "Invocations" {
+			sum = fn.Invocations
+		}
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<GetMetricStatisticsResponse><GetMetricStatisticsResult><Label>%s</Label><Datapoints><member><Timestamp>%s</Timestamp><Sum>%d</Sum></member></Datapoints></GetMetricStatisticsResult></GetMetricStatisticsResponse>`,
+		form.Get("MetricName"), f.Now.UTC().Format(time.RFC3339), sum)
+}
+
+// requestRegion returns the region that the request was signed for, since every region's client
+// calls the same fake.
+func requestRegion(r *http.Request) string {
+	_, credential, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	scope := strings.Split(credential, "/")
+	if len(scope) < 3 {
+		return ""
+	}
+	return scope[2]
+}
+
+// requestService returns the service that the request was signed for, e.g. s3.
+func requestService(r *http.Request) string {
+	_, credential, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	scope := strings.Split(credential, "/")
+	if len(scope) < 4 {
+		return ""
+	}
+	return scope[3]
+}
+
+func sortedKeys[V any](m map[string]V) (keys []string) {
+	keys = mapKeys(m)
+	sort.Strings(keys)
+	return keys
+}
+
+func mapKeys[V any](m map[string]V) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func writeFakeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func fakeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Amzn-ErrorType", code)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"__type": code, "message": message})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	"go.uber.org/zap"
+)
+
+// hiddenFlags aren't listed in the usage, since they're for testing the program, not for reports.
+var hiddenFlags = map[string]bool{"fault-inject": true}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		visible.SetOutput(flag.CommandLine.Output())
+		flag.VisitAll(func(f *flag.Flag) {
+			if !hiddenFlags[f.Name] {
+				visible.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+		visible.PrintDefaults()
+	}
+}
+
+// Faults that can be injected into AWS API calls.
+const (
+	// faultThrottle fails request attempts with a throttling error, which the SDK retries.
+	faultThrottle = "throttle"
+	// faultPartial cuts short pages of log events, so that invocations are missing.
+	faultPartial = "partial"
+	// faultMalformed corrupts log events, e.g. truncating REPORT lines or adding invalid UTF-8.
+	faultMalformed = "malformed"
+)
+
+// faultInjector injects faults into AWS API calls, at the given probability of each fault, to
+// check that retries, partial data and malformed events are handled before runs are scheduled.
+// Faults are chosen by a seeded random number generator, so that runs can be repeated.
+type faultInjector struct {
+	Probabilities map[string]float64
+	m             sync.Mutex
+	rand          *rand.Rand
+	Injected      map[string]int
+}
+
+// parseFaultInjection parses a comma separated list of faults and their probabilities, with an
+// optional seed, e.g. throttle=0.2,partial=0.05,malformed=0.01,seed=42.
+func parseFaultInjection(s string) (fi *faultInjector, err error) {
+	fi = &faultInjector{Probabilities: map[string]float64{}, Injected: map[string]int{}}
+	seed := int64(1)
+	for _, part := range splitList(s) {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("parseFaultInjection: expected name=value, got %q", part)
+		}
+		if name == "seed" {
+			if seed, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("parseFaultInjection: invalid seed %q", value)
+			}
+			continue
+		}
+		if name != faultThrottle && name != faultPartial && name != faultMalformed {
+			return nil, fmt.Errorf("parseFaultInjection: unknown fault %q, expected %s, %s or %s", name, faultThrottle, faultPartial, faultMalformed)
+		}
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("parseFaultInjection: %s: probability must be between 0 and 1, got %q", name, value)
+		}
+		fi.Probabilities[name] = p
+	}
+	if len(fi.Probabilities) == 0 {
+		return nil, fmt.Errorf("parseFaultInjection: no faults in %q", s)
+	}
+	fi.rand = rand.New(rand.NewSource(seed))
+	return fi, nil
+}
+
+// inject returns true if the fault should be injected, and counts it.
+func (fi *faultInjector) inject(fault string) bool {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+	if fi.rand.Float64() >= fi.Probabilities[fault] {
+		return false
+	}
+	fi.Injected[fault]++
+	return true
+}
+
+// intn returns a random number in [0, n).
+func (fi *faultInjector) intn(n int) int {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+	return fi.rand.Intn(n)
+}
+
+// addMiddleware is an SDK option that adds the fault injection middleware.
+func (fi *faultInjector) addMiddleware(stack *middleware.Stack) error {
+	// Throttling is injected after the retry middleware, so that each attempt can fail.
+	err := stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("FaultInjectThrottle", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		if fi.inject(faultThrottle) {
+			return middleware.FinalizeOutput{}, middleware.Metadata{}, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded (injected fault)", Fault: smithy.FaultClient}
+		}
+		return next.HandleFinalize(ctx, in)
+	}), middleware.After)
+	if err != nil {
+		return err
+	}
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("FaultInjectLogEvents", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		if page, ok := out.Result.(*cloudwatchlogs.FilterLogEventsOutput); ok && err == nil {
+			fi.corruptLogEvents(page)
+		}
+		return out, metadata, err
+	}), middleware.Before)
+}
+
+// corruptLogEvents cuts the page short, or corrupts its events.
+func (fi *faultInjector) corruptLogEvents(page *cloudwatchlogs.FilterLogEventsOutput) {
+	if len(page.Events) > 0 && fi.inject(faultPartial) {
+		page.Events = page.Events[:fi.intn(len(page.Events))]
+	}
+	for i := range page.Events {
+		if page.Events[i].Message == nil || !fi.inject(faultMalformed) {
+			continue
+		}
+		message := *page.Events[i].Message
+		switch fi.intn(3) {
+		case 0:
+			// Truncated, e.g. by a log forwarder with a line length limit.
+			message = message[:fi.intn(len(message)+1)]
+		case 1:
+			// Invalid UTF-8.
+			message += "\xff\xfe"
+		case 2:
+			// Multi-line event, with other output before the REPORT line.
+			message = "panic: injected fault\n\ngoroutine 1 [running]:\n" + message
+		}
+		page.Events[i].Message = aws.String(message)
+	}
+}
+
+// logInjected logs the number of each fault that was injected.
+func (fi *faultInjector) logInjected(log *zap.Logger) {
+	fi.m.Lock()
+	defer fi.m.Unlock()
+	faults := make([]string, 0, len(fi.Probabilities))
+	for fault := range fi.Probabilities {
+		faults = append(faults, fault)
+	}
+	sort.Strings(faults)
+	fields := make([]zap.Field, len(faults))
+	for i, fault := range faults {
+		fields[i] = zap.Int(fault, fi.Injected[fault])
+	}
+	log.Warn("injected faults", fields...)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// faultTestFunctions have enough invocations to span several pages of log events.
+var faultTestFunctions = []fakeFunction{
+	{Name: "api", MemorySize: 1024, Architecture: "x86_64", Invocations: 95, BilledDurationMS: 120},
+	{Name: "worker", MemorySize: 512, Architecture: "arm64", Invocations: 60, BilledDurationMS: 900, ProvisionedConcurrency: 2},
+	{Name: "cron", MemorySize: 128, Architecture: "x86_64", Invocations: 7, BilledDurationMS: 15},
+}
+
+// collectFromFake collects the report data of the fake's functions, with the faults injected
+// into every AWS client, in the same way as a scan.
+func collectFromFake(t *testing.T, fake *fakeAWS, faults *faultInjector) (functionReports []FunctionReports, stats *scanStats, quarantineFile string) {
+	t.Helper()
+	stats = &scanStats{}
+	cfg := fake.Config()
+	cfg.APIOptions = append(cfg.APIOptions, stats.countAPICalls)
+	if faults != nil {
+		cfg.APIOptions = append(cfg.APIOptions, faults.addMiddleware)
+	}
+	quarantineFile = filepath.Join(t.TempDir(), "quarantine.jsonl")
+	functionReports, err := getFunctionReports(context.Background(), zap.NewNop(), cfg, stats, fakeAccountID, "test", collectOptions{
+		Window:         24 * time.Hour,
+		Collector:      collectorAuto,
+		QuarantineFile: quarantineFile,
+	})
+	if err != nil {
+		t.Fatalf("failed to collect: %v", err)
+	}
+	if len(functionReports) != len(fake.Functions) {
+		t.Fatalf("expected %d functions, got %d", len(fake.Functions), len(functionReports))
+	}
+	return functionReports, stats, quarantineFile
+}
+
+func mustParseFaultInjection(t *testing.T, s string) *faultInjector {
+	t.Helper()
+	faults, err := parseFaultInjection(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return faults
+}
+
+func readQuarantine(t *testing.T, fileName string) (entries []QuarantineEntry) {
+	t.Helper()
+	f, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e QuarantineEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid quarantine entry %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestCollectWithoutFaults(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+	functionReports, stats, quarantineFile := collectFromFake(t, fake, nil)
+	for i, fr := range functionReports {
+		expected := fake.Functions[i]
+		if fr.Name != expected.Name {
+			t.Fatalf("expected function %q, got %q", expected.Name, fr.Name)
+		}
+		if len(fr.Reports) != expected.Invocations {
+			t.Errorf("%s: expected %d reports, got %d", fr.Name, expected.Invocations, len(fr.Reports))
+		}
+		if len(fr.Errors) > 0 || fr.Incomplete || fr.InvocationMismatch(0) {
+			t.Errorf("%s: expected complete data, got errors %v, incomplete %v, mismatch %v", fr.Name, fr.Errors, fr.Incomplete, fr.InvocationMismatch(0))
+		}
+	}
+	if got := functionReports[1].ProvisionedConcurrency; got != 2 {
+		t.Errorf("expected provisioned concurrency of 2, got %d", got)
+	}
+	if !reflect.DeepEqual(fake.Requests(), stats.APICalls) {
+		t.Errorf("expected the stats to count every request\nrequests: %v\nstats:    %v", fake.Requests(), stats.APICalls)
+	}
+	if entries := readQuarantine(t, quarantineFile); len(entries) > 0 {
+		t.Errorf("expected nothing to be quarantined, got %v", entries)
+	}
+}
+
+func TestFaultInjectionThrottlingIsRetried(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+	faults := mustParseFaultInjection(t, "throttle=0.3,seed=42")
+	functionReports, stats, _ := collectFromFake(t, fake, faults)
+	if faults.Injected[faultThrottle] == 0 {
+		t.Fatal("expected throttling to be injected")
+	}
+	for i, fr := range functionReports {
+		if len(fr.Reports) != fake.Functions[i].Invocations || len(fr.Errors) > 0 {
+			t.Errorf("%s: expected throttled requests to be retried, got %d reports and errors %v", fr.Name, len(fr.Reports), fr.Errors)
+		}
+	}
+	// Throttled attempts don't reach the fake, so the difference between the attempts that were
+	// counted and the requests that were served is the number of injected faults. Every
+	// operation must be counted, so that no client bypasses the middleware.
+	requests := fake.Requests()
+	var served, attempts int
+	for operation, count := range requests {
+		if stats.APICalls[operation] < count {
+			t.Errorf("%s: %d requests were served, but only %d attempts were counted", operation, count, stats.APICalls[operation])
+		}
+		served += count
+	}
+	for operation, count := range stats.APICalls {
+		if requests[operation] == 0 {
+			t.Errorf("%s: counted, but never served", operation)
+		}
+		attempts += count
+	}
+	if attempts-served != faults.Injected[faultThrottle] {
+		t.Errorf("expected %d throttled attempts, got %d", faults.Injected[faultThrottle], attempts-served)
+	}
+	if requests["Application Auto Scaling:DescribeScheduledActions"] == 0 {
+		t.Error("expected provisioned concurrency schedules to be listed")
+	}
+}
+
+func TestFaultInjectionPartialPagesAreFlagged(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+	faults := mustParseFaultInjection(t, "partial=1,seed=7")
+	functionReports, _, _ := collectFromFake(t, fake, faults)
+	if faults.Injected[faultPartial] == 0 {
+		t.Fatal("expected partial pages to be injected")
+	}
+	for i, fr := range functionReports {
+		if len(fr.Reports) >= fake.Functions[i].Invocations {
+			t.Errorf("%s: expected invocations to be missing, got %d of %d", fr.Name, len(fr.Reports), fake.Functions[i].Invocations)
+		}
+		if !fr.InvocationMismatch(0) {
+			t.Errorf("%s: expected the invocation count to be flagged as mismatched", fr.Name)
+		}
+		if fr.costRelativeError() <= 0 {
+			t.Errorf("%s: expected missing invocations to reduce confidence", fr.Name)
+		}
+	}
+}
+
+func TestFaultInjectionMalformedEventsAreQuarantined(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+	faults := mustParseFaultInjection(t, "malformed=0.5,seed=3")
+	functionReports, _, quarantineFile := collectFromFake(t, fake, faults)
+	if faults.Injected[faultMalformed] == 0 {
+		t.Fatal("expected malformed events to be injected")
+	}
+	entries := readQuarantine(t, quarantineFile)
+	if len(entries) == 0 {
+		t.Fatal("expected malformed REPORT lines to be quarantined")
+	}
+	quarantined := map[string]int{}
+	for _, e := range entries {
+		if e.Region != "eu-west-1" || e.Error == "" || e.Line == "" {
+			t.Errorf("expected the region, error and line to be quarantined, got %+v", e)
+		}
+		quarantined[e.Function]++
+	}
+	for i, fr := range functionReports {
+		if len(fr.Reports)+quarantined[fr.Name] > fake.Functions[i].Invocations {
+			t.Errorf("%s: %d reports and %d quarantined lines is more than the %d invocations", fr.Name, len(fr.Reports), quarantined[fr.Name], fake.Functions[i].Invocations)
+		}
+		if quarantined[fr.Name] == 0 {
+			continue
+		}
+		var parseErrors int
+		for _, e := range fr.Errors {
+			if e.Kind == errorKindParse {
+				parseErrors += e.Count
+			}
+		}
+		if parseErrors != quarantined[fr.Name] {
+			t.Errorf("%s: expected %d parse errors, got %d", fr.Name, quarantined[fr.Name], parseErrors)
+		}
+		if !fr.InvocationMismatch(0) {
+			t.Errorf("%s: expected quarantined lines to be flagged as an invocation count mismatch", fr.Name)
+		}
+	}
+}
+
+func TestFaultInjectionIsRepeatable(t *testing.T) {
+	const faults = "throttle=0.2,partial=0.3,malformed=0.1,seed=99"
+	collect := func() (injected map[string]int, reports []int) {
+		fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+		fi := mustParseFaultInjection(t, faults)
+		functionReports, _, _ := collectFromFake(t, fake, fi)
+		for _, fr := range functionReports {
+			reports = append(reports, len(fr.Reports))
+		}
+		return fi.Injected, reports
+	}
+	injected1, reports1 := collect()
+	injected2, reports2 := collect()
+	if !reflect.DeepEqual(injected1, injected2) || !reflect.DeepEqual(reports1, reports2) {
+		t.Errorf("expected the same seed to inject the same faults, got %v %v and %v %v", injected1, reports1, injected2, reports2)
+	}
+}
+
+func TestParseFaultInjection(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]float64
+		err      bool
+	}{
+		{name: "faults and seed", input: "throttle=0.2,partial=0.05,malformed=0.01,seed=42", expected: map[string]float64{faultThrottle: 0.2, faultPartial: 0.05, faultMalformed: 0.01}},
+		{name: "single fault", input: "throttle=1", expected: map[string]float64{faultThrottle: 1}},
+		{name: "unknown fault", input: "latency=0.1", err: true},
+		{name: "probability above one", input: "throttle=1.5", err: true},
+		{name: "negative probability", input: "partial=-0.1", err: true},
+		{name: "missing value", input: "throttle", err: true},
+		{name: "invalid seed", input: "throttle=0.1,seed=x", err: true},
+		{name: "seed only", input: "seed=1", err: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			faults, err := parseFaultInjection(test.input)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", faults.Probabilities)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.expected, faults.Probabilities) {
+				t.Errorf("expected %v, got %v", test.expected, faults.Probabilities)
+			}
+		})
+	}
+}
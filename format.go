@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units that durations can be displayed in.
+const (
+	// durationUnitAuto uses Go's duration formatting, e.g. 1.365s or 250ms.
+	durationUnitAuto = "auto"
+	durationUnitMS   = "ms"
+	durationUnitS    = "s"
+)
+
+// displayFormat controls how durations, numbers and times are displayed, so that reports can be
+// read outside engineering, e.g. pasted into a spreadsheet that uses a comma decimal separator.
+// The zero value uses Go's duration formatting, and a point as the decimal separator.
+type displayFormat struct {
+	DurationUnit     string
+	DecimalSeparator string
+}
+
+func newDisplayFormat(durationUnit, decimalSeparator string) (f displayFormat, err error) {
+	switch durationUnit {
+	case "", durationUnitAuto, durationUnitMS, durationUnitS:
+	default:
+		return f, fmt.Errorf("newDisplayFormat: unknown duration unit %q, expected auto, ms or s", durationUnit)
+	}
+	switch decimalSeparator {
+	case "", ".", ",":
+	default:
+		return f, fmt.Errorf("newDisplayFormat: unsupported decimal separator %q, expected . or ,", decimalSeparator)
+	}
+	return displayFormat{DurationUnit: durationUnit, DecimalSeparator: decimalSeparator}, nil
+}
+
+// displayFormat returns the display format of the settings, e.g. for recommendation descriptions.
+func (s Settings) displayFormat() displayFormat {
+	return displayFormat{DurationUnit: s.DurationUnit, DecimalSeparator: s.DecimalSeparator}
+}
+
+// Decimal formats a number with the given number of decimal places, or the fewest needed if
+// precision is -1.
+func (f displayFormat) Decimal(v float64, precision int) string {
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	if f.DecimalSeparator != "" && f.DecimalSeparator != "." {
+		s = strings.Replace(s, ".", f.DecimalSeparator, 1)
+	}
+	return s
+}
+
+// SignedDecimal formats a number like Decimal, with a + sign if it isn't negative, e.g. for a change.
+func (f displayFormat) SignedDecimal(v float64, precision int) string {
+	if v >= 0 {
+		return "+" + f.Decimal(v, precision)
+	}
+	return f.Decimal(v, precision)
+}
+
+// Money formats an amount in the currency, e.g. $1.50, or 1.50 CNY in other currencies. Totals
+// in mixed currencies are formatted without a currency.
+func (f displayFormat) Money(v float64, currency string, precision int) string {
+	switch currency {
+	case currencyUSD:
+		return "$" + f.Decimal(v, precision)
+	case currencyMixed:
+		return f.Decimal(v, precision)
+	}
+	return f.Decimal(v, precision) + " " + currency
+}
+
+// MoneyMargin formats an amount in the currency with its margin, e.g. $42.00 ± $9.00.
+func (f displayFormat) MoneyMargin(v, margin float64, currency string, precision int) string {
+	if margin < 0.5*math.Pow10(-precision) {
+		return f.Money(v, currency, precision)
+	}
+	return f.Money(v, currency, precision) + " ± " + f.Money(margin, currency, precision)
+}
+
+// Percent formats a percentage, e.g. 12.5%.
+func (f displayFormat) Percent(v float64, precision int) string {
+	return f.Decimal(v, precision) + "%"
+}
+
+// Duration formats a duration in the configured unit.
+func (f displayFormat) Duration(d time.Duration) string {
+	switch f.DurationUnit {
+	case durationUnitMS:
+		return f.Decimal(float64(d)/float64(time.Millisecond), 2) + " ms"
+	case durationUnitS:
+		return f.Decimal(d.Seconds(), 3) + " s"
+	}
+	s := d.String()
+	if f.DecimalSeparator != "" && f.DecimalSeparator != "." {
+		s = strings.Replace(s, ".", f.DecimalSeparator, 1)
+	}
+	return s
+}
+
+// Time formats a time as an ISO 8601 timestamp in UTC.
+func (f displayFormat) Time(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
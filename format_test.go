@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDisplayReportUsesDecimalSeparator checks that the sections after the table, and the
+// recommendation descriptions, format amounts in the same way as the table.
+func TestDisplayReportUsesDecimalSeparator(t *testing.T) {
+	functionReports := generateDemoReports(1, time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC), 24*time.Hour)
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	of := newOutputFlags(fs)
+	if err := fs.Parse([]string{"-decimal-separator=,", "-required-tags=team", "-no-color"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts, err := of.reportOptions(Settings{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	displayReport(&buf, functionReports, opts)
+	output := buf.String()
+	for _, expected := range []string{"Missing tags", "Layers", "Recommendations", "$0,81"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to include %q", expected)
+		}
+	}
+	if amounts := regexp.MustCompile(`\$\d+\.\d`).FindAllString(output, -1); len(amounts) > 0 {
+		t.Errorf("expected amounts to use a comma decimal separator, got %v in:\n%s", amounts, output)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   displayFormat
+		currency string
+		expected string
+	}{
+		{name: "dollars", currency: currencyUSD, expected: "$1.50"},
+		{name: "other currencies follow the amount", currency: "CNY", expected: "1.50 CNY"},
+		{name: "totals in mixed currencies don't have a currency", currency: currencyMixed, expected: "1.50"},
+		{name: "decimal separator", format: displayFormat{DecimalSeparator: ","}, currency: "CNY", expected: "1,50 CNY"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.format.Money(1.5, test.currency, 2); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReportCellsUseRegionCurrency(t *testing.T) {
+	functionReports := generateDemoReports(1, time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC), 24*time.Hour)
+	fr := functionReports[0]
+	fr.Region = "cn-north-1"
+	recommenders, err := newRecommenders(Settings{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cells := reportCells(fr, reportOptions{Recommenders: recommenders})
+	for i, header := range []string{"Daily", "Monthly", "Monthly arm64 (same RAM)", "Monthly arm64 (optimal RAM)"} {
+		cell := cells[2+i]
+		if strings.HasPrefix(cell, "$") || !strings.HasSuffix(cell, " CNY") {
+			t.Errorf("%s: expected an amount in CNY, got %q", header, cell)
+		}
+	}
+}
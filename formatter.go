@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/a-h/lambdacost/report"
+)
+
+// Formatter writes the report in an output format. Formatters that only need the rows of the
+// report are registered with the report package, so that they can be written outside of this
+// repository.
+type Formatter interface {
+	// Format writes the report for the functions. reportContent includes functions without log
+	// data, and functions skipped by preselection.
+	Format(w io.Writer, reportContent []FunctionReports, opts reportOptions) error
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(w io.Writer, reportContent []FunctionReports, opts reportOptions) error
+
+func (f FormatterFunc) Format(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+	return f(w, reportContent, opts)
+}
+
+var formatters = map[string]Formatter{}
+
+// registerFormatter makes a formatter available by name, for use in the -output flag.
+func registerFormatter(name string, f Formatter) {
+	if _, exists := formatters[name]; exists {
+		panic(fmt.Sprintf("formatter %q is already registered", name))
+	}
+	// Packages are initialised before main, so formats registered with the report package
+	// are known by now.
+	if _, exists := report.Lookup(name); exists {
+		panic(fmt.Sprintf("formatter %q is already registered with the report package", name))
+	}
+	formatters[name] = f
+}
+
+// rowFormatter adapts a formatter registered with the report package, which is given the rows of
+// the report rather than the report data.
+type rowFormatter struct {
+	formatter report.Formatter
+}
+
+func (rf rowFormatter) Format(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+	return rf.formatter.Format(w, reportOutput(reportContent, opts), report.Options{
+		Output:              opts.Output,
+		UseColor:            opts.UseColor,
+		InvocationTolerance: opts.InvocationTolerance,
+	})
+}
+
+// reportOutput returns the window and rows of the report.
+func reportOutput(reportContent []FunctionReports, opts reportOptions) (output ReportOutput) {
+	output.Functions = reportRows(reportContent, opts)
+	if output.Functions == nil {
+		output.Functions = []ReportRow{}
+	}
+	output.Start, output.End = reportWindow(reportContent)
+	return output
+}
+
+// formatterTable is the default, human readable, output format.
+const formatterTable = "table"
+
+func init() {
+	registerFormatter(formatterTable, FormatterFunc(func(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+		displayReport(w, reportContent, opts)
+		return nil
+	}))
+}
+
+func formatterNames() (names []string) {
+	for name := range formatters {
+		names = append(names, name)
+	}
+	names = append(names, report.Names()...)
+	sort.Strings(names)
+	return names
+}
+
+// getFormatter returns the formatter registered with the name.
+func getFormatter(name string) (Formatter, error) {
+	if f, ok := formatters[name]; ok {
+		return f, nil
+	}
+	rf, ok := report.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q, expected one of: %s", name, strings.Join(formatterNames(), ", "))
+	}
+	return rowFormatter{formatter: rf}, nil
+}
+
+// extrasWriter is where anything shown after the report is written. Output formats other than
+// the table are read by programs, so it goes to stderr, and stdout only contains the report.
+func extrasWriter(output string) io.Writer {
+	if output != formatterTable {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// accountRegionCells returns a function that adds the account and region columns to a row, when
+// the report covers more than one account or region.
+func accountRegionCells(reportContent []FunctionReports) func(account, region string, cells []string) []string {
+	multiAccount, multiRegion := multipleAccounts(reportContent), multipleRegions(reportContent)
+	return func(account, region string, cells []string) []string {
+		if multiRegion {
+			cells = append([]string{region}, cells...)
+		}
+		if multiAccount {
+			cells = append([]string{account}, cells...)
+		}
+		return cells
+	}
+}
+
+// multipleAccounts returns true if the report covers more than one account, in which case the
+// account column is shown.
+func multipleAccounts(reportContent []FunctionReports) bool {
+	accounts := map[string]struct{}{}
+	for _, rc := range reportContent {
+		accounts[rc.Account] = struct{}{}
+	}
+	return len(accounts) > 1
+}
+
+// multipleRegions returns true if the report covers more than one region, e.g. when regions are
+// discovered, in which case the region column is shown.
+func multipleRegions(reportContent []FunctionReports) bool {
+	regions := map[string]struct{}{}
+	for _, rc := range reportContent {
+		regions[rc.Region] = struct{}{}
+	}
+	return len(regions) > 1
+}
+
+// reportHeaders are the column headings of the cells returned by reportCells.
+var reportHeaders = []string{
+	"Name",
+	"Arch",
+	"Daily",
+	"Monthly",
+	"Monthly arm64 (same RAM)",
+	"Monthly arm64 (optimal RAM)",
+	"Requests",
+	"Executions (billed)",
+	"Avg Warm Duration",
+	"Avg Cold Duration",
+	"Max Duration",
+	"Max Billed",
+	"RAM Max",
+	"RAM Assigned",
+	"RAM Optimal",
+	"Monthly Savings (arm64 + RAM)",
+	"Data Quality",
+	"Notes",
+}
+
+// ReportRow is a function's row in the report, as numbers rather than display text, for the
+// machine readable output formats. Costs are in the currency of the function's region.
+type ReportRow = report.Row
+
+// reportRows returns the rows of the functions with log data, highest daily cost first.
+func reportRows(reportContent []FunctionReports, opts reportOptions) (rows []ReportRow) {
+	withLogData, _, _ := splitLogData(reportContent)
+	for _, rc := range withLogData {
+		optimisedRAM, optimisedCost := rc.OptimisedCost()
+		savings, margin := rc.MonthlySavings(), rc.MonthlySavingsMargin()
+		rows = append(rows, ReportRow{
+			Account:              rc.Account,
+			AccountName:          rc.AccountName,
+			Region:               rc.Region,
+			Name:                 rc.Name,
+			Architecture:         rc.Architecture,
+			Currency:             priceForRegion(rc.Region).CurrencyCode(),
+			DailyCost:            rc.DailyCost(),
+			MonthlyCost:          rc.DailyCost() * 30,
+			MonthlyCostARM64:     rc.CostForArchitecture(ArchitectureARM64, 0) / rc.Days() * 30,
+			MonthlyCostOptimal:   optimisedCost / rc.Days() * 30,
+			Requests:             rc.UniqueRequests(),
+			Executions:           rc.Executions(),
+			AvgWarmDuration:      rc.AvgWarmDuration(),
+			AvgColdDuration:      rc.AvgColdDuration(),
+			MaxDuration:          rc.MaxDuration(),
+			MaxBilledDuration:    rc.MaxBilledDuration(),
+			DurationHistogram:    rc.DurationHistogram(),
+			MaxMemoryUsed:        rc.MaxMemoryUsed(),
+			MemoryAssigned:       rc.MemoryAssigned(),
+			OptimalMemory:        optimisedRAM,
+			MonthlySavings:       savings,
+			MonthlySavingsMargin: margin,
+			Confidence:           savingsConfidence(savings, margin),
+			DataQuality:          rc.DataQuality(opts.InvocationTolerance),
+			Incomplete:           rc.Incomplete,
+			Notes:                rc.Notes(opts.Format),
+			Errors:               rc.Errors,
+		})
+	}
+	return rows
+}
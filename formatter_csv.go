@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formatterCSV writes a row for each function, with a header row. Durations are in milliseconds.
+const formatterCSV = "csv"
+
+func init() {
+	registerFormatter(formatterCSV, FormatterFunc(formatCSV))
+}
+
+var csvHeaders = []string{
+	"account",
+	"account_name",
+	"region",
+	"name",
+	"architecture",
+	"currency",
+	"daily_cost",
+	"monthly_cost",
+	"monthly_cost_arm64",
+	"monthly_cost_optimal",
+	"requests",
+	"executions",
+	"avg_warm_duration_ms",
+	"avg_cold_duration_ms",
+	"max_duration_ms",
+	"max_billed_duration_ms",
+	"max_memory_used_mb",
+	"memory_assigned_mb",
+	"optimal_memory_mb",
+	"monthly_savings",
+	"monthly_savings_margin",
+	"confidence",
+	"data_quality",
+	"incomplete",
+	"notes",
+}
+
+func formatCSV(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeaders); err != nil {
+		return fmt.Errorf("formatCSV: could not write header: %w", err)
+	}
+	float := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	ms := func(d time.Duration) string {
+		return float(float64(d) / float64(time.Millisecond))
+	}
+	for _, r := range reportRows(reportContent, opts) {
+		record := []string{
+			r.Account,
+			r.AccountName,
+			r.Region,
+			r.Name,
+			string(r.Architecture),
+			r.Currency,
+			float(r.DailyCost),
+			float(r.MonthlyCost),
+			float(r.MonthlyCostARM64),
+			float(r.MonthlyCostOptimal),
+			strconv.Itoa(r.Requests),
+			strconv.Itoa(r.Executions),
+			ms(r.AvgWarmDuration),
+			ms(r.AvgColdDuration),
+			ms(r.MaxDuration),
+			ms(r.MaxBilledDuration),
+			strconv.FormatInt(r.MaxMemoryUsed, 10),
+			strconv.FormatInt(r.MemoryAssigned, 10),
+			strconv.FormatInt(r.OptimalMemory, 10),
+			float(r.MonthlySavings),
+			float(r.MonthlySavingsMargin),
+			r.Confidence,
+			r.DataQuality,
+			strconv.FormatBool(r.Incomplete),
+			strings.Join(r.Notes, "; "),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("formatCSV: could not write row for %q: %w", r.Name, err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("formatCSV: could not write: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/a-h/lambdacost/pricing"
+)
+
+// formatterFOCUS writes the cost of each function in the window as charges in the FinOps FOCUS
+// schema, as CSV, so that it can be loaded by FinOps platforms without custom mapping.
+const formatterFOCUS = "focus"
+
+func init() {
+	registerFormatter(formatterFOCUS, FormatterFunc(formatFOCUS))
+}
+
+// focusColumns are the FOCUS 1.0 columns, followed by custom columns, which FOCUS requires to be
+// prefixed with x_.
+var focusColumns = []string{
+	"BillingAccountId",
+	"BillingAccountName",
+	"BillingCurrency",
+	"BillingPeriodStart",
+	"BillingPeriodEnd",
+	"ChargePeriodStart",
+	"ChargePeriodEnd",
+	"ChargeCategory",
+	"ChargeClass",
+	"ChargeDescription",
+	"ChargeFrequency",
+	"ProviderName",
+	"PublisherName",
+	"InvoiceIssuerName",
+	"ServiceCategory",
+	"ServiceName",
+	"RegionId",
+	"RegionName",
+	"ResourceId",
+	"ResourceName",
+	"ResourceType",
+	"SubAccountId",
+	"SubAccountName",
+	"SkuId",
+	"SkuPriceId",
+	"PricingCategory",
+	"PricingQuantity",
+	"PricingUnit",
+	"ListUnitPrice",
+	"ContractedUnitPrice",
+	"ConsumedQuantity",
+	"ConsumedUnit",
+	"ListCost",
+	"ContractedCost",
+	"BilledCost",
+	"EffectiveCost",
+	"Tags",
+	"x_Architecture",
+	"x_MemorySizeMB",
+	"x_MonthlySavings",
+	"x_Recommendations",
+}
+
+// focusCharge is one of a function's charges in the window, e.g. requests or duration.
+type focusCharge struct {
+	Description string
+	// SkuID is the AWS usage type, without the region prefix, e.g. Lambda-GB-Second-ARM.
+	SkuID     string
+	Quantity  float64
+	Unit      string
+	UnitPrice float64
+	Cost      float64
+}
+
+// focusCharges splits the function's cost in the window into request, duration and provisioned
+// concurrency charges, in the same way as CostBreakdown, so that the charges add up to its cost.
+func focusCharges(fr FunctionReports) (charges []focusCharge) {
+	price := priceForRegion(fr.Region)
+	suffix := ""
+	if fr.Architecture == ArchitectureARM64 {
+		suffix = "-ARM"
+	}
+	invocations := float64(len(fr.Reports))
+	charges = append(charges, focusCharge{
+		Description: "requests",
+		SkuID:       "Request" + suffix,
+		Quantity:    invocations,
+		Unit:        "Requests",
+		UnitPrice:   price.PerMillionRequests / 1000000,
+		Cost:        pricing.Requests(price, invocations),
+	})
+	var gbSeconds, provisionedGBSeconds float64
+	for _, r := range fr.Reports {
+		gbs := pricing.GBSeconds(r.MemorySize, r.BilledDuration)
+		if fr.RanOnProvisionedConcurrency(r) {
+			provisionedGBSeconds += gbs
+			continue
+		}
+		gbSeconds += gbs
+	}
+	charges = append(charges, focusCharge{
+		Description: "duration",
+		SkuID:       "Lambda-GB-Second" + suffix,
+		Quantity:    gbSeconds,
+		Unit:        "GB-Seconds",
+		UnitPrice:   price.GBSecond(fr.Architecture),
+		Cost:        pricing.Duration(price, fr.Architecture, gbSeconds),
+	})
+	if !fr.HasProvisionedConcurrency() {
+		return charges
+	}
+	durationPrice := provisionedConcurrencyDurationGBSecondPrice(fr.Region, fr.Architecture)
+	charges = append(charges, focusCharge{
+		Description: "provisioned concurrency duration",
+		SkuID:       "Lambda-Provisioned-GB-Second" + suffix,
+		Quantity:    provisionedGBSeconds,
+		Unit:        "GB-Seconds",
+		UnitPrice:   durationPrice,
+		Cost:        provisionedGBSeconds * durationPrice,
+	})
+	allocationPrice := provisionedConcurrencyGBSecondPrice(fr.Region, fr.Architecture)
+	allocatedGBSeconds := fr.provisionedConcurrencyAllocatedGBSeconds(0)
+	charges = append(charges, focusCharge{
+		Description: "provisioned concurrency",
+		SkuID:       "Lambda-Provisioned-Concurrency" + suffix,
+		Quantity:    allocatedGBSeconds,
+		Unit:        "GB-Seconds",
+		UnitPrice:   allocationPrice,
+		Cost:        allocatedGBSeconds * allocationPrice,
+	})
+	return charges
+}
+
+// focusTime formats a time as FOCUS requires, in UTC with second precision.
+func focusTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+func formatFOCUS(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(focusColumns); err != nil {
+		return fmt.Errorf("formatFOCUS: could not write header: %w", err)
+	}
+	// Numbers are rounded to 12 significant figures, so that floating point error doesn't show
+	// up in prices, e.g. 0.0000002 rather than 0.00000020000000000000002.
+	float := func(v float64) string {
+		v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	withLogData, _, _ := splitLogData(reportContent)
+	for _, fr := range withLogData {
+		// Costs are estimated at list prices, since discounts aren't known, so the list,
+		// contracted, billed and effective costs are the same.
+		start, end := fr.Start.UTC(), fr.End.UTC()
+		billingStart := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		tags := "{}"
+		if len(fr.Tags) > 0 {
+			b, err := json.Marshal(fr.Tags)
+			if err != nil {
+				return fmt.Errorf("formatFOCUS: could not encode tags of %q: %w", fr.Name, err)
+			}
+			tags = string(b)
+		}
+		// Recommendations are on the duration charge, since that's where most savings come
+		// from, so that summing the column doesn't count them more than once.
+		recs := opts.Recommenders.Recommend(fr)
+		recommendations := ""
+		if len(recs) > 0 {
+			b, err := json.Marshal(recs)
+			if err != nil {
+				return fmt.Errorf("formatFOCUS: could not encode recommendations of %q: %w", fr.Name, err)
+			}
+			recommendations = string(b)
+		}
+		currency := priceForRegion(fr.Region).CurrencyCode()
+		for _, c := range focusCharges(fr) {
+			var savings, recsColumn string
+			if c.Description == "duration" {
+				savings, recsColumn = float(fr.MonthlySavings()), recommendations
+			}
+			record := []string{
+				fr.Account,
+				fr.AccountName,
+				currency,
+				focusTime(billingStart),
+				focusTime(billingStart.AddDate(0, 1, 0)),
+				focusTime(start),
+				focusTime(end),
+				"Usage",
+				"",
+				fmt.Sprintf("AWS Lambda %s for %s", c.Description, fr.Name),
+				"Usage-Based",
+				"AWS",
+				"AWS",
+				"AWS",
+				"Compute",
+				"AWS Lambda",
+				fr.Region,
+				fr.Region,
+				fr.ARN(),
+				fr.Name,
+				"Function",
+				fr.Account,
+				fr.AccountName,
+				c.SkuID,
+				fr.Region + ":" + c.SkuID,
+				"Standard",
+				float(c.Quantity),
+				c.Unit,
+				float(c.UnitPrice),
+				float(c.UnitPrice),
+				float(c.Quantity),
+				c.Unit,
+				float(c.Cost),
+				float(c.Cost),
+				float(c.Cost),
+				float(c.Cost),
+				tags,
+				string(fr.Architecture),
+				strconv.FormatInt(fr.MemoryAssigned(), 10),
+				savings,
+				recsColumn,
+			}
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("formatFOCUS: could not write %s charge for %q: %w", c.Description, fr.Name, err)
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("formatFOCUS: could not write: %w", err)
+	}
+	return nil
+}
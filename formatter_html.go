@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+// formatterHTML writes the report as a standalone HTML page, e.g. to publish from a scheduled job.
+const formatterHTML = "html"
+
+func init() {
+	registerFormatter(formatterHTML, FormatterFunc(formatHTML))
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>lambdacost report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+tr.warning { background: #fff3cd; }
+tr.critical { background: #f8d7da; }
+</style>
+</head>
+<body>
+<h1>lambdacost report</h1>
+{{- if .Window }}
+<p>Window: {{ .Window }}</p>
+{{- end }}
+<table>
+<thead>
+<tr>{{ range .Headers }}<th>{{ . }}</th>{{ end }}</tr>
+</thead>
+<tbody>
+{{- range .Rows }}
+<tr{{ if .Class }} class="{{ .Class }}"{{ end }}>{{ range .Cells }}<td>{{ . }}</td>{{ end }}</tr>
+{{- end }}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+type htmlReportRow struct {
+	Class string
+	Cells []string
+}
+
+func formatHTML(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+	withLogData, _, _ := splitLogData(reportContent)
+	data := struct {
+		Window  string
+		Headers []string
+		Rows    []htmlReportRow
+	}{}
+	withAccount := accountRegionCells(withLogData)
+	data.Headers = withAccount("Account", "Region", reportHeaders)
+	if start, end := reportWindow(withLogData); !start.IsZero() {
+		data.Window = opts.Format.Time(start) + " to " + opts.Format.Time(end)
+	}
+	for _, rc := range withLogData {
+		row := htmlReportRow{Cells: withAccount(rc.DisplayAccount(), rc.Region, reportCells(rc, opts))}
+		switch rc.Severity() {
+		case severityWarning:
+			row.Class = "warning"
+		case severityCritical:
+			row.Class = "critical"
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	if err := htmlReportTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("formatHTML: could not write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/a-h/lambdacost/report"
+)
+
+// formatterJSON writes the rows of the report as a JSON document.
+const formatterJSON = "json"
+
+func init() {
+	report.Register(formatterJSON, report.FormatterFunc(formatJSON))
+}
+
+// ReportOutput is the report written with -output json.
+type ReportOutput = report.Output
+
+func formatJSON(w io.Writer, output report.Output, opts report.Options) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(output); err != nil {
+		return fmt.Errorf("formatJSON: could not encode report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// formatterMarkdown writes the report as a Markdown table, e.g. for a pull request comment or wiki.
+const formatterMarkdown = "markdown"
+
+func init() {
+	registerFormatter(formatterMarkdown, FormatterFunc(formatMarkdown))
+}
+
+func formatMarkdown(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+	withLogData, _, _ := splitLogData(reportContent)
+	withAccount := accountRegionCells(withLogData)
+	headers := withAccount("Account", "Region", reportHeaders)
+	if start, end := reportWindow(withLogData); !start.IsZero() {
+		fmt.Fprintf(w, "Window: %s to %s\n\n", opts.Format.Time(start), opts.Format.Time(end))
+	}
+	writeRow := func(cells []string) {
+		escaped := make([]string, len(cells))
+		for i, c := range cells {
+			escaped[i] = markdownEscape(c)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+	}
+	writeRow(headers)
+	separators := make([]string, len(headers))
+	for i := range separators {
+		separators[i] = "---"
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+	for _, rc := range withLogData {
+		writeRow(withAccount(rc.DisplayAccount(), rc.Region, reportCells(rc, opts)))
+	}
+	return nil
+}
+
+// markdownEscape escapes the characters that would break a table cell, or be read as formatting.
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "*", `\*`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// formatterOpenCost writes the cost of each function in the window in the shape of the OpenCost
+// cloud cost API, so that Lambda costs can be loaded alongside Kubernetes costs.
+const formatterOpenCost = "opencost"
+
+func init() {
+	registerFormatter(formatterOpenCost, FormatterFunc(formatOpenCost))
+}
+
+// OpenCostOutput is the report written with -output opencost.
+type OpenCostOutput struct {
+	Window     OpenCostWindow      `json:"window"`
+	CloudCosts []OpenCostCloudCost `json:"cloudCosts"`
+}
+
+// OpenCostWindow is the time window that a cost covers.
+type OpenCostWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// OpenCostProperties identify the resource that a cost is for.
+type OpenCostProperties struct {
+	ProviderID      string            `json:"providerID"`
+	Provider        string            `json:"provider"`
+	AccountID       string            `json:"accountID"`
+	AccountName     string            `json:"accountName,omitempty"`
+	InvoiceEntityID string            `json:"invoiceEntityID"`
+	RegionID        string            `json:"regionID"`
+	Service         string            `json:"service"`
+	Category        string            `json:"category"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// OpenCostMetric is a cost. Lambda functions aren't part of a Kubernetes cluster, so
+// KubernetesPercent is always zero.
+type OpenCostMetric struct {
+	Cost              float64 `json:"cost"`
+	KubernetesPercent float64 `json:"kubernetesPercent"`
+}
+
+// OpenCostCloudCost is the cost of a function in the window. Costs are estimated at list prices,
+// so each of the costs is the same. Currency and Recommendations aren't part of the OpenCost
+// cloud cost, and are ignored by OpenCost.
+type OpenCostCloudCost struct {
+	Properties       OpenCostProperties `json:"properties"`
+	Window           OpenCostWindow     `json:"window"`
+	ListCost         OpenCostMetric     `json:"listCost"`
+	NetCost          OpenCostMetric     `json:"netCost"`
+	AmortizedNetCost OpenCostMetric     `json:"amortizedNetCost"`
+	InvoicedCost     OpenCostMetric     `json:"invoicedCost"`
+	AmortizedCost    OpenCostMetric     `json:"amortizedCost"`
+	Currency         string             `json:"currency"`
+	Recommendations  []Recommendation   `json:"recommendations,omitempty"`
+}
+
+func formatOpenCost(w io.Writer, reportContent []FunctionReports, opts reportOptions) error {
+	output := OpenCostOutput{
+		CloudCosts: []OpenCostCloudCost{},
+	}
+	start, end := reportWindow(reportContent)
+	output.Window = OpenCostWindow{Start: start.UTC(), End: end.UTC()}
+	withLogData, _, _ := splitLogData(reportContent)
+	for _, fr := range withLogData {
+		cost := OpenCostMetric{Cost: fr.Cost()}
+		output.CloudCosts = append(output.CloudCosts, OpenCostCloudCost{
+			Properties: OpenCostProperties{
+				ProviderID:      fr.ARN(),
+				Provider:        "AWS",
+				AccountID:       fr.Account,
+				AccountName:     fr.AccountName,
+				InvoiceEntityID: fr.Account,
+				RegionID:        fr.Region,
+				Service:         "AWSLambda",
+				Category:        "Compute",
+				Labels:          fr.Tags,
+			},
+			Window:           OpenCostWindow{Start: fr.Start.UTC(), End: fr.End.UTC()},
+			ListCost:         cost,
+			NetCost:          cost,
+			AmortizedNetCost: cost,
+			InvoicedCost:     cost,
+			AmortizedCost:    cost,
+			Currency:         priceForRegion(fr.Region).CurrencyCode(),
+			Recommendations:  opts.Recommenders.Recommend(fr),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(output); err != nil {
+		return fmt.Errorf("formatOpenCost: could not encode report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/a-h/lambdacost/report"
+)
+
+// formatterPrometheus writes gauges in the Prometheus text exposition format, e.g. for the
+// node_exporter textfile collector, or a Pushgateway.
+const formatterPrometheus = "prometheus"
+
+func init() {
+	report.Register(formatterPrometheus, report.FormatterFunc(formatPrometheus))
+}
+
+type prometheusMetric struct {
+	Name  string
+	Help  string
+	Value func(r ReportRow) float64
+}
+
+var prometheusMetrics = []prometheusMetric{
+	{Name: "lambdacost_daily_cost", Help: "Daily cost of the function, in the currency label.", Value: func(r ReportRow) float64 { return r.DailyCost }},
+	{Name: "lambdacost_monthly_cost", Help: "Monthly cost of the function, in the currency label.", Value: func(r ReportRow) float64 { return r.MonthlyCost }},
+	{Name: "lambdacost_monthly_savings", Help: "Monthly savings from moving to arm64 with the optimal memory size, in the currency label.", Value: func(r ReportRow) float64 { return r.MonthlySavings }},
+	{Name: "lambdacost_monthly_savings_margin", Help: "Margin of the monthly savings, in the currency label.", Value: func(r ReportRow) float64 { return r.MonthlySavingsMargin }},
+	{Name: "lambdacost_requests", Help: "Requests in the window, counting retries once.", Value: func(r ReportRow) float64 { return float64(r.Requests) }},
+	{Name: "lambdacost_executions", Help: "Billed executions in the window.", Value: func(r ReportRow) float64 { return float64(r.Executions) }},
+	{Name: "lambdacost_max_duration_seconds", Help: "Maximum duration in the window.", Value: func(r ReportRow) float64 { return r.MaxDuration.Seconds() }},
+	{Name: "lambdacost_max_memory_used_megabytes", Help: "Maximum memory used in the window.", Value: func(r ReportRow) float64 { return float64(r.MaxMemoryUsed) }},
+	{Name: "lambdacost_memory_assigned_megabytes", Help: "Memory size of the function.", Value: func(r ReportRow) float64 { return float64(r.MemoryAssigned) }},
+	{Name: "lambdacost_optimal_memory_megabytes", Help: "Optimal memory size of the function, or zero if unknown.", Value: func(r ReportRow) float64 { return float64(r.OptimalMemory) }},
+}
+
+func formatPrometheus(w io.Writer, output report.Output, opts report.Options) error {
+	for _, m := range prometheusMetrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.Name, m.Help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", m.Name)
+		for _, r := range output.Functions {
+			labels := []string{
+				prometheusLabel("account", r.Account),
+				prometheusLabel("region", r.Region),
+				prometheusLabel("function", r.Name),
+				prometheusLabel("architecture", string(r.Architecture)),
+				prometheusLabel("currency", r.Currency),
+			}
+			if _, err := fmt.Fprintf(w, "%s{%s} %s\n", m.Name, strings.Join(labels, ","), strconv.FormatFloat(m.Value(r), 'g', -1, 64)); err != nil {
+				return fmt.Errorf("formatPrometheus: could not write %s: %w", m.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// prometheusLabel formats a label, escaping the value.
+func prometheusLabel(name, value string) string {
+	return name + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
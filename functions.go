@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// functionRef is a function listed in a functions file.
+type functionRef struct {
+	// Name is the function name, or its full ARN.
+	Name string
+	// Region is empty if the function is in the default region.
+	Region string
+}
+
+// readFunctionsFile reads a newline separated list of function names or ARNs.
+// Blank lines, and lines starting with # are ignored.
+func readFunctionsFile(fileName string) (refs []functionRef, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		err = fmt.Errorf("readFunctionsFile: could not open %q: %w", fileName, err)
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ref := functionRef{Name: line}
+		if arn.IsARN(line) {
+			a, err := arn.Parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("readFunctionsFile: line %d: invalid ARN %q: %w", lineNumber, line, err)
+			}
+			if a.Service != "lambda" {
+				return nil, fmt.Errorf("readFunctionsFile: line %d: %q is not a Lambda function ARN", lineNumber, line)
+			}
+			ref.Region = a.Region
+		}
+		refs = append(refs, ref)
+	}
+	if err = scanner.Err(); err != nil {
+		err = fmt.Errorf("readFunctionsFile: could not read %q: %w", fileName, err)
+		return
+	}
+	return
+}
+
+func getLambdaFunctionsFromFile(ctx context.Context, lambdaClient *lambda.Client, refs []functionRef) (functions []types.FunctionConfiguration, err error) {
+	for _, ref := range refs {
+		ref := ref
+		output, err := lambdaClient.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: &ref.Name,
+		}, func(o *lambda.Options) {
+			if ref.Region != "" {
+				o.Region = ref.Region
+			}
+		})
+		if err != nil {
+			// The functions that were read are returned, in case the deadline expired.
+			return functions, fmt.Errorf("getLambdaFunctionsFromFile: failed to get function %q: %w", ref.Name, err)
+		}
+		functions = append(functions, types.FunctionConfiguration{
+			Architectures:    output.Architectures,
+			CodeSize:         output.CodeSize,
+			Description:      output.Description,
+			EphemeralStorage: output.EphemeralStorage,
+			FunctionArn:      output.FunctionArn,
+			FunctionName:     output.FunctionName,
+			Layers:           output.Layers,
+			MemorySize:       output.MemorySize,
+			PackageType:      output.PackageType,
+			Runtime:          output.Runtime,
+			SnapStart:        output.SnapStart,
+			Timeout:          output.Timeout,
+			Version:          output.Version,
+		})
+	}
+	return
+}
+
+// functionRegion returns the region in the function ARN, or the default region.
+func functionRegion(f types.FunctionConfiguration, defaultRegion string) string {
+	if f.FunctionArn == nil {
+		return defaultRegion
+	}
+	a, err := arn.Parse(*f.FunctionArn)
+	if err != nil || a.Region == "" {
+		return defaultRegion
+	}
+	return a.Region
+}
+
+// ARN returns the unqualified ARN of the function.
+func (fr FunctionReports) ARN() string {
+	return arn.ARN{
+		Partition: regionPartition(fr.Region),
+		Service:   "lambda",
+		Region:    fr.Region,
+		AccountID: fr.Account,
+		Resource:  "function:" + fr.Name,
+	}.String()
+}
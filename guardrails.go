@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Memory headroom applied to the max memory used when recommending a memory size.
+const (
+	memoryHeadroom = 2.0
+	// memoryUnstableHeadroom is used for functions with errors or timeouts at the current setting.
+	memoryUnstableHeadroom = 3.0
+	// Memory recommendations are suppressed if at least this proportion of invocations fail.
+	memorySuppressFailureRate = 0.01
+)
+
+// Warn when the projected p99 duration at a recommended memory size is at least this proportion of the timeout.
+const timeoutRiskProportion = 0.8
+
+// Lambda allocates CPU in proportion to memory, reaching one full vCPU at 1,769 MB.
+const singleVCPUMemory = 1769
+
+// Timeouts is the number of invocations with a timeout status in the REPORT line.
+func (fr FunctionReports) Timeouts() (n int) {
+	for _, r := range fr.Reports {
+		if r.Extra["Status"] == "timeout" {
+			n++
+		}
+	}
+	return n
+}
+
+// OutOfMemoryErrors is the number of invocations that ran out of memory.
+func (fr FunctionReports) OutOfMemoryErrors() (n int) {
+	for _, r := range fr.Reports {
+		if r.Extra["Error Type"] == "Runtime.OutOfMemory" || (r.MemorySize > 0 && r.MaxMemoryUsed >= r.MemorySize) {
+			n++
+		}
+	}
+	return n
+}
+
+// FailureRate is the proportion of invocations that errored or timed out. The Errors metric is used
+// if it was collected, otherwise the status in the REPORT lines is used.
+func (fr FunctionReports) FailureRate() float64 {
+	if fr.MetricErrors != nil && fr.MetricInvocations != nil && *fr.MetricInvocations > 0 {
+		return float64(*fr.MetricErrors) / float64(*fr.MetricInvocations)
+	}
+	if len(fr.Reports) == 0 {
+		return 0
+	}
+	var failures int
+	for _, r := range fr.Reports {
+		if status := r.Extra["Status"]; status == "error" || status == "timeout" {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(fr.Reports))
+}
+
+// MemoryHeadroom returns the multiple of the max memory used to recommend. Functions that have run
+// out of memory, or that frequently fail, don't get a memory recommendation, and functions with some
+// errors or timeouts get more headroom. The rationale explains any adjustment.
+func (fr FunctionReports) MemoryHeadroom() (headroom float64, suppressed bool, rationale string) {
+	if oom := fr.OutOfMemoryErrors(); oom > 0 {
+		return 0, true, fmt.Sprintf("memory recommendation suppressed, %d out of memory errors at the current setting", oom)
+	}
+	rate := fr.FailureRate()
+	if rate >= memorySuppressFailureRate {
+		return 0, true, fmt.Sprintf("memory recommendation suppressed, %.1f%% of invocations failed at the current setting", rate*100)
+	}
+	if timeouts := fr.Timeouts(); timeouts > 0 {
+		return memoryUnstableHeadroom, false, fmt.Sprintf("headroom increased to %.0fx max memory used, %d timeouts at the current setting", memoryUnstableHeadroom, timeouts)
+	}
+	if rate > 0 {
+		return memoryUnstableHeadroom, false, fmt.Sprintf("headroom increased to %.0fx max memory used, %.2f%% of invocations failed at the current setting", memoryUnstableHeadroom, rate*100)
+	}
+	return memoryHeadroom, false, ""
+}
+
+// ProjectedDurationPercentile estimates the duration percentile if the function ran with the memory
+// size. CPU is allocated in proportion to memory, so the share of the duration spent using CPU is
+// assumed to increase in proportion to the reduction in CPU, see durationFactor.
+func (fr FunctionReports) ProjectedDurationPercentile(p float64, memorySize int64) time.Duration {
+	return fr.withMemorySize(memorySize).DurationPercentile(p)
+}
+
+// TimeoutRisk returns true if the projected p99 duration at the memory size is close to the
+// function's timeout, so reducing memory could cause invocations to time out.
+func (fr FunctionReports) TimeoutRisk(memorySize int64) (projected time.Duration, risk bool) {
+	if fr.Timeout == 0 {
+		return 0, false
+	}
+	projected = fr.ProjectedDurationPercentile(99, memorySize)
+	return projected, float64(projected) >= float64(fr.Timeout)*timeoutRiskProportion
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+
+	"github.com/a-h/lambdacost/report"
+)
+
+// durationHistogramBounds are the upper bounds of the duration histogram buckets. They're the same
+// for every function, so that histograms can be added together across functions and runs. The
+// last is the maximum Lambda timeout.
+var durationHistogramBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+}
+
+// DurationHistogram counts invocations by duration.
+type DurationHistogram = report.DurationHistogram
+
+// DurationHistogramBucket is the number of invocations with a duration of at most LE.
+type DurationHistogramBucket = report.DurationHistogramBucket
+
+// DurationHistogram returns the histogram of the durations of the function's invocations.
+func (fr FunctionReports) DurationHistogram() (h DurationHistogram) {
+	h.Buckets = make([]DurationHistogramBucket, len(durationHistogramBounds))
+	for i, le := range durationHistogramBounds {
+		h.Buckets[i].LE = le
+	}
+	for _, r := range fr.Reports {
+		h.Count++
+		h.Sum += r.Duration
+		for i := len(h.Buckets) - 1; i >= 0 && r.Duration <= h.Buckets[i].LE; i-- {
+			h.Buckets[i].Count++
+		}
+	}
+	return h
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Logs Insights returns at most 10,000 rows per query.
+const insightsMaxResults = 10000
+
+// Functions that use the JSON log format write platform.report events instead of REPORT lines.
+const insightsReportQuery = `fields @timestamp, @message | filter @message like /^REPORT/ or @message like /"type":"platform.report"/ | limit 10000`
+
+// insightsQuery returns the query for REPORT log events, restricted to the log streams of the
+// version, if it's set.
+func insightsQuery(version string) string {
+	if version == "" {
+		return insightsReportQuery
+	}
+	return strings.Replace(insightsReportQuery, " | limit", fmt.Sprintf(" | filter @logStream like /%s/ | limit", regexp.QuoteMeta(logStreamVersion(version))), 1)
+}
+
+// Minimum window to split a query into. Below this size, a query that
+// returns the maximum number of results is accepted as-is.
+const insightsMinWindow = time.Minute
+
+// Logs Insights returns timestamps in UTC, in this format.
+const insightsTimestampFormat = "2006-01-02 15:04:05.000"
+
+const insightsPollInterval = time.Second
+
+const collectorInsights = "insights"
+
+func init() {
+	registerCollector(collectorInsights, func(deps collectorDependencies) (Collector, error) {
+		return insightsCollector{client: deps.CloudWatchLogs, stats: deps.Stats}, nil
+	})
+}
+
+// insightsCollector uses Logs Insights to query REPORT log messages. Only REPORT messages and
+// platform.report events are returned, so less data is downloaded than with FilterLogEvents,
+// but the query is charged by the amount of data scanned.
+type insightsCollector struct {
+	client *cloudwatchlogs.Client
+	stats  *scanStats
+}
+
+func (c insightsCollector) Collect(ctx context.Context, target CollectTarget, onEvent func(e LogEvent)) error {
+	limit := &pageLimit{Max: target.MaxPages}
+	events, err := getInsightsEvents(ctx, c.client, target.Region, target.LogGroupName, insightsQuery(target.Version), target.Start, target.End, c.stats, limit)
+	var notFound *cwtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return errLogGroupNotFound
+	}
+	// If collection was capped, or failed part way through, the events collected so far are kept.
+	for _, e := range events {
+		onEvent(e)
+	}
+	return err
+}
+
+// getInsightsEvents uses Logs Insights to get the REPORT log events in the window. Log groups
+// in the Infrequent Access class don't support FilterLogEvents, so Logs Insights is used instead.
+// Windows that return the maximum number of results are split in half and queried again. If a
+// query for part of the window fails, the events returned by the query for the whole window are
+// returned with the error.
+func getInsightsEvents(ctx context.Context, cwLogsClient *cloudwatchlogs.Client, region, logGroupName, queryString string, start, end time.Time, stats *scanStats, limit *pageLimit) (events []LogEvent, err error) {
+	if err = limit.Next(); err != nil {
+		return nil, err
+	}
+	events, err = runInsightsQuery(ctx, cwLogsClient, region, logGroupName, queryString, start, end, stats)
+	if err != nil {
+		return
+	}
+	window := end.Sub(start)
+	if len(events) < insightsMaxResults || window <= insightsMinWindow {
+		return
+	}
+	mid := start.Add(window / 2)
+	before, err := getInsightsEvents(ctx, cwLogsClient, region, logGroupName, queryString, start, mid, stats, limit)
+	if err != nil {
+		return events, err
+	}
+	after, err := getInsightsEvents(ctx, cwLogsClient, region, logGroupName, queryString, mid, end, stats, limit)
+	if err != nil {
+		return events, err
+	}
+	return append(before, after...), nil
+}
+
+func runInsightsQuery(ctx context.Context, cwLogsClient *cloudwatchlogs.Client, region, logGroupName, queryString string, start, end time.Time, stats *scanStats) (events []LogEvent, err error) {
+	withRegion := func(o *cloudwatchlogs.Options) {
+		o.Region = region
+	}
+	// The start and end times of a query are whole seconds, and both are included. The window
+	// doesn't include its end, so that when a window is split, events in the second at the
+	// split are only returned by the second half.
+	endTime := end.Unix() - 1
+	if endTime < start.Unix() {
+		endTime = start.Unix()
+	}
+	query, err := cwLogsClient.StartQuery(ctx, &cloudwatchlogs.StartQueryInput{
+		LogGroupName: &logGroupName,
+		QueryString:  &queryString,
+		StartTime:    aws.Int64(start.Unix()),
+		EndTime:      aws.Int64(endTime),
+		Limit:        aws.Int32(insightsMaxResults),
+	}, withRegion)
+	if err != nil {
+		return nil, fmt.Errorf("runInsightsQuery: failed to start query: %w", err)
+	}
+	stats.InsightsQueries++
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(insightsPollInterval):
+		}
+		results, err := cwLogsClient.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{
+			QueryId: query.QueryId,
+		}, withRegion)
+		if err != nil {
+			return nil, fmt.Errorf("runInsightsQuery: failed to get query results: %w", err)
+		}
+		switch results.Status {
+		case cwtypes.QueryStatusScheduled, cwtypes.QueryStatusRunning:
+			continue
+		case cwtypes.QueryStatusComplete:
+			if results.Statistics != nil {
+				stats.InsightsBytesScanned += results.Statistics.BytesScanned
+			}
+			for _, row := range results.Results {
+				var e LogEvent
+				for _, field := range row {
+					switch aws.ToString(field.Field) {
+					case "@message":
+						e.Message = aws.ToString(field.Value)
+					case "@timestamp":
+						e.Timestamp, _ = time.Parse(insightsTimestampFormat, aws.ToString(field.Value))
+					}
+				}
+				events = append(events, e)
+			}
+			return events, nil
+		default:
+			return nil, fmt.Errorf("runInsightsQuery: query finished with status %q", results.Status)
+		}
+	}
+}
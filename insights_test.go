@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// TestInsightsSplitWindowsDontOverlap queries a window with more events than a query returns, so
+// that it's split in half, with an event in the second at the split.
+func TestInsightsSplitWindowsDontOverlap(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", fakeFunction{Name: "api", MemorySize: 128, Architecture: "x86_64", Invocations: insightsMaxResults, BilledDurationMS: 10})
+	fake.Now = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	start := fake.Now
+	end := start.Add(insightsMaxResults * time.Second)
+	split := start.Add(end.Sub(start) / 2)
+	events, err := getInsightsEvents(context.Background(), cloudwatchlogs.NewFromConfig(fake.Config()), "eu-west-1", "/aws/lambda/api", insightsReportQuery, start, end, &scanStats{}, &pageLimit{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := fake.Requests()["CloudWatch Logs:StartQuery"]; queries != 3 {
+		t.Fatalf("expected the window to be split into two queries, got %d queries", queries)
+	}
+	if len(events) != insightsMaxResults {
+		t.Errorf("expected %d events, got %d", insightsMaxResults, len(events))
+	}
+	var atSplit int
+	for _, e := range events {
+		if e.Timestamp.Equal(split) {
+			atSplit++
+		}
+	}
+	if atSplit != 1 {
+		t.Errorf("expected the event at the split to be returned once, got %d", atSplit)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/a-h/lambdacost/pricing"
+	"go.uber.org/zap"
+)
+
+// Items on the invoice, in the order they're listed for each region.
+const (
+	invoiceItemRequests               = "Requests"
+	invoiceItemDuration               = "Duration"
+	invoiceItemProvisionedConcurrency = "Provisioned Concurrency"
+	invoiceItemProvisionedDuration    = "Provisioned Duration"
+	invoiceItemEphemeralStorage       = "Ephemeral Storage"
+)
+
+// InvoiceLine is a line of a simulated invoice, for a single item in an account and region.
+type InvoiceLine struct {
+	Account     string  `json:"account"`
+	Region      string  `json:"region"`
+	Item        string  `json:"item"`
+	Description string  `json:"description"`
+	Usage       float64 `json:"usage"`
+	Unit        string  `json:"unit"`
+	Cost        float64 `json:"cost"`
+	Currency    string  `json:"currency"`
+}
+
+// Invoice is the simulated Lambda invoice for a month, grouped like the AWS bill.
+type Invoice struct {
+	Month time.Time     `json:"month"`
+	Lines []InvoiceLine `json:"lines"`
+	// Totals is the total cost in each currency.
+	Totals map[string]float64 `json:"totals"`
+	// Retries is the number of executions that reused the request ID of an earlier execution,
+	// e.g. retries of asynchronous invocations, which are charged as requests.
+	Retries int `json:"retries"`
+	// DaysWithData is the number of days in the month with REPORT lines.
+	DaysWithData int `json:"daysWithData"`
+	// Untimed is the number of REPORT lines without timestamps, which can't be placed in the month.
+	Untimed int `json:"untimed"`
+}
+
+// invoiceUsage is the billable usage of an account and region in the month.
+type invoiceUsage struct {
+	Account        string
+	Region         string
+	Executions     int
+	UniqueRequests int
+	// GBSeconds is the on-demand duration of each architecture.
+	GBSeconds map[Architecture]float64
+	// ProvisionedGBSeconds is the duration of invocations in provisioned environments.
+	ProvisionedGBSeconds map[Architecture]float64
+	// AllocatedGBSeconds is the provisioned concurrency allocated while data was collected.
+	AllocatedGBSeconds map[Architecture]float64
+	// EphemeralGBSeconds is the ephemeral storage above the default, for the billed duration.
+	EphemeralGBSeconds float64
+}
+
+// add adds the function's usage on a day, from its REPORT lines on the day.
+func (u *invoiceUsage) add(fr FunctionReports, reports []Report, day time.Time) {
+	architecture := fr.Architecture
+	if architecture != ArchitectureARM64 {
+		architecture = ArchitectureX86_64
+	}
+	d := fr
+	d.Reports = reports
+	u.Executions += d.Executions()
+	u.UniqueRequests += d.UniqueRequests()
+	for _, r := range reports {
+		gbs := pricing.GBSeconds(r.MemorySize, r.BilledDuration)
+		if fr.RanOnProvisionedConcurrency(r) {
+			u.ProvisionedGBSeconds[architecture] += gbs
+		} else {
+			u.GBSeconds[architecture] += gbs
+		}
+		u.EphemeralGBSeconds += pricing.EphemeralStorageGBSeconds(fr.EphemeralStorage, r.BilledDuration)
+	}
+	if fr.HasProvisionedConcurrency() {
+		// Provisioned concurrency is charged for the part of the day that data was collected for.
+		start, end := day, day.Add(24*time.Hour)
+		if fr.Start.After(start) {
+			start = fr.Start
+		}
+		if !fr.End.IsZero() && fr.End.Before(end) {
+			end = fr.End
+		}
+		u.AllocatedGBSeconds[architecture] += fr.provisionedConcurrencyBetween(start, end) * float64(fr.MemoryAssigned()) / 1024 * end.Sub(start).Seconds()
+	}
+}
+
+// newInvoice rolls the REPORT lines in the month up into invoice lines for each account and region.
+// Duration is priced in tiers of the month's GB-seconds, so the invoice is only accurate if the
+// data covers every day of the month.
+func newInvoice(reportContent []FunctionReports, month time.Time) (inv Invoice) {
+	inv.Month = month
+	inv.Totals = map[string]float64{}
+	usage := map[string]*invoiceUsage{}
+	days := map[time.Time]struct{}{}
+	for _, fr := range reportContent {
+		byDay := map[time.Time][]Report{}
+		for _, r := range fr.Reports {
+			if r.Timestamp.IsZero() {
+				inv.Untimed++
+				continue
+			}
+			t := r.Timestamp.UTC()
+			if t.Year() != month.Year() || t.Month() != month.Month() {
+				continue
+			}
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			byDay[day] = append(byDay[day], r)
+		}
+		if len(byDay) == 0 {
+			continue
+		}
+		key := fr.Account + "/" + fr.Region
+		u, ok := usage[key]
+		if !ok {
+			u = &invoiceUsage{
+				Account:              fr.Account,
+				Region:               fr.Region,
+				GBSeconds:            map[Architecture]float64{},
+				ProvisionedGBSeconds: map[Architecture]float64{},
+				AllocatedGBSeconds:   map[Architecture]float64{},
+			}
+			usage[key] = u
+		}
+		for day, reports := range byDay {
+			days[day] = struct{}{}
+			u.add(fr, reports, day)
+		}
+	}
+	inv.DaysWithData = len(days)
+	var keys []string
+	for key := range usage {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		u := usage[key]
+		inv.Retries += u.Executions - u.UniqueRequests
+		for _, line := range u.lines() {
+			inv.Lines = append(inv.Lines, line)
+			inv.Totals[line.Currency] += line.Cost
+		}
+	}
+	return inv
+}
+
+// lines returns the invoice lines for the usage, with a line for each duration tier used.
+func (u *invoiceUsage) lines() (lines []InvoiceLine) {
+	price := priceForRegion(u.Region)
+	add := func(item, description string, usage float64, unit string, cost float64) {
+		lines = append(lines, InvoiceLine{
+			Account:     u.Account,
+			Region:      u.Region,
+			Item:        item,
+			Description: description,
+			Usage:       usage,
+			Unit:        unit,
+			Cost:        cost,
+			Currency:    price.CurrencyCode(),
+		})
+	}
+	requestsDescription := formatUnitPrice(price.PerMillionRequests, price.CurrencyCode()) + " per 1M requests"
+	if retries := u.Executions - u.UniqueRequests; retries > 0 {
+		requestsDescription += fmt.Sprintf(", including %d retries", retries)
+	}
+	add(invoiceItemRequests, requestsDescription, float64(u.Executions), "Requests", pricing.Requests(price, float64(u.Executions)))
+	for _, architecture := range []Architecture{ArchitectureX86_64, ArchitectureARM64} {
+		for _, tier := range pricing.TieredDuration(price, architecture, u.GBSeconds[architecture]) {
+			description := fmt.Sprintf("%s per GB-second, %s, %s", formatUnitPrice(tier.Price, price.CurrencyCode()), architecture, tierRange(tier.From, tier.UpTo))
+			add(invoiceItemDuration, description, tier.GBSeconds, "GB-seconds", tier.Cost)
+		}
+	}
+	for _, architecture := range []Architecture{ArchitectureX86_64, ArchitectureARM64} {
+		if gbs := u.AllocatedGBSeconds[architecture]; gbs > 0 {
+			p := price.ProvisionedConcurrencyGBSecond(architecture)
+			add(invoiceItemProvisionedConcurrency, fmt.Sprintf("%s per GB-second, %s", formatUnitPrice(p, price.CurrencyCode()), architecture), gbs, "GB-seconds", gbs*p)
+		}
+		if gbs := u.ProvisionedGBSeconds[architecture]; gbs > 0 {
+			p := price.ProvisionedDurationGBSecond(architecture)
+			add(invoiceItemProvisionedDuration, fmt.Sprintf("%s per GB-second, %s", formatUnitPrice(p, price.CurrencyCode()), architecture), gbs, "GB-seconds", gbs*p)
+		}
+	}
+	if u.EphemeralGBSeconds > 0 {
+		p := price.EphemeralStoragePrice()
+		add(invoiceItemEphemeralStorage, fmt.Sprintf("%s per GB-second above %d MB", formatUnitPrice(p, price.CurrencyCode()), pricing.EphemeralStorageFreeMB), u.EphemeralGBSeconds, "GB-seconds", u.EphemeralGBSeconds*p)
+	}
+	return lines
+}
+
+// formatUnitPrice formats a price without trailing zeros, e.g. 0.0000166667 USD.
+func formatUnitPrice(price float64, currency string) string {
+	s := strings.TrimRight(strconv.FormatFloat(price, 'f', 10, 64), "0")
+	return strings.TrimSuffix(s, ".") + " " + currency
+}
+
+// usagePrecision is the number of decimal places of usage in the unit. Requests are whole numbers.
+func usagePrecision(unit string) int {
+	if unit == "Requests" {
+		return 0
+	}
+	return 3
+}
+
+// tierRange describes a duration pricing tier, e.g. first 6 billion GB-seconds.
+func tierRange(from, upTo float64) string {
+	switch {
+	case from == 0 && upTo == 0:
+		return "all GB-seconds"
+	case from == 0:
+		return fmt.Sprintf("first %v billion GB-seconds", upTo/1e9)
+	case upTo == 0:
+		return fmt.Sprintf("over %v billion GB-seconds", from/1e9)
+	}
+	return fmt.Sprintf("next %v billion GB-seconds", (upTo-from)/1e9)
+}
+
+func displayInvoice(w io.Writer, inv Invoice, files int) {
+	if len(inv.Lines) == 0 {
+		fmt.Fprintln(w, "No data for the month.")
+		return
+	}
+	daysInMonth := inv.Month.AddDate(0, 1, -1).Day()
+	fmt.Fprintf(w, "Simulated invoice: %s, from %d report data files, %d of %d days have data\n", inv.Month.Format("January 2006"), files, inv.DaysWithData, daysInMonth)
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Account", "Region", "Item", "Description", "Usage", "Cost"}, "\t"))
+	var account, region string
+	var regionTotal float64
+	flushRegion := func(currency string) {
+		if region != "" {
+			fmt.Fprintln(tw, strings.Join([]string{"", "", "", "Region total", "", fmt.Sprintf("%.2f %s", regionTotal, currency)}, "\t"))
+		}
+	}
+	var currency string
+	for _, l := range inv.Lines {
+		if l.Account != account || l.Region != region {
+			flushRegion(currency)
+			account, region, currency, regionTotal = l.Account, l.Region, l.Currency, 0
+		}
+		regionTotal += l.Cost
+		fmt.Fprintln(tw, strings.Join([]string{
+			l.Account,
+			l.Region,
+			l.Item,
+			l.Description,
+			fmt.Sprintf("%s %s", strconv.FormatFloat(l.Usage, 'f', usagePrecision(l.Unit), 64), l.Unit),
+			fmt.Sprintf("%.2f %s", l.Cost, l.Currency),
+		}, "\t"))
+	}
+	flushRegion(currency)
+	tw.Flush()
+	fmt.Fprintln(w)
+	var codes []string
+	for code := range inv.Totals {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "Total: %.2f %s\n", inv.Totals[code], code)
+	}
+	if inv.Retries > 0 {
+		fmt.Fprintf(w, "Requests include %d retries, which are charged as requests, but aren't client-visible requests.\n", inv.Retries)
+	}
+	if missing := daysInMonth - inv.DaysWithData; missing > 0 {
+		fmt.Fprintf(w, "%d days have no data, so usage is understated, and duration may be priced in a higher tier than on the bill.\n", missing)
+	}
+	fmt.Fprintln(w, "The free tier, Savings Plans and credits aren't applied.")
+}
+
+func invoiceCmd(args []string) {
+	cmd := flag.NewFlagSet("invoice", flag.ExitOnError)
+	config := cmd.String("config", "", "Path to a JSON settings file, e.g. to override region prices")
+	month := cmd.String("month", "", "The month to simulate the invoice for, e.g. 2024-03, defaults to the month of the latest data")
+	output := cmd.String("o", "", "Path to write the invoice to as JSON, e.g. invoice.json")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost invoice [flags] <file.json|directory>")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	settings, err := loadSettings(*config)
+	if err != nil {
+		log.Fatal("could not load settings", zap.Error(err))
+	}
+	setRegionPrices(settings.RegionPrices)
+	functionReports, files := []FunctionReports(nil), 1
+	if info, err := os.Stat(cmd.Arg(0)); err == nil && info.IsDir() {
+		functionReports, files, err = readSnapshots(log, cmd.Arg(0))
+		if err != nil {
+			log.Fatal("could not read report data", zap.Error(err))
+		}
+	} else if functionReports, err = readFunctionReports(cmd.Arg(0)); err != nil {
+		log.Fatal("could not read report data", zap.Error(err))
+	}
+	var m time.Time
+	if *month != "" {
+		if m, err = time.Parse("2006-01", *month); err != nil {
+			log.Fatal("could not parse month", zap.String("month", *month), zap.Error(err))
+		}
+	} else {
+		days, _ := dailyCosts(functionReports)
+		if len(days) == 0 {
+			log.Fatal("no REPORT lines with timestamps found", zap.String("path", cmd.Arg(0)))
+		}
+		latest := days[len(days)-1].Day
+		m = time.Date(latest.Year(), latest.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	inv := newInvoice(functionReports, m)
+	if inv.Untimed > 0 {
+		log.Warn("some REPORT lines don't have timestamps, and are excluded from the invoice", zap.Int("count", inv.Untimed))
+	}
+	displayInvoice(os.Stdout, inv, files)
+	if *output == "" {
+		return
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		log.Fatal("could not create invoice file", zap.Error(err))
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(inv); err != nil {
+		log.Fatal("could not write invoice", zap.Error(err))
+	}
+}
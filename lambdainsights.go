@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+// The Lambda Insights extension is added to functions as a layer, and publishes metrics to the
+// LambdaInsights namespace with a function_name dimension.
+const (
+	lambdaInsightsLayerName = "LambdaInsightsExtension"
+	lambdaInsightsNamespace = "LambdaInsights"
+)
+
+// Functions that use at least this proportion of their allocated CPU are CPU-bound, so reducing
+// memory, which reduces CPU, increases duration.
+const cpuBoundUtilisation = 0.8
+
+// LambdaInsights is the data collected from Lambda Insights metrics over the window.
+type LambdaInsights struct {
+	// CPUTime is the CPU time used by invocations, from the cpu_total_time metric.
+	CPUTime time.Duration `json:"cpuTime"`
+	// NetworkBytes is the bytes sent and received, from the total_network metric.
+	NetworkBytes int64 `json:"networkBytes"`
+}
+
+// LambdaInsightsEnabled returns true if the function has the Lambda Insights extension layer.
+func (fr FunctionReports) LambdaInsightsEnabled() bool {
+	for _, l := range fr.Layers {
+		if strings.Contains(l.ARN, ":layer:"+lambdaInsightsLayerName) {
+			return true
+		}
+	}
+	return false
+}
+
+func getLambdaInsights(ctx context.Context, cwClient *cloudwatch.Client, region, functionName string, start, end time.Time) (li LambdaInsights, err error) {
+	cpuMS, err := sumMetric(ctx, cwClient, region, lambdaInsightsNamespace, "cpu_total_time", "function_name", functionName, start, end)
+	if err != nil {
+		return li, fmt.Errorf("getLambdaInsights: %w", err)
+	}
+	li.CPUTime = time.Duration(cpuMS) * time.Millisecond
+	if li.NetworkBytes, err = sumMetric(ctx, cwClient, region, lambdaInsightsNamespace, "total_network", "function_name", functionName, start, end); err != nil {
+		return li, fmt.Errorf("getLambdaInsights: %w", err)
+	}
+	return li, nil
+}
+
+// invocationCount is the number of invocations in the window, from the Invocations metric if it
+// was collected, since REPORT lines may be missing.
+func (fr FunctionReports) invocationCount() int64 {
+	if fr.MetricInvocations != nil {
+		return *fr.MetricInvocations
+	}
+	return int64(len(fr.Reports))
+}
+
+// AvgVCPUs is the average number of vCPUs used while invocations are running. Lambda Insights
+// metrics cover every invocation, so the duration of the REPORT lines is scaled to the
+// Invocations metric. It returns false if Lambda Insights data wasn't collected.
+func (fr FunctionReports) AvgVCPUs() (vcpus float64, ok bool) {
+	if fr.LambdaInsights == nil || len(fr.Reports) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, r := range fr.Reports {
+		total += r.Duration
+	}
+	scaled := float64(total) * float64(fr.invocationCount()) / float64(len(fr.Reports))
+	if scaled <= 0 {
+		return 0, false
+	}
+	return float64(fr.LambdaInsights.CPUTime) / scaled, true
+}
+
+// allocatedVCPUs is the CPU allocated in proportion to memory.
+func allocatedVCPUs(memorySize int64) float64 {
+	return float64(memorySize) / singleVCPUMemory
+}
+
+// CPUUtilisation is the proportion of the allocated CPU used while invocations are running.
+func (fr FunctionReports) CPUUtilisation() (utilisation float64, ok bool) {
+	vcpus, ok := fr.AvgVCPUs()
+	if !ok || fr.MemoryAssigned() == 0 {
+		return 0, false
+	}
+	return vcpus / allocatedVCPUs(fr.MemoryAssigned()), true
+}
+
+// CPUBound returns true if the function uses most of its allocated CPU, so that reducing memory
+// would severely increase duration. It's only known if Lambda Insights data was collected. The
+// share of duration spent using CPU, which can also be estimated without Lambda Insights, is used
+// to classify the workload, see Workload.
+func (fr FunctionReports) CPUBound() bool {
+	utilisation, ok := fr.CPUUtilisation()
+	return ok && utilisation >= cpuBoundUtilisation
+}
+
+// CPUMemoryFloor is the smallest memory size (MB) that allocates enough CPU for the function to
+// stay below the CPU-bound utilisation. It's zero if Lambda Insights data wasn't collected.
+func (fr FunctionReports) CPUMemoryFloor() int64 {
+	vcpus, ok := fr.AvgVCPUs()
+	if !ok {
+		return 0
+	}
+	return int64(math.Ceil(vcpus * singleVCPUMemory / cpuBoundUtilisation))
+}
+
+func displayLambdaInsights(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	var rows []FunctionReports
+	for _, fr := range reportContent {
+		if _, ok := fr.CPUUtilisation(); ok {
+			rows = append(rows, fr)
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Lambda Insights")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Memory", "Avg vCPUs", "CPU Utilisation", "Network per Invocation", "CPU-bound"}, "\t"))
+	for _, fr := range rows {
+		vcpus, _ := fr.AvgVCPUs()
+		utilisation, _ := fr.CPUUtilisation()
+		network := "-"
+		if n := fr.invocationCount(); n > 0 {
+			network = formatBytes(float64(fr.LambdaInsights.NetworkBytes) / float64(n))
+		}
+		cpuBound := "no"
+		if fr.CPUBound() {
+			cpuBound = "yes"
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			fr.Name,
+			fr.Region,
+			fmt.Sprintf("%d MB", fr.MemoryAssigned()),
+			format.Decimal(vcpus, 2),
+			format.Percent(utilisation*100, 0),
+			network,
+			cpuBound,
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "CPU is allocated in proportion to memory. Memory recommendations keep CPU utilisation below %.0f%%, so that\n", cpuBoundUtilisation*100)
+	fmt.Fprintln(w, "CPU-bound functions aren't slowed down by a memory reduction.")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// LayerSummary aggregates the functions that use a layer, across all versions of the layer.
+type LayerSummary struct {
+	// ARN of the layer, without the version.
+	ARN       string
+	Versions  []string
+	Functions int
+	// MaxCodeSize is the size of the largest version of the layer in bytes.
+	MaxCodeSize int64
+	// Currency of the costs. Layers are regional, so the functions that use a layer are priced
+	// in the same currency.
+	Currency string
+	// MonthlyCost is the total monthly cost of the functions that use the layer.
+	MonthlyCost float64
+	// MonthlyInitOverhead estimates the monthly cost of init duration attributable to
+	// the layer, based on the layer's share of the total deployment size of each function.
+	MonthlyInitOverhead float64
+}
+
+// splitLayerVersion splits a layer version ARN into the layer ARN and the version.
+func splitLayerVersion(arn string) (layer, version string) {
+	index := strings.LastIndex(arn, ":")
+	if index < 0 {
+		return arn, ""
+	}
+	return arn[:index], arn[index+1:]
+}
+
+// MonthlyInitCost is the monthly cost of the init duration of cold starts.
+func (fr FunctionReports) MonthlyInitCost() float64 {
+	var seconds float64
+	for _, r := range fr.Reports {
+		seconds += r.InitDuration.Seconds()
+	}
+	gb := float64(fr.MemoryAssigned()) / 1024.0
+	return seconds * gb * priceForRegion(fr.Region).GBSecond(fr.Architecture) / fr.Days() * 30
+}
+
+func summariseLayers(reportContent []FunctionReports) (layers []LayerSummary) {
+	indexes := map[string]int{}
+	for _, rc := range reportContent {
+		if len(rc.Layers) == 0 {
+			continue
+		}
+		totalSize := rc.CodeSize
+		for _, l := range rc.Layers {
+			totalSize += l.CodeSize
+		}
+		monthlyCost := rc.DailyCost() * 30
+		initCost := rc.MonthlyInitCost()
+		for _, l := range rc.Layers {
+			arn, version := splitLayerVersion(l.ARN)
+			index, ok := indexes[arn]
+			if !ok {
+				index = len(layers)
+				indexes[arn] = index
+				layers = append(layers, LayerSummary{ARN: arn, Currency: rc.Currency()})
+			}
+			ls := &layers[index]
+			ls.Functions++
+			ls.MonthlyCost += monthlyCost
+			if totalSize > 0 {
+				ls.MonthlyInitOverhead += initCost * float64(l.CodeSize) / float64(totalSize)
+			}
+			if l.CodeSize > ls.MaxCodeSize {
+				ls.MaxCodeSize = l.CodeSize
+			}
+			if !contains(ls.Versions, version) {
+				ls.Versions = append(ls.Versions, version)
+			}
+		}
+	}
+	sort.Slice(layers, func(i, j int) bool {
+		return layers[i].MonthlyCost > layers[j].MonthlyCost
+	})
+	return layers
+}
+
+func displayLayers(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	layers := summariseLayers(reportContent)
+	if len(layers) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Layers")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Layer", "Versions", "Functions", "Size (MB)", "Monthly Cost (functions)", "Monthly Init Overhead (est.)"}, "\t"))
+	for _, l := range layers {
+		sort.Strings(l.Versions)
+		fmt.Fprintln(tw, strings.Join([]string{
+			l.ARN,
+			strings.Join(l.Versions, ","),
+			fmt.Sprintf("%d", l.Functions),
+			format.Decimal(float64(l.MaxCodeSize)/1024/1024, 1),
+			format.Money(l.MonthlyCost, l.Currency, 2),
+			format.Money(l.MonthlyInitOverhead, l.Currency, 2),
+		}, "\t"))
+	}
+	tw.Flush()
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
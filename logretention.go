@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Retention period suggested for log groups that never expire.
+const suggestedLogRetentionDays = 30
+
+// LogRetention describes the log group's retention setting, e.g. 14 days or never expires.
+func (fr FunctionReports) LogRetention() string {
+	switch {
+	case fr.LogGroupNeverExpires:
+		return "never expires"
+	case fr.LogRetentionDays > 0:
+		return fmt.Sprintf("%d days", fr.LogRetentionDays)
+	}
+	return ""
+}
+
+// MonthlyLogStorageCost is the monthly cost of storing the log group's data, from its stored bytes.
+func (fr FunctionReports) MonthlyLogStorageCost() float64 {
+	if fr.LogStoredBytes == nil {
+		return 0
+	}
+	return float64(*fr.LogStoredBytes) / bytesPerGB * priceForRegion(fr.Region).LogStoragePrice()
+}
+
+// LogRetentionSavings estimates the monthly storage savings of setting a retention period on a
+// log group that never expires. The log group would keep the data ingested during the retention
+// period, estimated from the IncomingBytes metric, and the rest would be deleted. It returns false
+// if the log group has a retention period, or the stored or incoming bytes weren't collected.
+func (fr FunctionReports) LogRetentionSavings(days int) (savings float64, ok bool) {
+	if !fr.LogGroupNeverExpires || fr.LogStoredBytes == nil || fr.LogIncomingBytes == nil || fr.Days() <= 0 {
+		return 0, false
+	}
+	retained := float64(*fr.LogIncomingBytes) / fr.Days() * float64(days)
+	deleted := float64(*fr.LogStoredBytes) - retained
+	if deleted <= 0 {
+		return 0, true
+	}
+	return deleted / bytesPerGB * priceForRegion(fr.Region).LogStoragePrice(), true
+}
+
+// logRetentionRecommendation identifies log groups that are set to never expire.
+func logRetentionRecommendation(fr FunctionReports) (rec Recommendation, ok bool) {
+	if !fr.LogGroupNeverExpires {
+		return
+	}
+	rec = Recommendation{
+		Type:        recommendationLogRetention,
+		Description: fmt.Sprintf("log group never expires, set a retention period, e.g. %d days", suggestedLogRetentionDays),
+	}
+	if fr.LogStoredBytes != nil {
+		rec.Description = fmt.Sprintf("log group never expires and stores %s, set a retention period, e.g. %d days", formatBytes(float64(*fr.LogStoredBytes)), suggestedLogRetentionDays)
+	}
+	if savings, ok := fr.LogRetentionSavings(suggestedLogRetentionDays); ok {
+		rec.MonthlySavings = savings
+		rec.Rationale = fmt.Sprintf("keeps %d days of logs at the current ingestion rate, storage savings grow each month the log group isn't expired", suggestedLogRetentionDays)
+	}
+	return rec, true
+}
+
+func displayLogRetention(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	var groups []FunctionReports
+	for _, fr := range reportContent {
+		if fr.LogStoredBytes != nil {
+			groups = append(groups, fr)
+		}
+	}
+	if len(groups) == 0 {
+		return
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return *groups[i].LogStoredBytes > *groups[j].LogStoredBytes
+	})
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Log retention")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Retention", "Stored", "Monthly Storage Cost", fmt.Sprintf("Monthly Savings (%d days)", suggestedLogRetentionDays)}, "\t"))
+	for _, fr := range groups {
+		savings := "N/A"
+		if s, ok := fr.LogRetentionSavings(suggestedLogRetentionDays); ok {
+			savings = format.Money(s, fr.Currency(), 2)
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			fr.Name,
+			fr.LogRetention(),
+			formatBytes(float64(*fr.LogStoredBytes)),
+			format.Money(fr.MonthlyLogStorageCost(), fr.Currency(), 2),
+			savings,
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Stored bytes are reported by CloudWatch Logs, and storage is priced in the currency of each region, e.g. %s per GB-month in us-east-1.\n", format.Money(priceForRegion("us-east-1").LogStoragePrice(), currencyUSD, 2))
+}
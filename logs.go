@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// getLogGroup returns the named log group, or nil if it doesn't exist.
+func getLogGroup(ctx context.Context, cwLogsClient *cloudwatchlogs.Client, region, logGroupName string) (lg *cwtypes.LogGroup, err error) {
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(cwLogsClient, &cloudwatchlogs.DescribeLogGroupsInput{
+		LogGroupNamePrefix: &logGroupName,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, func(o *cloudwatchlogs.Options) {
+			o.Region = region
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getLogGroup: failed to describe log groups: %w", err)
+		}
+		for i := range page.LogGroups {
+			if page.LogGroups[i].LogGroupName != nil && *page.LogGroups[i].LogGroupName == logGroupName {
+				return &page.LogGroups[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// clampToRetention moves the start of the window forward if the log group doesn't retain logs
+// for the whole window. It returns true if the window was clamped.
+func clampToRetention(lg *cwtypes.LogGroup, start, end time.Time) (clampedStart time.Time, clamped bool) {
+	if lg == nil || lg.RetentionInDays == nil || *lg.RetentionInDays <= 0 {
+		return start, false
+	}
+	earliest := end.Add(time.Duration(*lg.RetentionInDays) * time.Hour * -24)
+	if start.Before(earliest) {
+		return earliest, true
+	}
+	return start, false
+}
+
+// parseWindow parses a duration, with support for a "d" suffix for days, e.g. "7d".
+func parseWindow(v string) (d time.Duration, err error) {
+	if strings.HasSuffix(v, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+		if err != nil {
+			return d, fmt.Errorf("invalid window %q: %w", v, err)
+		}
+		d = time.Duration(days) * time.Hour * 24
+	} else {
+		d, err = time.ParseDuration(v)
+		if err != nil {
+			return d, fmt.Errorf("invalid window %q: %w", v, err)
+		}
+	}
+	if d <= 0 {
+		return d, fmt.Errorf("invalid window %q: must be positive", v)
+	}
+	return d, nil
+}
@@ -2,20 +2,27 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
+	"unicode/utf8"
 
+	"github.com/a-h/lambdacost/pricing"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
@@ -23,13 +30,124 @@ import (
 )
 
 var flagRegion = flag.String("region", "", "The AWS region to query")
+var flagFunctionsFile = flag.String("functions-file", "", "Path to a newline separated list of function names or ARNs to analyse, instead of listing all functions")
+var flagWindow = flag.String("window", "1d", "The time window of logs to analyse, e.g. 1d, 7d or 12h")
+var flagCollector = flag.String("collector", collectorAuto, "The collector used to get log data: "+strings.Join(collectorNames(), ", "))
+var flagLogsDir = flag.String("logs-dir", "", "Directory of {functionName}.log files, used by the file collector")
+var flagQuarantineFile = flag.String("quarantine-file", "quarantine.jsonl", "Path to append REPORT lines that could not be parsed to, or empty to disable")
+var flagPreselectMinMonthlyCost = flag.Float64("preselect-min-monthly-cost", 0, "Skip downloading logs for functions whose maximum possible monthly cost, estimated from metrics, is below this value in USD, e.g. 0.5")
+var flagMaxPagesPerFunction = flag.Int("max-pages-per-function", 0, "Stop collecting a function's logs after this many pages, or Logs Insights queries, and flag it as partial, or 0 for no limit")
+var flagMaxDuration = flag.Duration("max-duration", 0, "Stop collecting a function's logs after this long, e.g. 5m, and flag it as partial, or 0 for no limit")
+var flagTriggers = flag.Bool("triggers", false, "Find the triggers of each function, e.g. API Gateway or SQS, to report cost by trigger")
+var flagDeadline = flag.Duration("deadline", 0, "Stop collecting after this long, e.g. 30m, and write a partial report, or 0 for no limit")
+var flagQualifier = flag.String("qualifier", "", "Only collect the logs of the version that an alias or version refers to, e.g. live, 3 or $LATEST")
+var flagShard = flag.String("shard", "", "Only collect a deterministic slice of functions, e.g. 3/8 for the third of eight shards, for parallel collection")
+var flagRefresh = flag.Bool("refresh", false, "Download log data again, even if the cached report data is newer than -cache-max-age. Cached function metadata is still used")
+var flagRefreshMetadata = flag.Bool("refresh-metadata", false, "List functions and get their configuration, tags and provisioned concurrency again, even if the cached metadata is newer than -metadata-max-age")
+var flagCacheMaxAge = flag.Duration("cache-max-age", defaultLogDataMaxAge, "Maximum age of cached report data before log data is downloaded again, or 0 to never expire")
+var flagMetadataMaxAge = flag.Duration("metadata-max-age", defaultMetadataMaxAge, "Maximum age of cached function metadata before functions are listed again, or 0 to never expire")
+var flagDiscoverRegions = flag.Bool("discover-regions", false, "Collect from every enabled region that contains functions, instead of only the configured region")
+var flagSyntheticMarkers = flag.String("synthetic-marker", "", "Comma separated text logged while handling synthetic requests, e.g. warm-up pings or health checks, so that they can be reported separately")
+var flagFaultInject = flag.String("fault-inject", "", "Inject faults into AWS API calls at the given probabilities, e.g. throttle=0.2,partial=0.05,malformed=0.01,seed=42, to check that retries, partial data and malformed events are handled")
+var flagDemo = flag.Bool("demo", false, "Use generated demo data instead of collecting data from AWS")
+var flagOutput = newOutputFlags(flag.CommandLine)
+var flagAWS = newAWSFlags(flag.CommandLine)
 
-func main() {
-	flag.Parse()
+func newLog() *zap.Logger {
 	log, err := zap.NewProduction()
 	if err != nil {
 		panic(fmt.Sprintf("could not create log: %v", err))
 	}
+	return log
+}
+
+func main() {
+	if startPipelineFunction() {
+		return
+	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "merge":
+			mergeCmd(os.Args[2:])
+			return
+		case "export":
+			exportCmd(os.Args[2:])
+			return
+		case "import":
+			importCmd(os.Args[2:])
+			return
+		case "ack":
+			ackCmd(os.Args[2:])
+			return
+		case "report":
+			reportCmd(os.Args[2:])
+			return
+		case "apply":
+			applyCmd(os.Args[2:])
+			return
+		case "show":
+			showCmd(os.Args[2:])
+			return
+		case "query":
+			queryCmd(os.Args[2:])
+			return
+		case "calc":
+			calcCmd(os.Args[2:])
+			return
+		case "invoice":
+			invoiceCmd(os.Args[2:])
+			return
+		case "plan":
+			planCmd(os.Args[2:])
+			return
+		case "simulate":
+			simulateCmd(os.Args[2:])
+			return
+		case "schema":
+			schemaCmd(os.Args[2:])
+			return
+		case "verify-pricing":
+			verifyPricingCmd(os.Args[2:])
+			return
+		case "deploy-pipeline":
+			deployPipelineCmd(os.Args[2:])
+			return
+		}
+	}
+	flag.Parse()
+	log := newLog()
+	settings := mustLoadSettings(log, *flagOutput.config)
+	// Check the report options before collecting data, which may take a long time.
+	opts, err := flagOutput.reportOptions(settings)
+	if err != nil {
+		log.Fatal("invalid report options", zap.Error(err))
+	}
+	windowName, window, err := collectionWindow(*flagWindow, flagPassed(flag.CommandLine, "window"), *flagOutput.compare)
+	if err != nil {
+		log.Fatal("invalid window", zap.Error(err))
+	}
+	functionShard, err := parseShard(*flagShard)
+	if err != nil {
+		log.Fatal("could not parse shard", zap.Error(err))
+	}
+	if *flagDiscoverRegions && *flagFunctionsFile != "" {
+		log.Fatal("-discover-regions can't be used with -functions-file, since the regions are taken from the function ARNs")
+	}
+	if *flagDemo {
+		log.Info("generating demo data")
+		functionReports := generateDemoReports(1, time.Now(), window)
+		if err = writeFunctionReports("demo.json", functionReports); err != nil {
+			log.Fatal("could not export JSON", zap.Error(err))
+		}
+		audit := newAuditLog(*flagOutput.auditLog, localActor())
+		status := writeOutputs(log, functionReports, settings, flagOutput, audit)
+		audit.Close()
+		flagOutput.writeStatus(log, status)
+		if !status.Passed {
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Handle Ctrl-C.
 	signals := make(chan os.Signal, 1)
@@ -41,16 +159,30 @@ func main() {
 		fmt.Println()
 		cancel()
 	}()
+	if *flagDeadline > 0 {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithTimeout(ctx, *flagDeadline)
+		defer cancelDeadline()
+	}
 
 	// Set up the AWS SDK.
-	cfg, err := config.LoadDefaultConfig(ctx)
+	cfg, err := loadAWSConfig(ctx, flagAWS.options())
 	if err != nil {
 		log.Fatal("could not load AWS config", zap.Error(err))
 	}
 	if flagRegion != nil && *flagRegion != "" {
 		cfg.Region = *flagRegion
 	}
-	log = log.With(zap.String("region", cfg.Region))
+	var stats scanStats
+	cfg.APIOptions = append(cfg.APIOptions, stats.countAPICalls)
+	var faults *faultInjector
+	if *flagFaultInject != "" {
+		if faults, err = parseFaultInjection(*flagFaultInject); err != nil {
+			log.Fatal("invalid fault injection", zap.Error(err))
+		}
+		log.Warn("injecting faults into AWS API calls, report data is stored separately", zap.String("faults", *flagFaultInject))
+		cfg.APIOptions = append(cfg.APIOptions, faults.addMiddleware)
+	}
 
 	// Find current account.
 	log.Info("Looking up account ID")
@@ -59,184 +191,879 @@ func main() {
 		log.Fatal("could not get current identity, are you logged in?", zap.Error(err))
 	}
 	log = log.With(zap.String("account", *identity.Account))
+	accountName := getAccountName(ctx, log, cfg, settings, *identity.Account)
+	log = log.With(zap.String("accountName", accountName))
+	audit := newAuditLog(*flagOutput.auditLog, aws.ToString(identity.Arn))
+	defer audit.Close()
 
-	// Create the file name used to store the data.
-	outputFileName := fmt.Sprintf("%s-%s.json", *identity.Account, cfg.Region)
-
-	// Run the report.
-	var functionReports []FunctionReports
-	// If the data doesn't exist on disk, get it and cache it.
-	if _, err := os.Stat(outputFileName); err != nil {
-		log.Info("no existing report data found, downloading logs from AWS")
-		functionReports, err = getFunctionReports(ctx, log, cfg)
-		if err != nil {
-			log.Fatal("failed to get function reports", zap.Error(err))
+	// collectRegion returns the report data of the functions in the region, using cached data
+	// where it's fresh.
+	collectRegion := func(log *zap.Logger, cfg aws.Config) (functionReports []FunctionReports, passed, partial bool) {
+		// Create the file names used to store the data.
+		outputFileNameParts := []string{accountName, cfg.Region}
+		if *flagFunctionsFile != "" {
+			// Reports for a specific set of functions are stored separately to full account reports.
+			outputFileNameParts = append(outputFileNameParts, strings.TrimSuffix(filepath.Base(*flagFunctionsFile), filepath.Ext(*flagFunctionsFile)))
 		}
-		log.Info("creating report JSON file")
-		f, err := os.Create(outputFileName)
-		if err != nil {
-			log.Fatal("could not create report JSON file", zap.Error(err))
+		// Function metadata doesn't depend on the window, so it's shared by reports with different windows.
+		metadataFileNameParts := append([]string{}, outputFileNameParts...)
+		if window != time.Hour*24 {
+			outputFileNameParts = append(outputFileNameParts, windowName)
 		}
-		defer f.Close()
-		err = json.NewEncoder(f).Encode(functionReports)
-		if err != nil {
-			log.Fatal("could not export JSON", zap.Error(err))
+		if *flagQualifier != "" {
+			outputFileNameParts = append(outputFileNameParts, qualifierFileName(*flagQualifier))
+			metadataFileNameParts = append(metadataFileNameParts, qualifierFileName(*flagQualifier))
 		}
-		log.Info("downloading logs complete")
-	} else {
-		log.Info("existing report data found, using it", zap.String("filename", outputFileName))
-		// Now that the data is found, display the results.
-		input, err := os.Open(outputFileName)
-		if err != nil {
-			log.Fatal("could not get open output.json", zap.Error(err))
+		if functionShard.Count > 1 {
+			outputFileNameParts = append(outputFileNameParts, functionShard.String())
+			metadataFileNameParts = append(metadataFileNameParts, functionShard.String())
+		}
+		// Data collected with injected faults mustn't be used as the cache of normal runs.
+		if faults != nil {
+			outputFileNameParts = append(outputFileNameParts, "faults")
+			metadataFileNameParts = append(metadataFileNameParts, "faults")
 		}
-		err = json.NewDecoder(input).Decode(&functionReports)
+		outputFileName := strings.Join(outputFileNameParts, "-") + ".json"
+		metadataFileName := strings.Join(metadataFileNameParts, "-") + "-metadata.json"
+		opts := collectOptions{
+			FunctionsFile:    *flagFunctionsFile,
+			Window:           window,
+			Collector:        *flagCollector,
+			Collectors:       settings.Collectors,
+			LogsDir:          *flagLogsDir,
+			QuarantineFile:   *flagQuarantineFile,
+			Shard:            functionShard,
+			MinMonthlyCost:   *flagPreselectMinMonthlyCost,
+			MaxPages:         *flagMaxPagesPerFunction,
+			MaxDuration:      *flagMaxDuration,
+			Triggers:         *flagTriggers,
+			Qualifier:        *flagQualifier,
+			SyntheticMarkers: append(append([]string{}, settings.SyntheticMarkers...), splitList(*flagSyntheticMarkers)...),
+		}
+		// getMetadata returns the cached function metadata, or collects it and updates the cache.
+		// If the deadline expired before every function was listed, the metadata of the functions
+		// that were listed is returned, and partial is true.
+		getMetadata := func() (metadata []FunctionReports, partial bool) {
+			if !*flagRefreshMetadata {
+				metadata, ok, err := readMetadataCache(metadataFileName, *flagMetadataMaxAge, *flagTriggers, time.Now())
+				if err != nil {
+					log.Warn("could not read cached function metadata, collecting it again", zap.Error(err))
+				}
+				if ok {
+					log.Info("existing function metadata found, using it", zap.String("filename", metadataFileName))
+					return metadata, false
+				}
+			}
+			metadata, err := getFunctionMetadata(ctx, log, cfg, *identity.Account, accountName, opts)
+			if errors.Is(err, errFunctionListPartial) {
+				log.Error("deadline expired before every function was listed, the report is partial", zap.Duration("deadline", *flagDeadline), zap.Int("functionCount", len(metadata)))
+				return metadata, true
+			}
+			if err != nil {
+				log.Fatal("failed to get function metadata", zap.Error(err))
+			}
+			written, err := writeMetadataCache(metadataFileName, metadata, *flagTriggers, time.Now())
+			if err != nil {
+				log.Fatal("could not write function metadata", zap.Error(err))
+			}
+			if !written {
+				log.Warn("function metadata has errors or is partial, so it wasn't cached")
+			}
+			return metadata, false
+		}
+
+		// Run the report.
+		passed = true
+		// If the data doesn't exist on disk, or has expired, get it and cache it.
+		var fresh bool
+		if !*flagRefresh {
+			functionReports, fresh, err = cacheFresh(outputFileName, *flagCacheMaxAge, time.Now())
+			if err != nil {
+				log.Warn("could not read cached report data, downloading logs again", zap.Error(err))
+			}
+		}
+		if !fresh {
+			log.Info("no fresh report data found, downloading logs from AWS")
+			metadata, metadataPartial := getMetadata()
+			functionReports, err = collectFunctionLogs(ctx, log, cfg, &stats, metadata, opts)
+			if err != nil {
+				log.Fatal("failed to get function reports", zap.Error(err))
+			}
+			// Functions that were listed before the deadline are marked as partial, so a run that
+			// uses the cached data also fails, but an empty list can't be, so it isn't cached.
+			if metadataPartial && len(functionReports) == 0 {
+				log.Warn("no functions were listed before the deadline, so the report data wasn't written")
+			} else {
+				log.Info("creating report JSON file")
+				if err = writeFunctionReports(outputFileName, functionReports); err != nil {
+					log.Fatal("could not export JSON", zap.Error(err))
+				}
+			}
+			log.Info("downloading logs complete")
+			scan := AuditEntry{
+				Action:    auditActionScan,
+				Account:   *identity.Account,
+				Region:    cfg.Region,
+				Functions: len(functionReports),
+				Status:    auditStatusComplete,
+				Details:   fmt.Sprintf("%s window, written to %s", windowName, outputFileName),
+			}
+			if count := countDeadlineExceeded(functionReports); count > 0 || metadataPartial {
+				log.Error("deadline expired before collection was complete, the report is partial", zap.Duration("deadline", *flagDeadline), zap.Int("partialFunctionCount", count))
+				passed = false
+				partial = true
+				scan.Status = auditStatusPartial
+			}
+			if err = audit.Add(scan); err != nil {
+				log.Fatal("could not write audit log", zap.Error(err))
+			}
+		} else {
+			log.Info("existing report data found, using it", zap.String("filename", outputFileName), zap.Time("collected", reportDataCollected(functionReports)))
+			if count := countDeadlineExceeded(functionReports); count > 0 {
+				log.Error("the cached report data is partial, since the deadline expired before its collection was complete", zap.String("filename", outputFileName), zap.Int("partialFunctionCount", count))
+				passed = false
+				partial = true
+			}
+			if *flagRefreshMetadata {
+				metadata, metadataPartial := getMetadata()
+				if metadataPartial {
+					// Functions that weren't listed would be removed from the report data.
+					log.Error("the report data wasn't updated, since the function metadata is partial")
+					return functionReports, false, true
+				}
+				var removed, added []string
+				functionReports, removed, added = applyMetadata(functionReports, metadata)
+				if len(removed) > 0 {
+					log.Info("removed functions that no longer exist from the report data", zap.Strings("functionNames", removed))
+				}
+				if len(added) > 0 {
+					log.Warn("new functions have no log data until it's downloaded again with -refresh", zap.Strings("functionNames", added))
+				}
+				if err = writeFunctionReports(outputFileName, functionReports); err != nil {
+					log.Fatal("could not export JSON", zap.Error(err))
+				}
+			}
+		}
+		return functionReports, passed, partial
+	}
+
+	regions := []string{cfg.Region}
+	var regionErrors []RegionError
+	if *flagDiscoverRegions {
+		log.Info("Discovering regions that contain functions")
+		regions, regionErrors, err = discoverRegions(ctx, log, cfg)
 		if err != nil {
-			log.Fatal("could not get decode output.json", zap.Error(err))
+			log.Fatal("could not discover regions", zap.Error(err))
 		}
+		log.Info("Found regions", zap.Strings("regions", regions))
+	}
+	var functionReports []FunctionReports
+	passed, partial := true, false
+	for _, region := range regions {
+		regionCfg := cfg.Copy()
+		regionCfg.Region = region
+		regionReports, regionPassed, regionPartial := collectRegion(log.With(zap.String("region", region)), regionCfg)
+		functionReports = append(functionReports, regionReports...)
+		passed = passed && regionPassed
+		partial = partial || regionPartial
+	}
+	if faults != nil {
+		faults.logInjected(log)
 	}
 
 	// Display the results.
-	displayReport(functionReports)
+	status := writeOutputs(log, functionReports, settings, flagOutput, audit)
+	status.Passed = status.Passed && passed
+	status.Partial = status.Partial || partial
+	status.RegionErrors = regionErrors
+	if stats.TotalAPICalls() > 0 {
+		displayScanStats(extrasWriter(*flagOutput.output), &stats, opts.Format)
+	}
+	flagOutput.writeStatus(log, status)
+	if !status.Passed {
+		audit.Close()
+		os.Exit(1)
+	}
 }
 
-func displayReport(reportContent []FunctionReports) {
-	sort.Slice(reportContent, func(i, j int) bool {
-		a := reportContent[i].Cost()
-		b := reportContent[j].Cost()
-		return a > b
-	})
-	tw := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
-	fmt.Fprintln(tw, strings.Join([]string{
+func displayReport(w io.Writer, reportContent []FunctionReports, opts reportOptions) {
+	displayDeadlineExceeded(w, reportContent)
+	// Functions without log data are listed separately, but still need their required tags.
+	allFunctions := reportContent
+	reportContent, noLogData, preselectionSkipped := splitLogData(reportContent)
+	// Only show the account and region columns when the report covers more than one.
+	withAccount := accountRegionCells(reportContent)
+	colorize := func(s severity, line string) string {
+		if !opts.UseColor {
+			return line
+		}
+		return s.Color() + line + colorReset
+	}
+	if start, end := reportWindow(reportContent); !start.IsZero() {
+		fmt.Fprintf(w, "Window: %s to %s\n\n", opts.Format.Time(start), opts.Format.Time(end))
+	}
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, colorize(severityNone, strings.Join(withAccount("Account", "Region", []string{
 		"Name",
 		"Arch",
 		"Daily",
 		"Monthly",
-		"Invocations",
-		"Avg",             // Duration
+		"Monthly arm64", // Same RAM
+		"Monthly arm64", // Optimal RAM
+		"Requests",
+		"Executions",
+		"Avg Warm",        // Duration
+		"Avg Cold",        // Duration
+		"Max",             // Duration
+		"Max",             // Billed Duration
 		"RAM",             // Max
 		"RAM",             // Assigned
 		"RAM",             // Optimal)
 		"Monthly Savings", // arm64 + RAM
-	}, "\t"))
-	fmt.Fprintln(tw, strings.Join([]string{
+		"Data",            // Quality
+		"Notes",
+	}), "\t")))
+	fmt.Fprintln(tw, colorize(severityNone, strings.Join(withAccount("", "", []string{
 		"",
 		"",
 		"",
+		"(current)",
+		"(same RAM)",
+		"(optimal RAM)",
 		"",
-		"",
-		"Duration", // Avg
+		"(billed)", // Executions
+		"Duration", // Avg Warm
+		"Duration", // Avg Cold
+		"Duration", // Max
+		"Billed",   // Max
 		"Max",      // RAM
 		"Assigned", // RAM
 		"Optimal",  // RAM
 		"(arm64 + RAM)",
-	}, "\t"))
+		"Quality", // Data
+		"",
+	}), "\t")))
 	for _, rc := range reportContent {
-		var pcUsed float64
-		if rc.MemoryAssigned() > 0 {
-			pcUsed = (float64(rc.MaxMemoryUsed()) / float64(rc.MemoryAssigned())) * 100.0
-		}
-		cost := rc.Cost()
-		optimisedRAM, optimisedCost := rc.OptimisedCost()
-		optimisedRAMDisplay := fmt.Sprintf("%d", optimisedRAM)
-		if optimisedRAM == 0 {
-			optimisedRAMDisplay = "N/A"
-		}
-		monthlySavings := (cost * 30) - (optimisedCost * 30)
-		if monthlySavings < 0 {
-			monthlySavings = 0.0
-		}
-		fmt.Fprintln(tw, strings.Join([]string{
-			rc.Name,
-			rc.Architecture,
-			fmt.Sprintf("$%.5f", cost),
-			fmt.Sprintf("$%.5f", cost*30),
-			fmt.Sprintf("%d", len(rc.Reports)),
-			fmt.Sprintf("%v", rc.AvgDuration()),
-			fmt.Sprintf("%d (%.2f%%)", rc.MaxMemoryUsed(), pcUsed),
-			fmt.Sprintf("%d", rc.MemoryAssigned()),
-			optimisedRAMDisplay,
-			fmt.Sprintf("$%.2f", monthlySavings),
-		}, "\t"))
+		fmt.Fprintln(tw, colorize(rc.Severity(), strings.Join(withAccount(rc.DisplayAccount(), rc.Region, reportCells(rc, opts)), "\t")))
 	}
 	tw.Flush()
-	return
+	displayCurrency(w, reportContent)
+	displayExtrapolationWarnings(w, extrapolationWarnings(reportContent, opts))
+	displayIncomplete(w, reportContent)
+	displayInvocationMismatches(w, reportContent, opts.InvocationTolerance)
+	displayMemoryMismatches(w, reportContent)
+	displayConfigDrift(w, reportContent, opts.Format)
+	displayLambdaInsights(w, reportContent, opts.Format)
+	displayLayers(w, reportContent, opts.Format)
+	displayMissingTags(w, allFunctions, opts.RequiredTags, opts.Format)
+	displayOwners(w, reportContent, opts.Owners, opts.Format)
+	displayRegionComparison(w, reportContent, opts.WorkloadTag, opts.Format)
+	displayLogicalServices(w, reportContent, opts.Format)
+	displayCostByTrigger(w, reportContent, opts.Format)
+	displayDuplicateLogging(w, reportContent, opts.Format)
+	displayLogRetention(w, reportContent, opts.Format)
+	displayMemoryTrends(w, reportContent)
+	displayConsolidationGroups(w, reportContent, opts.Format)
+	displayWindowChanges(w, reportContent, opts.CompareWindows, opts.Format)
+	displayRecommendations(w, reportContent, opts.Recommenders, opts.Format)
+	displayNoLogData(w, noLogData)
+	displayPreselectionSkipped(w, preselectionSkipped, opts.Format)
+	displayErrors(w, reportContent)
 }
 
-func getFunctionReports(ctx context.Context, log *zap.Logger, cfg aws.Config) (functionReports []FunctionReports, err error) {
+func displayIncomplete(w io.Writer, reportContent []FunctionReports) {
+	var incomplete []FunctionReports
+	for _, rc := range reportContent {
+		if rc.Incomplete {
+			incomplete = append(incomplete, rc)
+		}
+	}
+	if len(incomplete) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "* Incomplete data, costs are averaged over the data that was available")
+	fmt.Fprintln(w)
+	for _, rc := range incomplete {
+		fmt.Fprintf(w, "  %s: %s\n", rc.Name, strings.Join(rc.Warnings, ", "))
+	}
+}
+
+func displayNoLogData(w io.Writer, reportContent []FunctionReports) {
+	if len(reportContent) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "No log data")
+	fmt.Fprintln(w)
+	for _, rc := range reportContent {
+		fmt.Fprintf(w, "  %s (%s)\n", rc.Name, rc.Region)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The log group for these functions was not found. Check that the function's execution role")
+	fmt.Fprintln(w, "has permission to write to CloudWatch Logs, and that the log group has not been deleted")
+	fmt.Fprintln(w, "or expired due to its retention settings.")
+}
+
+// reportCells returns the cells of the function's row in the report.
+func reportCells(rc FunctionReports, opts reportOptions) []string {
+	var pcUsed float64
+	if rc.MemoryAssigned() > 0 {
+		pcUsed = (float64(rc.MaxMemoryUsed()) / float64(rc.MemoryAssigned())) * 100.0
+	}
+	cost := rc.DailyCost()
+	optimisedRAM, optimisedCost := rc.OptimisedCost()
+	optimisedRAMDisplay := fmt.Sprintf("%d", optimisedRAM)
+	if optimisedRAM == 0 {
+		optimisedRAMDisplay = "N/A"
+	}
+	name := rc.Name
+	if rc.Incomplete {
+		name += " *"
+	}
+	return []string{
+		name,
+		string(rc.Architecture),
+		opts.Format.Money(cost, rc.Currency(), 5),
+		opts.Format.Money(cost*30, rc.Currency(), 5),
+		opts.Format.Money(rc.CostForArchitecture(ArchitectureARM64, 0)/rc.Days()*30, rc.Currency(), 5),
+		opts.Format.Money(optimisedCost/rc.Days()*30, rc.Currency(), 5),
+		fmt.Sprintf("%d", rc.UniqueRequests()),
+		fmt.Sprintf("%d", rc.Executions()),
+		opts.Format.Duration(rc.AvgWarmDuration()),
+		opts.Format.Duration(rc.AvgColdDuration()),
+		opts.Format.Duration(rc.MaxDuration()),
+		opts.Format.Duration(rc.MaxBilledDuration()),
+		fmt.Sprintf("%d (%s)", rc.MaxMemoryUsed(), opts.Format.Percent(pcUsed, 2)),
+		fmt.Sprintf("%d", rc.MemoryAssigned()),
+		optimisedRAMDisplay,
+		opts.Format.MoneyMargin(rc.MonthlySavings(), rc.MonthlySavingsMargin(), rc.Currency(), 2),
+		rc.DataQuality(opts.InvocationTolerance),
+		strings.Join(rc.Notes(opts.Format), "; "),
+	}
+}
+
+// splitLogData separates the functions without log data, and those skipped by preselection, from
+// the functions with log data, which are sorted by daily cost, highest first.
+func splitLogData(reportContent []FunctionReports) (withLogData, noLogData, preselectionSkipped []FunctionReports) {
+	for _, rc := range reportContent {
+		if rc.LogGroupMissing {
+			noLogData = append(noLogData, rc)
+			continue
+		}
+		if rc.PreselectionSkipped {
+			preselectionSkipped = append(preselectionSkipped, rc)
+			continue
+		}
+		withLogData = append(withLogData, rc)
+	}
+	sort.Slice(withLogData, func(i, j int) bool {
+		a := withLogData[i].DailyCost()
+		b := withLogData[j].DailyCost()
+		return a > b
+	})
+	return withLogData, noLogData, preselectionSkipped
+}
+
+// collectOptions control which functions are analysed, and how their logs are collected.
+type collectOptions struct {
+	// FunctionsFile is a list of functions to analyse, instead of listing all functions.
+	FunctionsFile string
+	Window        time.Duration
+	// Collector is the name of the default collector.
+	Collector string
+	// Collectors maps function names to the name of the collector to use for that function.
+	Collectors map[string]string
+	// LogsDir is used by the file collector.
+	LogsDir string
+	// QuarantineFile is where REPORT lines that could not be parsed are written.
+	QuarantineFile string
+	// Shard is the slice of functions to collect.
+	Shard shard
+	// MinMonthlyCost skips downloading logs for functions whose maximum possible monthly cost,
+	// estimated from metrics, is below the threshold. Zero disables preselection.
+	MinMonthlyCost float64
+	// MaxPages and MaxDuration limit the collection of each function's logs, so that a single
+	// large log group can't stall the scan. Zero is unlimited.
+	MaxPages    int
+	MaxDuration time.Duration
+	// Triggers finds the event sources and permissions that invoke each function.
+	Triggers bool
+	// Qualifier is an alias or version. If set, only the log streams of the version it refers to
+	// are collected.
+	Qualifier string
+	// SyntheticMarkers are text logged while handling synthetic requests. The REPORT lines of the
+	// requests that log them are flagged as synthetic.
+	SyntheticMarkers []string
+}
+
+func getFunctionReports(ctx context.Context, log *zap.Logger, cfg aws.Config, stats *scanStats, accountID, accountName string, opts collectOptions) (functionReports []FunctionReports, err error) {
+	functionReports, err = getFunctionMetadata(ctx, log, cfg, accountID, accountName, opts)
+	if err != nil && !errors.Is(err, errFunctionListPartial) {
+		return nil, err
+	}
+	listErr := err
+	if functionReports, err = collectFunctionLogs(ctx, log, cfg, stats, functionReports, opts); err != nil {
+		return nil, err
+	}
+	return functionReports, listErr
+}
+
+// getFunctionMetadata lists the functions, and gets their configuration, provisioned concurrency,
+// tags and triggers. Metadata changes rarely, so it can be cached for longer than log data. If
+// the deadline expires while the functions are listed, the functions that were listed are
+// returned with errFunctionListPartial, and functions whose metadata wasn't read before the
+// deadline are marked as partial.
+func getFunctionMetadata(ctx context.Context, log *zap.Logger, cfg aws.Config, accountID, accountName string, opts collectOptions) (functionReports []FunctionReports, err error) {
 	// Get functions.
-	log.Info("Listing functions")
 	lambdaClient := lambda.NewFromConfig(cfg)
-	lambdaFunctions, err := getLambdaFunctions(ctx, lambdaClient)
+	var lambdaFunctions []types.FunctionConfiguration
+	if opts.FunctionsFile != "" {
+		log.Info("Reading functions file", zap.String("filename", opts.FunctionsFile))
+		var refs []functionRef
+		refs, err = readFunctionsFile(opts.FunctionsFile)
+		if err != nil {
+			return nil, fmt.Errorf("getFunctionMetadata: could not read functions file: %w", err)
+		}
+		lambdaFunctions, err = getLambdaFunctionsFromFile(ctx, lambdaClient, refs)
+	} else {
+		log.Info("Listing functions")
+		lambdaFunctions, err = getLambdaFunctions(ctx, lambdaClient)
+	}
+	var listErr error
 	if err != nil {
-		log.Fatal("could not load functions", zap.Error(err))
+		if !deadlineExceeded(ctx) {
+			return nil, fmt.Errorf("getFunctionMetadata: could not load functions: %w", err)
+		}
+		listErr = errFunctionListPartial
+	}
+	if opts.Shard.Count > 1 {
+		var inShard []types.FunctionConfiguration
+		for _, f := range lambdaFunctions {
+			if opts.Shard.Contains(accountID, functionRegion(f, cfg.Region), *f.FunctionName) {
+				inShard = append(inShard, f)
+			}
+		}
+		log.Info("Selected shard", zap.String("shard", opts.Shard.String()), zap.Int("totalFunctionCount", len(lambdaFunctions)))
+		lambdaFunctions = inShard
 	}
-	log = log.With(zap.Int("functionCount", len(lambdaFunctions)))
-	log.Info("Found functions")
+	if opts.Qualifier != "" {
+		var skipped []string
+		lambdaFunctions, skipped, err = qualifiedFunctions(ctx, lambdaClient, lambdaFunctions, cfg.Region, opts.Qualifier)
+		if err != nil {
+			return nil, err
+		}
+		if len(skipped) > 0 {
+			log.Info("Skipped functions without the qualifier", zap.String("qualifier", opts.Qualifier), zap.Strings("functionNames", skipped))
+		}
+	}
+	log.Info("Found functions", zap.Int("functionCount", len(lambdaFunctions)))
 
-	// Get log streams for each log group.
-	cwLogsClient := cloudwatchlogs.NewFromConfig(cfg)
+	ebClient := eventbridge.NewFromConfig(cfg)
 
 	// Create the function functionReports.
 	functionReports = make([]FunctionReports, len(lambdaFunctions))
+	allocations := make([]map[string]int32, len(lambdaFunctions))
 	for i := range lambdaFunctions {
 		f := lambdaFunctions[i]
+		functionReports[i].Account = accountID
+		functionReports[i].AccountName = accountName
 		functionReports[i].Name = *f.FunctionName
-		var architectures []string
-		for ia := range f.Architectures {
-			architectures = append(architectures, string(f.Architectures[ia]))
+		functionReports[i].Region = functionRegion(f, cfg.Region)
+		functionReports[i].MemorySize = int64(aws.ToInt32(f.MemorySize))
+		if f.Timeout != nil {
+			functionReports[i].Timeout = time.Duration(*f.Timeout) * time.Second
+		}
+		functionReports[i].Description = aws.ToString(f.Description)
+		functionReports[i].Runtime = string(f.Runtime)
+		functionReports[i].PackageType = string(f.PackageType)
+		functionReports[i].CodeSize = f.CodeSize
+		if f.EphemeralStorage != nil {
+			functionReports[i].EphemeralStorage = int64(aws.ToInt32(f.EphemeralStorage.Size))
+		}
+		functionReports[i].SnapStart = f.SnapStart != nil && f.SnapStart.ApplyOn == types.SnapStartApplyOnPublishedVersions
+		if opts.Qualifier != "" {
+			functionReports[i].Qualifier = opts.Qualifier
+			functionReports[i].Version = aws.ToString(f.Version)
+		}
+		for _, l := range f.Layers {
+			functionReports[i].Layers = append(functionReports[i].Layers, Layer{
+				ARN:      aws.ToString(l.Arn),
+				CodeSize: l.CodeSize,
+			})
+		}
+		functionReports[i].Architecture, err = parseArchitecture(f.Architectures)
+		if err != nil {
+			log.Warn("unexpected function architecture, pricing as x86_64", zap.String("functionName", *f.FunctionName), zap.Error(err))
+			functionReports[i].addError(errorKindOther, "parseArchitecture", err)
+		}
+		if deadlineExceeded(ctx) {
+			functionReports[i].markDeadlineExceeded("metadata not collected before the deadline")
+			continue
+		}
+		functionReports[i].ProvisionedConcurrency, allocations[i], err = getProvisionedConcurrency(ctx, lambdaClient, functionReports[i].Region, *f.FunctionName)
+		if err != nil {
+			log.Warn("could not get provisioned concurrency", zap.String("functionName", *f.FunctionName), zap.Error(err))
+			functionReports[i].addError(errorKind(err), "getProvisionedConcurrency", err)
+		}
+		if f.FunctionArn != nil {
+			functionReports[i].Tags, err = getTags(ctx, lambdaClient, functionReports[i].Region, *f.FunctionArn)
+			if err != nil {
+				log.Warn("could not get function tags", zap.String("functionName", *f.FunctionName), zap.Error(err))
+				functionReports[i].addError(errorKind(err), "getTags", err)
+			}
+		}
+		if opts.Triggers {
+			functionReports[i].Triggers, err = getTriggers(ctx, lambdaClient, ebClient, functionReports[i].Region, *f.FunctionName)
+			if err != nil {
+				log.Warn("could not get function triggers", zap.String("functionName", *f.FunctionName), zap.Error(err))
+				functionReports[i].addError(errorKind(err), "getTriggers", err)
+			}
 		}
-		functionReports[i].Architecture = strings.Join(architectures, " ")
 	}
+	// Scheduled actions are listed once for each region, rather than for each function.
+	for region, err := range setProvisionedConcurrencySchedules(ctx, applicationautoscaling.NewFromConfig(cfg), functionReports, allocations) {
+		if deadlineExceeded(ctx) {
+			log.Warn("deadline expired before provisioned concurrency schedules were listed, assuming provisioned concurrency is allocated 24/7", zap.String("functionRegion", region))
+			for i := range functionReports {
+				if functionReports[i].Region == region && functionReports[i].ProvisionedConcurrency > 0 && !functionReports[i].DeadlineExceeded {
+					functionReports[i].markDeadlineExceeded("provisioned concurrency schedules not collected before the deadline")
+				}
+			}
+			continue
+		}
+		log.Warn("could not get provisioned concurrency schedules, assuming provisioned concurrency is allocated 24/7", zap.String("functionRegion", region), zap.Error(err))
+		for i := range functionReports {
+			if functionReports[i].Region == region && functionReports[i].ProvisionedConcurrency > 0 {
+				functionReports[i].addError(errorKind(err), "getProvisionedConcurrencySchedules", err)
+			}
+		}
+	}
+	return functionReports, listErr
+}
+
+// collectFunctionLogs downloads the logs and metrics of the functions, whose metadata has already
+// been collected.
+func collectFunctionLogs(ctx context.Context, log *zap.Logger, cfg aws.Config, stats *scanStats, functionReports []FunctionReports, opts collectOptions) ([]FunctionReports, error) {
+	log = log.With(zap.Int("functionCount", len(functionReports)))
+	cwLogsClient := cloudwatchlogs.NewFromConfig(cfg)
+	cwClient := cloudwatch.NewFromConfig(cfg)
 
 	// Download the log streams.
-	log.Info("Downloading logs")
 	end := time.Now()
-	start := end.Add(time.Hour * -24)
+	// Start at the beginning of a metric period, so that the Invocations metric covers the same
+	// time as the logs.
+	windowStart, _, _ := alignMetricWindow(end.Add(-opts.Window), end)
+	if opts.MinMonthlyCost > 0 {
+		preselectFunctions(ctx, log, cwClient, functionReports, windowStart, end, opts.MinMonthlyCost)
+	}
+	log.Info("Downloading logs")
+	collectors, err := newCollectorSet(collectorDependencies{
+		Config:         cfg,
+		CloudWatchLogs: cwLogsClient,
+		Stats:          stats,
+		LogsDir:        opts.LogsDir,
+	}, opts.Collector, opts.Collectors)
+	if err != nil {
+		return nil, err
+	}
+	q := newQuarantine(opts.QuarantineFile)
+	defer q.Close()
 	var logEventCount int
 	var invocationCount int
-	for i := range lambdaFunctions {
-		logGroupName := fmt.Sprintf("/aws/lambda/%s", *lambdaFunctions[i].FunctionName)
-		log.Info("Downloading logs", zap.String("functionName", *lambdaFunctions[i].FunctionName), zap.Int("functionIndex", i))
-		logEventsPaginator := cloudwatchlogs.NewFilterLogEventsPaginator(cwLogsClient, &cloudwatchlogs.FilterLogEventsInput{
-			LogGroupName: &logGroupName,
-			StartTime:    aws.Int64(start.UnixMilli()),
-			EndTime:      aws.Int64(end.UnixMilli()),
-		})
-		var page *cloudwatchlogs.FilterLogEventsOutput
-		for logEventsPaginator.HasMorePages() {
-			page, err = logEventsPaginator.NextPage(ctx)
+	var synthetic *syntheticRequests
+	processEvent := func(i int, e LogEvent) {
+		synthetic.Observe(e.Message)
+		r, ok, err := getFunctionReport(e.Message)
+		if err != nil {
+			log.Error("getLogStreams: failed to get report", zap.Error(err), zap.String("functionName", functionReports[i].Name), zap.String("logMessage", truncate(e.Message, maxLogMessageLength)))
+			functionReports[i].addError(errorKindParse, "parseReport", err)
+			qErr := q.Add(QuarantineEntry{
+				Function:  functionReports[i].Name,
+				Region:    functionReports[i].Region,
+				Timestamp: e.Timestamp,
+				Error:     err.Error(),
+				Line:      e.Message,
+			})
+			if qErr != nil {
+				log.Warn("could not quarantine report", zap.Error(qErr))
+			}
+			return
+		}
+		logEventCount++
+		if logEventCount%10000 == 0 {
+			log.Info("Working", zap.Int("logEventCount", logEventCount), zap.Int("invocationCount", invocationCount))
+		}
+		if !ok {
+			return
+		}
+		r.Timestamp = e.Timestamp
+		r.Synthetic = synthetic.Contains(r.RequestID)
+		functionReports[i].Reports = append(functionReports[i].Reports, r)
+		invocationCount++
+	}
+	for i := range functionReports {
+		if functionReports[i].PreselectionSkipped {
+			continue
+		}
+		if deadlineExceeded(ctx) {
+			functionReports[i].markDeadlineExceeded("not collected before the deadline")
+			continue
+		}
+		logGroupName := fmt.Sprintf("/aws/lambda/%s", functionReports[i].Name)
+		region := functionReports[i].Region
+		log.Info("Downloading logs", zap.String("functionName", functionReports[i].Name), zap.String("functionRegion", region), zap.Int("functionIndex", i))
+		logGroup, err := getLogGroup(ctx, cwLogsClient, region, logGroupName)
+		if err != nil {
+			log.Error("failed to get log group", zap.Error(err), zap.String("functionName", functionReports[i].Name))
+			functionReports[i].addError(errorKind(err), "getLogGroup", err)
+		} else if logGroup == nil {
+			log.Warn("log group not found, skipping", zap.String("functionName", functionReports[i].Name), zap.String("logGroupName", logGroupName))
+			functionReports[i].LogGroupMissing = true
+			continue
+		}
+		if logGroup != nil && logGroup.LogGroupClass != "" && logGroup.LogGroupClass != cwtypes.LogGroupClassStandard {
+			functionReports[i].LogGroupClass = string(logGroup.LogGroupClass)
+		}
+		functionReports[i].LogGroupNeverExpires = logGroup != nil && logGroup.RetentionInDays == nil
+		if logGroup != nil {
+			if logGroup.RetentionInDays != nil {
+				functionReports[i].LogRetentionDays = *logGroup.RetentionInDays
+			}
+			functionReports[i].LogStoredBytes = logGroup.StoredBytes
+			functionReports[i].SubscriptionFilters, err = getSubscriptionFilters(ctx, cwLogsClient, region, logGroupName)
 			if err != nil {
-				log.Error("getLogStreams: failed to get next page", zap.Error(err), zap.String("functionName", *lambdaFunctions[i].FunctionName))
-				break
+				log.Warn("could not get subscription filters", zap.String("functionName", functionReports[i].Name), zap.Error(err))
+				functionReports[i].addError(errorKind(err), "getSubscriptionFilters", err)
 			}
-			for ei := range page.Events {
-				event := page.Events[ei]
-				r, ok, err := getFunctionReport(*event.Message)
-				if err != nil {
-					log.Error("getLogStreams: failed to get report", zap.Error(err), zap.String("functionName", *lambdaFunctions[i].FunctionName), zap.String("logMessage", *event.Message))
-					continue
-				}
-				logEventCount++
-				if logEventCount%10000 == 0 {
-					log.Info("Working", zap.Int("logEventCount", logEventCount), zap.Int("invocationCount", invocationCount))
-				}
-				if !ok {
-					continue
-				}
-				functionReports[i].Reports = append(functionReports[i].Reports, r)
-				invocationCount++
+		}
+		start, clamped := clampToRetention(logGroup, windowStart, end)
+		if clamped {
+			// Logs before the retention period are gone, so start at the next metric period.
+			if alignedStart, _, period := alignMetricWindow(start, end); alignedStart.Before(start) {
+				start = alignedStart.Add(period)
 			}
+			log.Warn("window exceeds log group retention, only analysing retained logs", zap.String("functionName", functionReports[i].Name), zap.Int32("retentionInDays", *logGroup.RetentionInDays))
+			functionReports[i].Incomplete = true
+			functionReports[i].Warnings = append(functionReports[i].Warnings, fmt.Sprintf("window clamped to log group retention of %d days", *logGroup.RetentionInDays))
 		}
+		functionReports[i].Start = start
+		functionReports[i].End = end
+		functionReports[i].Collected = end.UTC()
+		functionReports[i].setProvisionedConcurrencyAverages()
+		target := CollectTarget{
+			FunctionName: functionReports[i].Name,
+			Region:       region,
+			LogGroupName: logGroupName,
+			LogGroup:     logGroup,
+			Start:        start,
+			End:          end,
+			MaxPages:     opts.MaxPages,
+			Version:      functionReports[i].Version,
+		}
+		synthetic = newSyntheticRequests(functionReports[i].SyntheticMarkers(opts.SyntheticMarkers))
+		collectorName, collector, err := collectors.For(target)
+		if err != nil {
+			return nil, err
+		}
+		collectCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.MaxDuration > 0 {
+			collectCtx, cancel = context.WithTimeout(ctx, opts.MaxDuration)
+		}
+		err = collector.Collect(collectCtx, target, func(e LogEvent) {
+			processEvent(i, e)
+		})
+		cancel()
+		// Check the collection context, since API timeouts are also reported as DeadlineExceeded.
+		if err != nil && opts.MaxDuration > 0 && errors.Is(collectCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("%w after %v", errCollectionCapped, opts.MaxDuration)
+		}
+		if deadlineExceeded(ctx) {
+			log.Warn("deadline expired, only analysing logs collected so far", zap.String("functionName", functionReports[i].Name))
+			functionReports[i].markDeadlineExceeded("collection stopped at the deadline")
+			continue
+		}
+		if errors.Is(err, errCollectionCapped) {
+			log.Warn("collection capped, only analysing logs collected so far", zap.String("functionName", functionReports[i].Name), zap.Error(err))
+			functionReports[i].Incomplete = true
+			functionReports[i].Warnings = append(functionReports[i].Warnings, err.Error())
+			err = nil
+		}
+		if errors.Is(err, errLogGroupNotFound) {
+			log.Warn("log group not found, skipping", zap.String("functionName", functionReports[i].Name), zap.String("logGroupName", logGroupName))
+			functionReports[i].LogGroupMissing = true
+			continue
+		}
+		if err != nil {
+			log.Error("failed to collect logs", zap.Error(err), zap.String("functionName", functionReports[i].Name), zap.String("collector", collectorName))
+			functionReports[i].addError(errorKind(err), "collectLogs", err)
+			functionReports[i].Incomplete = true
+			functionReports[i].Warnings = append(functionReports[i].Warnings, "failed to collect logs")
+		}
+		invocations, err := getQualifiedMetricSum(ctx, cwClient, region, functionReports[i].Name, opts.Qualifier, "Invocations", start, end)
+		if err != nil {
+			log.Warn("could not get invocations metric", zap.String("functionName", functionReports[i].Name), zap.Error(err))
+			functionReports[i].addError(errorKind(err), "getInvocationsMetric", err)
+			continue
+		}
+		functionReports[i].MetricInvocations = &invocations
+		metricErrors, err := getQualifiedMetricSum(ctx, cwClient, region, functionReports[i].Name, opts.Qualifier, "Errors", start, end)
+		if err != nil {
+			log.Warn("could not get errors metric", zap.String("functionName", functionReports[i].Name), zap.Error(err))
+			functionReports[i].addError(errorKind(err), "getErrorsMetric", err)
+			continue
+		}
+		functionReports[i].MetricErrors = &metricErrors
+		incomingBytes, err := getLogIncomingBytes(ctx, cwClient, region, logGroupName, start, end)
+		if err != nil {
+			log.Warn("could not get log group incoming bytes metric", zap.String("functionName", functionReports[i].Name), zap.Error(err))
+			functionReports[i].addError(errorKind(err), "getLogIncomingBytes", err)
+		} else {
+			functionReports[i].LogIncomingBytes = &incomingBytes
+		}
+		if !functionReports[i].LambdaInsightsEnabled() {
+			continue
+		}
+		insights, err := getLambdaInsights(ctx, cwClient, region, functionReports[i].Name, start, end)
+		if err != nil {
+			log.Warn("could not get Lambda Insights metrics", zap.String("functionName", functionReports[i].Name), zap.Error(err))
+			functionReports[i].addError(errorKind(err), "getLambdaInsights", err)
+			continue
+		}
+		functionReports[i].LambdaInsights = &insights
+	}
+	log.Info("Downloading log data complete", zap.Int("logEventCount", logEventCount), zap.Int("invocationCount", invocationCount), zap.Int("insightsQueries", stats.InsightsQueries), zap.Float64("insightsBytesScanned", stats.InsightsBytesScanned))
+	if q.Count > 0 {
+		log.Warn("REPORT lines that could not be parsed were quarantined", zap.Int("count", q.Count), zap.String("filename", opts.QuarantineFile))
 	}
-	log.Info("Downloading log data complete", zap.Int("logEventCount", logEventCount), zap.Int("invocationCount", invocationCount))
 	return functionReports, nil
 }
 
 type FunctionReports struct {
-	Name         string   `json:"name"`
-	Architecture string   `json:"architecture"`
-	Reports      []Report `json:"reports"`
+	Account      string       `json:"account"`
+	AccountName  string       `json:"accountName"`
+	Name         string       `json:"name"`
+	Region       string       `json:"region"`
+	Architecture Architecture `json:"architecture"`
+	Reports      []Report     `json:"reports"`
+	// MemorySize is the configured memory size in MB when the data was collected. Invocations
+	// record the memory size they ran with, which may be different.
+	MemorySize int64 `json:"memorySize,omitempty"`
+	// Timeout is the configured function timeout.
+	Timeout     time.Duration `json:"timeout,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Runtime     string        `json:"runtime,omitempty"`
+	PackageType string        `json:"packageType,omitempty"`
+	// CodeSize is the size of the deployment package in bytes.
+	CodeSize int64 `json:"codeSize,omitempty"`
+	// EphemeralStorage is the size of /tmp in MB.
+	EphemeralStorage int64             `json:"ephemeralStorage,omitempty"`
+	Layers           []Layer           `json:"layers,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	// Triggers are the types of trigger that invoke the function, e.g. "api" or "queue". It's
+	// only set if triggers were collected.
+	Triggers []string `json:"triggers,omitempty"`
+	// ProvisionedConcurrency is the total allocated provisioned concurrency across aliases and versions.
+	ProvisionedConcurrency int32 `json:"provisionedConcurrency,omitempty"`
+	// ProvisionedConcurrencySchedules are the scheduled actions that scale provisioned
+	// concurrency, so that the hours it's allocated for are modelled, rather than assuming 24/7.
+	ProvisionedConcurrencySchedules []ProvisionedConcurrencySchedule `json:"provisionedConcurrencySchedules,omitempty"`
+	// ProvisionedConcurrencyAverages are replayed from the schedules once the window is known.
+	ProvisionedConcurrencyAverages *ProvisionedConcurrencyAverages `json:"provisionedConcurrencyAverages,omitempty"`
+	// SnapStart is true if SnapStart is enabled for published versions.
+	SnapStart bool `json:"snapStart,omitempty"`
+	// Qualifier is the alias or version that collection was restricted to, and Version is the
+	// version it referred to. Both are empty if all versions were collected.
+	Qualifier string `json:"qualifier,omitempty"`
+	Version   string `json:"version,omitempty"`
+	// Start and End are the time window that the reports cover.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Gaps are the parts of the window that the reports don't cover, e.g. when report data from
+	// windows that don't overlap is merged. They're excluded from Days.
+	Gaps []TimeRange `json:"gaps,omitempty"`
+	// Collected is when the log data was downloaded. Cached report data expires based on it,
+	// rather than the file's modification time, since -refresh-metadata rewrites the file
+	// without downloading log data again.
+	Collected time.Time `json:"collected"`
+	// ConfigChanges are the configuration changes found when report data from different runs
+	// was merged.
+	ConfigChanges []ConfigChange `json:"configChanges,omitempty"`
+	// LogGroupClass is set if the log group is not in the Standard class.
+	LogGroupClass string `json:"logGroupClass,omitempty"`
+	// LogGroupNeverExpires is true if the log group has no retention period, otherwise
+	// LogRetentionDays is the retention period.
+	LogGroupNeverExpires bool  `json:"logGroupNeverExpires,omitempty"`
+	LogRetentionDays     int32 `json:"logRetentionDays,omitempty"`
+	// LogStoredBytes is the size of the log group's stored data when it was collected. It's nil
+	// if the log group wasn't described.
+	LogStoredBytes *int64 `json:"logStoredBytes,omitempty"`
+	// SubscriptionFilters are the log group's subscription filters.
+	SubscriptionFilters []SubscriptionFilter `json:"subscriptionFilters,omitempty"`
+	// LogIncomingBytes is the sum of the log group's IncomingBytes metric over the window. It's
+	// nil if the metric wasn't collected.
+	LogIncomingBytes *int64 `json:"logIncomingBytes,omitempty"`
+	// LogGroupMissing is true if the function's log group does not exist.
+	LogGroupMissing bool `json:"logGroupMissing,omitempty"`
+	// Incomplete is true if the reports don't cover the whole window, see Warnings for details.
+	Incomplete bool     `json:"incomplete,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	// MetricInvocations is the sum of the Invocations metric over the window, used to check
+	// that the REPORT lines are complete. It's nil if the metric wasn't collected.
+	MetricInvocations *int64 `json:"metricInvocations,omitempty"`
+	// SyntheticCost is the cost in the window of the synthetic invocations removed from Reports
+	// when the report is written. They're still paid for, so it's included in DailyCost.
+	SyntheticCost float64 `json:"-"`
+	// MetricErrors is the sum of the Errors metric over the window, which includes timeouts.
+	MetricErrors *int64 `json:"metricErrors,omitempty"`
+	// LambdaInsights is nil unless the function has the Lambda Insights extension.
+	LambdaInsights *LambdaInsights `json:"lambdaInsights,omitempty"`
+	// Errors are the errors that occurred while collecting data for the function.
+	Errors []CollectionError `json:"errors,omitempty"`
+	// PreselectionSkipped is true if logs weren't downloaded, because MaxMonthlyCost was below
+	// the preselection threshold.
+	PreselectionSkipped bool `json:"preselectionSkipped,omitempty"`
+	// MaxMonthlyCost is the highest monthly cost the function could have, estimated from metrics.
+	// It's only set if preselection is enabled.
+	MaxMonthlyCost float64 `json:"maxMonthlyCost,omitempty"`
+	// DeadlineExceeded is true if the overall collection deadline expired before the function's
+	// logs were collected, so its data is partial, or missing.
+	DeadlineExceeded bool `json:"deadlineExceeded,omitempty"`
+}
+
+type Layer struct {
+	ARN string `json:"arn"`
+	// CodeSize is the size of the layer in bytes.
+	CodeSize int64 `json:"codeSize"`
+}
+
+// Days returns the number of days covered by the reports, excluding gaps in the window. Data
+// from older versions of the program doesn't include the window, and covers a day.
+func (fr FunctionReports) Days() float64 {
+	if fr.Start.IsZero() || fr.End.IsZero() || !fr.End.After(fr.Start) {
+		return 1
+	}
+	covered := fr.End.Sub(fr.Start)
+	for _, gap := range fr.Gaps {
+		covered -= gap.overlap(fr.Start, fr.End)
+	}
+	if covered <= 0 {
+		return 1
+	}
+	return covered.Hours() / 24
+}
+
+// DailyCost is the average cost per day over the window, including synthetic traffic.
+func (fr FunctionReports) DailyCost() float64 {
+	return (fr.Cost() + fr.SyntheticCost) / fr.Days()
+}
+
+// DisplayAccount returns the friendly account name if known, or the account ID.
+func (fr FunctionReports) DisplayAccount() string {
+	if fr.AccountName != "" {
+		return fr.AccountName
+	}
+	return fr.Account
 }
 
 /*
@@ -252,6 +1079,29 @@ Arm Price
 
 const M = 1000000
 
+// Executions is the number of times the function ran, including retries. The request charge
+// applies to every execution.
+func (fr FunctionReports) Executions() int {
+	return len(fr.Reports)
+}
+
+// UniqueRequests is the number of client-visible requests. Retries of asynchronous invocations,
+// and redeliveries, reuse the request ID of the original invocation, so are only counted once.
+func (fr FunctionReports) UniqueRequests() (n int) {
+	seen := map[string]struct{}{}
+	for _, r := range fr.Reports {
+		if r.RequestID == "" {
+			n++
+			continue
+		}
+		if _, ok := seen[r.RequestID]; !ok {
+			seen[r.RequestID] = struct{}{}
+			n++
+		}
+	}
+	return n
+}
+
 func (fr FunctionReports) AvgDuration() (v time.Duration) {
 	if len(fr.Reports) == 0 {
 		return
@@ -264,6 +1114,24 @@ func (fr FunctionReports) AvgDuration() (v time.Duration) {
 	return v / time.Duration(count)
 }
 
+func (fr FunctionReports) MaxDuration() (v time.Duration) {
+	for _, r := range fr.Reports {
+		if v < r.Duration {
+			v = r.Duration
+		}
+	}
+	return
+}
+
+func (fr FunctionReports) MaxBilledDuration() (v time.Duration) {
+	for _, r := range fr.Reports {
+		if v < r.BilledDuration {
+			v = r.BilledDuration
+		}
+	}
+	return
+}
+
 func (fr FunctionReports) AvgMemoryUsed() (v int64) {
 	if len(fr.Reports) == 0 {
 		return
@@ -285,11 +1153,19 @@ func (fr FunctionReports) MaxMemoryUsed() (v int64) {
 	return
 }
 
+// MemoryAssigned is the memory size of the most recent invocation, or the configured memory size
+// if the function wasn't invoked.
 func (fr FunctionReports) MemoryAssigned() int64 {
 	if len(fr.Reports) == 0 {
-		return 0
+		return fr.MemorySize
+	}
+	latest := fr.Reports[len(fr.Reports)-1]
+	for _, r := range fr.Reports {
+		if r.Timestamp.After(latest.Timestamp) {
+			latest = r
+		}
 	}
-	return fr.Reports[0].MemorySize
+	return latest.MemorySize
 }
 
 // Minimum RAM assigned to a Lambda function.
@@ -299,53 +1175,158 @@ func (fr FunctionReports) OptimisedCost() (memSize int64, cost float64) {
 	if len(fr.Reports) == 0 {
 		return
 	}
-	memSize = fr.Reports[0].MemorySize
-	// Don't bother optimising below the minimum amount of RAM.
-	if memSize > minRAM {
-		// Select double the RAM that's ever been required.
-		proposedMemSize := fr.MaxMemoryUsed() * 2
-		// Use at least the minimum amount of RAM.
-		if proposedMemSize < minRAM {
-			proposedMemSize = minRAM + 1
-		}
-		// Round down to nearest 256MB chunk.
-		proposedMemSize = (proposedMemSize / 256) * 256
+	memSize = fr.MemoryAssigned()
+	// Don't bother optimising below the minimum amount of RAM, or when the
+	// request charge outweighs the compute charge.
+	if memSize > minRAM && !fr.RequestDominated() {
+		// Select double the RAM that's ever been required, or more if the function is unstable.
+		headroom, suppressed, _ := fr.MemoryHeadroom()
+		if suppressed {
+			return memSize, fr.CostForArchitecture(ArchitectureARM64, memSize)
+		}
+		proposedMemSize := proposedMemorySize(fr.MaxMemoryUsed(), headroom)
+		// Keep enough CPU for CPU-bound functions, if Lambda Insights shows how much is used.
+		if floor := fr.CPUMemoryFloor(); floor > proposedMemSize {
+			proposedMemSize = ((floor + 255) / 256) * 256
+		}
+		// Allow for the memory used, and the duration, of the init phase of cold starts.
+		if floor := fr.InitMemoryFloor(headroom); floor > proposedMemSize {
+			proposedMemSize = floor
+		}
 		// Only choose less RAM.
 		if proposedMemSize < memSize {
 			memSize = proposedMemSize
 		}
 	}
-	return memSize, fr.CostForArchitecture("arm64", memSize)
+	return memSize, fr.CostForArchitecture(ArchitectureARM64, memSize)
+}
+
+// proposedMemorySize applies the headroom to the memory used (MB), and rounds down to the nearest
+// 256 MB, using at least the minimum amount of RAM.
+func proposedMemorySize(memoryUsed int64, headroom float64) int64 {
+	proposedMemSize := int64(float64(memoryUsed) * headroom)
+	if proposedMemSize < minRAM {
+		proposedMemSize = minRAM + 1
+	}
+	return (proposedMemSize / 256) * 256
+}
+
+// MonthlySavings is the monthly saving from moving to arm64 and the optimised RAM size.
+func (fr FunctionReports) MonthlySavings() float64 {
+	_, optimisedCost := fr.OptimisedCost()
+	savings := (fr.Cost() - optimisedCost) / fr.Days() * 30
+	if savings < 0 {
+		return 0.0
+	}
+	return savings
+}
+
+// MonthlyMemorySavings is the monthly saving from moving to the optimised RAM size, without changing architecture.
+func (fr FunctionReports) MonthlyMemorySavings() float64 {
+	optimisedRAM, _ := fr.OptimisedCost()
+	savings := (fr.Cost() - fr.CostForArchitecture(fr.Architecture, optimisedRAM)) / fr.Days() * 30
+	if savings < 0 {
+		return 0.0
+	}
+	return savings
+}
+
+// MonthlyArchitectureSavings is the monthly saving from moving to arm64, without changing RAM.
+func (fr FunctionReports) MonthlyArchitectureSavings() float64 {
+	savings := (fr.Cost() - fr.CostForArchitecture(ArchitectureARM64, 0)) / fr.Days() * 30
+	if savings < 0 {
+		return 0.0
+	}
+	return savings
 }
 
 func (fr FunctionReports) Cost() (cost float64) {
 	return fr.CostForArchitecture(fr.Architecture, 0)
 }
 
-func (fr FunctionReports) CostForArchitecture(architecture string, memorySize int64) (cost float64) {
+func (fr FunctionReports) CostForArchitecture(architecture Architecture, memorySize int64) (cost float64) {
+	requests, compute := fr.CostBreakdown(architecture, memorySize)
+	return requests + compute
+}
+
+// CostBreakdown splits the cost into request charges and compute (GB-second) charges. If memorySize
+// is zero, each invocation is costed using the memory size it ran with, since versions or rollouts
+// during the window may have different memory settings. For functions with provisioned concurrency,
+// compute includes the allocation charge, and invocations in provisioned environments are charged
+// at the provisioned concurrency duration price.
+func (fr FunctionReports) CostBreakdown(architecture Architecture, memorySize int64) (requests, compute float64) {
 	if len(fr.Reports) == 0 {
-		return 0.0
+		return
 	}
-	costPer1MRequests := 0.20
-	costForRequests := costPer1MRequests / M * float64(len(fr.Reports))
-	var msBilled time.Duration
+	q := pricing.Quantities{Requests: float64(len(fr.Reports))}
 	for _, r := range fr.Reports {
-		msBilled += r.BilledDuration
-		if memorySize == 0 {
-			memorySize = r.MemorySize
+		mem := memorySize
+		if mem == 0 {
+			mem = r.MemorySize
 		}
+		gbs := pricing.GBSeconds(mem, r.BilledDuration)
+		if fr.RanOnProvisionedConcurrency(r) {
+			q.ProvisionedGBSeconds += gbs
+			continue
+		}
+		q.GBSeconds += gbs
 	}
-	gbSecondPrice := 0.0000166667
-	if architecture == "arm64" {
-		gbSecondPrice = 0.0000133334
+	if fr.HasProvisionedConcurrency() {
+		q.AllocatedGBSeconds = fr.provisionedConcurrencyAllocatedGBSeconds(memorySize)
 	}
-	secs := msBilled.Seconds()
-	gbs := float64(memorySize) / 1024.0
-	cost = (gbs * secs * gbSecondPrice) + costForRequests
-	return
+	cost := pricing.Charges(priceForRegion(fr.Region), architecture, q)
+	return cost.Requests, cost.Duration + cost.ProvisionedConcurrency
+}
+
+// Functions where request charges are at least this proportion of the cost are request dominated.
+const requestDominatedThreshold = 0.5
+
+// RequestDominated is true if the request charge makes up most of the function's cost, typically
+// because the function is very fast. Changing memory makes little difference to the cost of these
+// functions, reducing the number of invocations is more effective.
+func (fr FunctionReports) RequestDominated() bool {
+	requests, compute := fr.CostBreakdown(fr.Architecture, 0)
+	if requests+compute == 0 {
+		return false
+	}
+	return requests/(requests+compute) >= requestDominatedThreshold
+}
+
+// Notes are short annotations displayed alongside the function in the report.
+func (fr FunctionReports) Notes(format displayFormat) (notes []string) {
+	if !fr.Architecture.Known() {
+		notes = append(notes, fmt.Sprintf("unknown architecture %q, priced as x86_64", fr.Architecture))
+	}
+	if fr.RequestDominated() {
+		notes = append(notes, "request charges dominate, batch or reduce invocations instead of tuning memory")
+	}
+	if segments := fr.MemorySegments(); len(segments) > 1 {
+		notes = append(notes, "memory changed during window: "+formatMemorySegments(segments))
+	}
+	if configured, logged, ok := fr.MemoryMismatch(); ok {
+		notes = append(notes, fmt.Sprintf("configured with %d MB, but latest REPORT line has %d MB", configured, logged))
+	}
+	if note, ok := fr.memoryTrendNote(); ok {
+		notes = append(notes, note)
+	}
+	if _, suppressed, rationale := fr.MemoryHeadroom(); suppressed {
+		notes = append(notes, rationale)
+	}
+	if utilisation, ok := fr.CPUUtilisation(); ok && fr.CPUBound() {
+		notes = append(notes, fmt.Sprintf("CPU-bound, using %.0f%% of allocated CPU", utilisation*100))
+	}
+	if finding, ok := fr.ColdStartFinding(); ok {
+		notes = append(notes, finding)
+	}
+	if rec, ok := fr.reduceInvocationsRecommendation(); ok {
+		notes = append(notes, fmt.Sprintf("batching %dx would save %s/month", reduceInvocationsBatchSize, format.Money(rec.MonthlySavings, fr.Currency(), 2)))
+	}
+	return notes
 }
 
 type Report struct {
+	// Timestamp is when the REPORT line was logged, at the end of the invocation.
+	Timestamp      time.Time     `json:"timestamp,omitempty"`
 	RequestID      string        `json:"requestId"`
 	Duration       time.Duration `json:"duration"`
 	BilledDuration time.Duration `json:"billedDuration"`
@@ -353,28 +1334,90 @@ type Report struct {
 	MemorySize     int64         `json:"memorySize"`
 	MaxMemoryUsed  int64         `json:"maxMemoryUsed"`
 	IsColdStart    bool          `json:"isColdStart"`
+	// Synthetic is true if the request logged a synthetic marker, e.g. a warm-up ping.
+	Synthetic bool `json:"synthetic,omitempty"`
+	// Extra contains fields that aren't otherwise parsed, keyed by the field name in the REPORT
+	// line, e.g. "Restore Duration", so that fields from new platform features are kept.
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Limits that stop an unusual log event from bloating the report data, the quarantine file, or the
+// logs. REPORT lines written by Lambda are a few hundred bytes, but log events can be up to 256 KB.
+const (
+	maxReportValueLength = 256
+	maxReportKeyLength   = 64
+	maxExtraFields       = 16
+	maxLogMessageLength  = 8192
+)
+
+// requiredReportFields are in every REPORT line. A line without them has been truncated, and would
+// otherwise be counted as an invocation that was free and used no memory.
+var requiredReportFields = []string{"RequestId", "Duration", "Billed Duration", "Memory Size", "Max Memory Used"}
+
+// truncate shortens s to at most n bytes, without splitting a UTF-8 character, and notes how many
+// bytes were removed.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (%d bytes truncated)", s[:cut], len(s)-cut)
 }
 
 func parseMS(v string) (d time.Duration, err error) {
+	if !strings.HasSuffix(v, " ms") {
+		return 0, fmt.Errorf("missing ms unit, the REPORT line may be truncated")
+	}
 	return time.ParseDuration(strings.Replace(v, " ms", "ms", -1))
 }
 
 func parseMB(v string) (mb int64, err error) {
+	if !strings.HasSuffix(v, " MB") {
+		return 0, fmt.Errorf("missing MB unit, the REPORT line may be truncated")
+	}
 	return strconv.ParseInt(strings.Replace(v, " MB", "", -1), 10, 64)
 }
 
-func getFunctionReport(report string) (r Report, ok bool, err error) {
-	report = strings.TrimSpace(report)
-	if !strings.HasPrefix(report, "REPORT") {
+// reportLine returns the REPORT line of a log message. Custom log formats, and the XRAY line that
+// follows the REPORT line, can add lines to the message.
+func reportLine(message string) (line string, ok bool) {
+	if !strings.Contains(message, "REPORT RequestId:") {
+		return "", false
+	}
+	for message != "" {
+		line, message, _ = strings.Cut(message, "\n")
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "REPORT RequestId:") {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// getFunctionReport parses a REPORT line, or platform.report event, into a Report. It returns false
+// if the message isn't a report. Invalid UTF-8 is replaced, so that it can't be written to the
+// report data.
+func getFunctionReport(message string) (r Report, ok bool, err error) {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "{") {
+		return getPlatformReport(message)
+	}
+	report, ok := reportLine(message)
+	if !ok {
 		return
 	}
-	ok = true
-	parts := strings.Split(report, "\t")
+	report = strings.ToValidUTF8(report, "\uFFFD")
+	found := make(map[string]bool, len(requiredReportFields))
+	parts := strings.Split(strings.TrimPrefix(report, "REPORT"), "\t")
 	for _, p := range parts {
 		kv := strings.SplitN(p, ": ", 2)
 		if len(kv) > 1 {
-			v := strings.TrimSpace(kv[1])
-			switch strings.TrimSpace(kv[0]) {
+			k, v := strings.TrimSpace(kv[0]), truncate(strings.TrimSpace(kv[1]), maxReportValueLength)
+			found[k] = true
+			switch k {
 			case "RequestId":
 				r.RequestID = v
 			case "Duration":
@@ -408,9 +1451,25 @@ func getFunctionReport(report string) (r Report, ok bool, err error) {
 					return
 				}
 				r.IsColdStart = true
+			default:
+				// Text from custom log formats can look like extra fields, so only a few short
+				// fields are kept.
+				if len(k) > maxReportKeyLength || len(r.Extra) >= maxExtraFields {
+					continue
+				}
+				if r.Extra == nil {
+					r.Extra = map[string]string{}
+				}
+				r.Extra[k] = v
 			}
 		}
 	}
+	for _, k := range requiredReportFields {
+		if !found[k] {
+			err = fmt.Errorf("REPORT line is missing %q, it may be truncated", k)
+			return
+		}
+	}
 	return
 }
 
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestReportLine(t *testing.T) {
+	const report = "REPORT RequestId: d432a1bd-8320-4fad-95d5-290fc6ea9f02\tDuration: 27.83 ms\tBilled Duration: 28 ms\tMemory Size: 3096 MB\tMax Memory Used: 62 MB\t"
+	tests := []struct {
+		name       string
+		message    string
+		expected   string
+		expectedOK bool
+	}{
+		{
+			name:       "report line",
+			message:    report + "\n",
+			expected:   strings.TrimSpace(report),
+			expectedOK: true,
+		},
+		{
+			name:       "report line followed by an XRAY line",
+			message:    report + "\nXRAY TraceId: 1-62f6637f-27b6ec11099249663df0fc13\tSegmentId: 69ccfd435d559a96\tSampled: true\n",
+			expected:   strings.TrimSpace(report),
+			expectedOK: true,
+		},
+		{
+			name:       "report line after lines added by a custom log format",
+			message:    "2026-01-01T00:00:00Z INFO\n  " + report + "\n",
+			expected:   strings.TrimSpace(report),
+			expectedOK: true,
+		},
+		{
+			name:       "truncated report line",
+			message:    "REPORT RequestId: d432a1bd\tDuration: 27",
+			expected:   "REPORT RequestId: d432a1bd\tDuration: 27",
+			expectedOK: true,
+		},
+		{
+			name:       "invalid UTF-8 is left for the parser to replace",
+			message:    "REPORT RequestId: \xff\xfe\n",
+			expected:   "REPORT RequestId: \xff\xfe",
+			expectedOK: true,
+		},
+		{
+			name:    "REPORT RequestId inside another line",
+			message: "ERROR could not parse REPORT RequestId: from the log\n",
+		},
+		{
+			name:    "no report line",
+			message: "START RequestId: d432a1bd Version: $LATEST\n",
+		},
+		{
+			name: "empty message",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			actual, ok := reportLine(test.message)
+			if ok != test.expectedOK {
+				t.Fatalf("expected ok to be %v, got %v", test.expectedOK, ok)
+			}
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetFunctionReportLimits(t *testing.T) {
+	const required = "Duration: 27.83 ms\tBilled Duration: 28 ms\tMemory Size: 3096 MB\tMax Memory Used: 62 MB"
+	tests := []struct {
+		name          string
+		message       string
+		expectErr     bool
+		expectedCheck func(t *testing.T, r Report)
+	}{
+		{
+			name:      "truncated report lines are rejected",
+			message:   "REPORT RequestId: d432a1bd\tDuration: 27.83 ms\tBilled Dura",
+			expectErr: true,
+		},
+		{
+			name:    "oversized values are truncated",
+			message: "REPORT RequestId: " + strings.Repeat("a", 1000) + "\t" + required,
+			expectedCheck: func(t *testing.T, r Report) {
+				if !strings.HasSuffix(r.RequestID, "... (744 bytes truncated)") {
+					t.Errorf("expected the request ID to be truncated, got %q", r.RequestID)
+				}
+				if r.Duration != 27830*time.Microsecond {
+					t.Errorf("expected the fields after the oversized value to be parsed, got %v", r.Duration)
+				}
+			},
+		},
+		{
+			name:    "invalid UTF-8 is replaced",
+			message: "REPORT RequestId: a\xffb\t" + required,
+			expectedCheck: func(t *testing.T, r Report) {
+				if !utf8.ValidString(r.RequestID) || r.RequestID != "a\uFFFDb" {
+					t.Errorf("expected invalid UTF-8 to be replaced, got %q", r.RequestID)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			r, ok, err := getFunctionReport(test.message)
+			if test.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !ok {
+				t.Fatal("expected a report")
+			}
+			test.expectedCheck(t, r)
+		})
+	}
+}
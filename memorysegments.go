@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// MemorySegment is the invocations that ran with a single memory size, e.g. during a rollout
+// that changed memory, or with versions that have different memory settings.
+type MemorySegment struct {
+	MemorySize     int64
+	Invocations    int
+	BilledDuration time.Duration
+}
+
+// MemorySegments groups invocations by memory size, in order of memory size.
+func (fr FunctionReports) MemorySegments() (segments []MemorySegment) {
+	indexes := map[int64]int{}
+	for _, r := range fr.Reports {
+		index, ok := indexes[r.MemorySize]
+		if !ok {
+			index = len(segments)
+			indexes[r.MemorySize] = index
+			segments = append(segments, MemorySegment{MemorySize: r.MemorySize})
+		}
+		segments[index].Invocations++
+		segments[index].BilledDuration += r.BilledDuration
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].MemorySize < segments[j].MemorySize
+	})
+	return segments
+}
+
+func formatMemorySegments(segments []MemorySegment) string {
+	var total int
+	for _, s := range segments {
+		total += s.Invocations
+	}
+	parts := make([]string, len(segments))
+	for i, s := range segments {
+		parts[i] = fmt.Sprintf("%d MB (%.0f%%)", s.MemorySize, float64(s.Invocations)/float64(total)*100)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MemoryMismatch returns the configured memory size, and the memory size of the most recent
+// REPORT line, if they differ, e.g. because the configuration changed during the window, or
+// because the invoked version or alias has a different memory size. Data from older versions
+// doesn't include the configured memory size.
+func (fr FunctionReports) MemoryMismatch() (configured, logged int64, ok bool) {
+	if fr.MemorySize == 0 || len(fr.Reports) == 0 {
+		return 0, 0, false
+	}
+	logged = fr.MemoryAssigned()
+	return fr.MemorySize, logged, logged != fr.MemorySize
+}
+
+func displayMemoryMismatches(w io.Writer, reportContent []FunctionReports) {
+	type row struct {
+		fr                 FunctionReports
+		configured, logged int64
+	}
+	var rows []row
+	for _, fr := range reportContent {
+		if configured, logged, ok := fr.MemoryMismatch(); ok {
+			rows = append(rows, row{fr: fr, configured: configured, logged: logged})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Memory mismatches: %d functions are configured with a different memory size to their REPORT lines\n", len(rows))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Configured", "Latest REPORT", "Memory During Window"}, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join([]string{
+			r.fr.Name,
+			r.fr.Region,
+			fmt.Sprintf("%d MB", r.configured),
+			fmt.Sprintf("%d MB", r.logged),
+			formatMemorySegments(r.fr.MemorySegments()),
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The configuration changed during or after the window, or the invoked version or alias has a different memory size.")
+	fmt.Fprintln(w, "Each invocation is costed at the memory size it ran with, and memory recommendations are based on the")
+	fmt.Fprintln(w, "latest REPORT line, so check which memory size is in use before applying them.")
+}
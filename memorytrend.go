@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Thresholds used to flag a steady increase in max memory used, which usually signals a memory
+// leak, since execution environments are reused between invocations.
+const (
+	// memoryTrendMinBuckets is the number of hours, or days, with data needed to fit a trend.
+	memoryTrendMinBuckets = 6
+	// memoryTrendDailySpan is the span of data above which days are used instead of hours.
+	memoryTrendDailySpan = 4 * 24 * time.Hour
+	// memoryTrendMinRSquared is how well the line must fit the data for the increase to be steady.
+	memoryTrendMinRSquared = 0.7
+	// memoryTrendMinIncrease is the proportion that max memory used must rise by over the data.
+	memoryTrendMinIncrease = 0.1
+)
+
+// MemoryTrendPoint is the max memory used by the invocations in an hour or day.
+type MemoryTrendPoint struct {
+	Start         time.Time
+	MaxMemoryUsed int64
+}
+
+// MemoryTrend is a straight line fitted to the max memory used in each hour, or day, of data.
+type MemoryTrend struct {
+	Points []MemoryTrendPoint
+	// Bucket is the time covered by each point, an hour or a day.
+	Bucket time.Duration
+	// MBPerDay is the slope of the line, and From and To are the fitted values at the first and
+	// last point, in MB.
+	MBPerDay float64
+	From, To float64
+	// RSquared is the proportion of the variation in max memory used that the line explains.
+	RSquared float64
+}
+
+// Increasing is true if max memory used rose steadily, by enough to matter.
+func (t MemoryTrend) Increasing() bool {
+	return t.MBPerDay > 0 && t.RSquared >= memoryTrendMinRSquared && t.From > 0 && (t.To-t.From)/t.From >= memoryTrendMinIncrease
+}
+
+// DaysToLimit estimates how many days after the last point max memory used will reach the memory
+// size, if it keeps rising at the same rate. It returns false if it isn't rising.
+func (t MemoryTrend) DaysToLimit(memorySize int64) (days float64, ok bool) {
+	if t.MBPerDay <= 0 || memorySize <= 0 {
+		return 0, false
+	}
+	return math.Max(0, (float64(memorySize)-t.To)/t.MBPerDay), true
+}
+
+// MemoryTrend fits a line to the max memory used in each hour of the window, or each day, if the
+// data spans several days, e.g. when report data from regular runs is merged. It returns false if
+// there aren't enough REPORT lines with timestamps.
+func (fr FunctionReports) MemoryTrend() (t MemoryTrend, ok bool) {
+	var first, last time.Time
+	for _, r := range fr.Reports {
+		if r.Timestamp.IsZero() {
+			continue
+		}
+		if first.IsZero() || r.Timestamp.Before(first) {
+			first = r.Timestamp
+		}
+		if r.Timestamp.After(last) {
+			last = r.Timestamp
+		}
+	}
+	if first.IsZero() {
+		return t, false
+	}
+	t.Bucket = time.Hour
+	if last.Sub(first) >= memoryTrendDailySpan {
+		t.Bucket = 24 * time.Hour
+	}
+	buckets := map[time.Time]int64{}
+	for _, r := range fr.Reports {
+		if r.Timestamp.IsZero() {
+			continue
+		}
+		start := r.Timestamp.UTC().Truncate(t.Bucket)
+		if r.MaxMemoryUsed > buckets[start] {
+			buckets[start] = r.MaxMemoryUsed
+		}
+	}
+	if len(buckets) < memoryTrendMinBuckets {
+		return t, false
+	}
+	for start, mb := range buckets {
+		t.Points = append(t.Points, MemoryTrendPoint{Start: start, MaxMemoryUsed: mb})
+	}
+	sort.Slice(t.Points, func(i, j int) bool {
+		return t.Points[i].Start.Before(t.Points[j].Start)
+	})
+	// Least squares fit of max memory used against days since the first point.
+	n := float64(len(t.Points))
+	var sumX, sumY, sumXX, sumXY, sumYY float64
+	for _, p := range t.Points {
+		x, y := p.Start.Sub(t.Points[0].Start).Hours()/24, float64(p.MaxMemoryUsed)
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+		sumYY += y * y
+	}
+	varX, varY, covXY := n*sumXX-sumX*sumX, n*sumYY-sumY*sumY, n*sumXY-sumX*sumY
+	if varX == 0 {
+		return t, false
+	}
+	t.MBPerDay = covXY / varX
+	intercept := (sumY - t.MBPerDay*sumX) / n
+	t.From = intercept
+	t.To = intercept + t.MBPerDay*t.Points[len(t.Points)-1].Start.Sub(t.Points[0].Start).Hours()/24
+	if varY > 0 {
+		t.RSquared = covXY * covXY / (varX * varY)
+	}
+	return t, true
+}
+
+// memoryTrendNote describes a steady increase in max memory used, for the report notes.
+func (fr FunctionReports) memoryTrendNote() (note string, ok bool) {
+	t, ok := fr.MemoryTrend()
+	if !ok || !t.Increasing() {
+		return "", false
+	}
+	return fmt.Sprintf("max memory used rising %.0f MB/day, possible memory leak", t.MBPerDay), true
+}
+
+func displayMemoryTrends(w io.Writer, reportContent []FunctionReports) {
+	type row struct {
+		fr FunctionReports
+		t  MemoryTrend
+	}
+	var rows []row
+	for _, fr := range reportContent {
+		if t, ok := fr.MemoryTrend(); ok && t.Increasing() {
+			rows = append(rows, row{fr: fr, t: t})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].t.MBPerDay > rows[j].t.MBPerDay
+	})
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Memory trend: %d functions have steadily increasing max memory used\n", len(rows))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "From", "To", "Per Day", "Fit (R²)", "RAM Assigned", "Reaches RAM Assigned"}, "\t"))
+	for _, r := range rows {
+		assigned := r.fr.MemoryAssigned()
+		reaches := "N/A"
+		if days, ok := r.t.DaysToLimit(assigned); ok {
+			reaches = fmt.Sprintf("in %.1f days", days)
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			r.fr.Name,
+			r.fr.Region,
+			fmt.Sprintf("%.0f MB", r.t.From),
+			fmt.Sprintf("%.0f MB", r.t.To),
+			fmt.Sprintf("%+.1f MB", r.t.MBPerDay),
+			fmt.Sprintf("%.2f", r.t.RSquared),
+			fmt.Sprintf("%d MB", assigned),
+			reaches,
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Max memory used is taken from each hour of data, or each day if the data spans several days. A steady rise usually means a memory leak, which increases the memory needed, and ends in out of memory errors when it reaches the memory assigned.")
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func mergeCmd(args []string) {
+	cmd := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := cmd.String("o", "merged.json", "Path to write the merged report data to")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost merge [-o merged.json] <file.json> <file.json>...")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() == 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	var sets [][]FunctionReports
+	for _, fileName := range cmd.Args() {
+		functionReports, err := readFunctionReports(fileName)
+		if err != nil {
+			log.Fatal("could not read report data", zap.Error(err))
+		}
+		sets = append(sets, functionReports)
+	}
+	merged, duplicates := mergeFunctionReports(sets...)
+	if err := writeFunctionReports(*output, merged); err != nil {
+		log.Fatal("could not write merged report data", zap.Error(err))
+	}
+	log.Info("merge complete", zap.Int("files", len(sets)), zap.Int("functions", len(merged)), zap.Int("duplicateReports", duplicates), zap.String("filename", *output))
+}
+
+// mergeFunctionReports combines report data, e.g. from different regions, accounts or windows.
+// Reports for the same function are deduplicated by request ID and timestamp, or by request ID if
+// either report has no timestamp. The number of duplicate reports that were removed is returned.
+func mergeFunctionReports(sets ...[]FunctionReports) (merged []FunctionReports, duplicates int) {
+	indexes := map[string]int{}
+	requestIDs := map[string]map[string]struct{}{}
+	// snapshots are the configuration of each function in each set, used to find changes.
+	snapshots := map[string][]FunctionReports{}
+	// covered are the time ranges covered by each function's reports, used to find gaps.
+	covered := map[string][]TimeRange{}
+	var keys []string
+	for _, set := range sets {
+		for _, fr := range set {
+			key := fr.Account + "/" + fr.Region + "/" + fr.Name
+			snapshot := fr
+			snapshot.Reports = nil
+			snapshots[key] = append(snapshots[key], snapshot)
+			covered[key] = append(covered[key], fr.coveredRanges()...)
+			index, ok := indexes[key]
+			if !ok {
+				indexes[key] = len(merged)
+				keys = append(keys, key)
+				requestIDs[key] = map[string]struct{}{}
+				reports := fr.Reports
+				fr.Reports = nil
+				fr.Warnings = append([]string(nil), fr.Warnings...)
+				fr.Errors = append([]CollectionError(nil), fr.Errors...)
+				merged = append(merged, fr)
+				duplicates += addReports(&merged[len(merged)-1], requestIDs[key], reports)
+				continue
+			}
+			existing := &merged[index]
+			// Configuration is taken from the most recent data.
+			if fr.End.After(existing.End) {
+				existing.AccountName = fr.AccountName
+				existing.Architecture = fr.Architecture
+				existing.MemorySize = fr.MemorySize
+				existing.Timeout = fr.Timeout
+				existing.Description = fr.Description
+				existing.Runtime = fr.Runtime
+				existing.PackageType = fr.PackageType
+				existing.CodeSize = fr.CodeSize
+				existing.Layers = fr.Layers
+				existing.SnapStart = fr.SnapStart
+				existing.Tags = fr.Tags
+				existing.Triggers = fr.Triggers
+				existing.ProvisionedConcurrency = fr.ProvisionedConcurrency
+				existing.ProvisionedConcurrencySchedules = fr.ProvisionedConcurrencySchedules
+				existing.LogGroupNeverExpires = fr.LogGroupNeverExpires
+				existing.LogRetentionDays = fr.LogRetentionDays
+				existing.LogStoredBytes = fr.LogStoredBytes
+				existing.LogGroupClass = fr.LogGroupClass
+				existing.SubscriptionFilters = fr.SubscriptionFilters
+				existing.End = fr.End
+			}
+			if fr.Collected.After(existing.Collected) {
+				existing.Collected = fr.Collected
+			}
+			if !fr.Start.IsZero() && (existing.Start.IsZero() || fr.Start.Before(existing.Start)) {
+				existing.Start = fr.Start
+			}
+			existing.LogGroupMissing = existing.LogGroupMissing && fr.LogGroupMissing
+			existing.PreselectionSkipped = existing.PreselectionSkipped && fr.PreselectionSkipped
+			if fr.MaxMonthlyCost > existing.MaxMonthlyCost {
+				existing.MaxMonthlyCost = fr.MaxMonthlyCost
+			}
+			existing.Incomplete = existing.Incomplete || fr.Incomplete
+			existing.DeadlineExceeded = existing.DeadlineExceeded || fr.DeadlineExceeded
+			existing.Warnings = append(existing.Warnings, fr.Warnings...)
+			// Windows may overlap, so the Invocations metrics can't be combined.
+			existing.MetricInvocations = nil
+			existing.MetricErrors = nil
+			existing.LogIncomingBytes = nil
+			existing.LambdaInsights = nil
+			existing.mergeErrors(fr.Errors)
+			duplicates += addReports(existing, requestIDs[key], fr.Reports)
+		}
+	}
+	for i := range merged {
+		merged[i].ConfigChanges = configHistory(snapshots[keys[i]])
+		merged[i].setProvisionedConcurrencyAverages()
+		if ranges := unionTimeRanges(covered[keys[i]]); len(ranges) > 0 {
+			merged[i].Start, merged[i].End = ranges[0].Start, ranges[len(ranges)-1].End
+			merged[i].Gaps = nil
+			for j := 1; j < len(ranges); j++ {
+				merged[i].Gaps = append(merged[i].Gaps, TimeRange{Start: ranges[j-1].End, End: ranges[j].Start})
+			}
+		}
+		reports := merged[i].Reports
+		sort.SliceStable(reports, func(a, b int) bool {
+			if !reports[a].Timestamp.Equal(reports[b].Timestamp) {
+				return reports[a].Timestamp.Before(reports[b].Timestamp)
+			}
+			return reports[a].RequestID < reports[b].RequestID
+		})
+	}
+	return merged, duplicates
+}
+
+// addReports adds the reports that haven't been seen. Retries reuse the request ID, so reports
+// with a timestamp are keyed by request ID and timestamp. Reports without a timestamp, e.g. from
+// data written before timestamps were collected, can't be told apart from retries, so they're
+// keyed by request ID alone, and are duplicates of any report with the same request ID.
+func addReports(fr *FunctionReports, seen map[string]struct{}, reports []Report) (duplicates int) {
+	for _, r := range reports {
+		if r.RequestID != "" {
+			// seen has the request ID of each report without a timestamp, the request ID and
+			// timestamp of each report with one, and the request ID followed by a slash for both.
+			key := r.RequestID
+			var duplicate bool
+			if r.Timestamp.IsZero() {
+				_, duplicate = seen[r.RequestID+"/"]
+			} else {
+				key += "/" + r.Timestamp.UTC().Format(time.RFC3339Nano)
+				_, timed := seen[key]
+				_, untimed := seen[r.RequestID]
+				duplicate = timed || untimed
+			}
+			if duplicate {
+				duplicates++
+				continue
+			}
+			seen[key] = struct{}{}
+			seen[r.RequestID+"/"] = struct{}{}
+		}
+		fr.Reports = append(fr.Reports, r)
+	}
+	return duplicates
+}
+
+// TimeRange is a period of time, from Start up to End.
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// overlap returns the length of the part of the range that's between start and end.
+func (tr TimeRange) overlap(start, end time.Time) time.Duration {
+	if tr.Start.After(start) {
+		start = tr.Start
+	}
+	if tr.End.Before(end) {
+		end = tr.End
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// coveredRanges returns the parts of the window that the reports cover, or nothing if the
+// data doesn't include the window.
+func (fr FunctionReports) coveredRanges() (ranges []TimeRange) {
+	if fr.Start.IsZero() || fr.End.IsZero() || !fr.End.After(fr.Start) {
+		return nil
+	}
+	gaps := append([]TimeRange(nil), fr.Gaps...)
+	sort.Slice(gaps, func(i, j int) bool {
+		return gaps[i].Start.Before(gaps[j].Start)
+	})
+	start := fr.Start
+	for _, gap := range gaps {
+		if !gap.Start.Before(fr.End) {
+			break
+		}
+		if gap.Start.After(start) {
+			ranges = append(ranges, TimeRange{Start: start, End: gap.Start})
+		}
+		if gap.End.After(start) {
+			start = gap.End
+		}
+	}
+	if fr.End.After(start) {
+		ranges = append(ranges, TimeRange{Start: start, End: fr.End})
+	}
+	return ranges
+}
+
+// unionTimeRanges returns the ranges sorted by start, with overlapping and adjacent ranges combined.
+func unionTimeRanges(ranges []TimeRange) (union []TimeRange) {
+	ranges = append([]TimeRange(nil), ranges...)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start.Before(ranges[j].Start)
+	})
+	for _, r := range ranges {
+		if len(union) > 0 && !r.Start.After(union[len(union)-1].End) {
+			if r.End.After(union[len(union)-1].End) {
+				union[len(union)-1].End = r.End
+			}
+			continue
+		}
+		union = append(union, r)
+	}
+	return union
+}
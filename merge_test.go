@@ -0,0 +1,150 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddReports(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Minute)
+	tests := []struct {
+		name       string
+		reports    []Report
+		duplicates int
+	}{
+		{
+			name:    "different requests",
+			reports: []Report{{RequestID: "a", Timestamp: t1}, {RequestID: "b", Timestamp: t1}, {RequestID: "c"}},
+		},
+		{
+			name:       "same request and timestamp",
+			reports:    []Report{{RequestID: "a", Timestamp: t1}, {RequestID: "a", Timestamp: t1}},
+			duplicates: 1,
+		},
+		{
+			name:    "retries have different timestamps",
+			reports: []Report{{RequestID: "a", Timestamp: t1}, {RequestID: "a", Timestamp: t2}},
+		},
+		{
+			name:       "same request without timestamps",
+			reports:    []Report{{RequestID: "a"}, {RequestID: "a"}},
+			duplicates: 1,
+		},
+		{
+			name:       "without a timestamp, then with one",
+			reports:    []Report{{RequestID: "a"}, {RequestID: "a", Timestamp: t1}},
+			duplicates: 1,
+		},
+		{
+			name:       "with a timestamp, then without one",
+			reports:    []Report{{RequestID: "a", Timestamp: t1}, {RequestID: "a", Timestamp: t2}, {RequestID: "a"}},
+			duplicates: 1,
+		},
+		{
+			name:    "reports without a request ID are kept",
+			reports: []Report{{}, {}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var fr FunctionReports
+			duplicates := addReports(&fr, map[string]struct{}{}, test.reports)
+			if duplicates != test.duplicates {
+				t.Errorf("expected %d duplicates, got %d", test.duplicates, duplicates)
+			}
+			if len(fr.Reports) != len(test.reports)-test.duplicates {
+				t.Errorf("expected %d reports, got %d", len(test.reports)-test.duplicates, len(fr.Reports))
+			}
+		})
+	}
+}
+
+func TestMergeFunctionReportsWithAndWithoutTimestamps(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Data collected before timestamps were stored overlaps data that has them.
+	old := []FunctionReports{{Account: "1", Region: "eu-west-1", Name: "api", Reports: []Report{{RequestID: "a"}, {RequestID: "b"}}}}
+	current := []FunctionReports{{Account: "1", Region: "eu-west-1", Name: "api", Reports: []Report{{RequestID: "b", Timestamp: ts}, {RequestID: "c", Timestamp: ts}}}}
+	merged, duplicates := mergeFunctionReports(old, current)
+	if duplicates != 1 || len(merged) != 1 || len(merged[0].Reports) != 3 {
+		t.Errorf("expected 3 reports and 1 duplicate, got %d functions, %d duplicates", len(merged), duplicates)
+	}
+}
+
+func TestMergeFunctionReportsExcludesGapsFromDays(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	window := func(start, end time.Time, requestID string) []FunctionReports {
+		return []FunctionReports{{
+			Account: "1", Region: "eu-west-1", Name: "api", MemorySize: 1024, Architecture: ArchitectureX86_64,
+			Start: start, End: end,
+			Reports: []Report{{RequestID: requestID, Timestamp: start, Duration: time.Second, BilledDuration: time.Second, MemorySize: 1024}},
+		}}
+	}
+	tests := []struct {
+		name         string
+		sets         [][]FunctionReports
+		expectedDays float64
+		expectedGaps []TimeRange
+	}{
+		{
+			name:         "contiguous",
+			sets:         [][]FunctionReports{window(day(1), day(2), "a"), window(day(2), day(3), "b")},
+			expectedDays: 2,
+		},
+		{
+			name:         "overlapping",
+			sets:         [][]FunctionReports{window(day(1), day(3), "a"), window(day(2), day(4), "b")},
+			expectedDays: 3,
+		},
+		{
+			name:         "gap",
+			sets:         [][]FunctionReports{window(day(1), day(2), "a"), window(day(8), day(9), "b")},
+			expectedDays: 2,
+			expectedGaps: []TimeRange{{Start: day(2), End: day(8)}},
+		},
+		{
+			name:         "gap filled by a later window",
+			sets:         [][]FunctionReports{window(day(1), day(2), "a"), window(day(3), day(4), "b"), window(day(2), day(3), "c")},
+			expectedDays: 3,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			merged, _ := mergeFunctionReports(test.sets...)
+			if len(merged) != 1 {
+				t.Fatalf("expected 1 function, got %d", len(merged))
+			}
+			fr := merged[0]
+			if days := fr.Days(); days != test.expectedDays {
+				t.Errorf("expected %v days, got %v", test.expectedDays, days)
+			}
+			if !reflect.DeepEqual(fr.Gaps, test.expectedGaps) {
+				t.Errorf("expected gaps %v, got %v", test.expectedGaps, fr.Gaps)
+			}
+			// Merging the merged data again keeps the gaps.
+			remerged, _ := mergeFunctionReports(merged, window(fr.Start, fr.Start.Add(time.Hour), "d"))
+			if days := remerged[0].Days(); days != test.expectedDays {
+				t.Errorf("expected %v days after merging again, got %v", test.expectedDays, days)
+			}
+		})
+	}
+}
+
+func TestMergeFunctionReportsSortsReportsByTimestamp(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := []FunctionReports{{Account: "1", Region: "eu-west-1", Name: "api", Reports: []Report{{RequestID: "a", Timestamp: ts.Add(time.Minute)}, {RequestID: "c", Timestamp: ts}}}}
+	b := []FunctionReports{{Account: "1", Region: "eu-west-1", Name: "api", Reports: []Report{{RequestID: "b", Timestamp: ts}}}}
+	merged, _ := mergeFunctionReports(a, b)
+	var actual []string
+	for _, r := range merged[0].Reports {
+		actual = append(actual, r.RequestID)
+	}
+	if expected := []string{"b", "c", "a"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected reports ordered by timestamp, then request ID, %v, got %v", expected, actual)
+	}
+}
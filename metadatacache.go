@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Default maximum ages of cached data. Function metadata changes rarely, so it's cached for longer
+// than log data.
+const (
+	defaultLogDataMaxAge  = time.Hour
+	defaultMetadataMaxAge = 24 * time.Hour
+)
+
+// MetadataCache is the function metadata written to the metadata cache file. The functions have
+// their configuration, provisioned concurrency, tags and triggers, but no log data.
+type MetadataCache struct {
+	Collected time.Time `json:"collected"`
+	// Triggers is true if triggers were collected.
+	Triggers  bool              `json:"triggers,omitempty"`
+	Functions []FunctionReports `json:"functions"`
+}
+
+// cacheFresh returns the cached report data, and true if it was collected less than maxAge ago. A
+// maxAge of zero never expires. The age is the time since the function whose log data was
+// collected first, so that rewriting the file, e.g. with -refresh-metadata, doesn't extend it.
+// Data written before the collection time was stored uses the file's modification time.
+func cacheFresh(fileName string, maxAge time.Duration, now time.Time) (functionReports []FunctionReports, fresh bool, err error) {
+	fi, err := os.Stat(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("cacheFresh: %w", err)
+	}
+	if functionReports, err = readFunctionReports(fileName); err != nil {
+		return nil, false, fmt.Errorf("cacheFresh: %w", err)
+	}
+	collected := reportDataCollected(functionReports)
+	if collected.IsZero() {
+		collected = fi.ModTime()
+	}
+	return functionReports, maxAge <= 0 || now.Sub(collected) < maxAge, nil
+}
+
+// reportDataCollected returns the earliest time that log data was collected, or zero if none of
+// the functions record it.
+func reportDataCollected(functionReports []FunctionReports) (collected time.Time) {
+	for _, fr := range functionReports {
+		if !fr.Collected.IsZero() && (collected.IsZero() || fr.Collected.Before(collected)) {
+			collected = fr.Collected
+		}
+	}
+	return collected
+}
+
+// readMetadataCache returns the cached metadata. It returns false if the cache doesn't exist, is
+// older than maxAge, or is missing triggers that are required.
+func readMetadataCache(fileName string, maxAge time.Duration, triggers bool, now time.Time) (functions []FunctionReports, ok bool, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("readMetadataCache: could not open %q: %w", fileName, err)
+	}
+	defer f.Close()
+	var mc MetadataCache
+	if err = json.NewDecoder(f).Decode(&mc); err != nil {
+		return nil, false, fmt.Errorf("readMetadataCache: could not decode %q: %w", fileName, err)
+	}
+	if maxAge > 0 && now.Sub(mc.Collected) >= maxAge {
+		return nil, false, nil
+	}
+	if triggers && !mc.Triggers {
+		return nil, false, nil
+	}
+	return mc.Functions, true, nil
+}
+
+// writeMetadataCache writes the metadata, unless any function had an error while its metadata was
+// collected, or the deadline expired before it was collected, so that errors and partial metadata
+// aren't cached.
+func writeMetadataCache(fileName string, functions []FunctionReports, triggers bool, now time.Time) (written bool, err error) {
+	for _, fr := range functions {
+		if len(fr.Errors) > 0 || fr.DeadlineExceeded {
+			return false, nil
+		}
+	}
+	mc := MetadataCache{
+		Collected: now.UTC(),
+		Triggers:  triggers,
+		Functions: functions,
+	}
+	err = writeFileAtomic(fileName, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(mc)
+	})
+	if err != nil {
+		return false, fmt.Errorf("writeMetadataCache: %w", err)
+	}
+	return true, nil
+}
+
+// setMetadata replaces the function's metadata with newer metadata, keeping its log data.
+func (fr *FunctionReports) setMetadata(m FunctionReports) {
+	fr.AccountName = m.AccountName
+	fr.Architecture = m.Architecture
+	fr.MemorySize = m.MemorySize
+	fr.Timeout = m.Timeout
+	fr.Description = m.Description
+	fr.Runtime = m.Runtime
+	fr.PackageType = m.PackageType
+	fr.CodeSize = m.CodeSize
+	fr.EphemeralStorage = m.EphemeralStorage
+	fr.Layers = m.Layers
+	fr.Tags = m.Tags
+	fr.Triggers = m.Triggers
+	fr.ProvisionedConcurrency = m.ProvisionedConcurrency
+	fr.ProvisionedConcurrencySchedules = m.ProvisionedConcurrencySchedules
+	fr.SnapStart = m.SnapStart
+	fr.Version = m.Version
+	fr.Errors = append(fr.Errors, m.Errors...)
+}
+
+// applyMetadata updates the metadata of the report data. Functions that no longer exist are
+// removed, and functions that aren't in the report data are returned, since they don't have log
+// data until it's collected again.
+func applyMetadata(functionReports, metadata []FunctionReports) (updated []FunctionReports, removed, added []string) {
+	byKey := map[string]FunctionReports{}
+	for _, m := range metadata {
+		byKey[m.Account+"/"+m.Region+"/"+m.Name] = m
+	}
+	seen := map[string]struct{}{}
+	for _, fr := range functionReports {
+		key := fr.Account + "/" + fr.Region + "/" + fr.Name
+		m, ok := byKey[key]
+		if !ok {
+			removed = append(removed, fr.Name)
+			continue
+		}
+		seen[key] = struct{}{}
+		fr.setMetadata(m)
+		updated = append(updated, fr)
+	}
+	for _, m := range metadata {
+		if _, ok := seen[m.Account+"/"+m.Region+"/"+m.Name]; !ok {
+			added = append(added, m.Name)
+		}
+	}
+	return updated, removed, added
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheFresh(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		collected []time.Time
+		modified  time.Time
+		maxAge    time.Duration
+		expected  bool
+	}{
+		{name: "recently collected", collected: []time.Time{now.Add(-10 * time.Minute)}, modified: now.Add(-10 * time.Minute), maxAge: time.Hour, expected: true},
+		{name: "rewritten since it was collected", collected: []time.Time{now.Add(-2 * time.Hour)}, modified: now.Add(-time.Minute), maxAge: time.Hour},
+		{name: "oldest function is used", collected: []time.Time{now.Add(-10 * time.Minute), now.Add(-2 * time.Hour)}, modified: now, maxAge: time.Hour},
+		{name: "functions without log data are ignored", collected: []time.Time{now.Add(-10 * time.Minute), {}}, modified: now, maxAge: time.Hour, expected: true},
+		{name: "never expires", collected: []time.Time{now.Add(-48 * time.Hour)}, modified: now, expected: true},
+		{name: "data without a collection time uses the modification time", collected: []time.Time{{}}, modified: now.Add(-2 * time.Hour), maxAge: time.Hour},
+		{name: "recently modified data without a collection time", collected: []time.Time{{}}, modified: now.Add(-time.Minute), maxAge: time.Hour, expected: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "report.json")
+			var functionReports []FunctionReports
+			for _, collected := range test.collected {
+				functionReports = append(functionReports, FunctionReports{Name: "api", Collected: collected})
+			}
+			if err := writeFunctionReports(fileName, functionReports); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(fileName, test.modified, test.modified); err != nil {
+				t.Fatal(err)
+			}
+			cached, fresh, err := cacheFresh(fileName, test.maxAge, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fresh != test.expected {
+				t.Errorf("expected fresh to be %v, got %v", test.expected, fresh)
+			}
+			if len(cached) != len(functionReports) {
+				t.Errorf("expected %d functions, got %d", len(functionReports), len(cached))
+			}
+		})
+	}
+}
+
+func TestCacheFreshMissingFile(t *testing.T) {
+	_, fresh, err := cacheFresh(filepath.Join(t.TempDir(), "missing.json"), time.Hour, time.Now())
+	if err != nil || fresh {
+		t.Errorf("expected a missing file not to be fresh, without an error, got %v, %v", fresh, err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwmtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Default proportion by which the number of REPORT lines can differ from the Invocations metric
+// before the function is flagged.
+const defaultInvocationTolerance = 0.05
+
+// getMetricSum returns the sum of a Lambda metric, e.g. Invocations or Errors, for the function over the window.
+func getMetricSum(ctx context.Context, cwClient *cloudwatch.Client, region, functionName, metricName string, start, end time.Time) (total int64, err error) {
+	return sumMetric(ctx, cwClient, region, "AWS/Lambda", metricName, "FunctionName", functionName, start, end)
+}
+
+// getQualifiedMetricSum returns the sum of a Lambda metric for invocations of the alias or version.
+// $LATEST doesn't have its own metrics, so the function's metrics are used.
+func getQualifiedMetricSum(ctx context.Context, cwClient *cloudwatch.Client, region, functionName, qualifier, metricName string, start, end time.Time) (total int64, err error) {
+	if qualifier == "" || qualifier == "$LATEST" {
+		return getMetricSum(ctx, cwClient, region, functionName, metricName, start, end)
+	}
+	return sumMetricDimensions(ctx, cwClient, region, "AWS/Lambda", metricName, []cwmtypes.Dimension{
+		{Name: aws.String("FunctionName"), Value: aws.String(functionName)},
+		{Name: aws.String("Resource"), Value: aws.String(functionName + ":" + qualifier)},
+	}, start, end)
+}
+
+// sumMetric returns the sum of a metric with a single dimension over the window.
+func sumMetric(ctx context.Context, cwClient *cloudwatch.Client, region, namespace, metricName, dimensionName, dimensionValue string, start, end time.Time) (total int64, err error) {
+	return sumMetricDimensions(ctx, cwClient, region, namespace, metricName, []cwmtypes.Dimension{
+		{Name: aws.String(dimensionName), Value: aws.String(dimensionValue)},
+	}, start, end)
+}
+
+// alignMetricWindow widens the window to whole metric periods, since each datapoint covers a whole
+// period. The period is an hour, or a day for windows that would need more than the 1,440
+// datapoints that GetMetricStatistics returns. Periods are aligned to UTC.
+func alignMetricWindow(start, end time.Time) (alignedStart, alignedEnd time.Time, period time.Duration) {
+	for _, period = range []time.Duration{time.Hour, 24 * time.Hour} {
+		alignedStart, alignedEnd = start.Truncate(period), end.Truncate(period)
+		if alignedEnd.Before(end) {
+			alignedEnd = alignedEnd.Add(period)
+		}
+		if alignedEnd.Sub(alignedStart) <= 1440*period {
+			break
+		}
+	}
+	return alignedStart, alignedEnd, period
+}
+
+// sumMetricDimensions returns the sum of a metric with the dimensions over the window. The window
+// is widened to whole periods, so logs are collected from the aligned start, to cover the same time.
+func sumMetricDimensions(ctx context.Context, cwClient *cloudwatch.Client, region, namespace, metricName string, dimensions []cwmtypes.Dimension, start, end time.Time) (total int64, err error) {
+	start, end, period := alignMetricWindow(start, end)
+	output, err := cwClient.GetMetricStatistics(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(namespace),
+		MetricName: aws.String(metricName),
+		Dimensions: dimensions,
+		StartTime:  aws.Time(start),
+		EndTime:    aws.Time(end),
+		Period:     aws.Int32(int32(period.Seconds())),
+		Statistics: []cwmtypes.Statistic{cwmtypes.StatisticSum},
+	}, func(o *cloudwatch.Options) {
+		o.Region = region
+	})
+	if err != nil {
+		return 0, fmt.Errorf("sumMetricDimensions: failed to get %s metric statistics: %w", metricName, err)
+	}
+	var sum float64
+	for _, dp := range output.Datapoints {
+		sum += aws.ToFloat64(dp.Sum)
+	}
+	return int64(math.Round(sum)), nil
+}
+
+// InvocationMismatch returns true if the number of REPORT lines differs from the Invocations metric
+// by more than the tolerance, e.g. because logs are missing, or a subscription filter strips REPORT lines.
+func (fr FunctionReports) InvocationMismatch(tolerance float64) bool {
+	if fr.MetricInvocations == nil {
+		return false
+	}
+	metric := float64(*fr.MetricInvocations)
+	logged := float64(len(fr.Reports))
+	if metric == 0 {
+		return logged > 0
+	}
+	return math.Abs(logged-metric)/metric > tolerance
+}
+
+func displayInvocationMismatches(w io.Writer, reportContent []FunctionReports, tolerance float64) {
+	var mismatched []FunctionReports
+	for _, rc := range reportContent {
+		if rc.InvocationMismatch(tolerance) {
+			mismatched = append(mismatched, rc)
+		}
+	}
+	if len(mismatched) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Invocation count mismatch: REPORT lines differ from the Invocations metric by more than %.0f%%\n", tolerance*100)
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "REPORT Lines", "Invocations Metric"}, "\t"))
+	for _, rc := range mismatched {
+		fmt.Fprintln(tw, strings.Join([]string{
+			rc.Name,
+			rc.Region,
+			fmt.Sprintf("%d", len(rc.Reports)),
+			fmt.Sprintf("%d", *rc.MetricInvocations),
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Costs for these functions may be inaccurate. Check for missing log data, log sampling, or")
+	fmt.Fprintln(w, "subscription filters that drop REPORT lines.")
+}
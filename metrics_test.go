@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlignMetricWindow(t *testing.T) {
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		start, end     time.Time
+		expectedStart  time.Time
+		expectedEnd    time.Time
+		expectedPeriod time.Duration
+	}{
+		{
+			name:           "aligned",
+			start:          day.Add(time.Hour),
+			end:            day.Add(3 * time.Hour),
+			expectedStart:  day.Add(time.Hour),
+			expectedEnd:    day.Add(3 * time.Hour),
+			expectedPeriod: time.Hour,
+		},
+		{
+			name:           "short unaligned window",
+			start:          day.Add(time.Hour + 40*time.Minute),
+			end:            day.Add(2*time.Hour + 10*time.Minute),
+			expectedStart:  day.Add(time.Hour),
+			expectedEnd:    day.Add(3 * time.Hour),
+			expectedPeriod: time.Hour,
+		},
+		{
+			name:           "day",
+			start:          day.Add(-24*time.Hour + 30*time.Minute),
+			end:            day.Add(30 * time.Minute),
+			expectedStart:  day.Add(-24 * time.Hour),
+			expectedEnd:    day.Add(time.Hour),
+			expectedPeriod: time.Hour,
+		},
+		{
+			name:           "60 days is the most that hourly datapoints cover",
+			start:          day.Add(-1440 * time.Hour),
+			end:            day,
+			expectedStart:  day.Add(-1440 * time.Hour),
+			expectedEnd:    day,
+			expectedPeriod: time.Hour,
+		},
+		{
+			name:           "unaligned 60 days needs more than 1440 hourly datapoints",
+			start:          day.Add(-1440*time.Hour + 30*time.Minute),
+			end:            day.Add(30 * time.Minute),
+			expectedStart:  day.Add(-1440 * time.Hour),
+			expectedEnd:    day.Add(24 * time.Hour),
+			expectedPeriod: 24 * time.Hour,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			start, end, period := alignMetricWindow(test.start, test.end)
+			if !start.Equal(test.expectedStart) || !end.Equal(test.expectedEnd) || period != test.expectedPeriod {
+				t.Fatalf("expected %v to %v in periods of %v, got %v to %v in periods of %v", test.expectedStart, test.expectedEnd, test.expectedPeriod, start, end, period)
+			}
+			// Aligning an aligned start gives the same window, since logs are collected from it.
+			if againStart, _, againPeriod := alignMetricWindow(start, test.end); !againStart.Equal(start) || againPeriod != period {
+				t.Errorf("expected realigning to keep %v in periods of %v, got %v in periods of %v", start, period, againStart, againPeriod)
+			}
+		})
+	}
+}
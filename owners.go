@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultOwnerTag is the tag used to find the team that owns a function.
+const defaultOwnerTag = "team"
+
+// unowned is displayed for functions without an owner.
+const unowned = "(unowned)"
+
+// ownerResolver finds the team that owns a function, from a tag, falling back to a regular
+// expression matched against the function name.
+type ownerResolver struct {
+	Tag string
+	// Pattern extracts the owner from the function name, using the group named "owner", or
+	// the first group, e.g. ^([a-z]+)- for functions named {team}-{service}.
+	Pattern *regexp.Regexp
+}
+
+func newOwnerResolver(tag, pattern string) (r ownerResolver, err error) {
+	r.Tag = tag
+	if r.Tag == "" {
+		r.Tag = defaultOwnerTag
+	}
+	if pattern == "" {
+		return r, nil
+	}
+	if r.Pattern, err = regexp.Compile(pattern); err != nil {
+		return r, fmt.Errorf("newOwnerResolver: invalid owner pattern: %w", err)
+	}
+	if r.Pattern.NumSubexp() == 0 {
+		return r, fmt.Errorf("newOwnerResolver: owner pattern %q has no group to extract the owner from", pattern)
+	}
+	return r, nil
+}
+
+// Owner returns the owner of the function, or an empty string if it has no owner.
+func (r ownerResolver) Owner(fr FunctionReports) string {
+	if owner := strings.TrimSpace(fr.Tags[r.Tag]); owner != "" {
+		return owner
+	}
+	if r.Pattern == nil {
+		return ""
+	}
+	match := r.Pattern.FindStringSubmatch(fr.Name)
+	if match == nil {
+		return ""
+	}
+	if i := r.Pattern.SubexpIndex("owner"); i > 0 {
+		return match[i]
+	}
+	return match[1]
+}
+
+// OwnerCost is the cost, and savings opportunity, of the functions owned by a team.
+type OwnerCost struct {
+	Owner          string  `json:"owner"`
+	Functions      int     `json:"functions"`
+	Invocations    int     `json:"invocations"`
+	MonthlyCost    float64 `json:"monthlyCost"`
+	MonthlySavings float64 `json:"monthlySavings"`
+}
+
+// costByOwner totals the cost of functions by owner, most expensive first. Functions without
+// an owner are totalled as unowned. It returns nothing if no functions have an owner.
+func costByOwner(reportContent []FunctionReports, r ownerResolver) (costs []OwnerCost) {
+	var owned bool
+	indexes := map[string]int{}
+	for _, fr := range reportContent {
+		owner := r.Owner(fr)
+		if owner == "" {
+			owner = unowned
+		} else {
+			owned = true
+		}
+		i, ok := indexes[owner]
+		if !ok {
+			i = len(costs)
+			indexes[owner] = i
+			costs = append(costs, OwnerCost{Owner: owner})
+		}
+		costs[i].Functions++
+		costs[i].Invocations += len(fr.Reports)
+		costs[i].MonthlyCost += fr.DailyCost() * 30
+		costs[i].MonthlySavings += fr.MonthlySavings()
+	}
+	if !owned {
+		return nil
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		return costs[i].MonthlyCost > costs[j].MonthlyCost
+	})
+	return costs
+}
+
+func displayOwners(w io.Writer, reportContent []FunctionReports, r ownerResolver, format displayFormat) {
+	costs := costByOwner(reportContent, r)
+	if len(costs) == 0 {
+		return
+	}
+	var total float64
+	for _, c := range costs {
+		total += c.MonthlyCost
+	}
+	// Owners' functions can be in any region, so the totals are in the report's currency.
+	currency := reportCurrency(reportContent)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Top spenders by owner")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Owner", "Functions", "Invocations", "Monthly", "Share", "Monthly Savings"}, "\t"))
+	for _, c := range costs {
+		var share float64
+		if total > 0 {
+			share = c.MonthlyCost / total * 100
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			c.Owner,
+			fmt.Sprintf("%d", c.Functions),
+			fmt.Sprintf("%d", c.Invocations),
+			format.Money(c.MonthlyCost, currency, 2),
+			format.Percent(share, 1),
+			format.Money(c.MonthlySavings, currency, 2),
+		}, "\t"))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/a-h/lambdacost/pricing"
+)
+
+// AWS partitions. Regions in the China and GovCloud partitions have their own endpoints,
+// ARNs and prices.
+const (
+	partitionAWS      = pricing.PartitionAWS
+	partitionChina    = pricing.PartitionChina
+	partitionGovCloud = pricing.PartitionGovCloud
+)
+
+// regionPartition returns the partition that the region is in.
+func regionPartition(region string) string {
+	return pricing.Partition(region)
+}
+
+// currencyMixed is the currency of totals over functions in partitions with different currencies.
+const currencyMixed = "mixed"
+
+// regionCurrency returns the currency that functions in the region are priced in, e.g. USD, or
+// CNY in the China regions.
+func regionCurrency(region string) string {
+	return priceForRegion(region).CurrencyCode()
+}
+
+// Currency returns the currency that the function is priced in.
+func (fr FunctionReports) Currency() string {
+	return regionCurrency(fr.Region)
+}
+
+// currencies returns the currencies that the functions are priced in.
+func currencies(reportContent []FunctionReports) (codes []string) {
+	for _, fr := range reportContent {
+		if c := fr.Currency(); !contains(codes, c) {
+			codes = append(codes, c)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// reportCurrency returns the currency that the report's costs are in, or "mixed" if the
+// report includes functions in partitions with different currencies.
+func reportCurrency(reportContent []FunctionReports) string {
+	codes := currencies(reportContent)
+	switch len(codes) {
+	case 0:
+		return currencyUSD
+	case 1:
+		return codes[0]
+	}
+	return currencyMixed
+}
+
+func displayCurrency(w io.Writer, reportContent []FunctionReports) {
+	codes := currencies(reportContent)
+	if len(codes) == 0 || (len(codes) == 1 && codes[0] == currencyUSD) {
+		return
+	}
+	fmt.Fprintln(w)
+	if len(codes) == 1 {
+		fmt.Fprintf(w, "Costs are in %s.\n", codes[0])
+		return
+	}
+	fmt.Fprintf(w, "Warning: the report includes functions priced in %s, so costs are in the currency of each function's region, and totals mix currencies.\n", strings.Join(codes, " and "))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DurationPercentile returns the duration that p percent of invocations completed within, e.g. 99.
+func (fr FunctionReports) DurationPercentile(p float64) time.Duration {
+	values := make([]time.Duration, len(fr.Reports))
+	for i, r := range fr.Reports {
+		values[i] = r.Duration
+	}
+	return percentile(values, p)
+}
+
+// BilledDurationPercentile returns the billed duration that p percent of invocations were within.
+func (fr FunctionReports) BilledDurationPercentile(p float64) time.Duration {
+	values := make([]time.Duration, len(fr.Reports))
+	for i, r := range fr.Reports {
+		values[i] = r.BilledDuration
+	}
+	return percentile(values, p)
+}
+
+// MemoryUsedPercentile returns the memory used (MB) that p percent of invocations were within.
+func (fr FunctionReports) MemoryUsedPercentile(p float64) int64 {
+	values := make([]int64, len(fr.Reports))
+	for i, r := range fr.Reports {
+		values[i] = r.MaxMemoryUsed
+	}
+	return percentile(values, p)
+}
+
+// percentile uses the nearest-rank method. The values are sorted in place.
+func percentile[T time.Duration | int64](values []T, p float64) T {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := int(math.Ceil(p/100*float64(len(values)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(values) {
+		rank = len(values) - 1
+	}
+	return values[rank]
+}
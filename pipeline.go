@@ -0,0 +1,593 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"go.uber.org/zap"
+)
+
+// Environment variables used when lambdacost runs as a Lambda function in the pipeline.
+const (
+	envLambdaRuntimeAPI = "AWS_LAMBDA_RUNTIME_API"
+	envPipelineBucket   = "LAMBDACOST_BUCKET"
+	envPipelineWindow   = "LAMBDACOST_WINDOW"
+	// envPipelineAuditTable is the DynamoDB table that the audit log is written to.
+	envPipelineAuditTable = "LAMBDACOST_AUDIT_TABLE"
+)
+
+// Actions handled by the pipeline function.
+const (
+	pipelineActionCollect = "collect"
+	pipelineActionMerge   = "merge"
+)
+
+// pipelineEvent is the input to the pipeline function, sent by the state machine.
+type pipelineEvent struct {
+	Action string `json:"action"`
+	// RunID is the name of the state machine execution, used as the S3 prefix of the results.
+	RunID string `json:"runId"`
+	// Shard is the slice of functions to collect, e.g. 3/8.
+	Shard string `json:"shard,omitempty"`
+}
+
+type pipelineResult struct {
+	Key       string `json:"key"`
+	Functions int    `json:"functions"`
+}
+
+// pipelineKey returns the S3 key of a file written by a pipeline run.
+func pipelineKey(runID, name string) string {
+	return path.Join("runs", runID, name)
+}
+
+// handlePipelineEvent collects a shard of functions, or merges the shards of a run, reading and
+// writing report data in the pipeline's S3 bucket.
+func handlePipelineEvent(ctx context.Context, event pipelineEvent) (result pipelineResult, err error) {
+	log := newLog().With(zap.String("action", event.Action), zap.String("runId", event.RunID))
+	bucket := os.Getenv(envPipelineBucket)
+	if bucket == "" {
+		return result, fmt.Errorf("handlePipelineEvent: %s is not set", envPipelineBucket)
+	}
+	if event.RunID == "" {
+		return result, fmt.Errorf("handlePipelineEvent: runId is required")
+	}
+	cfg, err := loadAWSConfig(ctx, awsOptions{})
+	if err != nil {
+		return result, fmt.Errorf("handlePipelineEvent: could not load AWS config: %w", err)
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return result, fmt.Errorf("handlePipelineEvent: could not get current identity: %w", err)
+	}
+	// The Lambda filesystem is read-only, so the audit log is written to DynamoDB. Entries use the
+	// pipeline's run ID, so that the entries of each shard are grouped together.
+	audit := &auditLog{runID: event.RunID, actor: aws.ToString(identity.Arn)}
+	audit.WithTable(dynamodb.NewFromConfig(cfg), os.Getenv(envPipelineAuditTable))
+	s3Client := s3.NewFromConfig(cfg)
+	var functionReports []FunctionReports
+	var auditEntries []AuditEntry
+	switch event.Action {
+	case pipelineActionCollect:
+		functionShard, err := parseShard(event.Shard)
+		if err != nil {
+			return result, fmt.Errorf("handlePipelineEvent: %w", err)
+		}
+		var scan AuditEntry
+		if functionReports, scan, err = collectPipelineShard(ctx, log.With(zap.String("shard", event.Shard)), cfg, *identity.Account, functionShard); err != nil {
+			return result, fmt.Errorf("handlePipelineEvent: %w", err)
+		}
+		result.Key = pipelineKey(event.RunID, functionShard.String()+".json")
+		scan.Details = "written to " + result.Key
+		auditEntries = append(auditEntries, scan)
+	case pipelineActionMerge:
+		result.Key = pipelineKey(event.RunID, "merged.json")
+		recommenders, err := newRecommenders(Settings{}, nil, nil)
+		if err != nil {
+			return result, fmt.Errorf("handlePipelineEvent: %w", err)
+		}
+		if result.Functions, auditEntries, err = mergePipelineRun(ctx, log, s3Client, bucket, event.RunID, result.Key, recommenders); err != nil {
+			return result, fmt.Errorf("handlePipelineEvent: %w", err)
+		}
+	default:
+		return result, fmt.Errorf("handlePipelineEvent: unknown action %q", event.Action)
+	}
+	if event.Action == pipelineActionCollect {
+		body, err := json.Marshal(functionReports)
+		if err != nil {
+			return result, fmt.Errorf("handlePipelineEvent: could not encode report data: %w", err)
+		}
+		_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      &bucket,
+			Key:         &result.Key,
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String("application/json"),
+		})
+		if err != nil {
+			return result, fmt.Errorf("handlePipelineEvent: could not write %q: %w", result.Key, err)
+		}
+		result.Functions = len(functionReports)
+	}
+	log.Info("report data written", zap.String("key", result.Key), zap.Int("functions", result.Functions))
+	for _, e := range auditEntries {
+		if err = audit.Add(e); err != nil {
+			return result, fmt.Errorf("handlePipelineEvent: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// collectPipelineShard collects the shard's functions, and returns an audit entry for the scan.
+func collectPipelineShard(ctx context.Context, log *zap.Logger, cfg aws.Config, account string, functionShard shard) (functionReports []FunctionReports, scan AuditEntry, err error) {
+	window, err := parseWindow(os.Getenv(envPipelineWindow))
+	if err != nil {
+		return nil, scan, fmt.Errorf("collectPipelineShard: %w", err)
+	}
+	accountName := getAccountName(ctx, log, cfg, Settings{}, account)
+	var stats scanStats
+	cfg.APIOptions = append(cfg.APIOptions, stats.countAPICalls)
+	// The Lambda filesystem is read-only, so unparseable lines are logged rather than quarantined.
+	functionReports, err = getFunctionReports(ctx, log, cfg, &stats, account, accountName, collectOptions{
+		Window:    window,
+		Collector: collectorAuto,
+		Shard:     functionShard,
+	})
+	if err != nil && !errors.Is(err, errFunctionListPartial) {
+		return nil, scan, err
+	}
+	scan = AuditEntry{
+		Action:    auditActionScan,
+		Account:   account,
+		Region:    cfg.Region,
+		Functions: len(functionReports),
+		Status:    auditStatusComplete,
+	}
+	if countDeadlineExceeded(functionReports) > 0 || err != nil {
+		scan.Status = auditStatusPartial
+	}
+	return functionReports, scan, nil
+}
+
+// mergePipelineRun merges the report data written by each shard of a run into the key, and returns
+// an audit entry for each recommendation. Shards are partitioned by function, so the merge doesn't
+// need to combine the reports of a function. Each function is streamed from its shard to a multipart
+// upload, so that the merge uses the same memory however many functions are collected.
+func mergePipelineRun(ctx context.Context, log *zap.Logger, s3Client *s3.Client, bucket, runID, key string, recommenders *Recommenders) (functions int, recommendations []AuditEntry, err error) {
+	prefix := pipelineKey(runID, "shard-")
+	w, err := newS3Writer(ctx, s3Client, bucket, key)
+	if err != nil {
+		return 0, nil, fmt.Errorf("mergePipelineRun: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			w.Abort()
+		}
+	}()
+	if _, err = io.WriteString(w, "["); err != nil {
+		return 0, nil, fmt.Errorf("mergePipelineRun: %w", err)
+	}
+	enc := json.NewEncoder(w)
+	// Shards are written by function, so a function in more than one shard means that the shards
+	// were written by different runs.
+	shardOf := map[string]string{}
+	var shards, duplicates int
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: &bucket,
+		Prefix: &prefix,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, nil, fmt.Errorf("mergePipelineRun: failed to list shards: %w", err)
+		}
+		for _, object := range page.Contents {
+			shardKey := aws.ToString(object.Key)
+			err = readPipelineShard(ctx, s3Client, bucket, shardKey, func(fr FunctionReports) error {
+				functionKey := fr.Account + "/" + fr.Region + "/" + fr.Name
+				if previous, ok := shardOf[functionKey]; ok {
+					return fmt.Errorf("%s is also in %q", functionKey, previous)
+				}
+				shardOf[functionKey] = shardKey
+				// Merge the function on its own, so that its reports are deduplicated and sorted
+				// in the same way as the merge subcommand.
+				merged, d := mergeFunctionReports([]FunctionReports{fr})
+				fr, duplicates = merged[0], duplicates+d
+				if functions > 0 {
+					if _, err := io.WriteString(w, ","); err != nil {
+						return err
+					}
+				}
+				functions++
+				recommendations = append(recommendations, recommendationAuditEntries([]FunctionReports{fr}, recommenders)...)
+				return enc.Encode(fr)
+			})
+			if err != nil {
+				return 0, nil, fmt.Errorf("mergePipelineRun: %w", err)
+			}
+			shards++
+		}
+	}
+	if shards == 0 {
+		return 0, nil, fmt.Errorf("mergePipelineRun: no shards found under %q", prefix)
+	}
+	if _, err = io.WriteString(w, "]"); err != nil {
+		return 0, nil, fmt.Errorf("mergePipelineRun: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return 0, nil, fmt.Errorf("mergePipelineRun: %w", err)
+	}
+	log.Info("merged shards", zap.Int("shards", shards), zap.Int("functions", functions), zap.Int("duplicates", duplicates))
+	return functions, recommendations, nil
+}
+
+// readPipelineShard decodes the functions in a shard's report data one at a time.
+func readPipelineShard(ctx context.Context, s3Client *s3.Client, bucket, key string, f func(fr FunctionReports) error) (err error) {
+	output, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return fmt.Errorf("could not read %q: %w", key, err)
+	}
+	defer output.Body.Close()
+	dec := json.NewDecoder(output.Body)
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return fmt.Errorf("could not decode %q: expected an array", key)
+	}
+	for dec.More() {
+		var fr FunctionReports
+		if err = dec.Decode(&fr); err != nil {
+			return fmt.Errorf("could not decode %q: %w", key, err)
+		}
+		if err = f(fr); err != nil {
+			return fmt.Errorf("%q: %w", key, err)
+		}
+	}
+	if _, err = dec.Token(); err != nil {
+		return fmt.Errorf("could not decode %q: %w", key, err)
+	}
+	return nil
+}
+
+// s3PartSize is the size of each part of a multipart upload. S3 requires every part except the
+// last to be at least 5 MB.
+const s3PartSize = 8 * 1024 * 1024
+
+// s3Writer writes an S3 object as a multipart upload, uploading each part as it's filled, so that
+// only one part is held in memory.
+type s3Writer struct {
+	ctx      context.Context
+	client   *s3.Client
+	bucket   string
+	key      string
+	uploadID *string
+	part     bytes.Buffer
+	parts    []s3types.CompletedPart
+}
+
+func newS3Writer(ctx context.Context, client *s3.Client, bucket, key string) (w *s3Writer, err error) {
+	output, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      &bucket,
+		Key:         &key,
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not start upload of %q: %w", key, err)
+	}
+	return &s3Writer{
+		ctx:      ctx,
+		client:   client,
+		bucket:   bucket,
+		key:      key,
+		uploadID: output.UploadId,
+	}, nil
+}
+
+func (w *s3Writer) Write(p []byte) (n int, err error) {
+	n, _ = w.part.Write(p)
+	if w.part.Len() >= s3PartSize {
+		err = w.uploadPart()
+	}
+	return n, err
+}
+
+func (w *s3Writer) uploadPart() error {
+	partNumber := int32(len(w.parts) + 1)
+	output, err := w.client.UploadPart(w.ctx, &s3.UploadPartInput{
+		Bucket:     &w.bucket,
+		Key:        &w.key,
+		UploadId:   w.uploadID,
+		PartNumber: &partNumber,
+		Body:       bytes.NewReader(w.part.Bytes()),
+	})
+	if err != nil {
+		return fmt.Errorf("could not upload part %d of %q: %w", partNumber, w.key, err)
+	}
+	w.parts = append(w.parts, s3types.CompletedPart{ETag: output.ETag, PartNumber: &partNumber})
+	w.part.Reset()
+	return nil
+}
+
+// Close uploads the last part, and completes the upload.
+func (w *s3Writer) Close() (err error) {
+	if w.part.Len() > 0 || len(w.parts) == 0 {
+		if err = w.uploadPart(); err != nil {
+			return err
+		}
+	}
+	_, err = w.client.CompleteMultipartUpload(w.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          &w.bucket,
+		Key:             &w.key,
+		UploadId:        w.uploadID,
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: w.parts},
+	})
+	if err != nil {
+		return fmt.Errorf("could not complete upload of %q: %w", w.key, err)
+	}
+	return nil
+}
+
+// Abort discards the parts that have been uploaded, so that they're not charged for.
+func (w *s3Writer) Abort() {
+	w.client.AbortMultipartUpload(w.ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   &w.bucket,
+		Key:      &w.key,
+		UploadId: w.uploadID,
+	})
+}
+
+// startPipelineFunction runs lambdacost as the pipeline's Lambda function, if it's running in Lambda.
+func startPipelineFunction() (started bool) {
+	if os.Getenv(envLambdaRuntimeAPI) == "" {
+		return false
+	}
+	lambda.Start(handlePipelineEvent)
+	return true
+}
+
+type pipelineTemplateParams struct {
+	Shards         []string
+	MaxConcurrency int
+	Schedule       string
+	Window         string
+	CodeURI        string
+	MemorySize     int
+}
+
+func deployPipelineCmd(args []string) {
+	cmd := flag.NewFlagSet("deploy-pipeline", flag.ExitOnError)
+	shards := cmd.Int("shards", 8, "Number of shards to split collection into")
+	maxConcurrency := cmd.Int("max-concurrency", 0, "Maximum number of shards collected at the same time, or 0 for no limit")
+	schedule := cmd.String("schedule", "rate(1 day)", "EventBridge schedule expression used to start collection")
+	window := cmd.String("window", "1d", "The time window of logs to analyse, e.g. 1d, 7d or 12h")
+	codeURI := cmd.String("code-uri", "lambdacost.zip", "Path to a zip file containing lambdacost built as a bootstrap binary for linux/arm64")
+	memorySize := cmd.Int("memory", 1024, "Memory size of the collection function in MB")
+	output := cmd.String("o", "", "Path to write the template to, defaults to stdout")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost deploy-pipeline [flags]")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if *shards < 1 {
+		log.Fatal("shards must be at least 1", zap.Int("shards", *shards))
+	}
+	if _, err := parseWindow(*window); err != nil {
+		log.Fatal("could not parse window", zap.Error(err))
+	}
+	params := pipelineTemplateParams{
+		MaxConcurrency: *maxConcurrency,
+		Schedule:       *schedule,
+		Window:         *window,
+		CodeURI:        *codeURI,
+		MemorySize:     *memorySize,
+	}
+	for i := 1; i <= *shards; i++ {
+		params.Shards = append(params.Shards, fmt.Sprintf("%d/%d", i, *shards))
+	}
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal("could not create template file", zap.Error(err))
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writePipelineTemplate(w, params); err != nil {
+		log.Fatal("could not write template", zap.Error(err))
+	}
+}
+
+func writePipelineTemplate(w io.Writer, params pipelineTemplateParams) error {
+	if err := pipelineTemplate.Execute(w, params); err != nil {
+		return fmt.Errorf("writePipelineTemplate: %w", err)
+	}
+	return nil
+}
+
+var pipelineTemplate = template.Must(template.New("pipeline").Funcs(template.FuncMap{
+	"quote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	},
+}).Parse(`AWSTemplateFormatVersion: '2010-09-09'
+Transform: AWS::Serverless-2016-10-31
+Description: Collects lambdacost report data on a schedule, in {{ len .Shards }} parallel shards.
+
+Resources:
+  ReportBucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketEncryption:
+        ServerSideEncryptionConfiguration:
+          - ServerSideEncryptionByDefault:
+              SSEAlgorithm: AES256
+      PublicAccessBlockConfiguration:
+        BlockPublicAcls: true
+        BlockPublicPolicy: true
+        IgnorePublicAcls: true
+        RestrictPublicBuckets: true
+      LifecycleConfiguration:
+        Rules:
+          - Id: AbortIncompleteMultipartUploads
+            Status: Enabled
+            AbortIncompleteMultipartUpload:
+              DaysAfterInitiation: 1
+
+  AuditTable:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      BillingMode: PAY_PER_REQUEST
+      AttributeDefinitions:
+        - AttributeName: runId
+          AttributeType: S
+        - AttributeName: entryId
+          AttributeType: S
+      KeySchema:
+        - AttributeName: runId
+          KeyType: HASH
+        - AttributeName: entryId
+          KeyType: RANGE
+      PointInTimeRecoverySpecification:
+        PointInTimeRecoveryEnabled: true
+
+  CollectFunction:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: {{ quote .CodeURI }}
+      Handler: bootstrap
+      Runtime: provided.al2023
+      Architectures:
+        - arm64
+      MemorySize: {{ .MemorySize }}
+      Timeout: 900
+      Environment:
+        Variables:
+          LAMBDACOST_BUCKET: !Ref ReportBucket
+          LAMBDACOST_WINDOW: {{ quote .Window }}
+          LAMBDACOST_AUDIT_TABLE: !Ref AuditTable
+      Policies:
+        - S3CrudPolicy:
+            BucketName: !Ref ReportBucket
+        - Statement:
+            - Effect: Allow
+              Action:
+                - s3:AbortMultipartUpload
+              Resource: !Sub '${ReportBucket.Arn}/*'
+        - Statement:
+            - Effect: Allow
+              Action:
+                - dynamodb:PutItem
+              Resource: !GetAtt AuditTable.Arn
+        - Statement:
+            - Effect: Allow
+              Action:
+                - lambda:ListFunctions
+                - lambda:GetFunctionConfiguration
+                - lambda:ListTags
+                - lambda:ListProvisionedConcurrencyConfigs
+                - application-autoscaling:DescribeScheduledActions
+                - logs:DescribeLogGroups
+                - logs:DescribeSubscriptionFilters
+                - logs:FilterLogEvents
+                - logs:StartQuery
+                - logs:GetQueryResults
+                - cloudwatch:GetMetricStatistics
+                - cloudwatch:GetMetricData
+                - iam:ListAccountAliases
+              Resource: '*'
+
+  CollectStateMachine:
+    Type: AWS::Serverless::StateMachine
+    Properties:
+      Policies:
+        - LambdaInvokePolicy:
+            FunctionName: !Ref CollectFunction
+      Events:
+        Schedule:
+          Type: Schedule
+          Properties:
+            Schedule: {{ quote .Schedule }}
+      DefinitionSubstitutions:
+        CollectFunctionArn: !GetAtt CollectFunction.Arn
+        Partition: !Ref AWS::Partition
+      Definition:
+        StartAt: Shards
+        States:
+          Shards:
+            Type: Pass
+            Parameters:
+              runId.$: $$.Execution.Name
+              shards:
+{{- range .Shards }}
+                - {{ quote . }}
+{{- end }}
+            Next: Collect
+          Collect:
+            Type: Map
+            ItemsPath: $.shards
+            MaxConcurrency: {{ .MaxConcurrency }}
+            Parameters:
+              action: collect
+              runId.$: $.runId
+              shard.$: $$.Map.Item.Value
+            Iterator:
+              StartAt: CollectShard
+              States:
+                CollectShard:
+                  Type: Task
+                  Resource: arn:${Partition}:states:::lambda:invoke
+                  Parameters:
+                    FunctionName: ${CollectFunctionArn}
+                    Payload.$: $
+                  ResultSelector:
+                    key.$: $.Payload.key
+                  Retry:
+                    - ErrorEquals:
+                        - Lambda.TooManyRequestsException
+                        - Lambda.ServiceException
+                      IntervalSeconds: 5
+                      MaxAttempts: 3
+                      BackoffRate: 2
+                  End: true
+            ResultPath: null
+            Next: Merge
+          Merge:
+            Type: Task
+            Resource: arn:${Partition}:states:::lambda:invoke
+            Parameters:
+              FunctionName: ${CollectFunctionArn}
+              Payload:
+                action: merge
+                runId.$: $.runId
+            ResultSelector:
+              key.$: $.Payload.key
+              functions.$: $.Payload.functions
+            End: true
+
+Outputs:
+  ReportBucket:
+    Description: Bucket that report data is written to, under runs/{execution name}/merged.json
+    Value: !Ref ReportBucket
+  AuditTable:
+    Description: DynamoDB table that the audit log of each run is written to
+    Value: !Ref AuditTable
+  StateMachine:
+    Description: State machine that collects report data
+    Value: !Ref CollectStateMachine
+`))
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.uber.org/zap"
+)
+
+func TestMergePipelineRun(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	function := func(name string, requestIDs ...string) FunctionReports {
+		fr := FunctionReports{Name: name, Account: fakeAccountID, Region: "eu-west-1", Architecture: ArchitectureX86_64, MemorySize: 3008, Start: start, End: start.Add(24 * time.Hour)}
+		for _, id := range requestIDs {
+			fr.Reports = append(fr.Reports, Report{RequestID: id, Timestamp: start, Duration: time.Second, BilledDuration: time.Second, MemorySize: 3008, MaxMemoryUsed: 100})
+		}
+		return fr
+	}
+	shard := func(functions ...FunctionReports) []byte {
+		body, err := json.Marshal(functions)
+		if err != nil {
+			t.Fatalf("failed to encode shard: %v", err)
+		}
+		return body
+	}
+	tests := []struct {
+		name      string
+		shards    map[string][]byte
+		functions int
+		reports   int
+		err       string
+	}{
+		{
+			name: "shards are combined",
+			shards: map[string][]byte{
+				"shard-1-of-2.json": shard(function("api", "a", "b"), function("worker", "c")),
+				"shard-2-of-2.json": shard(function("cron", "d", "d")),
+			},
+			functions: 3,
+			reports:   4,
+		},
+		{
+			name: "empty shards are merged",
+			shards: map[string][]byte{
+				"shard-1-of-2.json": shard(function("api", "a")),
+				"shard-2-of-2.json": []byte("[]"),
+			},
+			functions: 1,
+			reports:   1,
+		},
+		{
+			name: "a function in more than one shard is an error",
+			shards: map[string][]byte{
+				"shard-1-of-2.json": shard(function("api", "a")),
+				"shard-2-of-2.json": shard(function("api", "b")),
+			},
+			err: "is also in",
+		},
+		{
+			name:   "invalid shards are an error",
+			shards: map[string][]byte{"shard-1-of-1.json": []byte(`{"name":"api"}`)},
+			err:    "expected an array",
+		},
+		{
+			name: "a run without shards is an error",
+			err:  "no shards found",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeAWS(t, "eu-west-1")
+			for name, body := range test.shards {
+				f.PutObject("reports", pipelineKey("run-1", name), body)
+			}
+			recommenders, err := newRecommenders(Settings{}, nil, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			client := s3.NewFromConfig(f.Config(), func(o *s3.Options) { o.UsePathStyle = true })
+			key := pipelineKey("run-1", "merged.json")
+			functions, recommendations, err := mergePipelineRun(context.Background(), zap.NewNop(), client, "reports", "run-1", key, recommenders)
+			if f.Uploads() != 0 {
+				t.Errorf("expected every upload to be completed or aborted, %d are in progress", f.Uploads())
+			}
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+				if _, ok := f.Object("reports", key); ok {
+					t.Error("expected merged report data not to be written")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			body, ok := f.Object("reports", key)
+			if !ok {
+				t.Fatal("expected merged report data to be written")
+			}
+			var merged []FunctionReports
+			if err = json.Unmarshal(body, &merged); err != nil {
+				t.Fatalf("failed to decode merged report data: %v", err)
+			}
+			if functions != test.functions || len(merged) != test.functions {
+				t.Errorf("expected %d functions, got %d, with %d in the merged data", test.functions, functions, len(merged))
+			}
+			var reports int
+			for _, fr := range merged {
+				reports += len(fr.Reports)
+			}
+			if reports != test.reports {
+				t.Errorf("expected %d reports after removing duplicates, got %d", test.reports, reports)
+			}
+			if len(recommendations) == 0 {
+				t.Error("expected recommendations to be returned for the audit log")
+			}
+			for _, e := range recommendations {
+				if e.Action != auditActionRecommend {
+					t.Errorf("expected recommend entries, got %q", e.Action)
+				}
+			}
+		})
+	}
+}
+
+func TestS3WriterUploadsParts(t *testing.T) {
+	f := newFakeAWS(t, "eu-west-1")
+	client := s3.NewFromConfig(f.Config(), func(o *s3.Options) { o.UsePathStyle = true })
+	w, err := newS3Writer(context.Background(), client, "reports", "merged.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := strings.Repeat("0123456789", (3*s3PartSize)/20)
+	for i := 0; i < len(expected); i += 1000 {
+		end := i + 1000
+		if end > len(expected) {
+			end = len(expected)
+		}
+		if _, err = w.Write([]byte(expected[i:end])); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parts := f.Requests()["S3:UploadPart"]; parts != 2 {
+		t.Errorf("expected 2 parts, got %d", parts)
+	}
+	if body, _ := f.Object("reports", "merged.json"); string(body) != expected {
+		t.Errorf("expected the object to contain everything that was written, got %d bytes of %d", len(body), len(expected))
+	}
+}
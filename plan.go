@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+)
+
+// Ways of grouping functions into migration batches.
+const (
+	planGroupByStack   = "stack"
+	planGroupByOwner   = "owner"
+	planGroupByRuntime = "runtime"
+)
+
+// noStack is the batch for functions that aren't managed by CloudFormation.
+const noStack = "(no stack)"
+
+// arm64UnsupportedRuntimes are runtimes that don't support arm64. Functions using them need
+// to move to a newer runtime before they can be migrated.
+var arm64UnsupportedRuntimes = map[string]string{
+	"go1.x":         "provided.al2023",
+	"java8":         "java8.al2",
+	"python3.6":     "python3.12",
+	"python3.7":     "python3.12",
+	"nodejs10.x":    "nodejs20.x",
+	"ruby2.5":       "ruby3.3",
+	"dotnetcore2.1": "dotnet8",
+	"provided":      "provided.al2023",
+}
+
+// MigrationFunction is a function in a migration batch.
+type MigrationFunction struct {
+	Account        string  `json:"account"`
+	Region         string  `json:"region"`
+	Name           string  `json:"name"`
+	Runtime        string  `json:"runtime"`
+	MonthlyCost    float64 `json:"monthlyCost"`
+	MonthlySavings float64 `json:"monthlySavings"`
+}
+
+func newMigrationFunction(fr FunctionReports) MigrationFunction {
+	return MigrationFunction{
+		Account:        fr.DisplayAccount(),
+		Region:         fr.Region,
+		Name:           fr.Name,
+		Runtime:        fr.Runtime,
+		MonthlyCost:    fr.DailyCost() * 30,
+		MonthlySavings: fr.MonthlyArchitectureSavings(),
+	}
+}
+
+// MigrationBatch is a group of functions to migrate together, e.g. the functions in a stack.
+type MigrationBatch struct {
+	Group          string              `json:"group"`
+	Functions      []MigrationFunction `json:"functions"`
+	MonthlySavings float64             `json:"monthlySavings"`
+	Checklist      []string            `json:"checklist"`
+}
+
+// BlockedMigration is a function that would save money on arm64, but can't be migrated yet.
+type BlockedMigration struct {
+	MigrationFunction
+	Reason string `json:"reason"`
+}
+
+// MigrationPlan is a programme of work to move functions from x86_64 to arm64, with the batches
+// that save the most first.
+type MigrationPlan struct {
+	GroupBy        string             `json:"groupBy"`
+	MonthlySavings float64            `json:"monthlySavings"`
+	Batches        []MigrationBatch   `json:"batches"`
+	Blocked        []BlockedMigration `json:"blocked,omitempty"`
+}
+
+// currency returns the currency of the plan's totals, or "mixed" if its functions are in
+// partitions with different currencies.
+func (p MigrationPlan) currency() string {
+	var codes []string
+	add := func(region string) {
+		if c := regionCurrency(region); !contains(codes, c) {
+			codes = append(codes, c)
+		}
+	}
+	for _, b := range p.Batches {
+		for _, f := range b.Functions {
+			add(f.Region)
+		}
+	}
+	for _, b := range p.Blocked {
+		add(b.Region)
+	}
+	switch len(codes) {
+	case 0:
+		return currencyUSD
+	case 1:
+		return codes[0]
+	}
+	return currencyMixed
+}
+
+// planGroup returns the batch that the function belongs to.
+func planGroup(fr FunctionReports, groupBy string, owners ownerResolver) string {
+	switch groupBy {
+	case planGroupByOwner:
+		if owner := owners.Owner(fr); owner != "" {
+			return owner
+		}
+		return unowned
+	case planGroupByRuntime:
+		if fr.Runtime == "" {
+			return "(unknown)"
+		}
+		return fr.Runtime
+	}
+	if stack := fr.Tags[tagCloudFormationStackName]; stack != "" {
+		return fr.Region + "/" + stack
+	}
+	return noStack
+}
+
+// planARM64Migration groups the functions that would save money on arm64 into batches.
+func planARM64Migration(reportContent []FunctionReports, groupBy string, owners ownerResolver) (plan MigrationPlan) {
+	plan.GroupBy = groupBy
+	batches := map[string][]FunctionReports{}
+	for _, fr := range reportContent {
+		if fr.LogGroupMissing || fr.PreselectionSkipped {
+			continue
+		}
+		if _, ok := architectureRecommendation(fr); !ok {
+			continue
+		}
+		if replacement, unsupported := arm64UnsupportedRuntimes[fr.Runtime]; unsupported {
+			plan.Blocked = append(plan.Blocked, BlockedMigration{
+				MigrationFunction: newMigrationFunction(fr),
+				Reason:            fmt.Sprintf("%s doesn't support arm64, upgrade to %s first", fr.Runtime, replacement),
+			})
+			continue
+		}
+		group := planGroup(fr, groupBy, owners)
+		batches[group] = append(batches[group], fr)
+	}
+	for group, functions := range batches {
+		batch := MigrationBatch{Group: group}
+		for _, fr := range functions {
+			mf := newMigrationFunction(fr)
+			batch.Functions = append(batch.Functions, mf)
+			batch.MonthlySavings += mf.MonthlySavings
+		}
+		sort.Slice(batch.Functions, func(i, j int) bool {
+			return batch.Functions[i].MonthlySavings > batch.Functions[j].MonthlySavings
+		})
+		batch.Checklist = migrationChecklist(functions, groupBy, group)
+		plan.Batches = append(plan.Batches, batch)
+		plan.MonthlySavings += batch.MonthlySavings
+	}
+	sort.Slice(plan.Batches, func(i, j int) bool {
+		if plan.Batches[i].MonthlySavings == plan.Batches[j].MonthlySavings {
+			return plan.Batches[i].Group < plan.Batches[j].Group
+		}
+		return plan.Batches[i].MonthlySavings > plan.Batches[j].MonthlySavings
+	})
+	sort.Slice(plan.Blocked, func(i, j int) bool {
+		return plan.Blocked[i].MonthlySavings > plan.Blocked[j].MonthlySavings
+	})
+	return plan
+}
+
+// migrationChecklist returns the steps to migrate a batch of functions.
+func migrationChecklist(functions []FunctionReports, groupBy, group string) (steps []string) {
+	var images, custom []string
+	layers := map[string]struct{}{}
+	for _, fr := range functions {
+		if fr.PackageType == "Image" {
+			images = append(images, fr.Name)
+		}
+		if strings.HasPrefix(fr.Runtime, "provided") {
+			custom = append(custom, fr.Name)
+		}
+		for _, l := range fr.Layers {
+			layers[l.ARN] = struct{}{}
+		}
+	}
+	if len(images) > 0 {
+		steps = append(steps, fmt.Sprintf("Build and push arm64 container images for %s", strings.Join(images, ", ")))
+	}
+	if len(custom) > 0 {
+		steps = append(steps, fmt.Sprintf("Cross-compile the bootstrap binaries for linux/arm64 for %s", strings.Join(custom, ", ")))
+	}
+	steps = append(steps, "Rebuild any native dependencies, e.g. compiled Python or Node.js packages, for arm64")
+	if len(layers) > 0 {
+		arns := make([]string, 0, len(layers))
+		for arn := range layers {
+			arns = append(arns, arn)
+		}
+		sort.Strings(arns)
+		steps = append(steps, fmt.Sprintf("Check that the layers support arm64, or publish arm64 versions: %s", strings.Join(arns, ", ")))
+	}
+	if groupBy == planGroupByStack && group != noStack {
+		steps = append(steps, "Set Architectures to arm64 in the stack, e.g. with lambdacost apply -changes architecture")
+	} else {
+		steps = append(steps, "Set Architectures to arm64 in each function's infrastructure as code")
+	}
+	steps = append(steps,
+		"Deploy to a test environment, and compare duration and errors with x86_64",
+		"Roll out to production, and re-run lambdacost to confirm the savings")
+	return steps
+}
+
+func displayMigrationPlan(w io.Writer, plan MigrationPlan, format displayFormat) {
+	if len(plan.Batches) == 0 && len(plan.Blocked) == 0 {
+		fmt.Fprintln(w, "No functions would save money by migrating to arm64.")
+		return
+	}
+	var functions int
+	for _, b := range plan.Batches {
+		functions += len(b.Functions)
+	}
+	currency := plan.currency()
+	fmt.Fprintf(w, "arm64 migration plan: %d functions in %d batches by %s, saving %s per month\n", functions, len(plan.Batches), plan.GroupBy, format.Money(plan.MonthlySavings, currency, 2))
+	for i, b := range plan.Batches {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Batch %d: %s, saving %s per month\n", i+1, b.Group, format.Money(b.MonthlySavings, currency, 2))
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+		fmt.Fprintln(tw, strings.Join([]string{"  Name", "Account", "Region", "Runtime", "Monthly", "Monthly Savings"}, "\t"))
+		for _, f := range b.Functions {
+			fmt.Fprintln(tw, strings.Join([]string{
+				"  " + f.Name,
+				f.Account,
+				f.Region,
+				f.Runtime,
+				format.Money(f.MonthlyCost, regionCurrency(f.Region), 2),
+				format.Money(f.MonthlySavings, regionCurrency(f.Region), 2),
+			}, "\t"))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+		for _, step := range b.Checklist {
+			fmt.Fprintf(w, "  [ ] %s\n", step)
+		}
+	}
+	if len(plan.Blocked) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Blocked")
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+		fmt.Fprintln(tw, strings.Join([]string{"  Name", "Region", "Monthly Savings", "Reason"}, "\t"))
+		for _, b := range plan.Blocked {
+			fmt.Fprintln(tw, strings.Join([]string{"  " + b.Name, b.Region, format.Money(b.MonthlySavings, regionCurrency(b.Region), 2), b.Reason}, "\t"))
+		}
+		tw.Flush()
+	}
+}
+
+func writeMigrationPlan(fileName string, plan MigrationPlan) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("writeMigrationPlan: could not create %q: %w", fileName, err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(plan); err != nil {
+		return fmt.Errorf("writeMigrationPlan: could not encode plan: %w", err)
+	}
+	return nil
+}
+
+func planCmd(args []string) {
+	if len(args) == 0 || args[0] != "arm64" {
+		fmt.Fprintln(os.Stderr, "usage: lambdacost plan arm64 [flags] <file.json>")
+		os.Exit(1)
+	}
+	cmd := flag.NewFlagSet("plan arm64", flag.ExitOnError)
+	config := cmd.String("config", "", "Path to a JSON settings file, e.g. to map account IDs to friendly names")
+	owner := newOwnerFlags(cmd)
+	groupBy := cmd.String("group-by", planGroupByStack, "How functions are grouped into batches: stack, owner or runtime")
+	out := cmd.String("out", "", "Path to write the plan as JSON to, e.g. plan.json")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost plan arm64 [flags] <file.json>")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args[1:])
+	log := newLog()
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	if *groupBy != planGroupByStack && *groupBy != planGroupByOwner && *groupBy != planGroupByRuntime {
+		log.Fatal("unsupported grouping", zap.String("groupBy", *groupBy))
+	}
+	settings := mustLoadSettings(log, *config)
+	owners, err := owner.owners(settings)
+	if err != nil {
+		log.Fatal("invalid owner options", zap.Error(err))
+	}
+	functionReports, err := readFunctionReports(cmd.Arg(0))
+	if err != nil {
+		log.Fatal("could not read report data", zap.Error(err))
+	}
+	format, err := newDisplayFormat(settings.DurationUnit, settings.DecimalSeparator)
+	if err != nil {
+		log.Fatal("invalid display format", zap.Error(err))
+	}
+	plan := planARM64Migration(functionReports, *groupBy, owners)
+	displayMigrationPlan(os.Stdout, plan, format)
+	if *out != "" {
+		if err = writeMigrationPlan(*out, plan); err != nil {
+			log.Fatal("could not write plan", zap.Error(err))
+		}
+	}
+}
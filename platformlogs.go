@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// platformReportType is the type of the platform event that replaces the REPORT line for
+// functions that use the JSON log format.
+const platformReportType = "platform.report"
+
+// platformEvent is a platform event written by Lambda for functions that use the JSON log format.
+type platformEvent struct {
+	Type   string `json:"type"`
+	Record struct {
+		RequestID string `json:"requestId"`
+		Metrics   struct {
+			DurationMS        float64  `json:"durationMs"`
+			BilledDurationMS  float64  `json:"billedDurationMs"`
+			MemorySizeMB      int64    `json:"memorySizeMB"`
+			MaxMemoryUsedMB   int64    `json:"maxMemoryUsedMB"`
+			InitDurationMS    *float64 `json:"initDurationMs"`
+			RestoreDurationMS *float64 `json:"restoreDurationMs"`
+		} `json:"metrics"`
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+	} `json:"record"`
+}
+
+func millisecondsToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// getPlatformReport parses a platform.report event into a Report. The status and error type are
+// stored in Extra under the same names as the REPORT line, so that errors and timeouts are
+// counted in the same way. It returns false if the message isn't a platform.report event.
+func getPlatformReport(message string) (r Report, ok bool, err error) {
+	if !strings.Contains(message, platformReportType) {
+		return
+	}
+	var e platformEvent
+	if err = json.Unmarshal([]byte(message), &e); err != nil {
+		// Application logs in JSON format may mention the type without being a platform event.
+		return r, false, nil
+	}
+	if e.Type != platformReportType {
+		return
+	}
+	ok = true
+	if e.Record.RequestID == "" {
+		err = fmt.Errorf("platform report is missing the request ID")
+		return
+	}
+	m := e.Record.Metrics
+	r.RequestID = e.Record.RequestID
+	r.Duration = millisecondsToDuration(m.DurationMS)
+	r.BilledDuration = millisecondsToDuration(m.BilledDurationMS)
+	r.MemorySize = m.MemorySizeMB
+	r.MaxMemoryUsed = m.MaxMemoryUsedMB
+	if m.InitDurationMS != nil {
+		r.InitDuration = millisecondsToDuration(*m.InitDurationMS)
+		r.IsColdStart = true
+	}
+	setExtra := func(k, v string) {
+		if r.Extra == nil {
+			r.Extra = map[string]string{}
+		}
+		r.Extra[k] = v
+	}
+	if m.RestoreDurationMS != nil {
+		setExtra("Restore Duration", strconv.FormatFloat(*m.RestoreDurationMS, 'f', 2, 64)+" ms")
+	}
+	if e.Record.Status != "" && e.Record.Status != "success" {
+		setExtra("Status", e.Record.Status)
+	}
+	if e.Record.ErrorType != "" {
+		setExtra("Error Type", e.Record.ErrorType)
+	}
+	return
+}
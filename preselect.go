@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/a-h/lambdacost/pricing"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwmtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"go.uber.org/zap"
+)
+
+// GetMetricData accepts up to 500 queries per request, and two queries are made per function.
+const preselectFunctionsPerRequest = 250
+
+// functionMetrics are the metrics used to estimate the maximum cost of a function, without
+// downloading its logs.
+type functionMetrics struct {
+	Invocations float64
+	MaxDuration time.Duration
+}
+
+// getFunctionMetrics gets the Invocations sum and maximum Duration of each function in a region
+// over the window, batching the queries for many functions into each GetMetricData request.
+// Functions without any datapoints weren't invoked, and have zero values.
+func getFunctionMetrics(ctx context.Context, cwClient *cloudwatch.Client, region string, functionNames []string, start, end time.Time) (metrics map[string]functionMetrics, err error) {
+	metrics = make(map[string]functionMetrics, len(functionNames))
+	// Use a single period that covers the whole window, rounded up to a whole minute.
+	period := int32(math.Ceil(end.Sub(start).Minutes())) * 60
+	for batchStart := 0; batchStart < len(functionNames); batchStart += preselectFunctionsPerRequest {
+		batchEnd := batchStart + preselectFunctionsPerRequest
+		if batchEnd > len(functionNames) {
+			batchEnd = len(functionNames)
+		}
+		batch := functionNames[batchStart:batchEnd]
+		var queries []cwmtypes.MetricDataQuery
+		for i, name := range batch {
+			metrics[name] = functionMetrics{}
+			queries = append(queries,
+				functionMetricQuery(fmt.Sprintf("i%d", i), name, "Invocations", cwmtypes.StatisticSum, period),
+				functionMetricQuery(fmt.Sprintf("d%d", i), name, "Duration", cwmtypes.StatisticMaximum, period))
+		}
+		paginator := cloudwatch.NewGetMetricDataPaginator(cwClient, &cloudwatch.GetMetricDataInput{
+			MetricDataQueries: queries,
+			StartTime:         aws.Time(start),
+			EndTime:           aws.Time(end),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx, func(o *cloudwatch.Options) {
+				o.Region = region
+			})
+			if err != nil {
+				return nil, fmt.Errorf("getFunctionMetrics: failed to get metric data: %w", err)
+			}
+			for _, result := range page.MetricDataResults {
+				id := aws.ToString(result.Id)
+				var index int
+				if _, err = fmt.Sscanf(id[1:], "%d", &index); err != nil || index >= len(batch) {
+					return nil, fmt.Errorf("getFunctionMetrics: unexpected result ID %q", id)
+				}
+				m := metrics[batch[index]]
+				for _, v := range result.Values {
+					switch id[0] {
+					case 'i':
+						m.Invocations += v
+					case 'd':
+						if d := time.Duration(v * float64(time.Millisecond)); d > m.MaxDuration {
+							m.MaxDuration = d
+						}
+					}
+				}
+				metrics[batch[index]] = m
+			}
+		}
+	}
+	return metrics, nil
+}
+
+func functionMetricQuery(id, functionName, metricName string, stat cwmtypes.Statistic, period int32) cwmtypes.MetricDataQuery {
+	return cwmtypes.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &cwmtypes.MetricStat{
+			Metric: &cwmtypes.Metric{
+				Namespace:  aws.String("AWS/Lambda"),
+				MetricName: aws.String(metricName),
+				Dimensions: []cwmtypes.Dimension{
+					{Name: aws.String("FunctionName"), Value: aws.String(functionName)},
+				},
+			},
+			Period: aws.Int32(period),
+			Stat:   aws.String(string(stat)),
+		},
+	}
+}
+
+// preselectFunctions marks functions whose maximum possible monthly cost is below the threshold as
+// skipped, so that their logs aren't downloaded. Functions with provisioned concurrency are never
+// skipped, since the allocation is charged whether or not they're invoked. If the metrics can't be
+// read, all functions in the region are collected.
+func preselectFunctions(ctx context.Context, log *zap.Logger, cwClient *cloudwatch.Client, functionReports []FunctionReports, start, end time.Time, threshold float64) {
+	indexesByRegion := map[string][]int{}
+	for i := range functionReports {
+		region := functionReports[i].Region
+		indexesByRegion[region] = append(indexesByRegion[region], i)
+	}
+	var skipped int
+	for region, indexes := range indexesByRegion {
+		// Costs aren't converted between currencies, so functions priced in another currency
+		// can't be compared with the threshold.
+		if currency := regionCurrency(region); currency != currencyUSD {
+			log.Warn("the preselection threshold is in USD, collecting all functions", zap.String("functionRegion", region), zap.String("currency", currency))
+			continue
+		}
+		names := make([]string, len(indexes))
+		for j, i := range indexes {
+			names[j] = functionReports[i].Name
+		}
+		log.Info("Getting metrics for preselection", zap.String("functionRegion", region), zap.Int("functionCount", len(names)))
+		metrics, err := getFunctionMetrics(ctx, cwClient, region, names, start, end)
+		if err != nil {
+			log.Warn("could not get metrics for preselection, collecting all functions", zap.String("functionRegion", region), zap.Error(err))
+			continue
+		}
+		for _, i := range indexes {
+			fr := &functionReports[i]
+			m := metrics[fr.Name]
+			fr.MaxMonthlyCost = maxMonthlyCost(*fr, m, end.Sub(start))
+			if fr.HasProvisionedConcurrency() || fr.MaxMonthlyCost >= threshold {
+				continue
+			}
+			invocations := int64(math.Round(m.Invocations))
+			fr.PreselectionSkipped = true
+			fr.MetricInvocations = &invocations
+			fr.Start = start
+			fr.End = end
+			skipped++
+		}
+	}
+	log.Info("Preselection complete", zap.Int("skippedFunctionCount", skipped), zap.Float64("minMonthlyCost", threshold))
+}
+
+// Lambda stops the init phase after 10 seconds, which is the most init duration that can be
+// billed for an invocation.
+const maxInitDuration = 10 * time.Second
+
+// maxMonthlyCost is the highest monthly cost the function could have, if every invocation in the
+// window ran for the maximum duration. Functions with a billed init phase, i.e. custom runtimes
+// and SnapStart, are assumed to have a cold start with the longest possible init on every
+// invocation.
+func maxMonthlyCost(fr FunctionReports, m functionMetrics, window time.Duration) float64 {
+	price := priceForRegion(fr.Region)
+	billedDuration := time.Duration(math.Ceil(float64(m.MaxDuration)/float64(time.Millisecond))) * time.Millisecond
+	snapStartCharged := false
+	if fr.SnapStart {
+		_, snapStartCharged = snapStartRuntime(fr.Runtime)
+	}
+	if fr.SnapStart || strings.HasPrefix(fr.Runtime, "provided") {
+		billedDuration += maxInitDuration
+	}
+	q := pricing.Quantities{
+		Requests:                  m.Invocations,
+		GBSeconds:                 m.Invocations * pricing.GBSeconds(fr.MemorySize, billedDuration),
+		EphemeralStorageGBSeconds: m.Invocations * pricing.EphemeralStorageGBSeconds(fr.EphemeralStorage, billedDuration),
+	}
+	gb := float64(fr.MemorySize) / 1024.0
+	if snapStartCharged {
+		q.SnapStartRestoreGB = m.Invocations * gb
+	}
+	days := window.Hours() / 24
+	monthly := pricing.Charges(price, fr.Architecture, q).Total / days * 30
+	if snapStartCharged {
+		// The snapshot is cached for the whole month, however often the function is invoked.
+		monthly += pricing.Charges(price, fr.Architecture, pricing.Quantities{SnapStartCacheGBSeconds: gb * 30 * 24 * 60 * 60}).Total
+	}
+	return monthly
+}
+
+func displayPreselectionSkipped(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	if len(reportContent) == 0 {
+		return
+	}
+	sort.Slice(reportContent, func(i, j int) bool {
+		return reportContent[i].MaxMonthlyCost > reportContent[j].MaxMonthlyCost
+	})
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Skipped by preselection")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Invocations Metric", "Max Monthly Cost"}, "\t"))
+	for _, rc := range reportContent {
+		var invocations int64
+		if rc.MetricInvocations != nil {
+			invocations = *rc.MetricInvocations
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			rc.Name,
+			rc.Region,
+			fmt.Sprintf("%d", invocations),
+			format.Money(rc.MaxMonthlyCost, rc.Currency(), 4),
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Logs weren't collected for these functions, because their maximum possible monthly cost,")
+	fmt.Fprintln(w, "estimated from the Invocations and Duration metrics, is below the preselection threshold.")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxMonthlyCost(t *testing.T) {
+	m := functionMetrics{Invocations: 1000, MaxDuration: 200 * time.Millisecond}
+	base := FunctionReports{Region: "us-east-1", Architecture: ArchitectureX86_64, MemorySize: 1024, Runtime: "nodejs20.x"}
+	baseCost := maxMonthlyCost(base, m, 24*time.Hour)
+	if baseCost <= 0 {
+		t.Fatalf("expected a cost, got %v", baseCost)
+	}
+	tests := []struct {
+		name string
+		fr   func(fr FunctionReports) FunctionReports
+		// extraGBSeconds is the additional billed duration of each invocation at 1 GB.
+		extraGBSeconds float64
+	}{
+		{
+			name: "ephemeral storage above 512 MB",
+			fr: func(fr FunctionReports) FunctionReports {
+				fr.EphemeralStorage = 10240
+				return fr
+			},
+		},
+		{
+			name: "custom runtimes are billed for init",
+			fr: func(fr FunctionReports) FunctionReports {
+				fr.Runtime = "provided.al2023"
+				return fr
+			},
+			extraGBSeconds: maxInitDuration.Seconds(),
+		},
+		{
+			name: "SnapStart is billed for restores",
+			fr: func(fr FunctionReports) FunctionReports {
+				fr.Runtime = "java21"
+				fr.SnapStart = true
+				return fr
+			},
+			extraGBSeconds: maxInitDuration.Seconds(),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fr := test.fr(base)
+			actual := maxMonthlyCost(fr, m, 24*time.Hour)
+			if actual <= baseCost {
+				t.Fatalf("expected more than %v, got %v", baseCost, actual)
+			}
+			if test.extraGBSeconds == 0 {
+				return
+			}
+			expected := baseCost + m.Invocations*test.extraGBSeconds*priceForRegion(fr.Region).GBSecond(fr.Architecture)*30
+			if diff := actual - expected; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("expected %v, got %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestMaxMonthlyCostIsAnUpperBound(t *testing.T) {
+	for _, fr := range generateDemoReports(1, time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC), 24*time.Hour) {
+		var m functionMetrics
+		for _, r := range fr.Reports {
+			m.Invocations++
+			if r.Duration > m.MaxDuration {
+				m.MaxDuration = r.Duration
+			}
+		}
+		if maxCost, actual := maxMonthlyCost(fr, m, fr.End.Sub(fr.Start)), fr.DailyCost()*30; maxCost < actual {
+			t.Errorf("%s: expected the maximum monthly cost, %v, to be at least the cost, %v", fr.Name, maxCost, actual)
+		}
+	}
+}
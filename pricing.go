@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/a-h/lambdacost/pricing"
+	"go.uber.org/zap"
+)
+
+// currencyUSD is the currency of prices in the commercial and GovCloud partitions.
+const currencyUSD = pricing.CurrencyUSD
+
+// RegionPrice is the on-demand price of Lambda in a region, for the first pricing tier.
+type RegionPrice = pricing.RegionPrice
+
+// prices are the built-in region prices, with any overrides from the settings file.
+var prices = pricing.Default()
+
+func priceForRegion(region string) RegionPrice {
+	return prices.ForRegion(region)
+}
+
+// setRegionPrices overrides the built-in region prices.
+func setRegionPrices(overrides map[string]RegionPrice) {
+	prices = prices.WithOverrides(overrides)
+}
+
+func verifyPricingCmd(args []string) {
+	cmd := flag.NewFlagSet("verify-pricing", flag.ExitOnError)
+	verbose := cmd.Bool("v", false, "List every test vector, not just those that fail")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost verify-pricing [-v]")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	vectors, err := pricing.Vectors()
+	if err != nil {
+		log.Fatal("could not read test vectors", zap.Error(err))
+	}
+	// The vectors are calculated with the built-in prices, so settings overrides aren't applied.
+	mismatches := pricing.Verify(pricing.Default(), vectors)
+	failed := map[string]pricing.Mismatch{}
+	for _, m := range mismatches {
+		failed[m.Name] = m
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, "Vector\tExpected\tActual\tResult")
+	for _, v := range vectors {
+		m, isFailed := failed[v.Name]
+		if !isFailed && !*verbose {
+			continue
+		}
+		actual, result := v.Expected, "ok"
+		if isFailed {
+			actual, result = m.Actual, "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Name, formatVectorCost(v.Expected), formatVectorCost(actual), result)
+	}
+	tw.Flush()
+	fmt.Printf("\n%d of %d pricing test vectors passed\n", len(vectors)-len(mismatches), len(vectors))
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+// formatVectorCost formats the total cost of a test vector, and its parts.
+func formatVectorCost(c pricing.Cost) string {
+	return fmt.Sprintf("%.10g %s (requests %.10g, duration %.10g, provisioned concurrency %.10g, ephemeral storage %.10g, SnapStart %.10g)", c.Total, c.Currency, c.Requests, c.Duration, c.ProvisionedConcurrency, c.EphemeralStorage, c.SnapStart)
+}
@@ -0,0 +1,188 @@
+package pricing
+
+import (
+	"time"
+)
+
+// Tier is a tier of duration pricing. Duration is charged in tiers of monthly GB-seconds, per
+// account, region and architecture, and each tier is a discount on the first tier's price.
+type Tier struct {
+	// UpTo is the monthly GB-seconds at which the tier ends, or zero for the last tier.
+	UpTo       float64
+	Multiplier float64
+}
+
+// Tiers are the duration pricing tiers of each architecture.
+var Tiers = map[Architecture][]Tier{
+	ArchitectureX86_64: {{UpTo: 6e9, Multiplier: 1}, {UpTo: 15e9, Multiplier: 0.9}, {Multiplier: 0.8}},
+	ArchitectureARM64:  {{UpTo: 7.5e9, Multiplier: 1}, {UpTo: 18.75e9, Multiplier: 0.9}, {Multiplier: 0.8}},
+}
+
+// Ephemeral storage up to the default 512 MB is free.
+const EphemeralStorageFreeMB = 512
+
+// monthSeconds is the length of a month that monthly costs are calculated for.
+const monthSeconds = 30 * 24 * 60 * 60
+
+// GBSeconds returns the compute used by an invocation with the memory size in MB.
+func GBSeconds(memorySize int64, billedDuration time.Duration) float64 {
+	return float64(memorySize) / 1024 * billedDuration.Seconds()
+}
+
+// Requests returns the cost of the number of requests.
+func Requests(rp RegionPrice, requests float64) float64 {
+	return rp.PerMillionRequests / 1e6 * requests
+}
+
+// Duration returns the cost of the GB-seconds at the first tier price.
+func Duration(rp RegionPrice, architecture Architecture, gbSeconds float64) float64 {
+	return gbSeconds * rp.GBSecond(architecture)
+}
+
+// TierUsage is the GB-seconds charged in a tier, and their cost.
+type TierUsage struct {
+	Tier
+	// From is the monthly GB-seconds at which the tier starts.
+	From      float64
+	GBSeconds float64
+	// Price is the price per GB-second in the tier.
+	Price float64
+	Cost  float64
+}
+
+// TieredDuration splits a month of GB-seconds into the tiers they're charged in. Only the tiers
+// that are used are returned.
+func TieredDuration(rp RegionPrice, architecture Architecture, gbSeconds float64) (tiers []TierUsage) {
+	if !architecture.Known() {
+		architecture = ArchitectureX86_64
+	}
+	remaining := gbSeconds
+	var from float64
+	for _, tier := range Tiers[architecture] {
+		if remaining <= 0 {
+			break
+		}
+		inTier := remaining
+		if tier.UpTo > 0 && from+inTier > tier.UpTo {
+			inTier = tier.UpTo - from
+		}
+		price := rp.GBSecond(architecture) * tier.Multiplier
+		tiers = append(tiers, TierUsage{
+			Tier:      tier,
+			From:      from,
+			GBSeconds: inTier,
+			Price:     price,
+			Cost:      inTier * price,
+		})
+		remaining -= inTier
+		from = tier.UpTo
+	}
+	return tiers
+}
+
+// EphemeralStorageGBSeconds returns the ephemeral storage GB-seconds charged for an invocation
+// of a function with the ephemeral storage size in MB.
+func EphemeralStorageGBSeconds(ephemeralStorage int64, billedDuration time.Duration) float64 {
+	if ephemeralStorage <= EphemeralStorageFreeMB {
+		return 0
+	}
+	return GBSeconds(ephemeralStorage-EphemeralStorageFreeMB, billedDuration)
+}
+
+// Quantities are the amounts of each charge, e.g. over the window of the report data.
+type Quantities struct {
+	Requests float64
+	// GBSeconds is the duration of invocations in on-demand environments.
+	GBSeconds float64
+	// ProvisionedGBSeconds is the duration of invocations in provisioned concurrency environments.
+	ProvisionedGBSeconds float64
+	// AllocatedGBSeconds is the provisioned concurrency that was allocated.
+	AllocatedGBSeconds        float64
+	EphemeralStorageGBSeconds float64
+	// SnapStartCacheGBSeconds and SnapStartRestoreGB are charged for SnapStart snapshots.
+	SnapStartCacheGBSeconds float64
+	SnapStartRestoreGB      float64
+}
+
+// Charges returns the cost of the quantities, with duration charged at the first tier price. The
+// report prices each function on its own, so tier discounts, which apply to the total of an
+// account, aren't included.
+func Charges(rp RegionPrice, architecture Architecture, q Quantities) (c Cost) {
+	c.Currency = rp.CurrencyCode()
+	c.Requests = Requests(rp, q.Requests)
+	c.Duration = Duration(rp, architecture, q.GBSeconds) + q.ProvisionedGBSeconds*rp.ProvisionedDurationGBSecond(architecture)
+	c.ProvisionedConcurrency = q.AllocatedGBSeconds * rp.ProvisionedConcurrencyGBSecond(architecture)
+	c.EphemeralStorage = q.EphemeralStorageGBSeconds * rp.EphemeralStoragePrice()
+	c.SnapStart = q.SnapStartCacheGBSeconds*rp.SnapStartCachePrice() + q.SnapStartRestoreGB*rp.SnapStartRestorePrice()
+	c.Total = c.Requests + c.Duration + c.ProvisionedConcurrency + c.EphemeralStorage + c.SnapStart
+	return c
+}
+
+// Usage is a month of invocations of a function that all have the same billed duration.
+type Usage struct {
+	Region       string       `json:"region"`
+	Architecture Architecture `json:"architecture"`
+	Invocations  float64      `json:"invocations"`
+	// MemorySize and EphemeralStorage are in MB. EphemeralStorage defaults to 512 MB.
+	MemorySize       int64 `json:"memorySize"`
+	EphemeralStorage int64 `json:"ephemeralStorage,omitempty"`
+	// BilledDurationMS is the billed duration of each invocation in milliseconds.
+	BilledDurationMS int64 `json:"billedDurationMs"`
+	// ProvisionedConcurrency is allocated for the whole month, and ProvisionedInvocations is how
+	// many of the invocations ran in provisioned concurrency environments.
+	ProvisionedConcurrency float64 `json:"provisionedConcurrency,omitempty"`
+	ProvisionedInvocations float64 `json:"provisionedInvocations,omitempty"`
+	// SnapStart is true if a charged SnapStart snapshot is cached for the whole month, and
+	// SnapStartRestores is the number of times that it's restored.
+	SnapStart         bool    `json:"snapStart,omitempty"`
+	SnapStartRestores float64 `json:"snapStartRestores,omitempty"`
+}
+
+// Quantities returns the amounts of each charge for the month of usage.
+func (u Usage) Quantities() (q Quantities) {
+	billed := time.Duration(u.BilledDurationMS) * time.Millisecond
+	gb := float64(u.MemorySize) / 1024
+	q.Requests = u.Invocations
+	q.GBSeconds = GBSeconds(u.MemorySize, billed) * (u.Invocations - u.ProvisionedInvocations)
+	q.ProvisionedGBSeconds = GBSeconds(u.MemorySize, billed) * u.ProvisionedInvocations
+	q.AllocatedGBSeconds = u.ProvisionedConcurrency * gb * monthSeconds
+	q.EphemeralStorageGBSeconds = EphemeralStorageGBSeconds(u.EphemeralStorage, billed) * u.Invocations
+	if u.SnapStart {
+		q.SnapStartCacheGBSeconds = gb * monthSeconds
+		q.SnapStartRestoreGB = gb * u.SnapStartRestores
+	}
+	return q
+}
+
+// Cost is the cost of usage, in Currency.
+type Cost struct {
+	Requests float64 `json:"requests"`
+	// Duration includes the duration of invocations in provisioned concurrency environments.
+	Duration float64 `json:"duration"`
+	// ProvisionedConcurrency is the cost of the allocation.
+	ProvisionedConcurrency float64 `json:"provisionedConcurrency"`
+	EphemeralStorage       float64 `json:"ephemeralStorage"`
+	SnapStart              float64 `json:"snapStart"`
+	Total                  float64 `json:"total"`
+	Currency               string  `json:"currency"`
+}
+
+// Cost returns the cost of the usage, with duration charged at the first tier price, as in the
+// report.
+func (t Table) Cost(u Usage) Cost {
+	return Charges(t.ForRegion(u.Region), u.Architecture, u.Quantities())
+}
+
+// MonthlyCost returns the cost of the usage, with on-demand duration charged in tiers, as in the
+// invoice.
+func (t Table) MonthlyCost(u Usage) (c Cost) {
+	rp := t.ForRegion(u.Region)
+	q := u.Quantities()
+	c = Charges(rp, u.Architecture, q)
+	c.Duration -= Duration(rp, u.Architecture, q.GBSeconds)
+	for _, tier := range TieredDuration(rp, u.Architecture, q.GBSeconds) {
+		c.Duration += tier.Cost
+	}
+	c.Total = c.Requests + c.Duration + c.ProvisionedConcurrency + c.EphemeralStorage + c.SnapStart
+	return c
+}
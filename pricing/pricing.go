@@ -0,0 +1,246 @@
+// Package pricing calculates the cost of AWS Lambda usage. It has no dependencies on AWS APIs or
+// log data, so that the calculations can be checked against the test vectors in vectors.json.
+package pricing
+
+import (
+	"sort"
+	"strings"
+)
+
+// Architecture is the instruction set architecture of a function.
+type Architecture string
+
+const (
+	ArchitectureX86_64 Architecture = "x86_64"
+	ArchitectureARM64  Architecture = "arm64"
+)
+
+// Known is true if the architecture is one that can be priced. Unknown architectures are priced
+// as x86_64.
+func (a Architecture) Known() bool {
+	return a == ArchitectureX86_64 || a == ArchitectureARM64
+}
+
+// CurrencyUSD is the currency of prices in the commercial and GovCloud partitions.
+const CurrencyUSD = "USD"
+
+// RegionPrice is the price of Lambda in a region, with on-demand duration at the first pricing
+// tier. All of the prices are in the same currency.
+type RegionPrice struct {
+	X86GBSecond        float64 `json:"x86GBSecond"`
+	ARM64GBSecond      float64 `json:"arm64GBSecond"`
+	PerMillionRequests float64 `json:"perMillionRequests"`
+	// Currency is the ISO 4217 currency code of the prices, e.g. CNY. Defaults to USD.
+	Currency string `json:"currency,omitempty"`
+	// The other prices are optional. If they're zero, they're derived from the on-demand duration
+	// price, in the same ratio as in us-east-1, so that they're in the region's currency.
+	//
+	// ProvisionedConcurrency prices are per GB-second of allocated concurrency, and
+	// ProvisionedDuration prices are per GB-second of invocations that run in it.
+	X86ProvisionedConcurrencyGBSecond   float64 `json:"x86ProvisionedConcurrencyGBSecond,omitempty"`
+	ARM64ProvisionedConcurrencyGBSecond float64 `json:"arm64ProvisionedConcurrencyGBSecond,omitempty"`
+	X86ProvisionedDurationGBSecond      float64 `json:"x86ProvisionedDurationGBSecond,omitempty"`
+	ARM64ProvisionedDurationGBSecond    float64 `json:"arm64ProvisionedDurationGBSecond,omitempty"`
+	// EphemeralStorageGBSecond is charged for ephemeral storage above the default 512 MB.
+	EphemeralStorageGBSecond float64 `json:"ephemeralStorageGBSecond,omitempty"`
+	// SnapStart for Python and .NET is charged per GB-second that the snapshot is cached, and per
+	// GB restored. SnapStart for Java has no additional charge.
+	SnapStartCacheGBSecond float64 `json:"snapStartCacheGBSecond,omitempty"`
+	SnapStartRestoreGB     float64 `json:"snapStartRestoreGB,omitempty"`
+	// LogStorageGBMonth is the price of storing CloudWatch Logs data.
+	LogStorageGBMonth float64 `json:"logStorageGBMonth,omitempty"`
+}
+
+// CurrencyCode returns the currency of the prices.
+func (rp RegionPrice) CurrencyCode() string {
+	if rp.Currency == "" {
+		return CurrencyUSD
+	}
+	return rp.Currency
+}
+
+// GBSecond returns the price per GB-second for the architecture.
+func (rp RegionPrice) GBSecond(architecture Architecture) float64 {
+	if architecture == ArchitectureARM64 {
+		return rp.ARM64GBSecond
+	}
+	return rp.X86GBSecond
+}
+
+// derived returns the price, or if it's zero, the us-east-1 price scaled by the ratio of the
+// region's on-demand duration price to the us-east-1 price.
+func (rp RegionPrice) derived(architecture Architecture, price, usEast1Price float64) float64 {
+	if price != 0 {
+		return price
+	}
+	if rp.GBSecond(architecture) == 0 {
+		return usEast1Price
+	}
+	return usEast1Price * rp.GBSecond(architecture) / defaultRegionPrice.GBSecond(architecture)
+}
+
+// ProvisionedConcurrencyGBSecond returns the price per GB-second of allocated provisioned
+// concurrency.
+func (rp RegionPrice) ProvisionedConcurrencyGBSecond(architecture Architecture) float64 {
+	if architecture == ArchitectureARM64 {
+		return rp.derived(architecture, rp.ARM64ProvisionedConcurrencyGBSecond, defaultRegionPrice.ARM64ProvisionedConcurrencyGBSecond)
+	}
+	return rp.derived(architecture, rp.X86ProvisionedConcurrencyGBSecond, defaultRegionPrice.X86ProvisionedConcurrencyGBSecond)
+}
+
+// ProvisionedDurationGBSecond returns the price per GB-second of invocations that run in
+// provisioned concurrency environments.
+func (rp RegionPrice) ProvisionedDurationGBSecond(architecture Architecture) float64 {
+	if architecture == ArchitectureARM64 {
+		return rp.derived(architecture, rp.ARM64ProvisionedDurationGBSecond, defaultRegionPrice.ARM64ProvisionedDurationGBSecond)
+	}
+	return rp.derived(architecture, rp.X86ProvisionedDurationGBSecond, defaultRegionPrice.X86ProvisionedDurationGBSecond)
+}
+
+// EphemeralStoragePrice returns the price per GB-second of ephemeral storage above the default.
+func (rp RegionPrice) EphemeralStoragePrice() float64 {
+	return rp.derived(ArchitectureX86_64, rp.EphemeralStorageGBSecond, defaultRegionPrice.EphemeralStorageGBSecond)
+}
+
+// SnapStartCachePrice returns the price per GB-second of caching a SnapStart snapshot.
+func (rp RegionPrice) SnapStartCachePrice() float64 {
+	return rp.derived(ArchitectureX86_64, rp.SnapStartCacheGBSecond, defaultRegionPrice.SnapStartCacheGBSecond)
+}
+
+// SnapStartRestorePrice returns the price per GB of restoring a SnapStart snapshot.
+func (rp RegionPrice) SnapStartRestorePrice() float64 {
+	return rp.derived(ArchitectureX86_64, rp.SnapStartRestoreGB, defaultRegionPrice.SnapStartRestoreGB)
+}
+
+// LogStoragePrice returns the price per GB-month of storing CloudWatch Logs data.
+func (rp RegionPrice) LogStoragePrice() float64 {
+	return rp.derived(ArchitectureX86_64, rp.LogStorageGBMonth, defaultRegionPrice.LogStorageGBMonth)
+}
+
+// AWS partitions. Regions in the China and GovCloud partitions have their own endpoints,
+// ARNs and prices.
+const (
+	PartitionAWS      = "aws"
+	PartitionChina    = "aws-cn"
+	PartitionGovCloud = "aws-us-gov"
+)
+
+// Partition returns the partition that the region is in.
+func Partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return PartitionChina
+	case strings.HasPrefix(region, "us-gov-"):
+		return PartitionGovCloud
+	}
+	return PartitionAWS
+}
+
+// defaultRegionPrice is used for regions that aren't in the regionPrices table.
+var defaultRegionPrice = RegionPrice{
+	X86GBSecond:                         0.0000166667,
+	ARM64GBSecond:                       0.0000133334,
+	PerMillionRequests:                  0.20,
+	X86ProvisionedConcurrencyGBSecond:   0.0000041667,
+	ARM64ProvisionedConcurrencyGBSecond: 0.0000033334,
+	X86ProvisionedDurationGBSecond:      0.0000097222,
+	ARM64ProvisionedDurationGBSecond:    0.0000077778,
+	EphemeralStorageGBSecond:            0.0000000309,
+	SnapStartCacheGBSecond:              0.0000015046,
+	SnapStartRestoreGB:                  0.0001397998,
+	LogStorageGBMonth:                   0.03,
+}
+
+// Regions in the China and GovCloud partitions are priced separately, and China regions are
+// priced in CNY. Their other prices are derived from the on-demand duration price.
+var (
+	chinaRegionPrice = RegionPrice{
+		X86GBSecond:        0.000113477,
+		ARM64GBSecond:      0.0000907816,
+		PerMillionRequests: 1.36,
+		Currency:           "CNY",
+	}
+	govCloudRegionPrice = RegionPrice{
+		X86GBSecond:        0.000020,
+		ARM64GBSecond:      0.000016,
+		PerMillionRequests: 0.25,
+	}
+)
+
+// partitionPrices are used for regions that aren't in the regionPrices table, so that new
+// regions in the China and GovCloud partitions aren't given commercial prices.
+var partitionPrices = map[string]RegionPrice{
+	PartitionAWS:      defaultRegionPrice,
+	PartitionChina:    chinaRegionPrice,
+	PartitionGovCloud: govCloudRegionPrice,
+}
+
+// regionPrices are taken from the AWS Lambda pricing page. They are not fetched from the
+// Pricing API, so check them against https://aws.amazon.com/lambda/pricing/ and override
+// them with Table.WithOverrides if required.
+var regionPrices = map[string]RegionPrice{
+	"us-east-1":      defaultRegionPrice,
+	"us-east-2":      defaultRegionPrice,
+	"us-west-1":      defaultRegionPrice,
+	"us-west-2":      defaultRegionPrice,
+	"ca-central-1":   defaultRegionPrice,
+	"eu-west-1":      defaultRegionPrice,
+	"eu-west-2":      defaultRegionPrice,
+	"eu-west-3":      defaultRegionPrice,
+	"eu-central-1":   defaultRegionPrice,
+	"eu-north-1":     defaultRegionPrice,
+	"ap-south-1":     defaultRegionPrice,
+	"ap-northeast-1": defaultRegionPrice,
+	"ap-northeast-2": defaultRegionPrice,
+	"ap-northeast-3": defaultRegionPrice,
+	"ap-southeast-1": defaultRegionPrice,
+	"ap-southeast-2": defaultRegionPrice,
+	"sa-east-1":      defaultRegionPrice,
+	"af-south-1":     {X86GBSecond: 0.0000221, ARM64GBSecond: 0.0000177, PerMillionRequests: 0.28},
+	"ap-east-1":      {X86GBSecond: 0.00002292, ARM64GBSecond: 0.00001834, PerMillionRequests: 0.28},
+	"eu-south-1":     {X86GBSecond: 0.0000195172, ARM64GBSecond: 0.0000156138, PerMillionRequests: 0.23},
+	"me-south-1":     {X86GBSecond: 0.0000206667, ARM64GBSecond: 0.0000165334, PerMillionRequests: 0.25},
+	"cn-north-1":     chinaRegionPrice,
+	"cn-northwest-1": chinaRegionPrice,
+	"us-gov-west-1":  govCloudRegionPrice,
+	"us-gov-east-1":  govCloudRegionPrice,
+}
+
+// Table is a set of region prices.
+type Table struct {
+	regions map[string]RegionPrice
+}
+
+// Default returns the built-in region prices.
+func Default() Table {
+	return Table{regions: regionPrices}
+}
+
+// WithOverrides returns a copy of the table, with the prices of the regions replaced.
+func (t Table) WithOverrides(prices map[string]RegionPrice) Table {
+	regions := make(map[string]RegionPrice, len(t.regions)+len(prices))
+	for region, rp := range t.regions {
+		regions[region] = rp
+	}
+	for region, rp := range prices {
+		regions[region] = rp
+	}
+	return Table{regions: regions}
+}
+
+// ForRegion returns the prices of the region, or of its partition if the region isn't in the table.
+func (t Table) ForRegion(region string) RegionPrice {
+	if rp, ok := t.regions[region]; ok {
+		return rp
+	}
+	return partitionPrices[Partition(region)]
+}
+
+// Regions returns the regions in the table, sorted by name.
+func (t Table) Regions() (regions []string) {
+	for region := range t.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
@@ -0,0 +1,69 @@
+package pricing
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// vectorsJSON are the test vectors. The expected costs are calculated by hand from the prices on
+// the AWS Lambda pricing page, not by this package, so that they catch mistakes in the calculation.
+//
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Vector is a test case for the pricing calculation, with the expected cost of the usage.
+type Vector struct {
+	Name  string `json:"name"`
+	Usage Usage  `json:"usage"`
+	// Tiered vectors are the total usage of an account, so duration is charged in tiers, as in
+	// MonthlyCost. Otherwise, the cost is calculated with Cost, which is used by the report.
+	Tiered   bool `json:"tiered,omitempty"`
+	Expected Cost `json:"expected"`
+}
+
+// Vectors returns the test vectors.
+func Vectors() (vectors []Vector, err error) {
+	if err = json.Unmarshal(vectorsJSON, &vectors); err != nil {
+		return nil, fmt.Errorf("pricing: could not parse test vectors: %w", err)
+	}
+	return vectors, nil
+}
+
+// Tolerance is the difference allowed between an expected and actual cost, since costs are
+// calculated with floating point numbers, and the expected costs are rounded.
+const Tolerance = 1e-9
+
+// Mismatch is a vector whose cost isn't the expected cost.
+type Mismatch struct {
+	Vector
+	Actual Cost
+}
+
+// Verify returns the vectors whose cost, calculated with the table, isn't the expected cost.
+func Verify(t Table, vectors []Vector) (mismatches []Mismatch) {
+	for _, v := range vectors {
+		actual := t.Cost(v.Usage)
+		if v.Tiered {
+			actual = t.MonthlyCost(v.Usage)
+		}
+		if !costEqual(v.Expected, actual) {
+			mismatches = append(mismatches, Mismatch{Vector: v, Actual: actual})
+		}
+	}
+	return mismatches
+}
+
+func costEqual(expected, actual Cost) bool {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) <= Tolerance*math.Max(1, math.Abs(a))
+	}
+	return expected.Currency == actual.Currency &&
+		near(expected.Requests, actual.Requests) &&
+		near(expected.Duration, actual.Duration) &&
+		near(expected.ProvisionedConcurrency, actual.ProvisionedConcurrency) &&
+		near(expected.EphemeralStorage, actual.EphemeralStorage) &&
+		near(expected.SnapStart, actual.SnapStart) &&
+		near(expected.Total, actual.Total)
+}
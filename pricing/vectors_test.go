@@ -0,0 +1,28 @@
+package pricing
+
+import "testing"
+
+func TestVectors(t *testing.T) {
+	vectors, err := Vectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) == 0 {
+		t.Fatal("no test vectors")
+	}
+	for _, v := range vectors {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			if mismatches := Verify(Default(), []Vector{v}); len(mismatches) > 0 {
+				t.Errorf("expected %+v, got %+v", v.Expected, mismatches[0].Actual)
+			}
+		})
+	}
+}
+
+func TestMonthlyCostMatchesCostInTheFirstTier(t *testing.T) {
+	u := Usage{Region: "us-east-1", Architecture: ArchitectureARM64, Invocations: 1e6, MemorySize: 512, BilledDurationMS: 250, ProvisionedConcurrency: 1, ProvisionedInvocations: 5e5}
+	if cost, monthly := Default().Cost(u), Default().MonthlyCost(u); !costEqual(cost, monthly) {
+		t.Errorf("expected the same cost below the second tier, got %+v and %+v", cost, monthly)
+	}
+}
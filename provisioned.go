@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// Recommend reducing provisioned concurrency when average concurrency is below this proportion of the allocation.
+const provisionedConcurrencyMinUtilisation = 0.5
+
+// getProvisionedConcurrency returns the total provisioned concurrency allocated across all aliases and versions,
+// and the allocation of each alias or version.
+func getProvisionedConcurrency(ctx context.Context, lambdaClient *lambda.Client, region, functionName string) (allocated int32, byQualifier map[string]int32, err error) {
+	paginator := lambda.NewListProvisionedConcurrencyConfigsPaginator(lambdaClient, &lambda.ListProvisionedConcurrencyConfigsInput{
+		FunctionName: &functionName,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, func(o *lambda.Options) {
+			o.Region = region
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("getProvisionedConcurrency: failed to list provisioned concurrency configs: %w", err)
+		}
+		for _, pc := range page.ProvisionedConcurrencyConfigs {
+			if pc.AllocatedProvisionedConcurrentExecutions != nil {
+				allocated += *pc.AllocatedProvisionedConcurrentExecutions
+				if byQualifier == nil {
+					byQualifier = map[string]int32{}
+				}
+				// ARNs are qualified, e.g. arn:aws:lambda:eu-west-1:123456789012:function:name:live.
+				arn := aws.ToString(pc.FunctionArn)
+				byQualifier[arn[strings.LastIndex(arn, ":")+1:]] += *pc.AllocatedProvisionedConcurrentExecutions
+			}
+		}
+	}
+	return allocated, byQualifier, nil
+}
+
+// provisionedConcurrencyGBSecondPrice is the price per GB-second of allocated concurrency, in the
+// currency of the region.
+func provisionedConcurrencyGBSecondPrice(region string, architecture Architecture) float64 {
+	return priceForRegion(region).ProvisionedConcurrencyGBSecond(architecture)
+}
+
+// provisionedConcurrencyDurationGBSecondPrice is the lower duration price of invocations that run
+// in provisioned concurrency environments, in the currency of the region.
+func provisionedConcurrencyDurationGBSecondPrice(region string, architecture Architecture) float64 {
+	return priceForRegion(region).ProvisionedDurationGBSecond(architecture)
+}
+
+// RanOnProvisionedConcurrency estimates whether an invocation ran in a provisioned concurrency
+// environment. Those environments are initialised before they're invoked, so REPORT lines don't
+// include an init duration, and the init isn't billed as part of the invocation. Cold starts are
+// treated as spillover to on-demand environments.
+func (fr FunctionReports) RanOnProvisionedConcurrency(r Report) bool {
+	return fr.HasProvisionedConcurrency() && !r.IsColdStart
+}
+
+// provisionedConcurrencyAllocatedGBSeconds is the provisioned concurrency allocated over the window.
+func (fr FunctionReports) provisionedConcurrencyAllocatedGBSeconds(memorySize int64) float64 {
+	if memorySize == 0 {
+		memorySize = fr.MemoryAssigned()
+	}
+	gb := float64(memorySize) / 1024.0
+	return fr.AvgProvisionedConcurrency() * gb * fr.Days() * 24 * 60 * 60
+}
+
+// MonthlyProvisionedConcurrencyCost is the monthly cost of the function's provisioned concurrency allocation.
+func (fr FunctionReports) MonthlyProvisionedConcurrencyCost() float64 {
+	return fr.provisionedConcurrencyMonthlyCost(fr.monthlyProvisionedConcurrency())
+}
+
+func (fr FunctionReports) provisionedConcurrencyMonthlyCost(concurrency float64) float64 {
+	gb := float64(fr.MemoryAssigned()) / 1024.0
+	if gb == 0 {
+		return 0
+	}
+	return concurrency * gb * 30 * 24 * 60 * 60 * provisionedConcurrencyGBSecondPrice(fr.Region, fr.Architecture)
+}
+
+// AvgConcurrency is the average number of concurrent executions over the window.
+func (fr FunctionReports) AvgConcurrency() float64 {
+	var seconds float64
+	for _, r := range fr.Reports {
+		seconds += r.Duration.Seconds()
+	}
+	return seconds / (fr.Days() * 24 * 60 * 60)
+}
+
+// provisionedConcurrencyRecommendation identifies functions where the provisioned concurrency
+// allocation is much higher than the average concurrency.
+func provisionedConcurrencyRecommendation(fr FunctionReports) (rec Recommendation, ok bool) {
+	if !fr.HasProvisionedConcurrency() || len(fr.Reports) == 0 {
+		return
+	}
+	avg := fr.AvgConcurrency()
+	allocated := fr.AvgProvisionedConcurrency()
+	if allocated <= 0 || avg >= allocated*provisionedConcurrencyMinUtilisation {
+		return
+	}
+	// Allow headroom of twice the average concurrency.
+	if len(fr.ProvisionedConcurrencySchedules) > 0 {
+		// The schedule's capacity is reduced in proportion, so that it still follows the traffic.
+		reduction := 1 - avg*2/allocated
+		return Recommendation{
+			Type:           recommendationProvisionedConcurrency,
+			Description:    fmt.Sprintf("average concurrency is %.2f, against an average of %.1f provisioned by the schedule, reduce the scheduled capacity by %.0f%%", avg, allocated, reduction*100),
+			MonthlySavings: fr.MonthlyProvisionedConcurrencyCost() * reduction,
+		}, true
+	}
+	proposed := int32(math.Ceil(avg * 2))
+	return Recommendation{
+		Type:           recommendationProvisionedConcurrency,
+		Description:    fmt.Sprintf("average concurrency is %.2f, reduce provisioned concurrency from %d to %d", avg, fr.ProvisionedConcurrency, proposed),
+		MonthlySavings: fr.MonthlyProvisionedConcurrencyCost() - fr.provisionedConcurrencyMonthlyCost(float64(proposed)),
+	}, true
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// provisionedScheduleLookback is how far before a period scheduled actions are replayed, to find
+// the capacity at the start of the period. Schedules usually repeat daily or weekly.
+const provisionedScheduleLookback = 7 * 24 * time.Hour
+
+// ProvisionedConcurrencySchedule is the scheduled scaling of the provisioned concurrency of an
+// alias or version, set by Application Auto Scaling scheduled actions.
+type ProvisionedConcurrencySchedule struct {
+	Qualifier string `json:"qualifier"`
+	// Allocated is the provisioned concurrency allocated to the qualifier when the data was collected.
+	Allocated int32               `json:"allocated"`
+	Actions   []ScheduledCapacity `json:"actions"`
+}
+
+// ScheduledCapacity is a scheduled action that sets the minimum and maximum capacity.
+type ScheduledCapacity struct {
+	Name string `json:"name"`
+	// Schedule is an at(), rate() or cron() expression, evaluated in the Timezone, or UTC.
+	Schedule string     `json:"schedule"`
+	Timezone string     `json:"timezone,omitempty"`
+	Start    *time.Time `json:"start,omitempty"`
+	End      *time.Time `json:"end,omitempty"`
+	// MinCapacity and MaxCapacity are nil if the action doesn't change them.
+	MinCapacity *int32    `json:"minCapacity,omitempty"`
+	MaxCapacity *int32    `json:"maxCapacity,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// HasProvisionedConcurrency is true if the function has provisioned concurrency allocated, or
+// scheduled, which may be zero when the data was collected, e.g. outside office hours.
+func (fr FunctionReports) HasProvisionedConcurrency() bool {
+	return fr.ProvisionedConcurrency > 0 || len(fr.ProvisionedConcurrencySchedules) > 0
+}
+
+// provisionedConcurrencyBetween is the average provisioned concurrency allocated between the
+// times. Qualifiers without a schedule are assumed to keep their allocation.
+func (fr FunctionReports) provisionedConcurrencyBetween(from, to time.Time) float64 {
+	allocated := float64(fr.ProvisionedConcurrency)
+	for _, s := range fr.ProvisionedConcurrencySchedules {
+		avg, err := s.AvgCapacity(from, to)
+		if err != nil {
+			continue
+		}
+		allocated += avg - float64(s.Allocated)
+	}
+	return allocated
+}
+
+// ProvisionedConcurrencyAverages are the average provisioned concurrency allocated by the
+// schedules over the window, and over the 30 days to its end. Replaying schedules is slow, and
+// the averages are used by every cost calculation, so they're stored with the data.
+type ProvisionedConcurrencyAverages struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Window  float64   `json:"window"`
+	Monthly float64   `json:"monthly"`
+}
+
+// setProvisionedConcurrencyAverages replays the schedules over the window. It's called whenever
+// the window changes, e.g. when data is collected or merged.
+func (fr *FunctionReports) setProvisionedConcurrencyAverages() {
+	fr.ProvisionedConcurrencyAverages = nil
+	if len(fr.ProvisionedConcurrencySchedules) == 0 {
+		return
+	}
+	fr.ProvisionedConcurrencyAverages = &ProvisionedConcurrencyAverages{
+		Start:   fr.Start,
+		End:     fr.End,
+		Window:  fr.avgProvisionedConcurrency(),
+		Monthly: fr.replayMonthlyProvisionedConcurrency(),
+	}
+}
+
+// provisionedConcurrencyAverages returns the stored averages, if they were calculated for the
+// current window.
+func (fr FunctionReports) provisionedConcurrencyAverages() (avgs ProvisionedConcurrencyAverages, ok bool) {
+	if fr.ProvisionedConcurrencyAverages == nil || !fr.ProvisionedConcurrencyAverages.Start.Equal(fr.Start) || !fr.ProvisionedConcurrencyAverages.End.Equal(fr.End) {
+		return avgs, false
+	}
+	return *fr.ProvisionedConcurrencyAverages, true
+}
+
+// AvgProvisionedConcurrency is the average provisioned concurrency allocated over the window.
+func (fr FunctionReports) AvgProvisionedConcurrency() float64 {
+	if avgs, ok := fr.provisionedConcurrencyAverages(); ok {
+		return avgs.Window
+	}
+	return fr.avgProvisionedConcurrency()
+}
+
+func (fr FunctionReports) avgProvisionedConcurrency() float64 {
+	if len(fr.ProvisionedConcurrencySchedules) == 0 || fr.Start.IsZero() || !fr.End.After(fr.Start) {
+		return float64(fr.ProvisionedConcurrency)
+	}
+	return fr.provisionedConcurrencyBetween(fr.Start, fr.End)
+}
+
+// monthlyProvisionedConcurrency is the average provisioned concurrency allocated over the 30 days
+// to the end of the window, so that schedules that differ by day of the week are averaged.
+func (fr FunctionReports) monthlyProvisionedConcurrency() float64 {
+	if avgs, ok := fr.provisionedConcurrencyAverages(); ok {
+		return avgs.Monthly
+	}
+	return fr.replayMonthlyProvisionedConcurrency()
+}
+
+func (fr FunctionReports) replayMonthlyProvisionedConcurrency() float64 {
+	if len(fr.ProvisionedConcurrencySchedules) == 0 {
+		return float64(fr.ProvisionedConcurrency)
+	}
+	end := fr.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return fr.provisionedConcurrencyBetween(end.Add(-30*24*time.Hour), end)
+}
+
+// AvgCapacity replays the scheduled actions, minute by minute, to find the average capacity
+// between the times. Before the first action, the capacity is the current allocation. Capacity
+// that target tracking adds above the scheduled minimum isn't modelled.
+func (s ProvisionedConcurrencySchedule) AvgCapacity(from, to time.Time) (avg float64, err error) {
+	from, to = from.UTC().Truncate(time.Minute), to.UTC().Truncate(time.Minute)
+	if !to.After(from) {
+		return float64(s.Allocated), nil
+	}
+	actions := append([]ScheduledCapacity{}, s.Actions...)
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Created.Before(actions[j].Created)
+	})
+	expressions := make([]scheduleExpression, len(actions))
+	for i, a := range actions {
+		if expressions[i], err = parseScheduleExpression(a); err != nil {
+			return 0, err
+		}
+	}
+	capacity := int64(s.Allocated)
+	var sum, minutes int64
+	for t := from.Add(-provisionedScheduleLookback); t.Before(to); t = t.Add(time.Minute) {
+		for i, a := range actions {
+			// Actions don't run before they're created.
+			if t.Before(a.Created) || (a.Start != nil && t.Before(*a.Start)) || (a.End != nil && t.After(*a.End)) || !expressions[i].Fires(t) {
+				continue
+			}
+			if a.MinCapacity != nil {
+				capacity = int64(*a.MinCapacity)
+			}
+			if a.MaxCapacity != nil && capacity > int64(*a.MaxCapacity) {
+				capacity = int64(*a.MaxCapacity)
+			}
+		}
+		if !t.Before(from) {
+			sum += capacity
+			minutes++
+		}
+	}
+	return float64(sum) / float64(minutes), nil
+}
+
+// scheduleExpression is a parsed Application Auto Scaling schedule.
+type scheduleExpression interface {
+	// Fires is true if the schedule runs in the minute starting at t.
+	Fires(t time.Time) bool
+}
+
+// parseScheduleExpression parses an at(), rate() or cron() expression.
+func parseScheduleExpression(a ScheduledCapacity) (se scheduleExpression, err error) {
+	loc := time.UTC
+	if a.Timezone != "" {
+		if loc, err = time.LoadLocation(a.Timezone); err != nil {
+			return nil, fmt.Errorf("parseScheduleExpression: %s: unknown timezone %q: %w", a.Name, a.Timezone, err)
+		}
+	}
+	kind, args, ok := strings.Cut(strings.TrimSpace(a.Schedule), "(")
+	if !ok || !strings.HasSuffix(args, ")") {
+		return nil, fmt.Errorf("parseScheduleExpression: %s: invalid schedule %q", a.Name, a.Schedule)
+	}
+	args = strings.TrimSuffix(args, ")")
+	switch kind {
+	case "at":
+		at, err := time.ParseInLocation("2006-01-02T15:04:05", args, loc)
+		if err != nil {
+			return nil, fmt.Errorf("parseScheduleExpression: %s: invalid at expression %q: %w", a.Name, a.Schedule, err)
+		}
+		return atSchedule(at.UTC().Truncate(time.Minute)), nil
+	case "rate":
+		return parseRateSchedule(a, args)
+	case "cron":
+		return parseCronSchedule(a, args, loc)
+	}
+	return nil, fmt.Errorf("parseScheduleExpression: %s: unsupported schedule %q", a.Name, a.Schedule)
+}
+
+// atSchedule runs once.
+type atSchedule time.Time
+
+func (s atSchedule) Fires(t time.Time) bool {
+	return t.Equal(time.Time(s))
+}
+
+// rateSchedule runs at a fixed interval from its start time, or the time it was created.
+type rateSchedule struct {
+	anchor time.Time
+	every  time.Duration
+}
+
+func (s rateSchedule) Fires(t time.Time) bool {
+	return !t.Before(s.anchor) && t.Sub(s.anchor)%s.every == 0
+}
+
+func parseRateSchedule(a ScheduledCapacity, args string) (se scheduleExpression, err error) {
+	value, unit, _ := strings.Cut(strings.TrimSpace(args), " ")
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("parseScheduleExpression: %s: invalid rate expression %q", a.Name, a.Schedule)
+	}
+	units := map[string]time.Duration{
+		"minute": time.Minute, "minutes": time.Minute,
+		"hour": time.Hour, "hours": time.Hour,
+		"day": 24 * time.Hour, "days": 24 * time.Hour,
+	}
+	d, ok := units[strings.TrimSpace(unit)]
+	if !ok {
+		return nil, fmt.Errorf("parseScheduleExpression: %s: invalid rate unit in %q", a.Name, a.Schedule)
+	}
+	anchor := a.Created
+	if a.Start != nil {
+		anchor = *a.Start
+	}
+	return rateSchedule{anchor: anchor.UTC().Truncate(time.Minute), every: time.Duration(n) * d}, nil
+}
+
+// cronSchedule is a cron expression, with the fields minutes, hours, day of month, month, day of
+// week and year. Each field is the set of values that it matches.
+type cronSchedule struct {
+	loc                                           *time.Location
+	minutes, hours, days, months, weekdays, years []bool
+	// anyDay and anyWeekday are true if the day of month, or day of week, is * or ?.
+	anyDay, anyWeekday bool
+}
+
+func (s cronSchedule) Fires(t time.Time) bool {
+	t = t.In(s.loc)
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[t.Month()] {
+		return false
+	}
+	if y := t.Year(); y >= len(s.years) || !s.years[y] {
+		return false
+	}
+	// Days of the week are numbered from 1, for Sunday.
+	day, weekday := s.days[t.Day()], s.weekdays[int(t.Weekday())+1]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	}
+	return day && weekday
+}
+
+var (
+	cronMonthNames   = map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}
+	cronWeekdayNames = map[string]int{"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7}
+)
+
+func parseCronSchedule(a ScheduledCapacity, args string, loc *time.Location) (se scheduleExpression, err error) {
+	fields := strings.Fields(args)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("parseScheduleExpression: %s: cron expression %q must have 6 fields", a.Name, a.Schedule)
+	}
+	s := cronSchedule{
+		loc:        loc,
+		anyDay:     fields[2] == "*" || fields[2] == "?",
+		anyWeekday: fields[4] == "*" || fields[4] == "?",
+	}
+	parsers := []struct {
+		set      *[]bool
+		min, max int
+		names    map[string]int
+	}{
+		{set: &s.minutes, min: 0, max: 59},
+		{set: &s.hours, min: 0, max: 23},
+		{set: &s.days, min: 1, max: 31},
+		{set: &s.months, min: 1, max: 12, names: cronMonthNames},
+		{set: &s.weekdays, min: 1, max: 7, names: cronWeekdayNames},
+		{set: &s.years, min: 1970, max: 2199},
+	}
+	for i, p := range parsers {
+		if *p.set, err = parseCronField(fields[i], p.min, p.max, p.names); err != nil {
+			return nil, fmt.Errorf("parseScheduleExpression: %s: invalid cron expression %q: %w", a.Name, a.Schedule, err)
+		}
+	}
+	return s, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps, e.g. MON-FRI or 0/15,
+// into the set of values that the field matches. L, W and # aren't supported.
+func parseCronField(field string, min, max int, names map[string]int) (set []bool, err error) {
+	set = make([]bool, max+1)
+	value := func(s string) (int, error) {
+		if n, ok := names[strings.ToUpper(s)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("%q is not a value between %d and %d", s, min, max)
+		}
+		return n, nil
+	}
+	for _, part := range strings.Split(field, ",") {
+		r, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		var from, to int
+		switch {
+		case r == "*" || r == "?":
+			from, to = min, max
+		case strings.Contains(r, "-"):
+			fromText, toText, _ := strings.Cut(r, "-")
+			if from, err = value(fromText); err != nil {
+				return nil, err
+			}
+			if to, err = value(toText); err != nil {
+				return nil, err
+			}
+		default:
+			if from, err = value(r); err != nil {
+				return nil, err
+			}
+			to = from
+			if hasStep {
+				to = max
+			}
+		}
+		if from > to {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// describeProvisionedConcurrencySchedules summarises the schedules, for display.
+func describeProvisionedConcurrencySchedules(schedules []ProvisionedConcurrencySchedule) string {
+	var parts []string
+	for _, s := range schedules {
+		var actions []string
+		for _, a := range s.Actions {
+			capacity := "unchanged"
+			if a.MinCapacity != nil {
+				capacity = strconv.Itoa(int(*a.MinCapacity))
+			}
+			actions = append(actions, fmt.Sprintf("%s at %s", capacity, a.Schedule))
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", s.Qualifier, strings.Join(actions, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
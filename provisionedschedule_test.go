@@ -0,0 +1,190 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		min, max int
+		names    map[string]int
+		expected []int
+		err      bool
+	}{
+		{name: "wildcard", field: "*", min: 1, max: 7, expected: []int{1, 2, 3, 4, 5, 6, 7}},
+		{name: "question mark", field: "?", min: 1, max: 7, expected: []int{1, 2, 3, 4, 5, 6, 7}},
+		{name: "value", field: "9", min: 0, max: 23, expected: []int{9}},
+		{name: "list", field: "0,30", min: 0, max: 59, expected: []int{0, 30}},
+		{name: "range", field: "8-10", min: 0, max: 23, expected: []int{8, 9, 10}},
+		{name: "start and step", field: "0/15", min: 0, max: 59, expected: []int{0, 15, 30, 45}},
+		{name: "wildcard and step", field: "*/6", min: 0, max: 23, expected: []int{0, 6, 12, 18}},
+		{name: "range and step", field: "1-10/3", min: 1, max: 31, expected: []int{1, 4, 7, 10}},
+		{name: "names", field: "MON-FRI", min: 1, max: 7, names: cronWeekdayNames, expected: []int{2, 3, 4, 5, 6}},
+		{name: "lower case names", field: "jan,jul", min: 1, max: 12, names: cronMonthNames, expected: []int{1, 7}},
+		{name: "below the minimum", field: "0", min: 1, max: 31, err: true},
+		{name: "above the maximum", field: "24", min: 0, max: 23, err: true},
+		{name: "reversed range", field: "10-8", min: 0, max: 23, err: true},
+		{name: "zero step", field: "0/0", min: 0, max: 59, err: true},
+		{name: "last day isn't supported", field: "L", min: 1, max: 31, err: true},
+		{name: "nth weekday isn't supported", field: "MON#2", min: 1, max: 7, names: cronWeekdayNames, err: true},
+		{name: "unknown name", field: "MON-FUN", min: 1, max: 7, names: cronWeekdayNames, err: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			set, err := parseCronField(test.field, test.min, test.max, test.names)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", set)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var actual []int
+			for v, ok := range set {
+				if ok {
+					actual = append(actual, v)
+				}
+			}
+			if !reflect.DeepEqual(test.expected, actual) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestScheduleExpressionFires(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		schedule string
+		timezone string
+		// at is in UTC.
+		at       time.Time
+		expected bool
+	}{
+		{name: "weekdays at 08:00, on a Monday", schedule: "cron(0 8 ? * MON-FRI *)", at: time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC), expected: true},
+		{name: "weekdays at 08:00, on a Saturday", schedule: "cron(0 8 ? * MON-FRI *)", at: time.Date(2024, 3, 9, 8, 0, 0, 0, time.UTC), expected: false},
+		{name: "weekdays at 08:00, a minute later", schedule: "cron(0 8 ? * MON-FRI *)", at: time.Date(2024, 3, 4, 8, 1, 0, 0, time.UTC), expected: false},
+		{name: "Sunday is the first day of the week", schedule: "cron(0 8 ? * 1 *)", at: time.Date(2024, 3, 3, 8, 0, 0, 0, time.UTC), expected: true},
+		{name: "day of month with any weekday", schedule: "cron(0 0 15 * ? *)", at: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "day of month, on another day", schedule: "cron(0 0 15 * ? *)", at: time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC), expected: false},
+		{name: "day of month and weekday must both match", schedule: "cron(0 0 1 * MON *)", at: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "day of month and weekday, only the day matches", schedule: "cron(0 0 1 * MON *)", at: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), expected: false},
+		{name: "month", schedule: "cron(0 0 * DEC ? *)", at: time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC), expected: true},
+		{name: "year", schedule: "cron(0 0 * * ? 2025)", at: time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC), expected: false},
+		{name: "timezone", schedule: "cron(0 8 ? * MON-FRI *)", timezone: "America/New_York", at: time.Date(2024, 3, 4, 13, 0, 0, 0, time.UTC), expected: true},
+		{name: "timezone, daylight saving time", schedule: "cron(0 8 ? * MON-FRI *)", timezone: "America/New_York", at: time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC), expected: true},
+		{name: "at", schedule: "at(2024-03-04T08:30:00)", at: time.Date(2024, 3, 4, 8, 30, 0, 0, time.UTC), expected: true},
+		{name: "at, in a timezone", schedule: "at(2024-03-04T08:30:00)", timezone: "Europe/Berlin", at: time.Date(2024, 3, 4, 7, 30, 0, 0, time.UTC), expected: true},
+		{name: "rate, from creation", schedule: "rate(6 hours)", at: time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC), expected: true},
+		{name: "rate, between runs", schedule: "rate(6 hours)", at: time.Date(2024, 1, 1, 19, 0, 0, 0, time.UTC), expected: false},
+		{name: "rate, before creation", schedule: "rate(1 minute)", at: time.Date(2023, 12, 31, 23, 59, 0, 0, time.UTC), expected: false},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			se, err := parseScheduleExpression(ScheduledCapacity{Name: "test", Schedule: test.schedule, Timezone: test.timezone, Created: created})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual := se.Fires(test.at); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseScheduleExpressionErrors(t *testing.T) {
+	for _, schedule := range []string{
+		"cron(0 8 * * *)",
+		"cron(0 8 L * ? *)",
+		"rate(0 minutes)",
+		"rate(5 weeks)",
+		"at(2024-03-04)",
+		"every(5 minutes)",
+		"cron(0 8 ? * MON-FRI *",
+	} {
+		if _, err := parseScheduleExpression(ScheduledCapacity{Name: "test", Schedule: schedule}); err == nil {
+			t.Errorf("%s: expected an error", schedule)
+		}
+	}
+	if _, err := parseScheduleExpression(ScheduledCapacity{Name: "test", Schedule: "rate(1 hour)", Timezone: "Mars/Olympus_Mons"}); err == nil {
+		t.Error("expected an error for an unknown timezone")
+	}
+}
+
+func TestAvgCapacity(t *testing.T) {
+	int32p := func(v int32) *int32 { return &v }
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Office hours: 10 from 08:00 to 18:00 on weekdays, otherwise 0.
+	s := ProvisionedConcurrencySchedule{
+		Qualifier: "live",
+		Allocated: 10,
+		Actions: []ScheduledCapacity{
+			{Name: "up", Schedule: "cron(0 8 ? * MON-FRI *)", MinCapacity: int32p(10), MaxCapacity: int32p(10), Created: created},
+			{Name: "down", Schedule: "cron(0 18 ? * MON-FRI *)", MinCapacity: int32p(0), MaxCapacity: int32p(0), Created: created},
+		},
+	}
+	// A week from Monday.
+	from := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	avg, err := s.AvgCapacity(from, from.Add(7*24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := 10.0 * 5 * 10 / (7 * 24); avg != expected {
+		t.Errorf("expected %v, got %v", expected, avg)
+	}
+	// Before the first action, the capacity is the allocation.
+	avg, err = s.AvgCapacity(created.Add(-2*time.Hour), created.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if avg != 10 {
+		t.Errorf("expected the allocation before the first action, got %v", avg)
+	}
+}
+
+func TestProvisionedConcurrencyAveragesAreStored(t *testing.T) {
+	int32p := func(v int32) *int32 { return &v }
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fr := FunctionReports{
+		ProvisionedConcurrency: 4,
+		ProvisionedConcurrencySchedules: []ProvisionedConcurrencySchedule{{
+			Qualifier: "live",
+			Allocated: 4,
+			Actions: []ScheduledCapacity{
+				{Name: "up", Schedule: "cron(0 12 * * ? *)", MinCapacity: int32p(4), Created: created},
+				{Name: "down", Schedule: "cron(0 0 * * ? *)", MinCapacity: int32p(0), Created: created},
+			},
+		}},
+		Start: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+	}
+	fr.setProvisionedConcurrencyAverages()
+	if fr.ProvisionedConcurrencyAverages == nil {
+		t.Fatal("expected averages to be stored")
+	}
+	if avg := fr.AvgProvisionedConcurrency(); avg != 2 {
+		t.Errorf("expected an average of 2, got %v", avg)
+	}
+	if avg := fr.monthlyProvisionedConcurrency(); avg != 2 {
+		t.Errorf("expected a monthly average of 2, got %v", avg)
+	}
+	// The stored averages are used, rather than replaying the schedules.
+	fr.ProvisionedConcurrencyAverages.Window = 3
+	if avg := fr.AvgProvisionedConcurrency(); avg != 3 {
+		t.Errorf("expected the stored average, got %v", avg)
+	}
+	// Once the window changes, they're replayed again.
+	fr.End = fr.End.Add(12 * time.Hour)
+	if avg := fr.AvgProvisionedConcurrency(); avg == 3 {
+		t.Errorf("expected the stored average to be ignored for a different window")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// qualifiedFunctions returns the configuration of the version that the qualifier, an alias or
+// version, refers to for each function, so that the memory size and architecture are those of the
+// qualified version. Functions that don't have the alias or version are skipped.
+func qualifiedFunctions(ctx context.Context, lambdaClient *lambda.Client, functions []types.FunctionConfiguration, defaultRegion, qualifier string) (qualified []types.FunctionConfiguration, skipped []string, err error) {
+	for _, f := range functions {
+		region := functionRegion(f, defaultRegion)
+		output, err := lambdaClient.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: f.FunctionName,
+			Qualifier:    &qualifier,
+		}, func(o *lambda.Options) {
+			o.Region = region
+		})
+		if err != nil {
+			var notFound *types.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				skipped = append(skipped, *f.FunctionName)
+				continue
+			}
+			return nil, nil, fmt.Errorf("qualifiedFunctions: failed to get function %q with qualifier %q: %w", *f.FunctionName, qualifier, err)
+		}
+		// The ARN is kept unqualified, since tags can only be read from the function.
+		qualified = append(qualified, types.FunctionConfiguration{
+			Architectures:    output.Architectures,
+			CodeSize:         output.CodeSize,
+			Description:      output.Description,
+			EphemeralStorage: output.EphemeralStorage,
+			FunctionArn:      f.FunctionArn,
+			FunctionName:     f.FunctionName,
+			Layers:           output.Layers,
+			MemorySize:       output.MemorySize,
+			PackageType:      output.PackageType,
+			Runtime:          output.Runtime,
+			SnapStart:        output.SnapStart,
+			Timeout:          output.Timeout,
+			Version:          output.Version,
+		})
+	}
+	return qualified, skipped, nil
+}
+
+// logStreamVersion returns the part of a Lambda log stream name that identifies the version, e.g.
+// 2024/01/02/[3]0123456789abcdef contains [3], and streams of unpublished code contain [$LATEST].
+func logStreamVersion(version string) string {
+	return "[" + version + "]"
+}
+
+// InVersion returns true if the log stream was written by the version, or if no version is set.
+func (t CollectTarget) InVersion(logStreamName string) bool {
+	return t.Version == "" || strings.Contains(logStreamName, logStreamVersion(t.Version))
+}
+
+// qualifierFileName returns the qualifier in a form that can be used in a file name.
+func qualifierFileName(qualifier string) string {
+	return strings.TrimPrefix(qualifier, "$")
+}
@@ -0,0 +1,32 @@
+package main
+
+// Data quality of a function's row, from most to least reliable.
+const (
+	// dataQualityComplete means that costs are calculated from a REPORT line for every invocation.
+	dataQualityComplete = "complete"
+	// dataQualitySampled means that there are fewer REPORT lines than the Invocations metric.
+	dataQualitySampled = "sampled"
+	// dataQualityMetricsEstimated means that there are no REPORT lines, but the Invocations
+	// metric shows that the function was invoked, so only metrics are available.
+	dataQualityMetricsEstimated = "metricsEstimated"
+	// dataQualityPartial means that errors occurred during collection, so data may be missing.
+	dataQualityPartial = "partial"
+)
+
+// DataQuality indicates how much the function's costs can be relied on, so that rows can be
+// weighted or excluded by downstream consumers. The tolerance is the proportion by which REPORT
+// lines can differ from the Invocations metric.
+func (fr FunctionReports) DataQuality(tolerance float64) string {
+	if fr.Incomplete || len(fr.Errors) > 0 {
+		return dataQualityPartial
+	}
+	if fr.MetricInvocations != nil && *fr.MetricInvocations > 0 {
+		if len(fr.Reports) == 0 {
+			return dataQualityMetricsEstimated
+		}
+		if int64(len(fr.Reports)) < *fr.MetricInvocations && fr.InvocationMismatch(tolerance) {
+			return dataQualitySampled
+		}
+	}
+	return dataQualityComplete
+}
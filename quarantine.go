@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// QuarantineEntry is a log line that could not be parsed, kept so that parser gaps can be
+// reported and fixed with real examples.
+type QuarantineEntry struct {
+	Function  string    `json:"function"`
+	Region    string    `json:"region"`
+	Timestamp time.Time `json:"timestamp"`
+	Error     string    `json:"error"`
+	Line      string    `json:"line"`
+}
+
+// quarantine writes entries to a JSON lines file. The file is only created when the first
+// entry is added.
+type quarantine struct {
+	fileName string
+	f        *os.File
+	enc      *json.Encoder
+	Count    int
+}
+
+func newQuarantine(fileName string) *quarantine {
+	return &quarantine{fileName: fileName}
+}
+
+func (q *quarantine) Add(e QuarantineEntry) (err error) {
+	if q.fileName == "" {
+		return nil
+	}
+	// Log events can be up to 256 KB, and may not be valid UTF-8.
+	e.Line = truncate(strings.ToValidUTF8(e.Line, "\uFFFD"), maxLogMessageLength)
+	if q.f == nil {
+		q.f, err = os.OpenFile(q.fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("quarantine: could not open %q: %w", q.fileName, err)
+		}
+		q.enc = json.NewEncoder(q.f)
+	}
+	if err = q.enc.Encode(e); err != nil {
+		return fmt.Errorf("quarantine: could not write to %q: %w", q.fileName, err)
+	}
+	q.Count++
+	return nil
+}
+
+func (q *quarantine) Close() error {
+	if q.f == nil {
+		return nil
+	}
+	return q.f.Close()
+}
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+func queryCmd(args []string) {
+	cmd := flag.NewFlagSet("query", flag.ExitOnError)
+	af := newAnalysisFlags(cmd)
+	owner := newOwnerFlags(cmd)
+	listFields := cmd.Bool("fields", false, "List the fields that can be used in queries")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), `usage: lambdacost query [flags] "<query>" <file.json>...`)
+		fmt.Fprintln(cmd.Output(), `e.g.: lambdacost query "functions where cold_start_rate > 0.2 and monthly_cost > 50" merged.json`)
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	if *listFields {
+		displayQueryFields(os.Stdout)
+		return
+	}
+	log := newLog()
+	if cmd.NArg() < 2 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	q, err := parseQuery(cmd.Arg(0))
+	if err != nil {
+		log.Fatal("could not parse query", zap.Error(err))
+	}
+	settings := mustLoadSettings(log, *af.config)
+	opts, err := af.reportOptions(settings)
+	if err != nil {
+		log.Fatal("invalid report options", zap.Error(err))
+	}
+	if opts.Owners, err = owner.owners(settings); err != nil {
+		log.Fatal("invalid owner options", zap.Error(err))
+	}
+	var sets [][]FunctionReports
+	for _, fileName := range cmd.Args()[1:] {
+		functionReports, err := readFunctionReports(fileName)
+		if err != nil {
+			log.Fatal("could not read report data", zap.Error(err))
+		}
+		sets = append(sets, functionReports)
+	}
+	functionReports, _ := mergeFunctionReports(sets...)
+	functionReports, _, _ = separateSynthetic(functionReports, opts.SyntheticMaxDuration)
+	if err = q.Run(os.Stdout, functionReports, opts); err != nil {
+		log.Fatal("could not run query", zap.Error(err))
+	}
+}
+
+// queryField is a value of a function that can be used in a query. Each field is either
+// numeric or text.
+type queryField struct {
+	Description string
+	Number      func(fr FunctionReports, opts reportOptions) float64
+	Text        func(fr FunctionReports, opts reportOptions) string
+}
+
+func (f queryField) format(fr FunctionReports, opts reportOptions) string {
+	if f.Text != nil {
+		return f.Text(fr, opts)
+	}
+	// Round to 4 decimal places, enough for costs and rates.
+	return opts.Format.Decimal(math.Round(f.Number(fr, opts)*10000)/10000, -1)
+}
+
+func durationMilliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
+
+var queryFields = map[string]queryField{
+	"name":            {Description: "Function name", Text: func(fr FunctionReports, _ reportOptions) string { return fr.Name }},
+	"account":         {Description: "Account ID", Text: func(fr FunctionReports, _ reportOptions) string { return fr.Account }},
+	"account_name":    {Description: "Account name", Text: func(fr FunctionReports, _ reportOptions) string { return fr.AccountName }},
+	"region":          {Description: "Region", Text: func(fr FunctionReports, _ reportOptions) string { return fr.Region }},
+	"runtime":         {Description: "Runtime, e.g. nodejs20.x", Text: func(fr FunctionReports, _ reportOptions) string { return fr.Runtime }},
+	"architecture":    {Description: "Architecture, x86_64 or arm64", Text: func(fr FunctionReports, _ reportOptions) string { return string(fr.Architecture) }},
+	"package_type":    {Description: "Package type, Zip or Image", Text: func(fr FunctionReports, _ reportOptions) string { return fr.PackageType }},
+	"description":     {Description: "Function description", Text: func(fr FunctionReports, _ reportOptions) string { return fr.Description }},
+	"owner":           {Description: "Owner, from the owner tag or pattern", Text: func(fr FunctionReports, opts reportOptions) string { return opts.Owners.Owner(fr) }},
+	"trigger":         {Description: "Trigger type, e.g. api, queue, stream, schedule, event, mixed or unknown", Text: func(fr FunctionReports, _ reportOptions) string { return fr.Trigger() }},
+	"data_quality":    {Description: "Data quality, e.g. complete or partial", Text: func(fr FunctionReports, opts reportOptions) string { return fr.DataQuality(opts.InvocationTolerance) }},
+	"memory":          {Description: "Memory size in MB", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.MemoryAssigned()) }},
+	"timeout":         {Description: "Timeout in seconds", Number: func(fr FunctionReports, _ reportOptions) float64 { return fr.Timeout.Seconds() }},
+	"code_size":       {Description: "Code size in MB", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.CodeSize) / 1024 / 1024 }},
+	"daily_cost":      {Description: "Daily cost in the region's currency", Number: func(fr FunctionReports, _ reportOptions) float64 { return fr.DailyCost() }},
+	"monthly_cost":    {Description: "Monthly cost in the region's currency", Number: func(fr FunctionReports, _ reportOptions) float64 { return fr.DailyCost() * 30 }},
+	"monthly_savings": {Description: "Monthly savings from memory and architecture changes in the region's currency", Number: func(fr FunctionReports, _ reportOptions) float64 { return fr.MonthlySavings() }},
+	"monthly_budget": {Description: "Monthly budget in the region's currency, from the lambdacost:budget-monthly tag, or 0", Number: func(fr FunctionReports, _ reportOptions) float64 {
+		budget, _, _ := fr.MonthlyBudget()
+		return budget
+	}},
+	"daily_invocations": {Description: "Average invocations per day", Number: func(fr FunctionReports, _ reportOptions) float64 { return fr.DailyInvocations() }},
+	"requests":          {Description: "Unique requests in the window", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.UniqueRequests()) }},
+	"executions":        {Description: "Billed executions in the window", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.Executions()) }},
+	"cold_starts":       {Description: "Cold starts in the window", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.ColdStarts()) }},
+	"cold_start_rate":   {Description: "Proportion of invocations that were cold starts, 0 to 1", Number: func(fr FunctionReports, _ reportOptions) float64 { return fr.ColdStartRate() }},
+	"avg_duration":      {Description: "Average duration in ms", Number: func(fr FunctionReports, _ reportOptions) float64 { return durationMilliseconds(fr.AvgDuration()) }},
+	"avg_warm_duration": {Description: "Average warm duration in ms", Number: func(fr FunctionReports, _ reportOptions) float64 { return durationMilliseconds(fr.AvgWarmDuration()) }},
+	"avg_cold_duration": {Description: "Average cold duration in ms", Number: func(fr FunctionReports, _ reportOptions) float64 { return durationMilliseconds(fr.AvgColdDuration()) }},
+	"p99_duration": {Description: "99th percentile duration in ms", Number: func(fr FunctionReports, _ reportOptions) float64 {
+		return durationMilliseconds(fr.DurationPercentile(99))
+	}},
+	"max_duration":       {Description: "Maximum duration in ms", Number: func(fr FunctionReports, _ reportOptions) float64 { return durationMilliseconds(fr.MaxDuration()) }},
+	"max_memory_used":    {Description: "Maximum memory used in MB", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.MaxMemoryUsed()) }},
+	"timeouts":           {Description: "Invocations that timed out", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.Timeouts()) }},
+	"failure_rate":       {Description: "Proportion of invocations that failed, 0 to 1", Number: func(fr FunctionReports, _ reportOptions) float64 { return fr.FailureRate() }},
+	"log_retention_days": {Description: "Log group retention in days, or 0 if it never expires", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.LogRetentionDays) }},
+	"log_stored_bytes": {Description: "Bytes stored in the log group", Number: func(fr FunctionReports, _ reportOptions) float64 {
+		if fr.LogStoredBytes == nil {
+			return 0
+		}
+		return float64(*fr.LogStoredBytes)
+	}},
+	"provisioned_concurrency": {Description: "Allocated provisioned concurrency", Number: func(fr FunctionReports, _ reportOptions) float64 { return float64(fr.ProvisionedConcurrency) }},
+	"recommendations": {Description: "Number of recommendations", Number: func(fr FunctionReports, opts reportOptions) float64 {
+		return float64(len(opts.Recommenders.Recommend(fr)))
+	}},
+}
+
+// queryTagPrefix is the prefix of fields that return a tag value, e.g. tag.team.
+const queryTagPrefix = "tag."
+
+func lookupQueryField(name string) (f queryField, ok bool) {
+	if strings.HasPrefix(name, queryTagPrefix) {
+		key := strings.TrimPrefix(name, queryTagPrefix)
+		return queryField{Text: func(fr FunctionReports, _ reportOptions) string { return fr.Tags[key] }}, true
+	}
+	f, ok = queryFields[name]
+	return f, ok
+}
+
+func displayQueryFields(w io.Writer) {
+	var names []string
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", name, queryFields[name].Description)
+	}
+	fmt.Fprintf(tw, "%s<key>\t%s\n", queryTagPrefix, "Value of the tag")
+	tw.Flush()
+}
+
+// query filters, groups and sorts functions. The syntax is:
+//
+//	[functions] [where <condition>] [group by <field>] [order by <field> [asc|desc]] [limit <n>]
+//
+// Conditions compare fields to values with =, !=, <, <=, >, >= and contains, and can be
+// combined with and, or, not and parentheses.
+type query struct {
+	Where   queryCondition
+	GroupBy string
+	OrderBy string
+	Desc    bool
+	Limit   int
+	// Fields are the fields used by the query, in the order they appear.
+	Fields []string
+}
+
+// Columns of grouped query results, which can be used in order by.
+const (
+	queryGroupCount          = "count"
+	queryGroupMonthlyCost    = "monthly_cost"
+	queryGroupMonthlySavings = "monthly_savings"
+)
+
+// Run writes the functions, or groups of functions, that match the query.
+func (q query) Run(w io.Writer, functionReports []FunctionReports, opts reportOptions) error {
+	var matched []FunctionReports
+	for _, fr := range functionReports {
+		if q.Where == nil || q.Where.Match(fr, opts) {
+			matched = append(matched, fr)
+		}
+	}
+	if q.GroupBy != "" {
+		return q.runGrouped(w, matched, opts)
+	}
+	orderBy, desc := q.OrderBy, q.Desc
+	if orderBy == "" {
+		orderBy, desc = "monthly_cost", true
+	}
+	field, _ := lookupQueryField(orderBy)
+	sort.SliceStable(matched, func(i, j int) bool {
+		if desc {
+			return queryLess(field, matched[j], matched[i], opts)
+		}
+		return queryLess(field, matched[i], matched[j], opts)
+	})
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	columns := []string{"name", "region", "account_name", "monthly_cost"}
+	for _, name := range q.Fields {
+		if !contains(columns, name) {
+			columns = append(columns, name)
+		}
+	}
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	for _, fr := range matched {
+		values := make([]string, len(columns))
+		for i, name := range columns {
+			f, _ := lookupQueryField(name)
+			values[i] = f.format(fr, opts)
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d of %d functions\n", len(matched), len(functionReports))
+	return nil
+}
+
+func queryLess(f queryField, a, b FunctionReports, opts reportOptions) bool {
+	if f.Text != nil {
+		return f.Text(a, opts) < f.Text(b, opts)
+	}
+	return f.Number(a, opts) < f.Number(b, opts)
+}
+
+type queryGroup struct {
+	Key            string
+	Count          int
+	MonthlyCost    float64
+	MonthlySavings float64
+}
+
+func (q query) runGrouped(w io.Writer, matched []FunctionReports, opts reportOptions) error {
+	field, _ := lookupQueryField(q.GroupBy)
+	indexes := map[string]int{}
+	var groups []queryGroup
+	for _, fr := range matched {
+		key := field.format(fr, opts)
+		index, ok := indexes[key]
+		if !ok {
+			index = len(groups)
+			indexes[key] = index
+			groups = append(groups, queryGroup{Key: key})
+		}
+		groups[index].Count++
+		groups[index].MonthlyCost += fr.DailyCost() * 30
+		groups[index].MonthlySavings += fr.MonthlySavings()
+	}
+	orderBy, desc := q.OrderBy, q.Desc
+	if orderBy == "" {
+		orderBy, desc = queryGroupMonthlyCost, true
+	}
+	var less func(a, b queryGroup) bool
+	switch orderBy {
+	case q.GroupBy:
+		less = func(a, b queryGroup) bool { return a.Key < b.Key }
+	case queryGroupCount:
+		less = func(a, b queryGroup) bool { return a.Count < b.Count }
+	case queryGroupMonthlyCost:
+		less = func(a, b queryGroup) bool { return a.MonthlyCost < b.MonthlyCost }
+	case queryGroupMonthlySavings:
+		less = func(a, b queryGroup) bool { return a.MonthlySavings < b.MonthlySavings }
+	default:
+		return fmt.Errorf("query: grouped results can only be ordered by %s, %s, %s or %s", q.GroupBy, queryGroupCount, queryGroupMonthlyCost, queryGroupMonthlySavings)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if desc {
+			return less(groups[j], groups[i])
+		}
+		return less(groups[i], groups[j])
+	})
+	if q.Limit > 0 && len(groups) > q.Limit {
+		groups = groups[:q.Limit]
+	}
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{q.GroupBy, queryGroupCount, queryGroupMonthlyCost, queryGroupMonthlySavings}, "\t"))
+	for _, g := range groups {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", g.Key, g.Count, opts.Format.Decimal(g.MonthlyCost, 2), opts.Format.Decimal(g.MonthlySavings, 2))
+	}
+	tw.Flush()
+	return nil
+}
+
+type queryCondition interface {
+	Match(fr FunctionReports, opts reportOptions) bool
+}
+
+type queryAnd struct{ Left, Right queryCondition }
+
+func (c queryAnd) Match(fr FunctionReports, opts reportOptions) bool {
+	return c.Left.Match(fr, opts) && c.Right.Match(fr, opts)
+}
+
+type queryOr struct{ Left, Right queryCondition }
+
+func (c queryOr) Match(fr FunctionReports, opts reportOptions) bool {
+	return c.Left.Match(fr, opts) || c.Right.Match(fr, opts)
+}
+
+type queryNot struct{ Condition queryCondition }
+
+func (c queryNot) Match(fr FunctionReports, opts reportOptions) bool {
+	return !c.Condition.Match(fr, opts)
+}
+
+type queryComparison struct {
+	Field    queryField
+	Operator string
+	Number   float64
+	Text     string
+}
+
+func (c queryComparison) Match(fr FunctionReports, opts reportOptions) bool {
+	if c.Field.Text != nil {
+		v := c.Field.Text(fr, opts)
+		switch c.Operator {
+		case "=":
+			return v == c.Text
+		case "!=":
+			return v != c.Text
+		case "<":
+			return v < c.Text
+		case "<=":
+			return v <= c.Text
+		case ">":
+			return v > c.Text
+		case ">=":
+			return v >= c.Text
+		case "contains":
+			return strings.Contains(v, c.Text)
+		}
+		return false
+	}
+	v := c.Field.Number(fr, opts)
+	switch c.Operator {
+	case "=":
+		return v == c.Number
+	case "!=":
+		return v != c.Number
+	case "<":
+		return v < c.Number
+	case "<=":
+		return v <= c.Number
+	case ">":
+		return v > c.Number
+	case ">=":
+		return v >= c.Number
+	}
+	return false
+}
+
+type queryTokenKind int
+
+const (
+	queryTokenWord queryTokenKind = iota
+	queryTokenNumber
+	queryTokenString
+	queryTokenOperator
+	queryTokenEnd
+)
+
+type queryToken struct {
+	Kind  queryTokenKind
+	Value string
+}
+
+func (t queryToken) String() string {
+	if t.Kind == queryTokenEnd {
+		return "end of query"
+	}
+	return strconv.Quote(t.Value)
+}
+
+// is returns true if the token is the keyword, ignoring case.
+func (t queryToken) is(keyword string) bool {
+	return t.Kind == queryTokenWord && strings.EqualFold(t.Value, keyword)
+}
+
+func tokenizeQuery(s string) (tokens []queryToken, err error) {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == ':' || r == '-' || r == '/'
+	}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{Kind: queryTokenOperator, Value: string(r)})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op, n := string(r), 1
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op, n = op+"=", 2
+			}
+			switch op {
+			case "==":
+				op = "="
+			case "!":
+				return nil, fmt.Errorf("unexpected %q at position %d", op, i)
+			}
+			tokens = append(tokens, queryToken{Kind: queryTokenOperator, Value: op})
+			i += n
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, queryToken{Kind: queryTokenString, Value: string(runes[i+1 : end])})
+			i = end + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			kind := queryTokenNumber
+			// Values that start with a digit, e.g. 2024-report, are words.
+			if end < len(runes) && isWordRune(runes[end]) {
+				kind = queryTokenWord
+				for end < len(runes) && isWordRune(runes[end]) {
+					end++
+				}
+			}
+			tokens = append(tokens, queryToken{Kind: kind, Value: string(runes[i:end])})
+			i = end
+		case isWordRune(r):
+			end := i + 1
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, queryToken{Kind: queryTokenWord, Value: string(runes[i:end])})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", r, i)
+		}
+	}
+	return append(tokens, queryToken{Kind: queryTokenEnd}), nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	q      *query
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[0]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[0]
+	if t.Kind != queryTokenEnd {
+		p.tokens = p.tokens[1:]
+	}
+	return t
+}
+
+func (p *queryParser) expect(keyword string) error {
+	if t := p.next(); !t.is(keyword) {
+		return fmt.Errorf("expected %q, got %v", keyword, t)
+	}
+	return nil
+}
+
+// field reads a field name, and records that it's used by the query.
+func (p *queryParser) field() (name string, f queryField, err error) {
+	t := p.next()
+	if t.Kind != queryTokenWord {
+		return "", f, fmt.Errorf("expected a field name, got %v", t)
+	}
+	name = strings.ToLower(t.Value)
+	if strings.HasPrefix(name, queryTagPrefix) {
+		// Tag keys are case sensitive.
+		name = queryTagPrefix + t.Value[len(queryTagPrefix):]
+	}
+	f, ok := lookupQueryField(name)
+	if !ok {
+		return "", f, fmt.Errorf("unknown field %q, use -fields to list the available fields", t.Value)
+	}
+	if !contains(p.q.Fields, name) {
+		p.q.Fields = append(p.q.Fields, name)
+	}
+	return name, f, nil
+}
+
+func (p *queryParser) or() (c queryCondition, err error) {
+	if c, err = p.and(); err != nil {
+		return nil, err
+	}
+	for p.peek().is("or") {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		c = queryOr{Left: c, Right: right}
+	}
+	return c, nil
+}
+
+func (p *queryParser) and() (c queryCondition, err error) {
+	if c, err = p.not(); err != nil {
+		return nil, err
+	}
+	for p.peek().is("and") {
+		p.next()
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		c = queryAnd{Left: c, Right: right}
+	}
+	return c, nil
+}
+
+func (p *queryParser) not() (c queryCondition, err error) {
+	if p.peek().is("not") {
+		p.next()
+		if c, err = p.not(); err != nil {
+			return nil, err
+		}
+		return queryNot{Condition: c}, nil
+	}
+	if t := p.peek(); t.Kind == queryTokenOperator && t.Value == "(" {
+		p.next()
+		if c, err = p.or(); err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.Kind != queryTokenOperator || t.Value != ")" {
+			return nil, fmt.Errorf("expected \")\", got %v", t)
+		}
+		return c, nil
+	}
+	return p.comparison()
+}
+
+func (p *queryParser) comparison() (c queryCondition, err error) {
+	name, f, err := p.field()
+	if err != nil {
+		return nil, err
+	}
+	cmp := queryComparison{Field: f}
+	switch t := p.next(); {
+	case t.Kind == queryTokenOperator && t.Value != "(" && t.Value != ")":
+		cmp.Operator = t.Value
+	case t.is("contains") && f.Text != nil:
+		cmp.Operator = "contains"
+	default:
+		return nil, fmt.Errorf("expected a comparison operator after %q, got %v", name, t)
+	}
+	value := p.next()
+	if f.Text != nil {
+		if value.Kind != queryTokenString && value.Kind != queryTokenWord && value.Kind != queryTokenNumber {
+			return nil, fmt.Errorf("expected a value to compare %q with, got %v", name, value)
+		}
+		cmp.Text = value.Value
+		return cmp, nil
+	}
+	if value.Kind != queryTokenNumber {
+		return nil, fmt.Errorf("%q is a number, got %v", name, value)
+	}
+	if cmp.Number, err = strconv.ParseFloat(value.Value, 64); err != nil {
+		return nil, fmt.Errorf("invalid number %q: %w", value.Value, err)
+	}
+	return cmp, nil
+}
+
+// parseQuery parses a query, e.g. "functions where cold_start_rate > 0.2 and monthly_cost > 50".
+func parseQuery(s string) (q query, err error) {
+	tokens, err := tokenizeQuery(s)
+	if err != nil {
+		return q, fmt.Errorf("parseQuery: %w", err)
+	}
+	p := &queryParser{tokens: tokens, q: &q}
+	if err = p.parse(); err != nil {
+		return q, fmt.Errorf("parseQuery: %w", err)
+	}
+	return q, nil
+}
+
+func (p *queryParser) parse() (err error) {
+	if p.peek().is("functions") {
+		p.next()
+	}
+	if p.peek().is("where") {
+		p.next()
+		if p.q.Where, err = p.or(); err != nil {
+			return err
+		}
+	}
+	if p.peek().is("group") {
+		p.next()
+		if err = p.expect("by"); err != nil {
+			return err
+		}
+		if p.q.GroupBy, _, err = p.field(); err != nil {
+			return err
+		}
+	}
+	if p.peek().is("order") {
+		p.next()
+		if err = p.expect("by"); err != nil {
+			return err
+		}
+		if p.q.GroupBy != "" && p.peek().is(queryGroupCount) {
+			p.q.OrderBy = queryGroupCount
+			p.next()
+		} else if p.q.OrderBy, _, err = p.field(); err != nil {
+			return err
+		}
+		switch {
+		case p.peek().is("asc"):
+			p.next()
+		case p.peek().is("desc"):
+			p.next()
+			p.q.Desc = true
+		}
+	}
+	if p.peek().is("limit") {
+		p.next()
+		t := p.next()
+		if p.q.Limit, err = strconv.Atoi(t.Value); t.Kind != queryTokenNumber || err != nil || p.q.Limit < 1 {
+			return fmt.Errorf("expected a positive limit, got %v", t)
+		}
+	}
+	if t := p.next(); t.Kind != queryTokenEnd {
+		return fmt.Errorf("unexpected %v", t)
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenizeQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []queryToken
+	}{
+		{
+			name:  "comparison",
+			query: "monthly_cost>=50",
+			expected: []queryToken{
+				{Kind: queryTokenWord, Value: "monthly_cost"},
+				{Kind: queryTokenOperator, Value: ">="},
+				{Kind: queryTokenNumber, Value: "50"},
+			},
+		},
+		{
+			name:  "negative and decimal numbers",
+			query: "-1 0.25",
+			expected: []queryToken{
+				{Kind: queryTokenNumber, Value: "-1"},
+				{Kind: queryTokenNumber, Value: "0.25"},
+			},
+		},
+		{
+			name:  "words that start with a digit",
+			query: "2024-report 3d",
+			expected: []queryToken{
+				{Kind: queryTokenWord, Value: "2024-report"},
+				{Kind: queryTokenWord, Value: "3d"},
+			},
+		},
+		{
+			name:  "quoted strings keep spaces and operators",
+			query: `'a b' "c = d"`,
+			expected: []queryToken{
+				{Kind: queryTokenString, Value: "a b"},
+				{Kind: queryTokenString, Value: "c = d"},
+			},
+		},
+		{
+			name:  "double equals is equals",
+			query: "region == eu-west-1",
+			expected: []queryToken{
+				{Kind: queryTokenWord, Value: "region"},
+				{Kind: queryTokenOperator, Value: "="},
+				{Kind: queryTokenWord, Value: "eu-west-1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := tokenizeQuery(test.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := append(test.expected, queryToken{Kind: queryTokenEnd})
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("expected %v, got %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected query
+		err      string
+	}{
+		{
+			name:     "empty",
+			query:    "",
+			expected: query{},
+		},
+		{
+			name:     "functions",
+			query:    "functions",
+			expected: query{},
+		},
+		{
+			name:     "where",
+			query:    "functions where cold_start_rate > 0.2 and monthly_cost > 50",
+			expected: query{Fields: []string{"cold_start_rate", "monthly_cost"}},
+		},
+		{
+			name:     "keywords ignore case",
+			query:    "FUNCTIONS WHERE Memory > 128 ORDER BY Memory DESC LIMIT 5",
+			expected: query{OrderBy: "memory", Desc: true, Limit: 5, Fields: []string{"memory"}},
+		},
+		{
+			name:     "tag keys are case sensitive",
+			query:    "where tag.Team = payments",
+			expected: query{Fields: []string{"tag.Team"}},
+		},
+		{
+			name:     "group by",
+			query:    "where region = eu-west-1 group by tag.team order by count asc limit 3",
+			expected: query{GroupBy: "tag.team", OrderBy: queryGroupCount, Limit: 3, Fields: []string{"region", "tag.team"}},
+		},
+		{
+			name:     "text values can start with a digit",
+			query:    "where name = 2024-report",
+			expected: query{Fields: []string{"name"}},
+		},
+		{
+			name:  "unknown field",
+			query: "where colour = red",
+			err:   `unknown field "colour"`,
+		},
+		{
+			name:  "missing operator",
+			query: "where memory 128",
+			err:   `expected a comparison operator after "memory"`,
+		},
+		{
+			name:  "contains is only for text",
+			query: "where memory contains 1",
+			err:   `expected a comparison operator after "memory"`,
+		},
+		{
+			name:  "numeric fields need a number",
+			query: "where memory > large",
+			err:   `"memory" is a number`,
+		},
+		{
+			name:  "invalid number",
+			query: "where memory > 1.2.3",
+			err:   `invalid number "1.2.3"`,
+		},
+		{
+			name:  "unterminated string",
+			query: "where name = 'api",
+			err:   "unterminated string",
+		},
+		{
+			name:  "unclosed parenthesis",
+			query: "where (memory > 128",
+			err:   `expected ")"`,
+		},
+		{
+			name:  "limit must be positive",
+			query: "limit 0",
+			err:   "expected a positive limit",
+		},
+		{
+			name:  "limit must be a number",
+			query: "limit 10x",
+			err:   "expected a positive limit",
+		},
+		{
+			name:  "group by needs by",
+			query: "group tag.team",
+			err:   `expected "by"`,
+		},
+		{
+			name:  "trailing tokens",
+			query: "where memory > 128 memory",
+			err:   `unexpected "memory"`,
+		},
+		{
+			name:  "bang without equals",
+			query: "where memory ! 128",
+			err:   `unexpected "!"`,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := parseQuery(test.query)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// Conditions contain functions, so they're tested by evaluating them.
+			actual.Where = nil
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func queryTestFunction(name, region, team string, memorySize int64, invocations int) FunctionReports {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fr := FunctionReports{
+		Name:         name,
+		Region:       region,
+		Architecture: ArchitectureX86_64,
+		MemorySize:   memorySize,
+		Start:        start,
+		End:          start.Add(24 * time.Hour),
+		Tags:         map[string]string{"team": team},
+	}
+	for i := 0; i < invocations; i++ {
+		fr.Reports = append(fr.Reports, Report{
+			RequestID:      fmt.Sprintf("%s-%d", name, i),
+			Timestamp:      start.Add(time.Duration(i) * time.Minute),
+			Duration:       100 * time.Millisecond,
+			BilledDuration: 100 * time.Millisecond,
+			MemorySize:     memorySize,
+			MaxMemoryUsed:  memorySize / 2,
+		})
+	}
+	return fr
+}
+
+var queryTestFunctions = []FunctionReports{
+	queryTestFunction("orders-api", "eu-west-1", "payments", 1024, 300),
+	queryTestFunction("orders-worker", "eu-west-1", "payments", 512, 100),
+	queryTestFunction("2024-report", "us-east-1", "analytics", 2048, 10),
+	queryTestFunction("thumbnails", "us-east-1", "media", 128, 50),
+}
+
+func TestQueryWhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		where    string
+		expected []string
+	}{
+		{
+			name:     "number",
+			where:    "memory >= 1024",
+			expected: []string{"2024-report", "orders-api"},
+		},
+		{
+			name:     "text",
+			where:    "region != eu-west-1",
+			expected: []string{"2024-report", "thumbnails"},
+		},
+		{
+			name:     "contains",
+			where:    "name contains orders",
+			expected: []string{"orders-api", "orders-worker"},
+		},
+		{
+			name:     "quoted value",
+			where:    `tag.team = "media"`,
+			expected: []string{"thumbnails"},
+		},
+		{
+			name:     "value that starts with a digit",
+			where:    "name = 2024-report",
+			expected: []string{"2024-report"},
+		},
+		{
+			name:     "and binds more tightly than or",
+			where:    "region = us-east-1 or tag.team = payments and memory < 1024",
+			expected: []string{"2024-report", "orders-worker", "thumbnails"},
+		},
+		{
+			name:     "parentheses",
+			where:    "(region = us-east-1 or tag.team = payments) and memory < 1024",
+			expected: []string{"orders-worker", "thumbnails"},
+		},
+		{
+			name:     "not binds more tightly than and",
+			where:    "not region = us-east-1 and memory < 1024",
+			expected: []string{"orders-worker"},
+		},
+		{
+			name:     "not of a group",
+			where:    "not (region = us-east-1 and memory < 1024)",
+			expected: []string{"2024-report", "orders-api", "orders-worker"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			q, err := parseQuery("functions where " + test.where)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var actual []string
+			for _, fr := range queryTestFunctions {
+				if q.Where.Match(fr, reportOptions{}) {
+					actual = append(actual, fr.Name)
+				}
+			}
+			sort.Strings(actual)
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+// queryOutputRows returns the rows written by the query, without the header and totals.
+func queryOutputRows(t *testing.T, q string) (rows [][]string) {
+	t.Helper()
+	parsed, err := parseQuery(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err = parsed.Run(&buf, queryTestFunctions, reportOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for _, line := range lines[1:] {
+		if line == "" {
+			break
+		}
+		rows = append(rows, strings.Fields(line))
+	}
+	return rows
+}
+
+func TestQueryRunOrdersAndLimits(t *testing.T) {
+	rows := queryOutputRows(t, "functions where memory > 128 order by memory desc limit 2")
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %v", rows)
+	}
+	if rows[0][0] != "2024-report" || rows[1][0] != "orders-api" {
+		t.Errorf("expected the functions with the most memory first, got %v", rows)
+	}
+	// The columns include the fields used by the query.
+	if memory := rows[0][len(rows[0])-1]; memory != "2048" {
+		t.Errorf("expected a memory column, got %v", rows[0])
+	}
+}
+
+func TestQueryRunGrouped(t *testing.T) {
+	// group returns the name, count and totals of the functions.
+	group := func(key string, names ...string) []string {
+		var cost, savings float64
+		for _, fr := range queryTestFunctions {
+			if contains(names, fr.Name) {
+				cost += fr.DailyCost() * 30
+				savings += fr.MonthlySavings()
+			}
+		}
+		return []string{key, fmt.Sprintf("%d", len(names)), displayFormat{}.Decimal(cost, 2), displayFormat{}.Decimal(savings, 2)}
+	}
+	tests := []struct {
+		name     string
+		query    string
+		expected [][]string
+	}{
+		{
+			name:  "by monthly cost",
+			query: "group by region",
+			expected: [][]string{
+				group("eu-west-1", "orders-api", "orders-worker"),
+				group("us-east-1", "2024-report", "thumbnails"),
+			},
+		},
+		{
+			name:  "by key",
+			query: "where memory > 128 group by tag.team order by tag.team",
+			expected: [][]string{
+				group("analytics", "2024-report"),
+				group("payments", "orders-api", "orders-worker"),
+			},
+		},
+		{
+			name:  "by count",
+			query: "group by tag.team order by count desc limit 1",
+			expected: [][]string{
+				group("payments", "orders-api", "orders-worker"),
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if actual := queryOutputRows(t, test.query); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestQueryRunGroupedOrderByOtherField(t *testing.T) {
+	q, err := parseQuery("group by region order by memory")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err = q.Run(&buf, queryTestFunctions, reportOptions{}); err == nil {
+		t.Error("expected an error ordering grouped results by a field that isn't aggregated")
+	}
+}
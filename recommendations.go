@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Recommendation types, which are also the names of the recommenders that make them.
+const (
+	recommendationMemory                 = "memory"
+	recommendationArchitecture           = "architecture"
+	recommendationReduceInvocations      = "reduceInvocations"
+	recommendationProvisionedConcurrency = "provisionedConcurrency"
+	recommendationIdle                   = "idle"
+	recommendationLogRetention           = "logRetention"
+)
+
+// Recommendation is a suggested change to a function, with its estimated monthly savings.
+type Recommendation struct {
+	Type           string  `json:"type"`
+	Description    string  `json:"description"`
+	MonthlySavings float64 `json:"monthlySavings"`
+	// MonthlySavingsMargin is the margin of MonthlySavings at the 95% confidence level, and
+	// Confidence is high, medium or low, from the size of the margin.
+	MonthlySavingsMargin float64 `json:"monthlySavingsMargin,omitempty"`
+	Confidence           string  `json:"confidence,omitempty"`
+	// Rationale explains any adjustment made to the recommendation, e.g. due to errors.
+	Rationale string `json:"rationale,omitempty"`
+}
+
+// Recommender makes a recommendation for a function, if one applies.
+type Recommender interface {
+	Recommend(fr FunctionReports) (rec Recommendation, ok bool)
+}
+
+// RecommenderFunc adapts a function to the Recommender interface.
+type RecommenderFunc func(fr FunctionReports) (rec Recommendation, ok bool)
+
+func (f RecommenderFunc) Recommend(fr FunctionReports) (rec Recommendation, ok bool) {
+	return f(fr)
+}
+
+// RecommenderFactory creates a Recommender, configured by the settings.
+type RecommenderFactory func(settings Settings) Recommender
+
+var recommenderFactories = map[string]RecommenderFactory{}
+
+// optInRecommenders are only enabled if they're named in the -recommenders flag or settings.
+var optInRecommenders = map[string]struct{}{}
+
+// registerRecommender makes a recommender available by name, for use in the -recommenders flag and settings.
+func registerRecommender(name string, factory RecommenderFactory) {
+	if _, exists := recommenderFactories[name]; exists {
+		panic(fmt.Sprintf("recommender %q is already registered", name))
+	}
+	recommenderFactories[name] = factory
+}
+
+// registerOptInRecommender makes a recommender available by name, but doesn't enable it by
+// default, e.g. because its recommendations increase cost.
+func registerOptInRecommender(name string, factory RecommenderFactory) {
+	registerRecommender(name, factory)
+	optInRecommenders[name] = struct{}{}
+}
+
+func recommenderNames() (names []string) {
+	for name := range recommenderFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	registerRecommender(recommendationMemory, func(settings Settings) Recommender {
+		return RecommenderFunc(memoryRecommendation)
+	})
+	registerRecommender(recommendationArchitecture, func(settings Settings) Recommender {
+		return RecommenderFunc(architectureRecommendation)
+	})
+	registerRecommender(recommendationReduceInvocations, func(settings Settings) Recommender {
+		return RecommenderFunc(FunctionReports.reduceInvocationsRecommendation)
+	})
+	registerRecommender(recommendationProvisionedConcurrency, func(settings Settings) Recommender {
+		return RecommenderFunc(provisionedConcurrencyRecommendation)
+	})
+	registerRecommender(recommendationIdle, func(settings Settings) Recommender {
+		return RecommenderFunc(idleRecommendation)
+	})
+	registerRecommender(recommendationLogRetention, func(settings Settings) Recommender {
+		return RecommenderFunc(logRetentionRecommendation)
+	})
+}
+
+// Recommenders is the set of enabled recommenders.
+type Recommenders struct {
+	names        []string
+	recommenders []Recommender
+	// acks hide recommendations until they expire, compared with now.
+	acks Acknowledgements
+	now  time.Time
+}
+
+// setAcknowledgements hides the recommendations that are acknowledged at the time.
+func (r *Recommenders) setAcknowledgements(acks Acknowledgements, now time.Time) {
+	r.acks, r.now = acks, now
+}
+
+// newRecommenders creates the enabled recommenders. If enabled is empty, all recommenders
+// are enabled, apart from those that are disabled, and those that are opt-in.
+func newRecommenders(settings Settings, enabled, disabled []string) (r *Recommenders, err error) {
+	for _, name := range append(append([]string{}, enabled...), disabled...) {
+		if _, ok := recommenderFactories[name]; !ok {
+			return nil, fmt.Errorf("unknown recommender %q, expected one of: %s", name, strings.Join(recommenderNames(), ", "))
+		}
+	}
+	if len(enabled) == 0 {
+		for _, name := range recommenderNames() {
+			if _, optIn := optInRecommenders[name]; !optIn {
+				enabled = append(enabled, name)
+			}
+		}
+	}
+	r = &Recommenders{}
+	for _, name := range enabled {
+		if contains(disabled, name) {
+			continue
+		}
+		r.names = append(r.names, name)
+		r.recommenders = append(r.recommenders, recommenderFactories[name](settings))
+	}
+	return r, nil
+}
+
+// Recommend returns the recommendations for the function from each enabled recommender, apart
+// from those that are acknowledged. Savings are given a margin from the error in the function's
+// monthly cost, unless the recommender set one.
+func (r *Recommenders) Recommend(fr FunctionReports) (recs []Recommendation) {
+	recs, _ = r.recommend(fr)
+	return recs
+}
+
+// recommend returns the recommendations for the function, and those hidden by an acknowledgement.
+func (r *Recommenders) recommend(fr FunctionReports) (recs []Recommendation, acknowledged []AcknowledgedRecommendation) {
+	for _, recommender := range r.recommenders {
+		if rec, ok := recommender.Recommend(fr); ok {
+			if rec.MonthlySavings > 0 && rec.MonthlySavingsMargin == 0 {
+				rec.MonthlySavingsMargin = fr.SavingsMargin(rec.MonthlySavings)
+			}
+			rec.Confidence = savingsConfidence(rec.MonthlySavings, rec.MonthlySavingsMargin)
+			if ack, ok := r.acks.Find(fr, rec, r.now); ok {
+				acknowledged = append(acknowledged, AcknowledgedRecommendation{Recommendation: rec, Acknowledgement: ack})
+				continue
+			}
+			recs = append(recs, rec)
+		}
+	}
+	return recs, acknowledged
+}
+
+func memoryRecommendation(fr FunctionReports) (rec Recommendation, ok bool) {
+	savings := fr.MonthlyMemorySavings()
+	if savings <= 0 {
+		return
+	}
+	optimisedRAM, _ := fr.OptimisedCost()
+	headroom, _, rationale := fr.MemoryHeadroom()
+	if initRationale := fr.initMemoryRationale(headroom); initRationale != "" {
+		rationale = strings.TrimPrefix(rationale+"; "+initRationale, "; ")
+	}
+	if vcpus, ok := fr.AvgVCPUs(); ok && fr.CPUMemoryFloor() > int64(float64(fr.MaxMemoryUsed())*headroom) {
+		cpuRationale := fmt.Sprintf("sized to keep CPU utilisation below %.0f%%, Lambda Insights shows %.2f vCPUs used", cpuBoundUtilisation*100, vcpus)
+		rationale = strings.TrimPrefix(rationale+"; "+cpuRationale, "; ")
+	} else if fr.Workload() == workloadIO {
+		rationale = strings.TrimPrefix(rationale+"; IO-bound, so reducing memory has little effect on duration", "; ")
+	}
+	if projected, risk := fr.TimeoutRisk(optimisedRAM); risk {
+		warning := fmt.Sprintf("projected p99 duration at %d MB is %v, %.0f%% of the %v timeout, test before applying", optimisedRAM, projected.Round(time.Millisecond), float64(projected)/float64(fr.Timeout)*100, fr.Timeout)
+		rationale = strings.TrimPrefix(rationale+"; "+warning, "; ")
+	}
+	return Recommendation{
+		Type:                 recommendationMemory,
+		Description:          fmt.Sprintf("reduce memory from %d MB to %d MB", fr.MemoryAssigned(), optimisedRAM),
+		MonthlySavings:       savings,
+		MonthlySavingsMargin: math.Min(savings, fr.SavingsMargin(savings)+fr.memoryProjectionMargin(fr.Architecture, optimisedRAM)),
+		Rationale:            rationale,
+	}, true
+}
+
+func architectureRecommendation(fr FunctionReports) (rec Recommendation, ok bool) {
+	savings := fr.MonthlyArchitectureSavings()
+	if savings <= 0 || !fr.Architecture.Known() {
+		return
+	}
+	return Recommendation{
+		Type:           recommendationArchitecture,
+		Description:    fmt.Sprintf("migrate from %s to arm64", fr.Architecture),
+		MonthlySavings: savings,
+	}, true
+}
+
+// Functions with at least this many invocations per day, and an average warm duration of
+// at most reduceInvocationsMaxDuration are candidates for reducing invocations.
+const (
+	reduceInvocationsMinDaily    = 100000
+	reduceInvocationsMaxDuration = 100 * time.Millisecond
+	// Estimates assume that batching combines this many invocations into one.
+	reduceInvocationsBatchSize = 10
+)
+
+// DailyInvocations is the average number of invocations per day over the window.
+func (fr FunctionReports) DailyInvocations() float64 {
+	return float64(len(fr.Reports)) / fr.Days()
+}
+
+// reduceInvocationsRecommendation identifies functions with high invocation counts and short
+// durations, which are candidates for batching (e.g. increasing SQS batch sizes), or filtering
+// events (e.g. EventBridge rule filters) before they reach the function.
+func (fr FunctionReports) reduceInvocationsRecommendation() (rec Recommendation, ok bool) {
+	if len(fr.Reports) == 0 || fr.DailyInvocations() < reduceInvocationsMinDaily || fr.AvgWarmDuration() > reduceInvocationsMaxDuration {
+		return
+	}
+	requests, _ := fr.CostBreakdown(fr.Architecture, 0)
+	savings := requests / fr.Days() * 30 * (1 - 1.0/reduceInvocationsBatchSize)
+	return Recommendation{
+		Type:           recommendationReduceInvocations,
+		Description:    fmt.Sprintf("%.0f invocations per day averaging %v when warm, batch or filter events to reduce invocations", fr.DailyInvocations(), fr.AvgWarmDuration()),
+		MonthlySavings: savings,
+	}, true
+}
+
+// idleRecommendation identifies functions that weren't invoked during the window.
+func idleRecommendation(fr FunctionReports) (rec Recommendation, ok bool) {
+	if len(fr.Reports) > 0 || fr.LogGroupMissing || fr.Incomplete || fr.Start.IsZero() {
+		return
+	}
+	return Recommendation{
+		Type:           recommendationIdle,
+		Description:    fmt.Sprintf("no invocations in %.0f days, consider deleting the function", fr.Days()),
+		MonthlySavings: fr.MonthlyProvisionedConcurrencyCost(),
+	}, true
+}
+
+func displayRecommendations(w io.Writer, reportContent []FunctionReports, recommenders *Recommenders, format displayFormat) {
+	type row struct {
+		fr  FunctionReports
+		rec Recommendation
+	}
+	var rows []row
+	var acknowledged int
+	for _, rc := range reportContent {
+		recs, acks := recommenders.recommend(rc)
+		for _, rec := range recs {
+			rows = append(rows, row{fr: rc, rec: rec})
+		}
+		acknowledged += len(acks)
+	}
+	if len(rows) == 0 {
+		displayAcknowledgedCount(w, acknowledged)
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].rec.MonthlySavings > rows[j].rec.MonthlySavings
+	})
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Recommendations")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Type", "Monthly Savings", "Confidence", "Recommendation", "Rationale"}, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join([]string{
+			r.fr.Name,
+			r.rec.Type,
+			format.MoneyMargin(r.rec.MonthlySavings, r.rec.MonthlySavingsMargin, r.fr.Currency(), 2),
+			r.rec.Confidence,
+			r.rec.Description,
+			r.rec.Rationale,
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Savings are extrapolated from the window, and shown with their margin at the %.0f%% confidence level.\n", savingsConfidenceLevel*100)
+	displayAcknowledgedCount(w, acknowledged)
+}
+
+func displayAcknowledgedCount(w io.Writer, acknowledged int) {
+	if acknowledged == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%d acknowledged recommendations are hidden until their acknowledgements expire, list them with: lambdacost ack -list\n", acknowledged)
+}
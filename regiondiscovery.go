@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"go.uber.org/zap"
+)
+
+// discoverRegions returns the regions that contain functions. The regions that are enabled for the
+// account, in the partition of the configured region, are listed with EC2 DescribeRegions, so
+// opt-in regions that aren't enabled aren't checked, and regions that launch are found without
+// updating lambdacost. Each region is then checked with a single GetAccountSettings call, which
+// returns the number of functions in the region. Regions that can't be checked are returned as
+// region errors, so that the other regions are still collected.
+func discoverRegions(ctx context.Context, log *zap.Logger, cfg aws.Config) (regions []string, regionErrors []RegionError, err error) {
+	output, err := ec2.NewFromConfig(cfg).DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("discoverRegions: failed to describe regions: %w", err)
+	}
+	var enabled []string
+	for _, r := range output.Regions {
+		if r.RegionName != nil {
+			enabled = append(enabled, *r.RegionName)
+		}
+	}
+	sort.Strings(enabled)
+	lambdaClient := lambda.NewFromConfig(cfg)
+	for _, region := range enabled {
+		output, err := lambdaClient.GetAccountSettings(ctx, &lambda.GetAccountSettingsInput{}, func(o *lambda.Options) {
+			o.Region = region
+		})
+		if err != nil {
+			if errorKind(err) == errorKindAccessDenied {
+				log.Info("skipping region that can't be accessed", zap.String("functionRegion", region), zap.Error(err))
+				continue
+			}
+			log.Error("could not check region for functions, skipping it", zap.String("functionRegion", region), zap.Error(err))
+			regionErrors = append(regionErrors, RegionError{Region: region, Error: fmt.Sprintf("failed to get account settings: %v", err)})
+			continue
+		}
+		if output.AccountUsage == nil || output.AccountUsage.FunctionCount == 0 {
+			continue
+		}
+		log.Info("found functions in region", zap.String("functionRegion", region), zap.Int64("functionCount", output.AccountUsage.FunctionCount))
+		regions = append(regions, region)
+	}
+	return regions, regionErrors, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDiscoverRegions(t *testing.T) {
+	fake := newFakeAWS(t, "eu-west-1", faultTestFunctions...)
+	fake.OtherRegions = map[string]int64{
+		"eu-central-1": 4,
+		"us-east-1":    0,
+		"ap-east-1":    0,
+		"us-west-2":    2,
+	}
+	fake.RegionErrors = map[string]string{
+		"ap-east-1": "UnrecognizedClientException",
+		"us-west-2": "InvalidParameterValueException",
+	}
+	regions, regionErrors, err := discoverRegions(context.Background(), zap.NewNop(), fake.Config())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"eu-central-1", "eu-west-1"}; !reflect.DeepEqual(expected, regions) {
+		t.Errorf("expected regions %v, got %v", expected, regions)
+	}
+	// Regions that deny access are skipped, but other errors are returned, and don't stop the
+	// remaining regions from being checked.
+	if len(regionErrors) != 1 || regionErrors[0].Region != "us-west-2" || regionErrors[0].Error == "" {
+		t.Errorf("expected an error for us-west-2, got %+v", regionErrors)
+	}
+	if got := fake.Requests()["Lambda:GetAccountSettings"]; got != 5 {
+		t.Errorf("expected each enabled region to be checked once, got %d requests", got)
+	}
+}
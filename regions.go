@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const recommendationRelocateRegion = "relocateRegion"
+
+func init() {
+	registerRecommender(recommendationRelocateRegion, func(settings Settings) Recommender {
+		return RecommenderFunc(func(fr FunctionReports) (Recommendation, bool) {
+			return fr.relocateRegionRecommendation(settings.WorkloadTag)
+		})
+	})
+}
+
+// defaultWorkloadTag is the tag used to identify latency insensitive functions.
+const defaultWorkloadTag = "lambdacost:workload"
+
+// LatencyInsensitive is true if the function is tagged as a batch or async workload.
+func (fr FunctionReports) LatencyInsensitive(workloadTag string) bool {
+	if workloadTag == "" {
+		workloadTag = defaultWorkloadTag
+	}
+	switch strings.ToLower(fr.Tags[workloadTag]) {
+	case "batch", "async":
+		return true
+	}
+	return false
+}
+
+// CheapestRegion returns the region in the price table where the function would cost the
+// least, with the same architecture, memory and invocations. Only regions in the same partition
+// are considered, since functions can't be moved between partitions.
+func (fr FunctionReports) CheapestRegion() (region string, cost float64) {
+	region, cost = fr.Region, fr.Cost()
+	partition := regionPartition(fr.Region)
+	var regions []string
+	for _, r := range prices.Regions() {
+		if regionPartition(r) == partition {
+			regions = append(regions, r)
+		}
+	}
+	for _, r := range regions {
+		alt := fr
+		alt.Region = r
+		if altCost := alt.Cost(); altCost < cost {
+			region, cost = r, altCost
+		}
+	}
+	return region, cost
+}
+
+func (fr FunctionReports) relocateRegionRecommendation(workloadTag string) (rec Recommendation, ok bool) {
+	if !fr.LatencyInsensitive(workloadTag) || len(fr.Reports) == 0 {
+		return
+	}
+	region, cost := fr.CheapestRegion()
+	if region == fr.Region {
+		return
+	}
+	return Recommendation{
+		Type:           recommendationRelocateRegion,
+		Description:    fmt.Sprintf("latency insensitive workload, relocate from %s to %s", fr.Region, region),
+		MonthlySavings: (fr.Cost() - cost) / fr.Days() * 30,
+	}, true
+}
+
+func displayRegionComparison(w io.Writer, reportContent []FunctionReports, workloadTag string, format displayFormat) {
+	type row struct {
+		fr  FunctionReports
+		rec Recommendation
+	}
+	var rows []row
+	for _, rc := range reportContent {
+		if rec, ok := rc.relocateRegionRecommendation(workloadTag); ok {
+			rows = append(rows, row{fr: rc, rec: rec})
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].rec.MonthlySavings > rows[j].rec.MonthlySavings
+	})
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Region comparison (batch and async functions)")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "Monthly", "Cheapest Region", "Monthly", "Monthly Savings"}, "\t"))
+	for _, r := range rows {
+		cheapest, cost := r.fr.CheapestRegion()
+		fmt.Fprintln(tw, strings.Join([]string{
+			r.fr.Name,
+			r.fr.Region,
+			format.Money(r.fr.DailyCost()*30, r.fr.Currency(), 2),
+			cheapest,
+			format.Money(cost/r.fr.Days()*30, r.fr.Currency(), 2),
+			format.Money(r.rec.MonthlySavings, r.fr.Currency(), 2),
+		}, "\t"))
+	}
+	tw.Flush()
+}
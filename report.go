@@ -0,0 +1,314 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// analysisFlags change how functions are analysed and displayed. They're shared by every command
+// that reads report data.
+type analysisFlags struct {
+	config       *string
+	recommenders *string
+	disabled     *string
+	acks         *string
+	tolerance    *float64
+	synthetic    *time.Duration
+	durationUnit *string
+	decimalSep   *string
+}
+
+func newAnalysisFlags(fs *flag.FlagSet) analysisFlags {
+	return analysisFlags{
+		config:       fs.String("config", "", "Path to a JSON settings file, e.g. to map account IDs to friendly names"),
+		recommenders: fs.String("recommenders", "", "Comma separated list of recommenders to enable, defaults to all apart from opt-in recommenders such as increaseMemory, e.g. memory,architecture"),
+		disabled:     fs.String("disable-recommenders", "", "Comma separated list of recommenders to disable"),
+		acks:         fs.String("acks", "", "Path to the acknowledgements file written by the ack subcommand, e.g. acks.json, whose recommendations are hidden until they expire"),
+		tolerance:    fs.Float64("invocation-tolerance", defaultInvocationTolerance, "Proportion by which REPORT lines can differ from the Invocations metric before a function is flagged"),
+		synthetic:    fs.Duration("synthetic-max-duration", 0, "Only treat requests that logged a synthetic marker as synthetic traffic if they ran for this duration or less, e.g. 5ms, or 0 for any duration"),
+		durationUnit: fs.String("duration-unit", "", "Unit to display durations in: auto (e.g. 1.365s), ms or s, defaults to auto"),
+		decimalSep:   fs.String("decimal-separator", "", "Decimal separator for displayed numbers, . or , defaults to ."),
+	}
+}
+
+// ownerFlags find the team that owns each function.
+type ownerFlags struct {
+	ownerTag     *string
+	ownerPattern *string
+}
+
+func newOwnerFlags(fs *flag.FlagSet) ownerFlags {
+	return ownerFlags{
+		ownerTag:     fs.String("owner-tag", "", "Tag that holds the team that owns each function, defaults to team"),
+		ownerPattern: fs.String("owner-pattern", "", "Regular expression that extracts the owner from the function name, for functions without the owner tag, e.g. ^(?P<owner>[a-z]+)-"),
+	}
+}
+
+// outputFlags are shared by the commands that display a report.
+type outputFlags struct {
+	analysisFlags
+	ownerFlags
+	noColor      *bool
+	summaryOut   *string
+	requiredTags *string
+	baseline     *string
+	threshold    *float64
+	newThreshold *float64
+	compare      *string
+	lowTraffic   *bool
+	previous     *string
+	auditLog     *string
+	statusOut    *string
+	output       *string
+}
+
+func newOutputFlags(fs *flag.FlagSet) outputFlags {
+	return outputFlags{
+		analysisFlags: newAnalysisFlags(fs),
+		ownerFlags:    newOwnerFlags(fs),
+		noColor:       fs.Bool("no-color", false, "Disable colored output"),
+		summaryOut:    fs.String("summary-out", "", "Path to write a summary JSON file to, e.g. summary.json"),
+		requiredTags:  fs.String("required-tags", "", "Comma separated list of tags that every function must have, e.g. team,cost-centre"),
+		baseline:      fs.String("baseline", "", "Path to baseline report data to compare costs against, e.g. baseline.json"),
+		threshold:     fs.Float64("baseline-threshold", defaultBaselineThreshold, "Percentage increase in a function's monthly cost, compared to the baseline, that causes a non-zero exit code"),
+		newThreshold:  fs.Float64("new-function-threshold", defaultNewFunctionThreshold, "Monthly cost in USD above which functions that aren't in the baseline cause a non-zero exit code"),
+		previous:      fs.String("previous-summary", "", "Path to the summary JSON file of the previous run, to show what changed, e.g. yesterday.json"),
+		compare:       fs.String("compare-windows", "", "Compare a recent window with a longer window, e.g. 7d,30d, and list functions whose behaviour changed"),
+		lowTraffic:    fs.Bool("low-traffic", false, "Mark the window as a known traffic trough, e.g. a holiday, so monthly costs are flagged as low confidence"),
+		auditLog:      fs.String("audit-log", "", "Path to append a JSON lines record of what was scanned and recommended to, e.g. audit.jsonl"),
+		output:        fs.String("output", formatterTable, "Output format of the report: "+strings.Join(formatterNames(), ", ")),
+		statusOut:     fs.String("status-out", "", "Path to write a JSON status file to on exit, with counts of functions, errors, recommendations and thresholds breached, e.g. status.json"),
+	}
+}
+
+// reportOptions control the content of the report.
+type reportOptions struct {
+	UseColor     bool
+	RequiredTags []string
+	// WorkloadTag identifies batch and async functions.
+	WorkloadTag  string
+	Recommenders *Recommenders
+	// InvocationTolerance is the proportion by which REPORT lines can differ from the Invocations metric.
+	InvocationTolerance float64
+	// CompareWindows are the recent and full windows to compare, or empty.
+	CompareWindows []time.Duration
+	// Baseline is the report data that costs are compared against, if set.
+	Baseline []FunctionReports
+	// NewFunctionThreshold is the monthly cost above which functions that aren't in the baseline are flagged.
+	NewFunctionThreshold float64
+	// LowTraffic is true if the window is known to be a traffic trough.
+	LowTraffic bool
+	// Owners finds the team that owns each function.
+	Owners ownerResolver
+	// Format controls how durations, numbers and times are displayed.
+	Format displayFormat
+	// PreviousSummary is the summary of the previous run that the report is compared with, if set.
+	PreviousSummary *Summary
+	// SyntheticMaxDuration is the duration at or below which requests that logged a synthetic
+	// marker are synthetic, or zero for any duration.
+	SyntheticMaxDuration time.Duration
+	// Output is the name of the output format, and Formatter writes the report in that format.
+	Output    string
+	Formatter Formatter
+}
+
+// reportOptions returns the options of commands that only analyse functions, e.g. show.
+func (af analysisFlags) reportOptions(settings Settings) (opts reportOptions, err error) {
+	opts.WorkloadTag = settings.WorkloadTag
+	opts.InvocationTolerance = *af.tolerance
+	durationUnit, decimalSeparator := settings.DurationUnit, settings.DecimalSeparator
+	if *af.durationUnit != "" {
+		durationUnit = *af.durationUnit
+	}
+	if *af.decimalSep != "" {
+		decimalSeparator = *af.decimalSep
+	}
+	if opts.Format, err = newDisplayFormat(durationUnit, decimalSeparator); err != nil {
+		return opts, err
+	}
+	// Recommenders are created from the settings, so the flags override the settings' format too.
+	settings.DurationUnit, settings.DecimalSeparator = durationUnit, decimalSeparator
+	opts.SyntheticMaxDuration = *af.synthetic
+	enabled, disabled := settings.Recommenders, settings.DisabledRecommenders
+	if *af.recommenders != "" {
+		enabled = splitList(*af.recommenders)
+	}
+	if *af.disabled != "" {
+		disabled = splitList(*af.disabled)
+	}
+	if opts.Recommenders, err = newRecommenders(settings, enabled, disabled); err != nil {
+		return opts, err
+	}
+	if *af.acks != "" {
+		acks, err := readAcknowledgements(*af.acks)
+		if err != nil {
+			return opts, err
+		}
+		opts.Recommenders.setAcknowledgements(acks, time.Now())
+	}
+	return opts, nil
+}
+
+// owners returns the owner resolver, using the flags in preference to the settings.
+func (of ownerFlags) owners(settings Settings) (ownerResolver, error) {
+	ownerTag, ownerPattern := settings.OwnerTag, settings.OwnerPattern
+	if *of.ownerTag != "" {
+		ownerTag = *of.ownerTag
+	}
+	if *of.ownerPattern != "" {
+		ownerPattern = *of.ownerPattern
+	}
+	return newOwnerResolver(ownerTag, ownerPattern)
+}
+
+func (of outputFlags) reportOptions(settings Settings) (opts reportOptions, err error) {
+	if opts, err = of.analysisFlags.reportOptions(settings); err != nil {
+		return opts, err
+	}
+	if opts.Owners, err = of.owners(settings); err != nil {
+		return opts, err
+	}
+	opts.UseColor = shouldUseColor(*of.noColor)
+	opts.Output = *of.output
+	if opts.Formatter, err = getFormatter(opts.Output); err != nil {
+		return opts, err
+	}
+	opts.RequiredTags = settings.RequiredTags
+	if *of.requiredTags != "" {
+		opts.RequiredTags = splitList(*of.requiredTags)
+	}
+	opts.LowTraffic = *of.lowTraffic
+	opts.NewFunctionThreshold = *of.newThreshold
+	if *of.baseline != "" {
+		if opts.Baseline, err = readFunctionReports(*of.baseline); err != nil {
+			return opts, err
+		}
+	}
+	if *of.previous != "" {
+		previous, err := readSummary(*of.previous)
+		if err != nil {
+			return opts, err
+		}
+		opts.PreviousSummary = &previous
+	}
+	if *of.compare != "" {
+		if _, opts.CompareWindows, err = parseCompareWindows(*of.compare); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// writeOutputs writes the report in the output format to stdout, and writes any additional outputs. The status hasn't passed if
+// any function is over the budget set by its tag, or if a baseline is set, and any function's cost
+// has increased beyond the threshold, or a new function costs more than the new function threshold.
+// Recommendations are added to the audit log.
+func writeOutputs(log *zap.Logger, functionReports []FunctionReports, settings Settings, of outputFlags, audit *auditLog) (status RunStatus) {
+	opts, err := of.reportOptions(settings)
+	if err != nil {
+		log.Fatal("invalid report options", zap.Error(err))
+	}
+	functionReports, synthetic, invalid := separateSynthetic(functionReports, opts.SyntheticMaxDuration)
+	if err := opts.Formatter.Format(os.Stdout, functionReports, opts); err != nil {
+		log.Fatal("could not write report", zap.Error(err))
+	}
+	w := extrasWriter(opts.Output)
+	displaySynthetic(w, synthetic, invalid, opts.Format)
+	summary := newSummary(functionReports, opts, time.Now())
+	summary.SyntheticTraffic = synthetic
+	if opts.PreviousSummary != nil {
+		changes := compareSummaries(*opts.PreviousSummary, summary, functionReports, opts.Recommenders)
+		displaySummaryChanges(w, changes, summary.Currency, opts.Format)
+		summary.Changes = &changes
+	}
+	if *of.summaryOut != "" {
+		if err := writeSummary(*of.summaryOut, summary); err != nil {
+			log.Fatal("could not write summary", zap.Error(err))
+		}
+	}
+	for _, e := range recommendationAuditEntries(functionReports, opts.Recommenders) {
+		if err := audit.Add(e); err != nil {
+			log.Fatal("could not write audit log", zap.Error(err))
+		}
+	}
+	status = newRunStatus(functionReports, opts.Recommenders)
+	violations, invalid := findBudgetViolations(functionReports)
+	displayBudgetViolations(w, violations, invalid, opts.Format)
+	status.BudgetViolations = len(violations)
+	if *of.baseline != "" {
+		regressions := findRegressions(opts.Baseline, functionReports, *of.threshold)
+		displayRegressions(w, regressions, *of.threshold, opts.Format)
+		newFunctions := findNewFunctions(opts.Baseline, functionReports, opts.NewFunctionThreshold)
+		displayNewFunctions(w, newFunctions, opts.NewFunctionThreshold, opts.Format)
+		status.Regressions, status.NewFunctions = len(regressions), len(newFunctions)
+	}
+	status.Passed = status.BudgetViolations == 0 && status.Regressions == 0 && status.NewFunctions == 0
+	return status
+}
+
+// writeStatus writes the status file, if -status-out is set.
+func (of outputFlags) writeStatus(log *zap.Logger, status RunStatus) {
+	if *of.statusOut == "" {
+		return
+	}
+	if err := writeRunStatus(*of.statusOut, status, time.Now()); err != nil {
+		log.Fatal("could not write status", zap.Error(err))
+	}
+}
+
+func reportCmd(args []string) {
+	cmd := flag.NewFlagSet("report", flag.ExitOnError)
+	of := newOutputFlags(cmd)
+	month := cmd.String("month", "", "The month to show when reporting on a directory, e.g. 2024-03, defaults to the month of the latest data")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost report [flags] <file.json|directory>")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	settings := mustLoadSettings(log, *of.config)
+	if info, err := os.Stat(cmd.Arg(0)); err == nil && info.IsDir() {
+		var m time.Time
+		if *month != "" {
+			if m, err = time.Parse("2006-01", *month); err != nil {
+				log.Fatal("could not parse month", zap.String("month", *month), zap.Error(err))
+			}
+		}
+		opts, err := of.reportOptions(settings)
+		if err != nil {
+			log.Fatal("invalid report options", zap.Error(err))
+		}
+		reportSnapshots(log, cmd.Arg(0), m, opts.Format)
+		return
+	}
+	functionReports, err := readFunctionReports(cmd.Arg(0))
+	if err != nil {
+		log.Fatal("could not read report data", zap.Error(err))
+	}
+	audit := newAuditLog(*of.auditLog, localActor())
+	status := writeOutputs(log, functionReports, settings, of, audit)
+	audit.Close()
+	of.writeStatus(log, status)
+	if !status.Passed {
+		os.Exit(1)
+	}
+}
+
+// splitList splits a comma separated list, ignoring empty values.
+func splitList(v string) (values []string) {
+	for _, value := range strings.Split(v, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
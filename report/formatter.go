@@ -0,0 +1,72 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Options are the report options that apply to every output format.
+type Options struct {
+	// Output is the name of the output format, as passed to -output.
+	Output string
+	// UseColor is true if the output is a terminal that supports color.
+	UseColor bool
+	// InvocationTolerance is the proportion by which REPORT lines can differ from the Invocations
+	// metric before a function's data quality is reduced.
+	InvocationTolerance float64
+}
+
+// Formatter writes the report in an output format.
+type Formatter interface {
+	// Format writes the report. Functions is never nil, and only includes functions with log
+	// data.
+	Format(w io.Writer, output Output, opts Options) error
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(w io.Writer, output Output, opts Options) error
+
+func (f FormatterFunc) Format(w io.Writer, output Output, opts Options) error {
+	return f(w, output, opts)
+}
+
+var (
+	formattersMutex sync.RWMutex
+	formatters      = map[string]Formatter{}
+)
+
+// Register makes a formatter available by name, for use in the -output flag. It's intended to
+// be called from an init function, and panics if the name is already registered, including by
+// the formats that are built into lambdacost.
+func Register(name string, f Formatter) {
+	formattersMutex.Lock()
+	defer formattersMutex.Unlock()
+	if name == "" || f == nil {
+		panic("report: Register called with an empty name or nil formatter")
+	}
+	if _, exists := formatters[name]; exists {
+		panic(fmt.Sprintf("report: formatter %q is already registered", name))
+	}
+	formatters[name] = f
+}
+
+// Lookup returns the formatter registered with the name.
+func Lookup(name string) (f Formatter, ok bool) {
+	formattersMutex.RLock()
+	defer formattersMutex.RUnlock()
+	f, ok = formatters[name]
+	return f, ok
+}
+
+// Names returns the names of the registered formatters, in alphabetical order.
+func Names() (names []string) {
+	formattersMutex.RLock()
+	defer formattersMutex.RUnlock()
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
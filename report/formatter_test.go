@@ -0,0 +1,68 @@
+package report_test
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/a-h/lambdacost/report"
+)
+
+func TestRegister(t *testing.T) {
+	var called bool
+	report.Register("test-register", report.FormatterFunc(func(w io.Writer, output report.Output, opts report.Options) error {
+		called = true
+		return nil
+	}))
+	f, ok := report.Lookup("test-register")
+	if !ok {
+		t.Fatal("expected the formatter to be registered")
+	}
+	if err := f.Format(io.Discard, report.Output{}, report.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("expected the registered formatter to be called")
+	}
+	var found bool
+	for _, name := range report.Names() {
+		found = found || name == "test-register"
+	}
+	if !found {
+		t.Errorf("expected the formatter to be listed, got %v", report.Names())
+	}
+	if _, ok := report.Lookup("test-unknown"); ok {
+		t.Error("expected unknown formatters not to be found")
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	f := report.FormatterFunc(func(w io.Writer, output report.Output, opts report.Options) error { return nil })
+	report.Register("test-twice", f)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering the same name twice to panic")
+		}
+	}()
+	report.Register("test-twice", f)
+}
+
+// A format written outside of lambdacost is registered in an init function of its package, and
+// added to a build by importing the package.
+func Example() {
+	report.Register("tsv", report.FormatterFunc(func(w io.Writer, output report.Output, opts report.Options) error {
+		for _, r := range output.Functions {
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%.2f %s\n", r.Name, r.Region, r.MonthlyCost, r.Currency); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	f, _ := report.Lookup("tsv")
+	f.Format(os.Stdout, report.Output{
+		Functions: []report.Row{{Name: "api", Region: "eu-west-1", MonthlyCost: 12.5, Currency: "USD"}},
+	}, report.Options{Output: "tsv"})
+	// Output: api	eu-west-1	12.50 USD
+}
@@ -0,0 +1,87 @@
+// Package report is the output of lambdacost, as data rather than display text, and the registry
+// of the output formats that write it. Formats that only need the rows of the report can be
+// written outside of this repository, and added to a build by importing their package.
+package report
+
+import (
+	"time"
+
+	"github.com/a-h/lambdacost/pricing"
+)
+
+// Output is the window that the report covers, and a row for each function with log data,
+// highest daily cost first. It's written as JSON with -output json.
+type Output struct {
+	// Start and End are the time window that the report covers.
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Functions []Row     `json:"functions"`
+}
+
+// Row is a function's row in the report, as numbers rather than display text, for the machine
+// readable output formats. Costs are in the currency of the function's region.
+type Row struct {
+	Account      string               `json:"account"`
+	AccountName  string               `json:"accountName,omitempty"`
+	Region       string               `json:"region"`
+	Name         string               `json:"name"`
+	Architecture pricing.Architecture `json:"architecture"`
+	Currency     string               `json:"currency"`
+	DailyCost    float64              `json:"dailyCost"`
+	MonthlyCost  float64              `json:"monthlyCost"`
+	// MonthlyCostARM64 is the monthly cost on arm64 with the same memory size, and
+	// MonthlyCostOptimal is the monthly cost on arm64 with the optimal memory size.
+	MonthlyCostARM64   float64       `json:"monthlyCostArm64"`
+	MonthlyCostOptimal float64       `json:"monthlyCostOptimal"`
+	Requests           int           `json:"requests"`
+	Executions         int           `json:"executions"`
+	AvgWarmDuration    time.Duration `json:"avgWarmDuration"`
+	AvgColdDuration    time.Duration `json:"avgColdDuration"`
+	MaxDuration        time.Duration `json:"maxDuration"`
+	MaxBilledDuration  time.Duration `json:"maxBilledDuration"`
+	// DurationHistogram counts invocations by duration, e.g. to compare latency objectives with
+	// the cost of each memory size. Of the built-in formats, only json writes it, since it doesn't
+	// fit in a column of the table, csv or markdown formats.
+	DurationHistogram DurationHistogram `json:"durationHistogram"`
+	// Memory sizes are in MB. OptimalMemory is zero if there's no data to optimise with.
+	MaxMemoryUsed        int64    `json:"maxMemoryUsed"`
+	MemoryAssigned       int64    `json:"memoryAssigned"`
+	OptimalMemory        int64    `json:"optimalMemory"`
+	MonthlySavings       float64  `json:"monthlySavings"`
+	MonthlySavingsMargin float64  `json:"monthlySavingsMargin"`
+	Confidence           string   `json:"confidence,omitempty"`
+	DataQuality          string   `json:"dataQuality"`
+	Incomplete           bool     `json:"incomplete,omitempty"`
+	Notes                []string `json:"notes,omitempty"`
+	// Errors are the errors that occurred while collecting the function's data.
+	Errors []CollectionError `json:"errors,omitempty"`
+}
+
+// CollectionError is an error that occurred while collecting data for a function.
+// Repeated errors of the same kind, for the same operation, are counted rather than
+// recorded individually.
+type CollectionError struct {
+	// Kind is throttle, accessDenied, parse or other.
+	Kind string `json:"kind"`
+	// Operation is what was being done, e.g. "getTags", "collectLogs", "parseReport".
+	Operation string `json:"operation"`
+	// Message is the first error message seen.
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// DurationHistogram counts invocations by duration.
+type DurationHistogram struct {
+	// Buckets are cumulative, as in Prometheus histograms, so each bucket counts the invocations
+	// whose duration was less than or equal to its upper bound.
+	Buckets []DurationHistogramBucket `json:"buckets"`
+	// Count is the number of invocations, and Sum is their total duration.
+	Count int           `json:"count"`
+	Sum   time.Duration `json:"sum"`
+}
+
+// DurationHistogramBucket is the number of invocations with a duration of at most LE.
+type DurationHistogramBucket struct {
+	LE    time.Duration `json:"le"`
+	Count int           `json:"count"`
+}
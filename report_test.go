@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestAnalysisFlags(t *testing.T) {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	af := newAnalysisFlags(fs)
+	if err := fs.Parse([]string{"-duration-unit=ms", "-recommenders=memory", "-acks="}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts, err := af.reportOptions(Settings{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := opts.Format.DurationUnit; got != "ms" {
+		t.Errorf("expected durations in ms, got %q", got)
+	}
+	if opts.Recommenders == nil {
+		t.Fatal("expected recommenders to be set")
+	}
+	if got := fs.Lookup("acks").DefValue; got != "" {
+		t.Errorf("expected acknowledgements not to be read unless -acks is passed, got default %q", got)
+	}
+	// Flags that only affect the report aren't registered.
+	for _, name := range []string{"output", "baseline", "compare-windows", "summary-out", "owner-tag"} {
+		if fs.Lookup(name) != nil {
+			t.Errorf("expected -%s not to be registered", name)
+		}
+	}
+}
+
+func TestOwnerFlags(t *testing.T) {
+	fr := FunctionReports{Name: "payments-api", Tags: map[string]string{"team": "payments", "squad": "billing"}}
+	tests := []struct {
+		name     string
+		args     []string
+		settings Settings
+		expected string
+	}{
+		{name: "default tag", expected: "payments"},
+		{name: "tag from the settings", settings: Settings{OwnerTag: "squad"}, expected: "billing"},
+		{name: "flags take precedence over the settings", args: []string{"-owner-tag=team"}, settings: Settings{OwnerTag: "squad"}, expected: "payments"},
+		{name: "pattern for functions without the tag", args: []string{"-owner-tag=missing", "-owner-pattern=^(?P<owner>[a-z]+)-"}, expected: "payments"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("plan arm64", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			of := newOwnerFlags(fs)
+			if err := fs.Parse(test.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			owners, err := of.owners(test.settings)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := owners.Owner(fr); got != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Prices used to estimate the cost of running the scan (us-east-1).
+const (
+	// CloudWatch charges per 1,000 API requests, see billableAPICalls.
+	apiRequestPricePer1000 = 0.01
+	// Logs Insights charges per GB of log data scanned.
+	insightsPricePerGB = 0.005
+	// Data transfer out to the internet, applicable if the program is run outside of AWS.
+	dataTransferOutPricePerGB = 0.09
+)
+
+const bytesPerGB = 1024 * 1024 * 1024
+
+// billableAPICalls are the operations charged at the CloudWatch API request price, keyed by
+// service ID and operation in the same way as scanStats.APICalls. Other requests, e.g. to Lambda
+// and STS, or to list log groups, aren't charged.
+var billableAPICalls = map[string]bool{
+	"CloudWatch:GetMetricData":        true,
+	"CloudWatch:GetMetricStatistics":  true,
+	"CloudWatch:ListMetrics":          true,
+	"CloudWatch Logs:FilterLogEvents": true,
+	"CloudWatch Logs:GetLogEvents":    true,
+}
+
+// scanStats records the work done to collect log data, to estimate the cost of the scan.
+type scanStats struct {
+	m                    sync.Mutex
+	APICalls             map[string]int
+	FilterLogEventsBytes int64
+	InsightsQueries      int
+	InsightsBytesScanned float64
+}
+
+// countAPICalls is an SDK middleware that counts each request attempt, including retries.
+func (s *scanStats) countAPICalls(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("CountAPICalls", func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		s.m.Lock()
+		if s.APICalls == nil {
+			s.APICalls = map[string]int{}
+		}
+		s.APICalls[awsmiddleware.GetServiceID(ctx)+":"+awsmiddleware.GetOperationName(ctx)]++
+		s.m.Unlock()
+		return next.HandleFinalize(ctx, in)
+	}), middleware.After)
+}
+
+func (s *scanStats) TotalAPICalls() (total int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, count := range s.APICalls {
+		total += count
+	}
+	return total
+}
+
+// BillableAPICalls returns the number of requests that are charged, see billableAPICalls.
+func (s *scanStats) BillableAPICalls() (total int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for operation, count := range s.APICalls {
+		if billableAPICalls[operation] {
+			total += count
+		}
+	}
+	return total
+}
+
+func (s *scanStats) APIRequestCost() float64 {
+	return float64(s.BillableAPICalls()) / 1000 * apiRequestPricePer1000
+}
+
+func (s *scanStats) InsightsCost() float64 {
+	return s.InsightsBytesScanned / bytesPerGB * insightsPricePerGB
+}
+
+func (s *scanStats) DataTransferCost() float64 {
+	return float64(s.FilterLogEventsBytes) / bytesPerGB * dataTransferOutPricePerGB
+}
+
+func (s *scanStats) EstimatedCost() float64 {
+	return s.APIRequestCost() + s.InsightsCost() + s.DataTransferCost()
+}
+
+func displayScanStats(w io.Writer, s *scanStats, format displayFormat) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Estimated cost of this scan: %s\n", format.Money(s.EstimatedCost(), currencyUSD, 4))
+	fmt.Fprintf(w, "  API requests: %d, of which %d are charged (%s)\n", s.TotalAPICalls(), s.BillableAPICalls(), format.Money(s.APIRequestCost(), currencyUSD, 4))
+	s.m.Lock()
+	operations := make([]string, 0, len(s.APICalls))
+	for operation := range s.APICalls {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		fmt.Fprintf(w, "    %s: %d\n", operation, s.APICalls[operation])
+	}
+	s.m.Unlock()
+	fmt.Fprintf(w, "  Logs Insights: %d queries, %s GB scanned (%s)\n", s.InsightsQueries, format.Decimal(s.InsightsBytesScanned/bytesPerGB, 3), format.Money(s.InsightsCost(), currencyUSD, 4))
+	fmt.Fprintf(w, "  Log data downloaded: %s GB (%s data transfer out, if run outside AWS)\n", format.Decimal(float64(s.FilterLogEventsBytes)/bytesPerGB, 3), format.Money(s.DataTransferCost(), currencyUSD, 4))
+}
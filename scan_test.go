@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestAPIRequestCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		calls    map[string]int
+		expected float64
+	}{
+		{
+			name:     "Lambda and STS calls aren't charged",
+			calls:    map[string]int{"Lambda:ListFunctions": 1000, "Lambda:GetFunctionConfiguration": 1000, "STS:GetCallerIdentity": 1000},
+			expected: 0,
+		},
+		{
+			name:     "CloudWatch Logs calls that list log groups aren't charged",
+			calls:    map[string]int{"CloudWatch Logs:DescribeLogGroups": 1000},
+			expected: 0,
+		},
+		{
+			name:     "metric and log event requests are charged",
+			calls:    map[string]int{"CloudWatch:GetMetricStatistics": 1000, "CloudWatch Logs:FilterLogEvents": 1000, "Lambda:ListFunctions": 1000},
+			expected: 2 * apiRequestPricePer1000,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := &scanStats{APICalls: test.calls}
+			if actual := s.APIRequestCost(); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
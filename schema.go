@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a-h/lambdacost/report"
+	"go.uber.org/zap"
+)
+
+// schemaVersion is increased when a field is removed or its meaning changes. Adding fields
+// doesn't change the version.
+const schemaVersion = 1
+
+// Schemas of the JSON files written by lambdacost.
+var schemaTypes = map[string]struct {
+	Title string
+	Type  reflect.Type
+}{
+	"report":   {Title: "lambdacost report data", Type: reflect.TypeOf([]FunctionReports{})},
+	"summary":  {Title: "lambdacost summary", Type: reflect.TypeOf(Summary{})},
+	"status":   {Title: "lambdacost run status", Type: reflect.TypeOf(RunStatus{})},
+	"output":   {Title: "lambdacost report output", Type: reflect.TypeOf(ReportOutput{})},
+	"opencost": {Title: "lambdacost OpenCost output", Type: reflect.TypeOf(OpenCostOutput{})},
+}
+
+func schemaCmd(args []string) {
+	cmd := flag.NewFlagSet("schema", flag.ExitOnError)
+	var names []string
+	for name := range schemaTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	schemaType := cmd.String("type", "report", "The file to output the schema of: "+strings.Join(names, ", "))
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost schema [-type report|summary|status|output]")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if _, ok := schemaTypes[*schemaType]; !ok {
+		log.Fatal("unknown schema type", zap.String("type", *schemaType))
+	}
+	if err := writeJSONSchema(os.Stdout, *schemaType); err != nil {
+		log.Fatal("could not write schema", zap.Error(err))
+	}
+}
+
+// writeJSONSchema writes the schema of the file, as indented JSON.
+func writeJSONSchema(w io.Writer, name string) error {
+	st, ok := schemaTypes[name]
+	if !ok {
+		return fmt.Errorf("writeJSONSchema: unknown schema type %q", name)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONSchema(name, st.Title, st.Type))
+}
+
+// jsonSchema is a JSON Schema (draft 2020-12) document, or subschema.
+type jsonSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is a type name, or a list of type names.
+	Type                 interface{}            `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// newJSONSchema creates a schema for a Go type from its JSON struct tags. Structs are
+// added to $defs, and referenced by name.
+func newJSONSchema(name, title string, t reflect.Type) *jsonSchema {
+	defs := map[string]*jsonSchema{}
+	s := typeSchema(t, defs)
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.ID = fmt.Sprintf("https://github.com/a-h/lambdacost/schema/v%d/%s.json", schemaVersion, name)
+	s.Title = title
+	s.Defs = defs
+	return s
+}
+
+func typeSchema(t reflect.Type, defs map[string]*jsonSchema) *jsonSchema {
+	switch t {
+	case timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &jsonSchema{Type: "integer", Description: "Duration in nanoseconds"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(typeSchema(t.Elem(), defs))
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Array:
+		return &jsonSchema{Type: "array", Items: typeSchema(t.Elem(), defs)}
+	case reflect.Slice:
+		return nullable(&jsonSchema{Type: "array", Items: typeSchema(t.Elem(), defs)})
+	case reflect.Map:
+		return nullable(&jsonSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), defs)})
+	case reflect.Struct:
+		name := schemaDefName(t)
+		if _, ok := defs[name]; !ok {
+			// Add a placeholder first, in case the type refers to itself.
+			defs[name] = nil
+			defs[name] = structSchema(t, defs)
+		}
+		return &jsonSchema{Ref: "#/$defs/" + name}
+	}
+	return &jsonSchema{}
+}
+
+// schemaDefNames are the names of types that were moved to the report package, as they were
+// named before, so that the schema doesn't change.
+var schemaDefNames = map[reflect.Type]string{
+	reflect.TypeOf(report.Output{}): "ReportOutput",
+	reflect.TypeOf(report.Row{}):    "ReportRow",
+}
+
+// schemaDefName is the name of the type in the schema.
+func schemaDefName(t reflect.Type) string {
+	if name, ok := schemaDefNames[t]; ok {
+		return name
+	}
+	return t.Name()
+}
+
+func structSchema(t reflect.Type, defs map[string]*jsonSchema) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			// The fields of embedded structs are written as if they were fields of the outer struct.
+			embedded := structSchema(f.Type, defs)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			for _, k := range embedded.Required {
+				if !contains(s.Required, k) {
+					s.Required = append(s.Required, k)
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = typeSchema(f.Type, defs)
+		// omitempty has no effect on struct values, so they're always present.
+		if !contains(tag[1:], "omitempty") || f.Type.Kind() == reflect.Struct {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// nullable allows null, which is written for nil pointers, slices and maps.
+func nullable(s *jsonSchema) *jsonSchema {
+	if s.Ref != "" {
+		return &jsonSchema{AnyOf: []*jsonSchema{s, {Type: "null"}}}
+	}
+	s.Type = []interface{}{s.Type, "null"}
+	return s
+}
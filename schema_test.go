@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateSchemas = flag.Bool("update-schemas", false, "Update the schemas in testdata/schema to match the Go types")
+
+// TestSchemaGolden checks that the schemas match those in testdata, so that changes to the files
+// written by lambdacost are reviewed. Run go test -run TestSchemaGolden -update-schemas to update
+// them after adding fields.
+func TestSchemaGolden(t *testing.T) {
+	for name := range schemaTypes {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeJSONSchema(&buf, name); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			fileName := filepath.Join("testdata", "schema", name+".json")
+			if *updateSchemas {
+				if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(fileName, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			expected, err := os.ReadFile(fileName)
+			if err != nil {
+				t.Fatalf("could not read the expected schema, run with -update-schemas to create it: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), expected) {
+				t.Errorf("the %s schema doesn't match %s, if the change is expected, run with -update-schemas to update it", name, fileName)
+			}
+		})
+	}
+}
+
+// TestSchemaMatchesOutput checks that the files written from the demo data are valid against the
+// schemas, so that the schemas can't drift from what's written.
+func TestSchemaMatchesOutput(t *testing.T) {
+	functionReports := generateDemoReports(1, time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC), 24*time.Hour)
+	recommenders, err := newRecommenders(Settings{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	owners, err := newOwnerResolver("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	opts := reportOptions{Recommenders: recommenders, Owners: owners}
+	// The report data only needs a few reports of each function to cover its fields.
+	reportData := make([]FunctionReports, len(functionReports))
+	for i, fr := range functionReports {
+		if len(fr.Reports) > 20 {
+			fr.Reports = fr.Reports[:20]
+		}
+		reportData[i] = fr
+	}
+	format := func(name string) json.RawMessage {
+		f, err := getFormatter(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var buf bytes.Buffer
+		if err = f.Format(&buf, functionReports, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.Bytes()
+	}
+	documents := map[string]interface{}{
+		"report":   reportData,
+		"summary":  newSummary(functionReports, opts, time.Now()),
+		"status":   newRunStatus(functionReports, recommenders),
+		"output":   format(formatterJSON),
+		"opencost": format(formatterOpenCost),
+	}
+	if len(documents) != len(schemaTypes) {
+		t.Fatalf("expected a document for each of the %d schemas, got %d", len(schemaTypes), len(documents))
+	}
+	for name, document := range documents {
+		name, document := name, document
+		t.Run(name, func(t *testing.T) {
+			st := schemaTypes[name]
+			schema := newJSONSchema(name, st.Title, st.Type)
+			b, err := json.Marshal(document)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var v interface{}
+			if err = json.Unmarshal(b, &v); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err = validateSchema(schema, schema.Defs, v, "$"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// validateSchema checks the value, decoded from JSON, against the subset of JSON Schema that
+// newJSONSchema writes.
+func validateSchema(s *jsonSchema, defs map[string]*jsonSchema, v interface{}, path string) error {
+	if s.Ref != "" {
+		def, ok := defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown $ref %q", path, s.Ref)
+		}
+		s = def
+	}
+	if len(s.AnyOf) > 0 {
+		var errs []string
+		for _, option := range s.AnyOf {
+			err := validateSchema(option, defs, v, path)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("%s: doesn't match any of the schemas: %s", path, strings.Join(errs, "; "))
+	}
+	if s.Type != nil && !schemaAllowsType(s.Type, v) {
+		return fmt.Errorf("%s: expected type %v, got %T", path, s.Type, v)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if s.Properties != nil {
+			for _, name := range s.Required {
+				if _, ok := v[name]; !ok {
+					return fmt.Errorf("%s: required property %q is missing", path, name)
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			property := s.AdditionalProperties
+			if s.Properties != nil {
+				if property = s.Properties[k]; property == nil {
+					return fmt.Errorf("%s: property %q isn't in the schema", path, k)
+				}
+			}
+			if property == nil {
+				continue
+			}
+			if err := validateSchema(property, defs, v[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range v {
+			if err := validateSchema(s.Items, defs, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case string:
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				return fmt.Errorf("%s: expected a date-time, got %q", path, v)
+			}
+		}
+	}
+	return nil
+}
+
+// schemaAllowsType returns true if the schema type, or list of types, allows the JSON value.
+func schemaAllowsType(schemaType interface{}, v interface{}) bool {
+	types, ok := schemaType.([]interface{})
+	if !ok {
+		types = []interface{}{schemaType}
+	}
+	for _, t := range types {
+		switch v := v.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// LogicalService is a function that's deployed in more than one account or region, e.g. a
+// log shipper, or a custom resource handler deployed by a platform team.
+type LogicalService struct {
+	Name        string
+	Deployments []FunctionReports
+}
+
+func (ls LogicalService) MonthlyCost() (cost float64) {
+	for _, d := range ls.Deployments {
+		cost += d.DailyCost() * 30
+	}
+	return cost
+}
+
+func (ls LogicalService) Invocations() (count int) {
+	for _, d := range ls.Deployments {
+		count += len(d.Reports)
+	}
+	return count
+}
+
+func (ls LogicalService) distinct(f func(fr FunctionReports) string) (values []string) {
+	for _, d := range ls.Deployments {
+		if v := f(d); !contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// findLogicalServices groups functions that have the same name, or the same non-empty
+// description, and are deployed to more than one account or region.
+func findLogicalServices(reportContent []FunctionReports) (services []LogicalService) {
+	// Union-find over function indexes.
+	parents := make([]int, len(reportContent))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	union := func(keys map[string]int, key string, i int) {
+		if j, ok := keys[key]; ok {
+			parents[find(i)] = find(j)
+			return
+		}
+		keys[key] = i
+	}
+	names := map[string]int{}
+	descriptions := map[string]int{}
+	for i, rc := range reportContent {
+		union(names, rc.Name, i)
+		if rc.Description != "" {
+			union(descriptions, rc.Description, i)
+		}
+	}
+	groups := map[int][]FunctionReports{}
+	for i, rc := range reportContent {
+		root := find(i)
+		groups[root] = append(groups[root], rc)
+	}
+	for root, deployments := range groups {
+		ls := LogicalService{Name: reportContent[root].Name, Deployments: deployments}
+		locations := ls.distinct(func(fr FunctionReports) string { return fr.Account + "/" + fr.Region })
+		if len(locations) < 2 {
+			continue
+		}
+		services = append(services, ls)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].MonthlyCost() > services[j].MonthlyCost()
+	})
+	return services
+}
+
+func displayLogicalServices(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	services := findLogicalServices(reportContent)
+	if len(services) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Functions deployed to multiple accounts or regions")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Service", "Deployments", "Accounts", "Regions", "Invocations", "Monthly"}, "\t"))
+	for _, ls := range services {
+		fmt.Fprintln(tw, strings.Join([]string{
+			ls.Name,
+			fmt.Sprintf("%d", len(ls.Deployments)),
+			strings.Join(ls.distinct(FunctionReports.DisplayAccount), ","),
+			strings.Join(ls.distinct(func(fr FunctionReports) string { return fr.Region }), ","),
+			fmt.Sprintf("%d", ls.Invocations()),
+			format.Money(ls.MonthlyCost(), reportCurrency(ls.Deployments), 2),
+		}, "\t"))
+	}
+	tw.Flush()
+}
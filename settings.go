@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// Settings are loaded from the JSON file passed in the -config flag.
+type Settings struct {
+	// AccountNames maps account IDs to friendly names, e.g. "123456789012": "prod-payments".
+	// Names in this map take precedence over IAM account aliases.
+	AccountNames map[string]string `json:"accountNames"`
+	// RequiredTags are tags that every function must have, e.g. for cost allocation.
+	// The -required-tags flag overrides this setting.
+	RequiredTags []string `json:"requiredTags"`
+	// WorkloadTag is the tag used to identify latency insensitive functions, which
+	// have the value "batch" or "async". Defaults to "lambdacost:workload".
+	WorkloadTag string `json:"workloadTag"`
+	// Collectors maps function names to the collector used to get their log data,
+	// overriding the -collector flag, e.g. "orders-api": "insights".
+	Collectors map[string]string `json:"collectors"`
+	// Recommenders and DisabledRecommenders control which recommendations are made,
+	// and are overridden by the -recommenders and -disable-recommenders flags.
+	Recommenders         []string `json:"recommenders"`
+	DisabledRecommenders []string `json:"disabledRecommenders"`
+	// OwnerTag is the tag that holds the team that owns a function. Defaults to "team".
+	OwnerTag string `json:"ownerTag"`
+	// OwnerPattern is a regular expression used to find the owner from the function name, for
+	// functions without the owner tag, e.g. "^(?P<owner>[a-z]+)-".
+	OwnerPattern string `json:"ownerPattern"`
+	// DurationUnit is the unit durations are displayed in: "auto", "ms" or "s". The
+	// -duration-unit flag overrides this setting.
+	DurationUnit string `json:"durationUnit"`
+	// DecimalSeparator is the decimal separator for displayed numbers, "." or ",". The
+	// -decimal-separator flag overrides this setting.
+	DecimalSeparator string `json:"decimalSeparator"`
+	// SyntheticMarkers are text logged while handling synthetic requests, e.g. "WarmUp", in
+	// addition to the -synthetic-marker flag.
+	SyntheticMarkers []string `json:"syntheticMarkers"`
+	// RegionPrices override the built-in Lambda prices for each region.
+	RegionPrices map[string]RegionPrice `json:"regionPrices"`
+}
+
+func loadSettings(fileName string) (s Settings, err error) {
+	if fileName == "" {
+		return
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		err = fmt.Errorf("loadSettings: could not open %q: %w", fileName, err)
+		return
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&s)
+	if err != nil {
+		err = fmt.Errorf("loadSettings: could not decode %q: %w", fileName, err)
+		return
+	}
+	return
+}
+
+// mustLoadSettings loads the settings file used by a command, and applies its region prices. It
+// exits if the file can't be loaded.
+func mustLoadSettings(log *zap.Logger, fileName string) Settings {
+	settings, err := loadSettings(fileName)
+	if err != nil {
+		log.Fatal("could not load settings", zap.Error(err))
+	}
+	setRegionPrices(settings.RegionPrices)
+	return settings
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// shard is a deterministic partition of functions, so that parallel runs can each collect
+// a slice of a large estate, and the results can be merged later.
+type shard struct {
+	// Index is 1-based, e.g. 3 in "3/8".
+	Index int
+	Count int
+}
+
+// parseShard parses a shard in the form "index/count", e.g. "3/8". An empty value returns
+// a shard that contains all functions.
+func parseShard(v string) (s shard, err error) {
+	if v == "" {
+		return shard{Index: 1, Count: 1}, nil
+	}
+	parts := strings.SplitN(v, "/", 2)
+	if len(parts) != 2 {
+		return s, fmt.Errorf("invalid shard %q: expected index/count, e.g. 3/8", v)
+	}
+	if s.Index, err = strconv.Atoi(parts[0]); err != nil {
+		return s, fmt.Errorf("invalid shard %q: %w", v, err)
+	}
+	if s.Count, err = strconv.Atoi(parts[1]); err != nil {
+		return s, fmt.Errorf("invalid shard %q: %w", v, err)
+	}
+	if s.Count < 1 || s.Index < 1 || s.Index > s.Count {
+		return s, fmt.Errorf("invalid shard %q: index must be between 1 and the count", v)
+	}
+	return s, nil
+}
+
+// Contains returns true if the function belongs to the shard. Functions are assigned to shards
+// using a hash of the account, region and function name, so the assignment doesn't depend on
+// the order that functions are listed in.
+func (s shard) Contains(account, region, functionName string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(account + "/" + region + "/" + functionName))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
+
+func (s shard) String() string {
+	return fmt.Sprintf("shard-%d-of-%d", s.Index, s.Count)
+}
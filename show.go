@@ -0,0 +1,350 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func showCmd(args []string) {
+	cmd := flag.NewFlagSet("show", flag.ExitOnError)
+	af := newAnalysisFlags(cmd)
+	region := cmd.String("region", "", "Only show the function in this region, if it's deployed to more than one")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost show [flags] <function> <file.json>")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() != 2 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	settings := mustLoadSettings(log, *af.config)
+	opts, err := af.reportOptions(settings)
+	if err != nil {
+		log.Fatal("invalid report options", zap.Error(err))
+	}
+	functionReports, err := readFunctionReports(cmd.Arg(1))
+	if err != nil {
+		log.Fatal("could not read report data", zap.Error(err))
+	}
+	functionReports, _, _ = separateSynthetic(functionReports, opts.SyntheticMaxDuration)
+	var found bool
+	for _, fr := range functionReports {
+		if fr.Name != cmd.Arg(0) || (*region != "" && fr.Region != *region) {
+			continue
+		}
+		if found {
+			fmt.Println()
+		}
+		found = true
+		displayFunction(os.Stdout, fr, opts)
+	}
+	if !found {
+		log.Fatal("function not found", zap.String("functionName", cmd.Arg(0)))
+	}
+}
+
+// displayFunction prints everything known about a single function.
+func displayFunction(w io.Writer, fr FunctionReports, opts reportOptions) {
+	section := func(title string) {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, title)
+		fmt.Fprintln(w)
+	}
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	row := func(k string, v interface{}) {
+		fmt.Fprintf(tw, "  %s\t%v\n", k, v)
+	}
+
+	fmt.Fprintf(w, "%s (%s, %s)\n", fr.Name, fr.DisplayAccount(), fr.Region)
+	section("Configuration")
+	if fr.Qualifier != "" {
+		row("Qualifier", fmt.Sprintf("%s (version %s)", fr.Qualifier, fr.Version))
+	}
+	row("Architecture", fr.Architecture)
+	row("Runtime", fr.Runtime)
+	row("Package type", fr.PackageType)
+	row("Code size", fmt.Sprintf("%.1f MB", float64(fr.CodeSize)/1024/1024))
+	row("Memory", fmt.Sprintf("%d MB", fr.MemoryAssigned()))
+	if configured, _, ok := fr.MemoryMismatch(); ok {
+		row("Configured memory", fmt.Sprintf("%d MB", configured))
+	}
+	if segments := fr.MemorySegments(); len(segments) > 1 {
+		row("Memory during window", formatMemorySegments(segments))
+	}
+	row("Timeout", fr.Timeout)
+	row("SnapStart", fr.SnapStart)
+	row("Provisioned concurrency", fr.ProvisionedConcurrency)
+	if len(fr.ProvisionedConcurrencySchedules) > 0 {
+		row("Provisioned concurrency schedule", describeProvisionedConcurrencySchedules(fr.ProvisionedConcurrencySchedules))
+		row("Average provisioned concurrency", fmt.Sprintf("%.1f", fr.AvgProvisionedConcurrency()))
+	}
+	for _, l := range fr.Layers {
+		row("Layer", l.ARN)
+	}
+	var tagKeys []string
+	for k := range fr.Tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		row("Tag", k+"="+fr.Tags[k])
+	}
+	if fr.LogGroupClass != "" {
+		row("Log group class", fr.LogGroupClass)
+	}
+	if retention := fr.LogRetention(); retention != "" {
+		row("Log retention", retention)
+	}
+	if fr.LogStoredBytes != nil {
+		row("Log stored", formatBytes(float64(*fr.LogStoredBytes)))
+	}
+	if t, ok := fr.MemoryTrend(); ok {
+		trend := fmt.Sprintf("%+.1f MB/day, fit %.2f", t.MBPerDay, t.RSquared)
+		if t.Increasing() {
+			trend += ", possible memory leak"
+		}
+		row("Memory trend", trend)
+	}
+	row("Data quality", fr.DataQuality(opts.InvocationTolerance))
+	workload := fr.Workload()
+	if share, source, ok := fr.CPUShare(); ok {
+		workload += fmt.Sprintf(" (%s of duration using CPU, from %s)", opts.Format.Percent(share*100, 0), source)
+	}
+	row("Workload", workload)
+	if !fr.Start.IsZero() {
+		row("Window", fmt.Sprintf("%s to %s", opts.Format.Time(fr.Start), opts.Format.Time(fr.End)))
+	}
+	tw.Flush()
+	if fr.LogGroupMissing {
+		section("No log data")
+		return
+	}
+
+	section("Cost")
+	requests, compute := fr.CostBreakdown(fr.Architecture, 0)
+	row("Daily", opts.Format.Money(fr.DailyCost(), fr.Currency(), 5))
+	row("Monthly", opts.Format.Money(fr.DailyCost()*30, fr.Currency(), 2))
+	row("Monthly requests", opts.Format.Money(requests/fr.Days()*30, fr.Currency(), 2))
+	row("Monthly compute", opts.Format.Money(compute/fr.Days()*30, fr.Currency(), 2))
+	row("Monthly savings", opts.Format.MoneyMargin(fr.MonthlySavings(), fr.MonthlySavingsMargin(), fr.Currency(), 2))
+	tw.Flush()
+
+	section("Invocations")
+	row("Requests", fr.UniqueRequests())
+	row("Executions (billed)", fr.Executions())
+	row("Daily invocations", fmt.Sprintf("%.0f", fr.DailyInvocations()))
+	if fr.MetricInvocations != nil {
+		row("Invocations metric", *fr.MetricInvocations)
+	}
+	row("Cold starts", fmt.Sprintf("%d (%s)", fr.ColdStarts(), opts.Format.Percent(fr.ColdStartRate()*100, 1)))
+	row("Avg init duration", opts.Format.Duration(fr.AvgInitDuration()))
+	if initMB, ok := fr.InitMemoryUsed(); ok {
+		row("Max cold start memory used", fmt.Sprintf("%d MB (warm %d MB)", initMB, fr.WarmMemoryUsed()))
+	}
+	row("Avg warm duration", opts.Format.Duration(fr.AvgWarmDuration()))
+	row("Avg cold duration", opts.Format.Duration(fr.AvgColdDuration()))
+	row("Timeouts", fr.Timeouts())
+	tw.Flush()
+
+	if utilisation, ok := fr.CPUUtilisation(); ok {
+		vcpus, _ := fr.AvgVCPUs()
+		section("Lambda Insights")
+		row("Avg vCPUs", opts.Format.Decimal(vcpus, 2))
+		row("CPU utilisation", opts.Format.Percent(utilisation*100, 0))
+		row("CPU-bound", fr.CPUBound())
+		row("Network", formatBytes(float64(fr.LambdaInsights.NetworkBytes)))
+		tw.Flush()
+	}
+
+	section("Percentiles")
+	fmt.Fprintln(tw, "  \tp50\tp90\tp99\tmax")
+	fmt.Fprintf(tw, "  Duration\t%s\t%s\t%s\t%s\n", opts.Format.Duration(fr.DurationPercentile(50)), opts.Format.Duration(fr.DurationPercentile(90)), opts.Format.Duration(fr.DurationPercentile(99)), opts.Format.Duration(fr.MaxDuration()))
+	fmt.Fprintf(tw, "  Billed duration\t%s\t%s\t%s\t%s\n", opts.Format.Duration(fr.BilledDurationPercentile(50)), opts.Format.Duration(fr.BilledDurationPercentile(90)), opts.Format.Duration(fr.BilledDurationPercentile(99)), opts.Format.Duration(fr.MaxBilledDuration()))
+	fmt.Fprintf(tw, "  Memory used (MB)\t%d\t%d\t%d\t%d\n", fr.MemoryUsedPercentile(50), fr.MemoryUsedPercentile(90), fr.MemoryUsedPercentile(99), fr.MaxMemoryUsed())
+	tw.Flush()
+
+	if counts, ok := fr.InvocationsByHourOfDay(); ok {
+		section("Invocations by hour of day (UTC)")
+		displayHistogram(w, counts)
+	}
+
+	recs, acknowledged := opts.Recommenders.recommend(fr)
+	if len(recs) > 0 {
+		section("Recommendations")
+		for _, rec := range recs {
+			savings := opts.Format.MoneyMargin(rec.MonthlySavings, rec.MonthlySavingsMargin, fr.Currency(), 2) + "/month"
+			if rec.Confidence != "" {
+				savings += ", " + rec.Confidence + " confidence"
+			}
+			fmt.Fprintf(w, "  %s: %s (%s)\n", rec.Type, rec.Description, savings)
+			if rec.Rationale != "" {
+				fmt.Fprintf(w, "    %s\n", rec.Rationale)
+			}
+		}
+	}
+	if len(acknowledged) > 0 {
+		section("Acknowledged recommendations")
+		for _, a := range acknowledged {
+			fmt.Fprintf(w, "  %s: %s (until %s)\n", a.Recommendation.Type, a.Recommendation.Description, a.Acknowledgement.Until.Format("2006-01-02"))
+			fmt.Fprintf(w, "    %s\n", a.Acknowledgement.Reason)
+		}
+	}
+	if notes := fr.Notes(opts.Format); len(notes) > 0 {
+		section("Notes")
+		for _, n := range notes {
+			fmt.Fprintf(w, "  %s\n", n)
+		}
+	}
+	if anomalies := fr.HourlyAnomalies(); len(anomalies) > 0 {
+		section("Recent anomalies")
+		if len(anomalies) > showMaxAnomalies {
+			anomalies = anomalies[len(anomalies)-showMaxAnomalies:]
+		}
+		for _, a := range anomalies {
+			fmt.Fprintf(w, "  %s  %s\n", a.Hour.UTC().Format("2006-01-02 15:00"), a.Description)
+		}
+	}
+	if len(fr.Warnings) > 0 || len(fr.Errors) > 0 {
+		section("Warnings and errors")
+		for _, warning := range fr.Warnings {
+			fmt.Fprintf(w, "  %s\n", warning)
+		}
+		for _, e := range fr.Errors {
+			fmt.Fprintf(w, "  %s: %s (%d): %s\n", e.Kind, e.Operation, e.Count, e.Message)
+		}
+	}
+}
+
+// Width of the largest bar in a histogram.
+const histogramWidth = 40
+
+const showMaxAnomalies = 10
+
+func displayHistogram(w io.Writer, counts [24]int) {
+	var max int
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	for hour, c := range counts {
+		var bar string
+		if max > 0 {
+			bar = strings.Repeat("#", c*histogramWidth/max)
+		}
+		fmt.Fprintf(w, "  %02d:00 %-*s %d\n", hour, histogramWidth, bar, c)
+	}
+}
+
+// InvocationsByHourOfDay counts invocations by the hour of the day (UTC) they completed in. It
+// returns false if the reports don't have timestamps, e.g. because they were collected by an older version.
+func (fr FunctionReports) InvocationsByHourOfDay() (counts [24]int, ok bool) {
+	for _, r := range fr.Reports {
+		if r.Timestamp.IsZero() {
+			continue
+		}
+		counts[r.Timestamp.UTC().Hour()]++
+		ok = true
+	}
+	return counts, ok
+}
+
+// Anomaly is an hour where the function behaved unusually.
+type Anomaly struct {
+	Hour        time.Time
+	Description string
+}
+
+// Hours with an invocation count or average duration more than this many standard deviations
+// from the mean are anomalies.
+const anomalyStdDevs = 3
+
+// HourlyAnomalies returns the hours in the window where the invocation count, or the average
+// duration, was unusually high or low, in time order.
+func (fr FunctionReports) HourlyAnomalies() (anomalies []Anomaly) {
+	type bucket struct {
+		count    int
+		duration time.Duration
+	}
+	buckets := map[time.Time]*bucket{}
+	for _, r := range fr.Reports {
+		if r.Timestamp.IsZero() {
+			continue
+		}
+		hour := r.Timestamp.UTC().Truncate(time.Hour)
+		b, ok := buckets[hour]
+		if !ok {
+			b = &bucket{}
+			buckets[hour] = b
+		}
+		b.count++
+		b.duration += r.Duration
+	}
+	if len(buckets) == 0 || fr.Start.IsZero() {
+		return nil
+	}
+	// Include hours without invocations, so that drops in traffic are detected. Partial hours at
+	// the start and end of the window are excluded.
+	var hours []time.Time
+	for h := fr.Start.UTC().Truncate(time.Hour); h.Before(fr.End); h = h.Add(time.Hour) {
+		if h.Before(fr.Start) || h.Add(time.Hour).After(fr.End) {
+			continue
+		}
+		hours = append(hours, h)
+		if _, ok := buckets[h]; !ok {
+			buckets[h] = &bucket{}
+		}
+	}
+	var counts, durations []float64
+	for _, h := range hours {
+		b := buckets[h]
+		counts = append(counts, float64(b.count))
+		if b.count > 0 {
+			durations = append(durations, float64(b.duration)/float64(b.count))
+		}
+	}
+	countMean, countStdDev := meanStdDev(counts)
+	durationMean, durationStdDev := meanStdDev(durations)
+	for _, h := range hours {
+		b := buckets[h]
+		var descriptions []string
+		if countStdDev > 0 && math.Abs(float64(b.count)-countMean) > anomalyStdDevs*countStdDev {
+			descriptions = append(descriptions, fmt.Sprintf("%d invocations, compared to an average of %.0f", b.count, countMean))
+		}
+		if b.count > 0 && durationStdDev > 0 {
+			avg := float64(b.duration) / float64(b.count)
+			if math.Abs(avg-durationMean) > anomalyStdDevs*durationStdDev {
+				descriptions = append(descriptions, fmt.Sprintf("average duration %v, compared to %v", time.Duration(avg).Round(time.Millisecond), time.Duration(durationMean).Round(time.Millisecond)))
+			}
+		}
+		if len(descriptions) > 0 {
+			anomalies = append(anomalies, Anomaly{Hour: h, Description: strings.Join(descriptions, ", ")})
+		}
+	}
+	return anomalies
+}
+
+func meanStdDev(values []float64) (mean, stdDev float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		stdDev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stdDev / float64(len(values)))
+}
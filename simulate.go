@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// MemoryMapEntry is the proposed configuration of a function. Fields that aren't set keep the
+// function's current configuration.
+type MemoryMapEntry struct {
+	Memory       int64        `yaml:"memory"`
+	Architecture Architecture `yaml:"architecture"`
+}
+
+// MemoryMap is a proposed change set, keyed by function name, e.g.:
+//
+//	orders-api:
+//	  memory: 512
+//	  architecture: arm64
+type MemoryMap map[string]MemoryMapEntry
+
+// readMemoryMap reads and validates a memory map file.
+func readMemoryMap(fileName string) (mm MemoryMap, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("readMemoryMap: could not open %q: %w", fileName, err)
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err = dec.Decode(&mm); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("readMemoryMap: could not decode %q: %w", fileName, err)
+	}
+	if len(mm) == 0 {
+		return nil, fmt.Errorf("readMemoryMap: %q doesn't contain any functions", fileName)
+	}
+	for name, e := range mm {
+		if e.Memory == 0 && e.Architecture == "" {
+			return nil, fmt.Errorf("readMemoryMap: %s: memory or architecture must be set", name)
+		}
+		if e.Memory != 0 && (e.Memory < 128 || e.Memory > 10240) {
+			return nil, fmt.Errorf("readMemoryMap: %s: memory must be between 128 and 10240 MB, got %d", name, e.Memory)
+		}
+		if e.Architecture != "" && !e.Architecture.Known() {
+			return nil, fmt.Errorf("readMemoryMap: %s: unknown architecture %q", name, e.Architecture)
+		}
+	}
+	return mm, nil
+}
+
+// SimulatedChange is a function's report data before and after the proposed change.
+type SimulatedChange struct {
+	Before, After FunctionReports
+	// Warnings are the risks of making the change, e.g. out of memory errors.
+	Warnings []string
+}
+
+// simulate returns the report data as if the functions in the memory map had the proposed memory
+// size and architecture. Durations are projected from the CPU share when memory changes, and are
+// assumed to be the same on both architectures. Functions in the map that aren't in the report data
+// are returned as missing.
+func simulate(functionReports []FunctionReports, mm MemoryMap) (simulated []FunctionReports, changes []SimulatedChange, missing []string) {
+	found := map[string]struct{}{}
+	simulated = make([]FunctionReports, len(functionReports))
+	for i, fr := range functionReports {
+		simulated[i] = fr
+		e, ok := mm[fr.Name]
+		if !ok {
+			continue
+		}
+		found[fr.Name] = struct{}{}
+		after := fr
+		if e.Memory != 0 {
+			after = fr.withMemorySize(e.Memory)
+			after.MemorySize = e.Memory
+		}
+		if e.Architecture != "" {
+			after.Architecture = e.Architecture
+		}
+		simulated[i] = after
+		changes = append(changes, SimulatedChange{
+			Before:   fr,
+			After:    after,
+			Warnings: simulationWarnings(fr, after),
+		})
+	}
+	for name := range mm {
+		if _, ok := found[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return simulated, changes, missing
+}
+
+// simulationWarnings returns the risks of changing the function's configuration.
+func simulationWarnings(before, after FunctionReports) (warnings []string) {
+	if after.MemorySize != before.MemoryAssigned() {
+		if used := before.MaxMemoryUsed(); used > after.MemorySize {
+			warnings = append(warnings, fmt.Sprintf("max memory used is %d MB, out of memory errors are likely", used))
+		}
+		if projected, risk := before.TimeoutRisk(after.MemorySize); risk {
+			warnings = append(warnings, fmt.Sprintf("projected p99 duration of %v is close to the %v timeout", projected.Round(time.Millisecond), before.Timeout))
+		}
+	}
+	if after.Architecture == ArchitectureARM64 && before.Architecture != ArchitectureARM64 {
+		if replacement, unsupported := arm64UnsupportedRuntimes[before.Runtime]; unsupported {
+			warnings = append(warnings, fmt.Sprintf("%s doesn't support arm64, upgrade to %s first", before.Runtime, replacement))
+		}
+	}
+	return warnings
+}
+
+func displaySimulation(w io.Writer, changes []SimulatedChange, before, after []FunctionReports, opts reportOptions) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Simulation: %d functions changed\n", len(changes))
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Name", "Region", "RAM Assigned", "Architecture", "Monthly Before", "Monthly After", "Change", "p99 Before", "p99 After", "Warnings"}, "\t"))
+	for _, c := range changes {
+		monthlyBefore, monthlyAfter := c.Before.DailyCost()*30, c.After.DailyCost()*30
+		fmt.Fprintln(tw, strings.Join([]string{
+			c.Before.Name,
+			c.Before.Region,
+			fmt.Sprintf("%d MB → %d MB", c.Before.MemoryAssigned(), c.After.MemorySize),
+			fmt.Sprintf("%s → %s", c.Before.Architecture, c.After.Architecture),
+			opts.Format.Money(monthlyBefore, c.Before.Currency(), 2),
+			opts.Format.Money(monthlyAfter, c.Before.Currency(), 2),
+			opts.Format.SignedDecimal(monthlyAfter-monthlyBefore, 2),
+			opts.Format.Duration(c.Before.DurationPercentile(99).Round(time.Millisecond)),
+			opts.Format.Duration(c.After.DurationPercentile(99).Round(time.Millisecond)),
+			strings.Join(c.Warnings, "; "),
+		}, "\t"))
+	}
+	tw.Flush()
+	var totalBefore, totalAfter float64
+	for _, fr := range before {
+		totalBefore += fr.DailyCost() * 30
+	}
+	for _, fr := range after {
+		totalAfter += fr.DailyCost() * 30
+	}
+	currency := reportCurrency(before)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Monthly cost: %s, from %s to %s\n", opts.Format.SignedDecimal(totalAfter-totalBefore, 2), opts.Format.Money(totalBefore, currency, 2), opts.Format.Money(totalAfter, currency, 2))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Durations are projected from the share of CPU allocated at each memory size, and are assumed to be the same on arm64 and x86_64. Test the change before applying it.")
+}
+
+func simulateCmd(args []string) {
+	cmd := flag.NewFlagSet("simulate", flag.ExitOnError)
+	of := newOutputFlags(cmd)
+	memoryMap := cmd.String("memory-map", "", "Path to a YAML file of the proposed memory size and architecture of each function, e.g. changes.yaml")
+	cmd.Usage = func() {
+		fmt.Fprintln(cmd.Output(), "usage: lambdacost simulate -memory-map changes.yaml [flags] <file.json>")
+		cmd.PrintDefaults()
+	}
+	cmd.Parse(args)
+	log := newLog()
+	if cmd.NArg() != 1 || *memoryMap == "" {
+		cmd.Usage()
+		os.Exit(1)
+	}
+	settings := mustLoadSettings(log, *of.config)
+	mm, err := readMemoryMap(*memoryMap)
+	if err != nil {
+		log.Fatal("could not read memory map", zap.Error(err))
+	}
+	functionReports, err := readFunctionReports(cmd.Arg(0))
+	if err != nil {
+		log.Fatal("could not read report data", zap.Error(err))
+	}
+	simulated, changes, missing := simulate(functionReports, mm)
+	if len(missing) > 0 {
+		log.Fatal("functions in the memory map aren't in the report data", zap.Strings("functions", missing))
+	}
+	opts, err := of.reportOptions(settings)
+	if err != nil {
+		log.Fatal("invalid report options", zap.Error(err))
+	}
+	if err := opts.Formatter.Format(os.Stdout, simulated, opts); err != nil {
+		log.Fatal("could not write report", zap.Error(err))
+	}
+	displaySimulation(extrasWriter(opts.Output), changes, functionReports, simulated, opts)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-h/lambdacost/pricing"
+)
+
+const recommendationSnapStart = "snapStart"
+
+func init() {
+	registerRecommender(recommendationSnapStart, func(settings Settings) Recommender {
+		format := settings.displayFormat()
+		return RecommenderFunc(func(fr FunctionReports) (Recommendation, bool) {
+			return fr.snapStartRecommendation(format)
+		})
+	})
+}
+
+// Restoring a snapshot is assumed to take this proportion of the original init duration.
+const snapStartRestoreRatio = 0.1
+
+// Functions with at least this proportion of cold starts are considered for SnapStart.
+const snapStartMinColdStartRate = 0.01
+
+// snapStartRuntime returns true if SnapStart supports the runtime, and whether it's charged for.
+func snapStartRuntime(runtime string) (supported, charged bool) {
+	switch {
+	case strings.HasPrefix(runtime, "java"):
+		return runtime != "java8" && runtime != "java8.al2", false
+	case strings.HasPrefix(runtime, "python3."):
+		minor, err := strconv.Atoi(strings.TrimPrefix(runtime, "python3."))
+		return err == nil && minor >= 12, true
+	case strings.HasPrefix(runtime, "dotnet"):
+		major, err := strconv.Atoi(strings.TrimPrefix(runtime, "dotnet"))
+		return err == nil && major >= 8, true
+	}
+	return false, false
+}
+
+// snapStartRecommendation estimates the effect of enabling SnapStart on functions with
+// frequent, slow cold starts. Init duration is billed, so reducing it to a restore reduces
+// cost, less any snapshot cache and restore charges.
+func (fr FunctionReports) snapStartRecommendation(format displayFormat) (rec Recommendation, ok bool) {
+	supported, charged := snapStartRuntime(fr.Runtime)
+	if !supported || fr.SnapStart || fr.ColdStartRate() < snapStartMinColdStartRate {
+		return
+	}
+	avgInit := fr.AvgInitDuration()
+	if avgInit < slowColdStartThreshold {
+		return
+	}
+	avgRestore := time.Duration(float64(avgInit) * snapStartRestoreRatio)
+	gb := float64(fr.MemoryAssigned()) / 1024.0
+	monthlyColdStarts := float64(fr.ColdStarts()) / fr.Days() * 30
+	savedSeconds := monthlyColdStarts * (avgInit - avgRestore).Seconds()
+	savings := savedSeconds * gb * priceForRegion(fr.Region).GBSecond(fr.Architecture)
+	var charges float64
+	if charged {
+		// SnapStart for Java has no additional charge, but Python and .NET functions pay to cache
+		// the snapshot, and for each restore.
+		charges = pricing.Charges(priceForRegion(fr.Region), fr.Architecture, pricing.Quantities{
+			SnapStartCacheGBSeconds: gb * 30 * 24 * 60 * 60,
+			SnapStartRestoreGB:      monthlyColdStarts * gb,
+		}).SnapStart
+	}
+	description := fmt.Sprintf("enable SnapStart to reduce cold starts from %s to around %s", format.Duration(avgInit.Round(time.Millisecond)), format.Duration(avgRestore.Round(time.Millisecond)))
+	if charges > 0 {
+		description += fmt.Sprintf(", including %s/month snapshot charges", format.Money(charges, fr.Currency(), 2))
+	}
+	savings -= charges
+	if savings < 0 {
+		description += fmt.Sprintf(", costs %s/month more", format.Money(-savings, fr.Currency(), 2))
+		savings = 0
+	}
+	return Recommendation{
+		Type:           recommendationSnapStart,
+		Description:    description,
+		MonthlySavings: savings,
+	}, true
+}
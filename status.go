@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runStart is when the process started, used to report the duration of the run.
+var runStart = time.Now()
+
+// RunStatus is a small summary of a run, written on exit with -status-out, so that wrappers such
+// as CI jobs or Step Functions tasks can branch on the result without parsing logs.
+type RunStatus struct {
+	// Passed is false if the run exits with a non-zero exit code.
+	Passed          bool      `json:"passed"`
+	ExitCode        int       `json:"exitCode"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Functions       int       `json:"functions"`
+	// FunctionsWithErrors is the number of functions with errors during collection.
+	FunctionsWithErrors int `json:"functionsWithErrors"`
+	// Partial is true if the deadline expired before collection was complete, including when
+	// cached report data from such a run is used. PartialFunctions is the number of functions whose
+	// collection was stopped by the deadline.
+	Partial          bool    `json:"partial"`
+	PartialFunctions int     `json:"partialFunctions"`
+	Recommendations  int     `json:"recommendations"`
+	MonthlySavings   float64 `json:"monthlySavings"`
+	BudgetViolations int     `json:"budgetViolations"`
+	Regressions      int     `json:"regressions"`
+	NewFunctions     int     `json:"newFunctions"`
+	// RegionErrors are the regions that were skipped because of an error, e.g. when regions are
+	// discovered with -discover-regions.
+	RegionErrors []RegionError `json:"regionErrors,omitempty"`
+}
+
+// RegionError is an error that stopped a region from being collected. The other regions are
+// still collected.
+type RegionError struct {
+	Region string `json:"region"`
+	Error  string `json:"error"`
+}
+
+func newRunStatus(functionReports []FunctionReports, recommenders *Recommenders) (s RunStatus) {
+	s.Functions = len(functionReports)
+	for _, fr := range functionReports {
+		if len(fr.Errors) > 0 {
+			s.FunctionsWithErrors++
+		}
+		for _, rec := range recommenders.Recommend(fr) {
+			s.Recommendations++
+			s.MonthlySavings += rec.MonthlySavings
+		}
+	}
+	s.PartialFunctions = countDeadlineExceeded(functionReports)
+	s.Partial = s.PartialFunctions > 0
+	return s
+}
+
+// writeRunStatus sets the exit code and duration of the run, and writes the status.
+func writeRunStatus(fileName string, s RunStatus, now time.Time) (err error) {
+	s.ExitCode = 0
+	if !s.Passed {
+		s.ExitCode = 1
+	}
+	s.Start = runStart.UTC()
+	s.End = now.UTC()
+	s.DurationSeconds = now.Sub(runStart).Seconds()
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("writeRunStatus: could not create %q: %w", fileName, err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(s); err != nil {
+		return fmt.Errorf("writeRunStatus: could not encode status: %w", err)
+	}
+	return nil
+}
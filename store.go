@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func readFunctionReports(fileName string) (functionReports []FunctionReports, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("readFunctionReports: could not open %q: %w", fileName, err)
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&functionReports)
+	if err != nil {
+		return nil, fmt.Errorf("readFunctionReports: could not decode %q: %w", fileName, err)
+	}
+	// Data written before the averages were stored has to be replayed once.
+	for i := range functionReports {
+		if _, ok := functionReports[i].provisionedConcurrencyAverages(); !ok {
+			functionReports[i].setProvisionedConcurrencyAverages()
+		}
+	}
+	return functionReports, nil
+}
+
+func writeFunctionReports(fileName string, functionReports []FunctionReports) (err error) {
+	err = writeFileAtomic(fileName, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(functionReports)
+	})
+	if err != nil {
+		return fmt.Errorf("writeFunctionReports: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes to a temporary file in the same directory, which replaces fileName once
+// it's complete, so that a run that's stopped while writing doesn't leave a truncated file that
+// can't be read.
+func writeFileAtomic(fileName string, write func(w io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file for %q: %w", fileName, err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err = write(f); err != nil {
+		return fmt.Errorf("could not write %q: %w", fileName, err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("could not write %q: %w", fileName, err)
+	}
+	// CreateTemp only allows the owner to read the file.
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("could not set permissions of %q: %w", fileName, err)
+	}
+	if err = os.Rename(f.Name(), fileName); err != nil {
+		return fmt.Errorf("could not replace %q: %w", fileName, err)
+	}
+	return nil
+}
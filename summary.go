@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Summary is a compact rollup of a report, for use by dashboards.
+type Summary struct {
+	GeneratedAt    time.Time `json:"generatedAt"`
+	FunctionCount  int       `json:"functionCount"`
+	Invocations    int       `json:"invocations"`
+	DailyCost      float64   `json:"dailyCost"`
+	MonthlyCost    float64   `json:"monthlyCost"`
+	MonthlySavings float64   `json:"monthlySavings"`
+	// MonthlySavingsMargin is the margin of MonthlySavings at the 95% confidence level, combining
+	// the margins of each function as independent errors.
+	MonthlySavingsMargin float64 `json:"monthlySavingsMargin"`
+	// Currency is the currency of the costs, e.g. USD, or "mixed" if the report includes functions
+	// in partitions with different currencies.
+	Currency string `json:"currency"`
+	// MonthlyMemorySavings and MonthlyArchitectureSavings are the savings available from each change
+	// on its own. Their sum is not the same as MonthlySavings, which applies both changes together.
+	MonthlyMemorySavings       float64 `json:"monthlyMemorySavings"`
+	MonthlyArchitectureSavings float64 `json:"monthlyArchitectureSavings"`
+	// Categories is the count of functions in each category, e.g. "arm64", "noLogData".
+	Categories map[string]int `json:"categories"`
+	// Recommendations totals the recommendations made, by type.
+	Recommendations map[string]RecommendationTotal `json:"recommendations"`
+	// DataQuality is the count of functions by data quality, e.g. "complete", "partial".
+	DataQuality map[string]int `json:"dataQuality"`
+	// Workloads is the count of functions by workload class, e.g. "cpu", "io", "unknown".
+	Workloads map[string]int `json:"workloads"`
+	// Errors is the count of collection errors, by kind, e.g. "throttle". If there are
+	// any errors, the report data may be incomplete.
+	Errors map[string]int `json:"errors,omitempty"`
+	// MonthlyCostByTrigger is the monthly cost of functions by trigger type, e.g. "api", "queue".
+	// It's only set if triggers were collected.
+	MonthlyCostByTrigger map[string]float64 `json:"monthlyCostByTrigger,omitempty"`
+	// Owners is the cost and savings of each team's functions, most expensive first. It's only set
+	// if functions have owners.
+	Owners []OwnerCost `json:"owners,omitempty"`
+	// BudgetViolations are functions whose monthly cost is over the budget set by their
+	// lambdacost:budget-monthly tag.
+	BudgetViolations []BudgetViolation `json:"budgetViolations,omitempty"`
+	// NewFunctions are functions that aren't in the baseline, and cost more than the new function
+	// threshold. It's only set if a baseline is used.
+	NewFunctions []NewFunction `json:"newFunctions,omitempty"`
+	// DuplicateLogging are functions whose log groups may be part of duplicated logging pipelines.
+	DuplicateLogging []DuplicateLogging `json:"duplicateLogging,omitempty"`
+	// Consolidation are groups of tiny, rarely invoked functions in the same stack that could be
+	// consolidated into a single function.
+	Consolidation []ConsolidationGroup `json:"consolidation,omitempty"`
+	// ConfigDrift are the configuration changes to functions during the window.
+	ConfigDrift []ConfigDrift `json:"configDrift,omitempty"`
+	// ExtrapolationWarnings explain why the monthly costs, which are extrapolated from the window,
+	// may be misleading, e.g. because the window is mostly a weekend.
+	ExtrapolationWarnings []string `json:"extrapolationWarnings,omitempty"`
+	// SyntheticTraffic is the traffic excluded from the report as synthetic, e.g. warm-up pings.
+	SyntheticTraffic []SyntheticTraffic `json:"syntheticTraffic,omitempty"`
+	// Changes is the difference from the previous run's summary. It's only set if a previous
+	// summary is used.
+	Changes *SummaryChanges `json:"changes,omitempty"`
+	// Top is the most expensive functions, by monthly cost.
+	Top []SummaryFunction `json:"top"`
+}
+
+type RecommendationTotal struct {
+	Count          int     `json:"count"`
+	MonthlySavings float64 `json:"monthlySavings"`
+}
+
+type SummaryFunction struct {
+	Account                    string           `json:"account"`
+	Region                     string           `json:"region"`
+	Name                       string           `json:"name"`
+	Architecture               string           `json:"architecture"`
+	Invocations                int              `json:"invocations"`
+	MonthlyCost                float64          `json:"monthlyCost"`
+	MonthlySavings             float64          `json:"monthlySavings"`
+	MonthlySavingsMargin       float64          `json:"monthlySavingsMargin"`
+	MonthlyMemorySavings       float64          `json:"monthlyMemorySavings"`
+	MonthlyArchitectureSavings float64          `json:"monthlyArchitectureSavings"`
+	Recommendations            []Recommendation `json:"recommendations"`
+	// DataQuality is "complete", "sampled", "metricsEstimated" or "partial".
+	DataQuality string `json:"dataQuality"`
+}
+
+const summaryTopCount = 10
+
+// Summary categories.
+const (
+	categoryNoLogData           = "noLogData"
+	categoryIncomplete          = "incomplete"
+	categoryWithSavings         = "withSavings"
+	categoryOverProvisioned     = "memoryOverProvisioned"
+	categoryRequestDominated    = "requestDominated"
+	categoryWithErrors          = "withErrors"
+	categoryPreselectionSkipped = "preselectionSkipped"
+	categoryDeadlineExceeded    = "deadlineExceeded"
+	categoryMemoryMismatch      = "memoryMismatch"
+	categoryCPUBound            = "cpuBound"
+)
+
+func newSummary(reportContent []FunctionReports, opts reportOptions, now time.Time) (s Summary) {
+	s.GeneratedAt = now
+	s.Categories = map[string]int{}
+	s.Recommendations = map[string]RecommendationTotal{}
+	s.DataQuality = map[string]int{}
+	s.Workloads = map[string]int{}
+	s.FunctionCount = len(reportContent)
+	s.Currency = reportCurrency(reportContent)
+	s.ExtrapolationWarnings = extrapolationWarnings(reportContent, opts)
+	withLogData := make([]FunctionReports, 0, len(reportContent))
+	var marginSquares float64
+	for _, rc := range reportContent {
+		if len(rc.Errors) > 0 {
+			s.Categories[categoryWithErrors]++
+			if s.Errors == nil {
+				s.Errors = map[string]int{}
+			}
+			for _, e := range rc.Errors {
+				s.Errors[e.Kind] += e.Count
+			}
+		}
+		s.DataQuality[rc.DataQuality(opts.InvocationTolerance)]++
+		if rc.DeadlineExceeded {
+			s.Categories[categoryDeadlineExceeded]++
+		}
+		if rc.LogGroupMissing {
+			s.Categories[categoryNoLogData]++
+			continue
+		}
+		if rc.PreselectionSkipped {
+			s.Categories[categoryPreselectionSkipped]++
+			continue
+		}
+		withLogData = append(withLogData, rc)
+		if rc.Architecture != "" {
+			s.Categories[string(rc.Architecture)]++
+		}
+		if rc.Incomplete {
+			s.Categories[categoryIncomplete]++
+		}
+		recs, acknowledged := opts.Recommenders.recommend(rc)
+		savings, margin, memorySavings, architectureSavings := unacknowledgedSavings(rc, acknowledged)
+		if savings > 0 {
+			s.Categories[categoryWithSavings]++
+		}
+		if optimisedRAM, _ := rc.OptimisedCost(); optimisedRAM < rc.MemoryAssigned() {
+			s.Categories[categoryOverProvisioned]++
+		}
+		if rc.RequestDominated() {
+			s.Categories[categoryRequestDominated]++
+		}
+		if _, _, mismatch := rc.MemoryMismatch(); mismatch {
+			s.Categories[categoryMemoryMismatch]++
+		}
+		if rc.CPUBound() {
+			s.Categories[categoryCPUBound]++
+		}
+		s.Workloads[rc.Workload()]++
+		for _, rec := range recs {
+			total := s.Recommendations[rec.Type]
+			total.Count++
+			total.MonthlySavings += rec.MonthlySavings
+			s.Recommendations[rec.Type] = total
+		}
+		s.Invocations += len(rc.Reports)
+		s.DailyCost += rc.DailyCost()
+		s.MonthlySavings += savings
+		marginSquares += math.Pow(margin, 2)
+		s.MonthlyMemorySavings += memorySavings
+		s.MonthlyArchitectureSavings += architectureSavings
+		s.Top = append(s.Top, SummaryFunction{
+			Account:                    rc.DisplayAccount(),
+			Region:                     rc.Region,
+			Name:                       rc.Name,
+			Architecture:               string(rc.Architecture),
+			Invocations:                len(rc.Reports),
+			MonthlyCost:                rc.DailyCost() * 30,
+			MonthlySavings:             savings,
+			MonthlySavingsMargin:       margin,
+			MonthlyMemorySavings:       memorySavings,
+			MonthlyArchitectureSavings: architectureSavings,
+			Recommendations:            recs,
+			DataQuality:                rc.DataQuality(opts.InvocationTolerance),
+		})
+	}
+	s.MonthlyCost = s.DailyCost * 30
+	s.MonthlySavingsMargin = math.Sqrt(marginSquares)
+	s.Owners = costByOwner(withLogData, opts.Owners)
+	s.BudgetViolations, _ = findBudgetViolations(withLogData)
+	s.DuplicateLogging = findDuplicateLogging(withLogData)
+	s.ConfigDrift = findConfigDrift(withLogData)
+	s.Consolidation = findConsolidationGroups(withLogData)
+	s.NewFunctions = findNewFunctions(opts.Baseline, withLogData, opts.NewFunctionThreshold)
+	for _, tc := range costByTrigger(withLogData) {
+		if s.MonthlyCostByTrigger == nil {
+			s.MonthlyCostByTrigger = map[string]float64{}
+		}
+		s.MonthlyCostByTrigger[tc.Trigger] = tc.MonthlyCost
+	}
+	sort.SliceStable(s.Top, func(i, j int) bool {
+		return s.Top[i].MonthlyCost > s.Top[j].MonthlyCost
+	})
+	if len(s.Top) > summaryTopCount {
+		s.Top = s.Top[:summaryTopCount]
+	}
+	return s
+}
+
+// unacknowledgedSavings returns the function's monthly savings from the memory and architecture
+// changes whose recommendations aren't acknowledged, so that hidden recommendations don't count
+// towards the summary totals.
+func unacknowledgedSavings(fr FunctionReports, acknowledged []AcknowledgedRecommendation) (savings, margin, memory, architecture float64) {
+	var memoryAcknowledged, architectureAcknowledged bool
+	for _, a := range acknowledged {
+		switch a.Recommendation.Type {
+		case recommendationMemory:
+			memoryAcknowledged = true
+		case recommendationArchitecture:
+			architectureAcknowledged = true
+		}
+	}
+	if !memoryAcknowledged {
+		memory = fr.MonthlyMemorySavings()
+	}
+	if !architectureAcknowledged {
+		architecture = fr.MonthlyArchitectureSavings()
+	}
+	switch {
+	case memoryAcknowledged && architectureAcknowledged:
+		return 0, 0, 0, 0
+	case memoryAcknowledged:
+		return architecture, fr.SavingsMargin(architecture), memory, architecture
+	case architectureAcknowledged:
+		optimisedRAM, _ := fr.OptimisedCost()
+		margin = math.Min(memory, fr.SavingsMargin(memory)+fr.memoryProjectionMargin(fr.Architecture, optimisedRAM))
+		return memory, margin, memory, architecture
+	}
+	return fr.MonthlySavings(), fr.MonthlySavingsMargin(), memory, architecture
+}
+
+func writeSummary(fileName string, s Summary) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("writeSummary: could not create %q: %w", fileName, err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(s); err != nil {
+		return fmt.Errorf("writeSummary: could not encode summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummaryConsolidationExcludesFunctionsWithoutLogData(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	function := func(name string, logGroupMissing bool) FunctionReports {
+		fr := FunctionReports{
+			Name:            name,
+			Region:          "eu-west-1",
+			Architecture:    ArchitectureX86_64,
+			Runtime:         "python3.12",
+			MemorySize:      128,
+			CodeSize:        1024 * 1024,
+			Start:           start,
+			End:             start.Add(24 * time.Hour),
+			Tags:            map[string]string{tagCloudFormationStackName: "ops-tools"},
+			LogGroupMissing: logGroupMissing,
+		}
+		if !logGroupMissing {
+			fr.Reports = []Report{{RequestID: name, Timestamp: start, Duration: time.Second, BilledDuration: time.Second, MemorySize: 128, MaxMemoryUsed: 60}}
+		}
+		return fr
+	}
+	recommenders, err := newRecommenders(Settings{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	owners, err := newOwnerResolver("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reportContent := []FunctionReports{
+		function("ops-rotate-keys", false),
+		function("ops-snapshot-cleanup", false),
+		function("ops-tag-audit", false),
+		function("ops-unused", true),
+	}
+	s := newSummary(reportContent, reportOptions{Recommenders: recommenders, Owners: owners}, start)
+	if len(s.Consolidation) != 1 {
+		t.Fatalf("expected one consolidation group, got %+v", s.Consolidation)
+	}
+	expected := []string{"ops-rotate-keys", "ops-snapshot-cleanup", "ops-tag-audit"}
+	if !reflect.DeepEqual(s.Consolidation[0].Functions, expected) {
+		t.Errorf("expected the group to contain %v, got %v", expected, s.Consolidation[0].Functions)
+	}
+	withLogData, _, _ := splitLogData(reportContent)
+	if displayed := findConsolidationGroups(withLogData); !reflect.DeepEqual(displayed, s.Consolidation) {
+		t.Errorf("expected the summary to match the report, got %+v and %+v", s.Consolidation, displayed)
+	}
+}
+
+func TestSummaryExcludesAcknowledgedSavings(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fr := FunctionReports{
+		Name:         "orders-api",
+		Region:       "eu-west-1",
+		Architecture: ArchitectureX86_64,
+		Runtime:      "python3.12",
+		MemorySize:   2048,
+		Start:        start,
+		End:          start.Add(24 * time.Hour),
+	}
+	for i := 0; i < 1000; i++ {
+		fr.Reports = append(fr.Reports, Report{RequestID: fmt.Sprint(i), Timestamp: start.Add(time.Duration(i) * time.Minute), Duration: time.Second, BilledDuration: time.Second, MemorySize: 2048, MaxMemoryUsed: 100})
+	}
+	owners, err := newOwnerResolver("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name                        string
+		acknowledged                []string
+		expectedSavings             float64
+		expectedMemorySavings       float64
+		expectedArchitectureSavings float64
+	}{
+		{
+			name:                        "nothing acknowledged",
+			expectedSavings:             fr.MonthlySavings(),
+			expectedMemorySavings:       fr.MonthlyMemorySavings(),
+			expectedArchitectureSavings: fr.MonthlyArchitectureSavings(),
+		},
+		{
+			name:                        "memory acknowledged",
+			acknowledged:                []string{recommendationMemory},
+			expectedSavings:             fr.MonthlyArchitectureSavings(),
+			expectedArchitectureSavings: fr.MonthlyArchitectureSavings(),
+		},
+		{
+			name:                  "architecture acknowledged",
+			acknowledged:          []string{recommendationArchitecture},
+			expectedSavings:       fr.MonthlyMemorySavings(),
+			expectedMemorySavings: fr.MonthlyMemorySavings(),
+		},
+		{
+			name:         "both acknowledged",
+			acknowledged: []string{recommendationMemory, recommendationArchitecture},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			recommenders, err := newRecommenders(Settings{}, []string{recommendationMemory, recommendationArchitecture}, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var acks Acknowledgements
+			for _, recType := range test.acknowledged {
+				acks = append(acks, Acknowledgement{Function: fr.Name, Type: recType, Reason: "accepted", Until: start.Add(30 * 24 * time.Hour)})
+			}
+			recommenders.setAcknowledgements(acks, start)
+			s := newSummary([]FunctionReports{fr}, reportOptions{Recommenders: recommenders, Owners: owners}, start)
+			if s.MonthlySavings != test.expectedSavings {
+				t.Errorf("expected monthly savings of %v, got %v", test.expectedSavings, s.MonthlySavings)
+			}
+			if s.MonthlyMemorySavings != test.expectedMemorySavings {
+				t.Errorf("expected monthly memory savings of %v, got %v", test.expectedMemorySavings, s.MonthlyMemorySavings)
+			}
+			if s.MonthlyArchitectureSavings != test.expectedArchitectureSavings {
+				t.Errorf("expected monthly architecture savings of %v, got %v", test.expectedArchitectureSavings, s.MonthlyArchitectureSavings)
+			}
+			if len(s.Top) != 1 || s.Top[0].MonthlySavings != test.expectedSavings {
+				t.Fatalf("expected the top function to have the same savings, got %+v", s.Top)
+			}
+			if got, expected := len(s.Top[0].Recommendations), 2-len(test.acknowledged); got != expected {
+				t.Errorf("expected %d recommendations, got %d", expected, got)
+			}
+			for _, recType := range test.acknowledged {
+				if _, ok := s.Recommendations[recType]; ok {
+					t.Errorf("expected the acknowledged %s recommendation not to be totalled", recType)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func readSummary(fileName string) (s Summary, err error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return s, fmt.Errorf("readSummary: could not open %q: %w", fileName, err)
+	}
+	defer f.Close()
+	if err = json.NewDecoder(f).Decode(&s); err != nil {
+		return s, fmt.Errorf("readSummary: could not decode %q: %w", fileName, err)
+	}
+	return s, nil
+}
+
+// SummaryChanges is the difference between the summary of this run and the previous run, so
+// that recipients of a daily report see what changed.
+type SummaryChanges struct {
+	PreviousGeneratedAt  time.Time `json:"previousGeneratedAt"`
+	PreviousMonthlyCost  float64   `json:"previousMonthlyCost"`
+	MonthlyCostChange    float64   `json:"monthlyCostChange"`
+	MonthlySavingsChange float64   `json:"monthlySavingsChange"`
+	// NewTopSpenders are the most expensive functions that weren't in the previous top spenders.
+	NewTopSpenders []SummaryFunction `json:"newTopSpenders,omitempty"`
+	// ResolvedRecommendations are recommendations made for the previous top spenders that no
+	// longer apply, e.g. because the memory size was changed.
+	ResolvedRecommendations []ResolvedRecommendation `json:"resolvedRecommendations,omitempty"`
+}
+
+// ResolvedRecommendation is a recommendation from the previous run that no longer applies.
+type ResolvedRecommendation struct {
+	Account        string         `json:"account"`
+	Region         string         `json:"region"`
+	Name           string         `json:"name"`
+	Recommendation Recommendation `json:"recommendation"`
+}
+
+func summaryFunctionKey(account, region, name string) string {
+	return account + "/" + region + "/" + name
+}
+
+// compareSummaries compares the summary with the previous run's summary. Recommendations are
+// only resolved if the function is in this run's report data, and is no longer given a
+// recommendation of the same type, since functions that weren't collected can't be checked.
+func compareSummaries(previous, current Summary, reportContent []FunctionReports, recommenders *Recommenders) (changes SummaryChanges) {
+	changes.PreviousGeneratedAt = previous.GeneratedAt
+	changes.PreviousMonthlyCost = previous.MonthlyCost
+	changes.MonthlyCostChange = current.MonthlyCost - previous.MonthlyCost
+	changes.MonthlySavingsChange = current.MonthlySavings - previous.MonthlySavings
+	previousTop := map[string]struct{}{}
+	for _, sf := range previous.Top {
+		previousTop[summaryFunctionKey(sf.Account, sf.Region, sf.Name)] = struct{}{}
+	}
+	for _, sf := range current.Top {
+		if _, ok := previousTop[summaryFunctionKey(sf.Account, sf.Region, sf.Name)]; !ok {
+			changes.NewTopSpenders = append(changes.NewTopSpenders, sf)
+		}
+	}
+	functions := map[string]FunctionReports{}
+	for _, fr := range reportContent {
+		if fr.LogGroupMissing || fr.PreselectionSkipped {
+			continue
+		}
+		functions[summaryFunctionKey(fr.DisplayAccount(), fr.Region, fr.Name)] = fr
+	}
+	for _, sf := range previous.Top {
+		fr, ok := functions[summaryFunctionKey(sf.Account, sf.Region, sf.Name)]
+		if !ok {
+			continue
+		}
+		types := map[string]struct{}{}
+		for _, rec := range recommenders.Recommend(fr) {
+			types[rec.Type] = struct{}{}
+		}
+		for _, rec := range sf.Recommendations {
+			if _, ok := types[rec.Type]; !ok {
+				changes.ResolvedRecommendations = append(changes.ResolvedRecommendations, ResolvedRecommendation{
+					Account:        sf.Account,
+					Region:         sf.Region,
+					Name:           sf.Name,
+					Recommendation: rec,
+				})
+			}
+		}
+	}
+	sort.SliceStable(changes.ResolvedRecommendations, func(i, j int) bool {
+		return changes.ResolvedRecommendations[i].Recommendation.MonthlySavings > changes.ResolvedRecommendations[j].Recommendation.MonthlySavings
+	})
+	return changes
+}
+
+// displaySummaryChanges writes the changes. The totals are in the currency of the summary.
+func displaySummaryChanges(w io.Writer, changes SummaryChanges, currency string, format displayFormat) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Changes since the previous run (%s)\n", changes.PreviousGeneratedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintln(w)
+	costChange := "-"
+	if changes.PreviousMonthlyCost > 0 {
+		costChange = formatPercentChange(percentChange(changes.PreviousMonthlyCost, changes.PreviousMonthlyCost+changes.MonthlyCostChange), format)
+	}
+	fmt.Fprintf(w, "  Monthly cost: %s (%s), from %s to %s\n", format.SignedDecimal(changes.MonthlyCostChange, 2), costChange, format.Money(changes.PreviousMonthlyCost, currency, 2), format.Money(changes.PreviousMonthlyCost+changes.MonthlyCostChange, currency, 2))
+	fmt.Fprintf(w, "  Monthly savings available: %s\n", format.SignedDecimal(changes.MonthlySavingsChange, 2))
+	if len(changes.NewTopSpenders) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "New top spenders")
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+		fmt.Fprintln(tw, strings.Join([]string{"Name", "Account", "Region", "Monthly"}, "\t"))
+		for _, sf := range changes.NewTopSpenders {
+			fmt.Fprintln(tw, strings.Join([]string{sf.Name, sf.Account, sf.Region, format.Money(sf.MonthlyCost, regionCurrency(sf.Region), 2)}, "\t"))
+		}
+		tw.Flush()
+	}
+	if len(changes.ResolvedRecommendations) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Resolved recommendations")
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+		fmt.Fprintln(tw, strings.Join([]string{"Name", "Type", "Monthly Savings", "Recommendation"}, "\t"))
+		for _, r := range changes.ResolvedRecommendations {
+			fmt.Fprintln(tw, strings.Join([]string{
+				r.Name,
+				r.Recommendation.Type,
+				format.Money(r.Recommendation.MonthlySavings, regionCurrency(r.Region), 2),
+				r.Recommendation.Description,
+			}, "\t"))
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// syntheticTag is the tag that marks a function's invocations that run for the duration or less
+// as synthetic traffic, e.g. lambdacost:synthetic-max-duration=5ms, for functions whose owners
+// know that only warm-up pings or health checks are that short. A value of 0 disables filtering
+// for the function, including by marker.
+const syntheticTag = "lambdacost:synthetic-max-duration"
+
+// syntheticMarkerTag is the tag that sets text that the function logs while handling synthetic
+// requests, e.g. lambdacost:synthetic-marker=WarmUp, in addition to the -synthetic-marker flag.
+const syntheticMarkerTag = "lambdacost:synthetic-marker"
+
+// Signals that identify synthetic traffic.
+const (
+	// syntheticSignalMarker is a request that logged a synthetic marker.
+	syntheticSignalMarker = "marker"
+	// syntheticSignalTag is an invocation of a function tagged with syntheticTag, that ran for
+	// the tagged duration or less.
+	syntheticSignalTag = "tag"
+)
+
+// SyntheticMaxDuration returns the duration at or below which the function's invocations are
+// synthetic, from its tag. It returns false if the function isn't tagged, or the tag is invalid.
+func (fr FunctionReports) SyntheticMaxDuration() (threshold time.Duration, ok bool, err error) {
+	v, ok := fr.Tags[syntheticTag]
+	if !ok {
+		return 0, false, nil
+	}
+	threshold, err = time.ParseDuration(strings.TrimSpace(v))
+	if err != nil || threshold < 0 {
+		return 0, false, fmt.Errorf("invalid %s tag value %q", syntheticTag, v)
+	}
+	return threshold, true, nil
+}
+
+// SyntheticMarkers returns the text logged by the function's synthetic requests, from the
+// markers for all functions and its tag.
+func (fr FunctionReports) SyntheticMarkers(markers []string) []string {
+	if v := strings.TrimSpace(fr.Tags[syntheticMarkerTag]); v != "" {
+		return append(append([]string{}, markers...), v)
+	}
+	return markers
+}
+
+// syntheticRequests are the requests that logged a synthetic marker, e.g. the message logged
+// by a warmer plugin's handler, so that their REPORT lines can be flagged as synthetic.
+type syntheticRequests struct {
+	markers    []string
+	requestIDs map[string]struct{}
+}
+
+func newSyntheticRequests(markers []string) *syntheticRequests {
+	return &syntheticRequests{markers: markers, requestIDs: map[string]struct{}{}}
+}
+
+// Observe records the request ID of the log event if it contains a marker.
+func (s *syntheticRequests) Observe(message string) {
+	for _, m := range s.markers {
+		if !strings.Contains(message, m) {
+			continue
+		}
+		if id, ok := logRequestID(message); ok {
+			s.requestIDs[id] = struct{}{}
+		}
+		return
+	}
+}
+
+// Contains returns true if the request logged a marker.
+func (s *syntheticRequests) Contains(requestID string) bool {
+	_, ok := s.requestIDs[requestID]
+	return ok
+}
+
+var requestIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// requestIDKeys are the keys of the request ID in JSON log events, written by the Lambda JSON log
+// format, and common logging libraries.
+var requestIDKeys = []string{"requestId", "AWSRequestId", "awsRequestId", "function_request_id"}
+
+// logRequestID returns the request ID of an application log event. Runtimes that write text
+// logs prefix each line with the request ID, e.g. "{timestamp}\t{requestId}\tINFO\t{message}"
+// in Node.js, or "[INFO]\t{timestamp}\t{requestId}\t{message}" in Python.
+func logRequestID(message string) (id string, ok bool) {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "{") {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal([]byte(message), &fields) != nil {
+			return "", false
+		}
+		for _, key := range requestIDKeys {
+			if json.Unmarshal(fields[key], &id) == nil && id != "" {
+				return id, true
+			}
+		}
+		return "", false
+	}
+	fields := strings.SplitN(message, "\t", 4)
+	for i := 0; i < len(fields) && i < 3; i++ {
+		if requestIDPattern.MatchString(fields[i]) {
+			return fields[i], true
+		}
+	}
+	return "", false
+}
+
+// SyntheticTraffic is the traffic removed from a function's reports, e.g. pings from warmer
+// plugins, or health checks.
+type SyntheticTraffic struct {
+	Account string `json:"account"`
+	Region  string `json:"region"`
+	Name    string `json:"name"`
+	// Signal is how the traffic was identified, "marker" or "tag".
+	Signal string `json:"signal"`
+	// MaxDuration is the duration at or below which invocations are synthetic, or zero if they
+	// aren't limited by duration.
+	MaxDuration time.Duration `json:"maxDuration"`
+	Invocations int           `json:"invocations"`
+	// Share is the proportion of the function's invocations that are synthetic.
+	Share       float64 `json:"share"`
+	MonthlyCost float64 `json:"monthlyCost"`
+}
+
+// separateSynthetic removes synthetic invocations from each function's reports, so that they don't
+// skew utilisation and recommendations. Invocations are only synthetic if there's an explicit
+// signal: the request logged a synthetic marker, and ran for maxDuration or less if it's set, or
+// the function is tagged with the duration at or below which its invocations are synthetic. The
+// Invocations metric is reduced to match, and the cost of the removed invocations is kept in the
+// function's cost. The removed traffic is returned, most expensive first, with the functions
+// whose tag can't be parsed, which are only filtered by marker.
+func separateSynthetic(reportContent []FunctionReports, maxDuration time.Duration) (filtered []FunctionReports, synthetic []SyntheticTraffic, invalid []FunctionReports) {
+	filtered = make([]FunctionReports, len(reportContent))
+	for i, fr := range reportContent {
+		filtered[i] = fr
+		threshold, tagged, err := fr.SyntheticMaxDuration()
+		if err != nil {
+			invalid = append(invalid, fr)
+		}
+		if (tagged && threshold <= 0) || len(fr.Reports) == 0 {
+			continue
+		}
+		signal := syntheticSignalMarker
+		isSynthetic := func(r Report) bool {
+			return r.Synthetic && (maxDuration <= 0 || r.Duration <= maxDuration)
+		}
+		if tagged {
+			signal = syntheticSignalTag
+			isSynthetic = func(r Report) bool {
+				return r.Duration <= threshold
+			}
+		} else {
+			threshold = maxDuration
+		}
+		var kept []Report
+		for _, r := range fr.Reports {
+			if !isSynthetic(r) {
+				kept = append(kept, r)
+			}
+		}
+		removed := len(fr.Reports) - len(kept)
+		if removed == 0 {
+			continue
+		}
+		f := &filtered[i]
+		f.Reports = kept
+		// The invocations are still paid for, so their cost stays in the function's cost.
+		f.SyntheticCost = fr.Cost() - f.Cost()
+		if fr.MetricInvocations != nil {
+			invocations := *fr.MetricInvocations - int64(removed)
+			if invocations < 0 {
+				invocations = 0
+			}
+			f.MetricInvocations = &invocations
+		}
+		synthetic = append(synthetic, SyntheticTraffic{
+			Account:     fr.DisplayAccount(),
+			Region:      fr.Region,
+			Name:        fr.Name,
+			Signal:      signal,
+			MaxDuration: threshold,
+			Invocations: removed,
+			Share:       float64(removed) / float64(len(fr.Reports)),
+			// The difference includes the provisioned concurrency allocation if every invocation is synthetic.
+			MonthlyCost: f.SyntheticCost / fr.Days() * 30,
+		})
+	}
+	sort.Slice(synthetic, func(i, j int) bool {
+		return synthetic[i].MonthlyCost > synthetic[j].MonthlyCost
+	})
+	return filtered, synthetic, invalid
+}
+
+func displaySynthetic(w io.Writer, synthetic []SyntheticTraffic, invalid []FunctionReports, format displayFormat) {
+	if len(synthetic) > 0 {
+		var total float64
+		var currencies []string
+		for _, s := range synthetic {
+			total += s.MonthlyCost
+			if c := regionCurrency(s.Region); !contains(currencies, c) {
+				currencies = append(currencies, c)
+			}
+		}
+		currency := currencies[0]
+		if len(currencies) > 1 {
+			currency = currencyMixed
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Synthetic traffic")
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+		fmt.Fprintln(tw, strings.Join([]string{"Name", "Account", "Region", "Signal", "Max Duration", "Invocations", "Share", "Monthly"}, "\t"))
+		for _, s := range synthetic {
+			maxDuration := "-"
+			if s.MaxDuration > 0 {
+				maxDuration = format.Duration(s.MaxDuration)
+			}
+			fmt.Fprintln(tw, strings.Join([]string{
+				s.Name,
+				s.Account,
+				s.Region,
+				s.Signal,
+				maxDuration,
+				fmt.Sprintf("%d", s.Invocations),
+				format.Percent(s.Share*100, 1),
+				format.Money(s.MonthlyCost, regionCurrency(s.Region), 2),
+			}, "\t"))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Synthetic invocations are excluded from utilisation and recommendations. Their monthly cost of %s is still included in the costs.\n", format.Money(total, currency, 2))
+	}
+	if len(invalid) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Synthetic traffic: %d functions have a %s tag that isn't a duration\n", len(invalid), syntheticTag)
+		fmt.Fprintln(w)
+		for _, fr := range invalid {
+			fmt.Fprintf(w, "  %s (%s): %q\n", fr.Name, fr.Region, fr.Tags[syntheticTag])
+		}
+	}
+}
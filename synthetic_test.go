@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLogRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{name: "Node.js", message: "2026-01-01T00:00:00.000Z\t3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e\tINFO\tWarmUp - Lambda is warm!", expected: "3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e"},
+		{name: "Python", message: "[INFO]\t2026-01-01T00:00:00.000Z\t3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e\tWarmUp\n", expected: "3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e"},
+		{name: "Lambda JSON log format", message: `{"timestamp":"2026-01-01T00:00:00Z","level":"INFO","requestId":"3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e","message":"WarmUp"}`, expected: "3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e"},
+		{name: "Powertools", message: `{"level":"INFO","message":"WarmUp","function_request_id":"3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e"}`, expected: "3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e"},
+		{name: "message without a request ID", message: "WarmUp - Lambda is warm!"},
+		{name: "ID later in the message isn't the request ID", message: "INFO\tWarmUp\tcustomer\t3f1c0e7a-0b5e-4a57-9c1e-2f5d1c9a8b7e"},
+		{name: "invalid JSON", message: `{"requestId":`},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			id, ok := logRequestID(test.message)
+			if ok != (test.expected != "") || id != test.expected {
+				t.Errorf("expected %q, got %q (%v)", test.expected, id, ok)
+			}
+		})
+	}
+}
+
+func TestSyntheticRequests(t *testing.T) {
+	s := newSyntheticRequests([]string{"WarmUp"})
+	s.Observe("2026-01-01T00:00:00.000Z\tf0a8b3c2-1d4e-4f6a-8b9c-0d1e2f3a4b5c\tINFO\tWarmUp - Lambda is warm!")
+	s.Observe("2026-01-01T00:00:01.000Z\t0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e\tINFO\tprocessing order")
+	if !s.Contains("f0a8b3c2-1d4e-4f6a-8b9c-0d1e2f3a4b5c") {
+		t.Error("expected the request that logged the marker to be synthetic")
+	}
+	if s.Contains("0b1c2d3e-4f5a-4b6c-8d7e-9f0a1b2c3d4e") {
+		t.Error("expected the request that didn't log the marker not to be synthetic")
+	}
+}
+
+func TestSeparateSynthetic(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := func(duration time.Duration, synthetic bool) Report {
+		return Report{Duration: duration, BilledDuration: duration, MemorySize: 1024, MaxMemoryUsed: 100, Synthetic: synthetic}
+	}
+	function := func(tags map[string]string, reports ...Report) FunctionReports {
+		return FunctionReports{Name: "api", Region: "eu-west-1", Architecture: ArchitectureX86_64, Start: start, End: start.Add(24 * time.Hour), Tags: tags, Reports: reports}
+	}
+	tests := []struct {
+		name        string
+		function    FunctionReports
+		maxDuration time.Duration
+		kept        int
+		signal      string
+		invalid     bool
+	}{
+		{
+			name:     "short invocations aren't synthetic without a signal",
+			function: function(nil, report(time.Millisecond, false), report(2*time.Millisecond, false), report(time.Second, false)),
+			kept:     3,
+		},
+		{
+			name:     "marked requests are synthetic",
+			function: function(nil, report(time.Millisecond, true), report(2*time.Second, true), report(time.Second, false)),
+			kept:     1,
+			signal:   syntheticSignalMarker,
+		},
+		{
+			name:        "marked requests over the max duration aren't synthetic",
+			function:    function(nil, report(time.Millisecond, true), report(2*time.Second, true), report(time.Second, false)),
+			maxDuration: 5 * time.Millisecond,
+			kept:        2,
+			signal:      syntheticSignalMarker,
+		},
+		{
+			name:     "tagged functions are filtered by duration",
+			function: function(map[string]string{syntheticTag: "5ms"}, report(time.Millisecond, false), report(2*time.Millisecond, false), report(time.Second, false)),
+			kept:     1,
+			signal:   syntheticSignalTag,
+		},
+		{
+			name:     "a tag of zero keeps every invocation",
+			function: function(map[string]string{syntheticTag: "0"}, report(time.Millisecond, true), report(time.Second, false)),
+			kept:     2,
+		},
+		{
+			name:     "invalid tags are listed, and marked requests are still synthetic",
+			function: function(map[string]string{syntheticTag: "fast"}, report(time.Millisecond, true), report(time.Second, false)),
+			kept:     1,
+			signal:   syntheticSignalMarker,
+			invalid:  true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			filtered, synthetic, invalid := separateSynthetic([]FunctionReports{test.function}, test.maxDuration)
+			if got := len(filtered[0].Reports); got != test.kept {
+				t.Errorf("expected %d invocations to be kept, got %d", test.kept, got)
+			}
+			if test.invalid != (len(invalid) == 1) {
+				t.Errorf("expected invalid %v, got %d invalid functions", test.invalid, len(invalid))
+			}
+			if test.signal == "" {
+				if len(synthetic) > 0 {
+					t.Errorf("expected no synthetic traffic, got %+v", synthetic)
+				}
+				return
+			}
+			if len(synthetic) != 1 || synthetic[0].Signal != test.signal {
+				t.Fatalf("expected synthetic traffic identified by %q, got %+v", test.signal, synthetic)
+			}
+			// Synthetic invocations are excluded from the analysis, but are still paid for.
+			if before, after := test.function.DailyCost(), filtered[0].DailyCost(); math.Abs(before-after) > 1e-12 {
+				t.Errorf("expected the daily cost to include synthetic traffic, got %v before and %v after", before, after)
+			}
+			if filtered[0].Cost() >= test.function.Cost() {
+				t.Errorf("expected the analysed cost to exclude synthetic traffic")
+			}
+			if synthetic[0].MonthlyCost <= 0 {
+				t.Errorf("expected the synthetic traffic to have a cost, got %v", synthetic[0].MonthlyCost)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func getTags(ctx context.Context, lambdaClient *lambda.Client, region, functionARN string) (tags map[string]string, err error) {
+	output, err := lambdaClient.ListTags(ctx, &lambda.ListTagsInput{
+		Resource: &functionARN,
+	}, func(o *lambda.Options) {
+		o.Region = region
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getTags: failed to list tags: %w", err)
+	}
+	return output.Tags, nil
+}
+
+// MissingTags returns the required tags that the function doesn't have.
+func (fr FunctionReports) MissingTags(required []string) (missing []string) {
+	for _, tag := range required {
+		if strings.TrimSpace(fr.Tags[tag]) == "" {
+			missing = append(missing, tag)
+		}
+	}
+	return missing
+}
+
+func displayMissingTags(w io.Writer, reportContent []FunctionReports, required []string, format displayFormat) {
+	if len(required) == 0 {
+		return
+	}
+	type row struct {
+		fr      FunctionReports
+		missing []string
+	}
+	var rows []row
+	for _, rc := range reportContent {
+		if missing := rc.MissingTags(required); len(missing) > 0 {
+			rows = append(rows, row{fr: rc, missing: missing})
+		}
+	}
+	fmt.Fprintln(w)
+	if len(rows) == 0 {
+		fmt.Fprintf(w, "All functions have the required tags (%s)\n", strings.Join(required, ", "))
+		return
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].fr.DailyCost() > rows[j].fr.DailyCost()
+	})
+	fmt.Fprintf(w, "Missing tags: %d of %d functions are missing required tags\n", len(rows), len(reportContent))
+	fmt.Fprintln(w)
+	for _, r := range rows {
+		fmt.Fprintf(w, "  %s (%s, %s/month): %s\n", r.fr.Name, r.fr.Region, format.Money(r.fr.DailyCost()*30, r.fr.Currency(), 2), strings.Join(r.missing, ", "))
+	}
+}
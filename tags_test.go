@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisplayReportIncludesFunctionsWithoutLogDataInMissingTags(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	reportContent := []FunctionReports{
+		{
+			Name: "api", Region: "eu-west-1", Architecture: ArchitectureX86_64, MemorySize: 128,
+			Start: start, End: start.Add(24 * time.Hour),
+			Tags:    map[string]string{"team": "payments"},
+			Reports: []Report{{RequestID: "a", Timestamp: start, Duration: time.Second, BilledDuration: time.Second, MemorySize: 128, MaxMemoryUsed: 60}},
+		},
+		{
+			Name: "unused", Region: "eu-west-1", Architecture: ArchitectureX86_64, MemorySize: 128,
+			Start: start, End: start.Add(24 * time.Hour),
+			LogGroupMissing: true,
+		},
+	}
+	recommenders, err := newRecommenders(Settings{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	owners, err := newOwnerResolver("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	displayReport(&buf, reportContent, reportOptions{Recommenders: recommenders, Owners: owners, RequiredTags: []string{"team"}})
+	output := buf.String()
+	if !strings.Contains(output, "Missing tags: 1 of 2 functions") || !strings.Contains(output, "  unused (eu-west-1") {
+		t.Errorf("expected the function without log data to be missing tags, got:\n%s", output)
+	}
+}
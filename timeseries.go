@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// The width of the cumulative cost chart, in characters.
+const timeSeriesChartWidth = 50
+
+// readSnapshots reads and merges the report data files in a directory, e.g. the cached data
+// from daily runs. Files that aren't report data, e.g. summaries, are skipped.
+func readSnapshots(log *zap.Logger, dir string) (merged []FunctionReports, files int, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("readSnapshots: could not read %q: %w", dir, err)
+	}
+	var sets [][]FunctionReports
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		functionReports, err := readFunctionReports(filepath.Join(dir, e.Name()))
+		if err != nil {
+			log.Warn("skipping file that isn't report data", zap.String("filename", e.Name()), zap.Error(err))
+			continue
+		}
+		sets = append(sets, functionReports)
+	}
+	if len(sets) == 0 {
+		return nil, 0, fmt.Errorf("readSnapshots: no report data found in %q", dir)
+	}
+	merged, _ = mergeFunctionReports(sets...)
+	return merged, len(sets), nil
+}
+
+// DailyCost is the cost of all functions on a day (UTC).
+type DailyCost struct {
+	Day         time.Time
+	Cost        float64
+	Invocations int
+}
+
+// dailyCosts buckets the reports of each function by day, using the REPORT line timestamps.
+// Reports without timestamps, from older versions, can't be placed on a day, and are counted.
+func dailyCosts(reportContent []FunctionReports) (days []DailyCost, untimed int) {
+	costs := map[time.Time]*DailyCost{}
+	for _, fr := range reportContent {
+		byDay := map[time.Time][]Report{}
+		for _, r := range fr.Reports {
+			if r.Timestamp.IsZero() {
+				untimed++
+				continue
+			}
+			t := r.Timestamp.UTC()
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			byDay[day] = append(byDay[day], r)
+		}
+		for day, reports := range byDay {
+			// Cost each day as a window of its own, so that provisioned concurrency is charged for the day.
+			d := fr
+			d.Reports = reports
+			d.Start, d.End = day, day.Add(24*time.Hour)
+			if fr.Start.After(d.Start) {
+				d.Start = fr.Start
+			}
+			if !fr.End.IsZero() && fr.End.Before(d.End) {
+				d.End = fr.End
+			}
+			dc, ok := costs[day]
+			if !ok {
+				dc = &DailyCost{Day: day}
+				costs[day] = dc
+			}
+			dc.Cost += d.Cost()
+			dc.Invocations += len(reports)
+		}
+	}
+	for _, dc := range costs {
+		days = append(days, *dc)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day.Before(days[j].Day)
+	})
+	return days, untimed
+}
+
+// monthToDate returns each day of the month, from the first of the month to the last day with
+// data. Days without data have zero cost.
+func monthToDate(days []DailyCost, month time.Time) (mtd []DailyCost) {
+	var last time.Time
+	costs := map[time.Time]DailyCost{}
+	for _, dc := range days {
+		if dc.Day.Year() != month.Year() || dc.Day.Month() != month.Month() {
+			continue
+		}
+		costs[dc.Day] = dc
+		if dc.Day.After(last) {
+			last = dc.Day
+		}
+	}
+	for day := month; !day.After(last); day = day.AddDate(0, 0, 1) {
+		dc, ok := costs[day]
+		if !ok {
+			dc = DailyCost{Day: day}
+		}
+		mtd = append(mtd, dc)
+	}
+	return mtd
+}
+
+func displayMonthToDate(w io.Writer, mtd []DailyCost, files int, currency string, format displayFormat) {
+	if len(mtd) == 0 {
+		fmt.Fprintln(w, "No data for the month.")
+		return
+	}
+	month := mtd[0].Day
+	var total float64
+	for _, dc := range mtd {
+		total += dc.Cost
+	}
+	fmt.Fprintf(w, "Month to date: %s, from %d report data files\n", month.Format("January 2006"), files)
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Date", "Invocations", "Daily", "Cumulative", ""}, "\t"))
+	var cumulative float64
+	var missing int
+	for _, dc := range mtd {
+		cumulative += dc.Cost
+		daily := format.Money(dc.Cost, currency, 2)
+		if dc.Invocations == 0 {
+			daily = "no data"
+			missing++
+		}
+		var bar string
+		if total > 0 {
+			bar = strings.Repeat("#", int(cumulative/total*timeSeriesChartWidth+0.5))
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			dc.Day.Format("Mon 2006-01-02"),
+			fmt.Sprintf("%d", dc.Invocations),
+			daily,
+			format.Money(cumulative, currency, 2),
+			bar,
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	// Project the month from the days with data, so that gaps don't reduce the projection.
+	daysInMonth := month.AddDate(0, 1, -1).Day()
+	var projected float64
+	if withData := len(mtd) - missing; withData > 0 {
+		projected = total / float64(withData) * float64(daysInMonth)
+	}
+	fmt.Fprintf(w, "Total: %s over %d days, projected %s for the month\n", format.Money(total, currency, 2), len(mtd), format.Money(projected, currency, 2))
+	if missing > 0 {
+		fmt.Fprintf(w, "%d days have no data, so costs are understated. Collect report data daily to fill the gaps.\n", missing)
+	}
+}
+
+// reportSnapshots displays the month-to-date cost of a directory of report data files. If month
+// is zero, the month of the latest data is used.
+func reportSnapshots(log *zap.Logger, dir string, month time.Time, format displayFormat) {
+	functionReports, files, err := readSnapshots(log, dir)
+	if err != nil {
+		log.Fatal("could not read report data", zap.Error(err))
+	}
+	days, untimed := dailyCosts(functionReports)
+	if untimed > 0 {
+		log.Warn("some REPORT lines don't have timestamps, and are excluded from the time series", zap.Int("count", untimed))
+	}
+	if len(days) == 0 {
+		log.Fatal("no REPORT lines with timestamps found", zap.String("dir", dir))
+	}
+	if month.IsZero() {
+		latest := days[len(days)-1].Day
+		month = time.Date(latest.Year(), latest.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	displayMonthToDate(os.Stdout, monthToDate(days, month), files, reportCurrency(functionReports), format)
+	displayMemoryTrends(os.Stdout, functionReports)
+}
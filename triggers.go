@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Types of trigger that invoke functions.
+const (
+	triggerAPI      = "api"
+	triggerQueue    = "queue"
+	triggerStream   = "stream"
+	triggerSchedule = "schedule"
+	triggerEvent    = "event"
+	// triggerMixed is used for functions with more than one type of trigger, since
+	// invocations can't be attributed to a trigger from the REPORT lines.
+	triggerMixed = "mixed"
+	// triggerUnknown is used for functions without any triggers that could be found, e.g.
+	// because they're invoked directly with the SDK.
+	triggerUnknown = "unknown"
+)
+
+// getTriggers finds the types of trigger that invoke a function, from its event source mappings
+// and resource-based policy. EventBridge rules are looked up to tell schedules from event patterns.
+func getTriggers(ctx context.Context, lambdaClient *lambda.Client, ebClient *eventbridge.Client, region, functionName string) (triggers []string, err error) {
+	add := func(trigger string) {
+		if !contains(triggers, trigger) {
+			triggers = append(triggers, trigger)
+		}
+	}
+	paginator := lambda.NewListEventSourceMappingsPaginator(lambdaClient, &lambda.ListEventSourceMappingsInput{
+		FunctionName: &functionName,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx, func(o *lambda.Options) {
+			o.Region = region
+		})
+		if err != nil {
+			return nil, fmt.Errorf("getTriggers: failed to list event source mappings: %w", err)
+		}
+		for _, esm := range page.EventSourceMappings {
+			if aws.ToString(esm.State) == "Disabled" {
+				continue
+			}
+			add(eventSourceTrigger(esm))
+		}
+	}
+	policy, err := lambdaClient.GetPolicy(ctx, &lambda.GetPolicyInput{
+		FunctionName: &functionName,
+	}, func(o *lambda.Options) {
+		o.Region = region
+	})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		// Functions without a resource-based policy can only be invoked by event source mappings, or directly.
+		sort.Strings(triggers)
+		return triggers, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getTriggers: failed to get policy: %w", err)
+	}
+	statements, err := parsePolicyStatements(aws.ToString(policy.Policy))
+	if err != nil {
+		return nil, fmt.Errorf("getTriggers: %w", err)
+	}
+	for _, s := range statements {
+		trigger := s.trigger()
+		if trigger == triggerEvent && s.SourceARN != "" {
+			// Rules are assumed to match events if they can't be read.
+			if scheduled, ruleErr := isScheduledRule(ctx, ebClient, s.SourceARN); ruleErr != nil {
+				err = ruleErr
+			} else if scheduled {
+				trigger = triggerSchedule
+			}
+		}
+		if trigger != "" {
+			add(trigger)
+		}
+	}
+	sort.Strings(triggers)
+	return triggers, err
+}
+
+// eventSourceTrigger returns the trigger type of an event source mapping.
+func eventSourceTrigger(esm types.EventSourceMappingConfiguration) string {
+	if esm.SelfManagedEventSource != nil {
+		// Self-managed Apache Kafka.
+		return triggerStream
+	}
+	source, err := arn.Parse(aws.ToString(esm.EventSourceArn))
+	if err != nil {
+		return triggerEvent
+	}
+	switch source.Service {
+	case "sqs", "mq":
+		return triggerQueue
+	case "kinesis", "dynamodb", "kafka":
+		return triggerStream
+	}
+	return triggerEvent
+}
+
+// policyStatement is the part of a resource-based policy statement used to find triggers.
+type policyStatement struct {
+	Action    string
+	Services  []string
+	SourceARN string
+}
+
+func (s policyStatement) trigger() string {
+	if s.Action == "lambda:InvokeFunctionUrl" {
+		return triggerAPI
+	}
+	for _, service := range s.Services {
+		// Service principals in the China partition may end with .amazonaws.com.cn.
+		switch strings.TrimSuffix(service, ".cn") {
+		case "apigateway.amazonaws.com", "elasticloadbalancing.amazonaws.com":
+			return triggerAPI
+		case "events.amazonaws.com", "sns.amazonaws.com", "s3.amazonaws.com", "iot.amazonaws.com", "cognito-idp.amazonaws.com", "logs.amazonaws.com", "ses.amazonaws.com":
+			return triggerEvent
+		}
+	}
+	return ""
+}
+
+// parsePolicyStatements reads the statements of a resource-based policy. Principals, actions and
+// conditions may be a string, or a list of strings.
+func parsePolicyStatements(policy string) (statements []policyStatement, err error) {
+	var doc struct {
+		Statement []struct {
+			Action    json.RawMessage
+			Principal json.RawMessage
+			Condition map[string]map[string]json.RawMessage
+		}
+	}
+	if err = json.Unmarshal([]byte(policy), &doc); err != nil {
+		return nil, fmt.Errorf("parsePolicyStatements: failed to parse policy: %w", err)
+	}
+	for _, s := range doc.Statement {
+		var ps policyStatement
+		if actions := stringOrList(s.Action); len(actions) > 0 {
+			ps.Action = actions[0]
+		}
+		var principal struct {
+			Service json.RawMessage
+		}
+		if json.Unmarshal(s.Principal, &principal) == nil {
+			ps.Services = stringOrList(principal.Service)
+		}
+		for _, values := range s.Condition {
+			for key, v := range values {
+				if strings.EqualFold(key, "AWS:SourceArn") {
+					if arns := stringOrList(v); len(arns) > 0 {
+						ps.SourceARN = arns[0]
+					}
+				}
+			}
+		}
+		statements = append(statements, ps)
+	}
+	return statements, nil
+}
+
+func stringOrList(v json.RawMessage) (values []string) {
+	var s string
+	if json.Unmarshal(v, &s) == nil {
+		return []string{s}
+	}
+	json.Unmarshal(v, &values)
+	return values
+}
+
+// isScheduledRule returns true if the EventBridge rule runs on a schedule, rather than matching events.
+func isScheduledRule(ctx context.Context, ebClient *eventbridge.Client, ruleARN string) (scheduled bool, err error) {
+	rule, err := arn.Parse(ruleARN)
+	if err != nil || rule.Service != "events" || !strings.HasPrefix(rule.Resource, "rule/") {
+		return false, nil
+	}
+	// Rules on custom event buses have ARNs of the form rule/{bus}/{name}.
+	input := &eventbridge.DescribeRuleInput{}
+	parts := strings.Split(strings.TrimPrefix(rule.Resource, "rule/"), "/")
+	input.Name = aws.String(parts[len(parts)-1])
+	if len(parts) > 1 {
+		input.EventBusName = aws.String(parts[0])
+	}
+	output, err := ebClient.DescribeRule(ctx, input, func(o *eventbridge.Options) {
+		o.Region = rule.Region
+	})
+	if err != nil {
+		return false, fmt.Errorf("isScheduledRule: failed to describe rule %q: %w", ruleARN, err)
+	}
+	return aws.ToString(output.ScheduleExpression) != "", nil
+}
+
+// Trigger returns the type of trigger that the function's cost is attributed to.
+func (fr FunctionReports) Trigger() string {
+	switch len(fr.Triggers) {
+	case 0:
+		return triggerUnknown
+	case 1:
+		return fr.Triggers[0]
+	}
+	return triggerMixed
+}
+
+// TriggerCost is the cost of the functions invoked by a type of trigger.
+type TriggerCost struct {
+	Trigger     string
+	Functions   int
+	Invocations int
+	MonthlyCost float64
+}
+
+// costByTrigger totals the cost of functions by trigger type, most expensive first. It returns
+// nothing if triggers weren't collected for any of the functions.
+func costByTrigger(reportContent []FunctionReports) (costs []TriggerCost) {
+	var collected bool
+	indexes := map[string]int{}
+	for _, fr := range reportContent {
+		collected = collected || len(fr.Triggers) > 0
+		trigger := fr.Trigger()
+		i, ok := indexes[trigger]
+		if !ok {
+			i = len(costs)
+			indexes[trigger] = i
+			costs = append(costs, TriggerCost{Trigger: trigger})
+		}
+		costs[i].Functions++
+		costs[i].Invocations += len(fr.Reports)
+		costs[i].MonthlyCost += fr.DailyCost() * 30
+	}
+	if !collected {
+		return nil
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		return costs[i].MonthlyCost > costs[j].MonthlyCost
+	})
+	return costs
+}
+
+func displayCostByTrigger(w io.Writer, reportContent []FunctionReports, format displayFormat) {
+	costs := costByTrigger(reportContent)
+	if len(costs) == 0 {
+		return
+	}
+	var total float64
+	for _, c := range costs {
+		total += c.MonthlyCost
+	}
+	currency := reportCurrency(reportContent)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Cost by trigger")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 1, 1, 1, ' ', 0)
+	fmt.Fprintln(tw, strings.Join([]string{"Trigger", "Functions", "Invocations", "Monthly", "Share"}, "\t"))
+	for _, c := range costs {
+		var share float64
+		if total > 0 {
+			share = c.MonthlyCost / total * 100
+		}
+		fmt.Fprintln(tw, strings.Join([]string{
+			c.Trigger,
+			fmt.Sprintf("%d", c.Functions),
+			fmt.Sprintf("%d", c.Invocations),
+			format.Money(c.MonthlyCost, currency, 2),
+			format.Percent(share, 1),
+		}, "\t"))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Functions with more than one type of trigger are shown as %q, and functions without any triggers that could be found as %q.\n", triggerMixed, triggerUnknown)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Workload classes, from what limits the function's duration.
+const (
+	workloadCPU     = "cpu"
+	workloadMemory  = "memory"
+	workloadIO      = "io"
+	workloadMixed   = "mixed"
+	workloadUnknown = "unknown"
+)
+
+const (
+	// Functions that use at least this proportion of their memory size are memory-bound.
+	memoryBoundUtilisation = 0.8
+	// Functions that spend at most this proportion of their duration using CPU are IO-bound.
+	ioBoundCPUShare = 0.3
+	// Each memory size must have at least this many warm invocations to estimate how duration
+	// changes with memory.
+	minSegmentInvocations = 100
+)
+
+const recommendationIncreaseMemory = "increaseMemory"
+
+// Memory increases are recommended if they cost at most this proportion more, so that the
+// reduction in billed duration pays for the extra memory, within the accuracy of the estimate.
+const increaseMemoryCostTolerance = 0.05
+
+// Memory increases are only recommended if they reduce duration by at least this proportion.
+const increaseMemoryMinReduction = 0.2
+
+func init() {
+	// Increasing memory can increase cost, so it's only recommended if it's asked for.
+	registerOptInRecommender(recommendationIncreaseMemory, func(settings Settings) Recommender {
+		format := settings.displayFormat()
+		return RecommenderFunc(func(fr FunctionReports) (Recommendation, bool) {
+			return increaseMemoryRecommendation(fr, format)
+		})
+	})
+}
+
+// CPUShare estimates the proportion of the duration spent using the allocated CPU, so that the
+// effect of changing memory on duration can be projected. Lambda Insights is used if it was
+// collected. Otherwise, if the function ran with two memory sizes during the window, e.g. during
+// a rollout, the change in warm duration is compared with the change in CPU. The source explains
+// where the estimate came from.
+func (fr FunctionReports) CPUShare() (share float64, source string, ok bool) {
+	if utilisation, ok := fr.CPUUtilisation(); ok {
+		return math.Min(utilisation, 1), "Lambda Insights", true
+	}
+	type segment struct {
+		memorySize  int64
+		invocations int
+		total       time.Duration
+	}
+	indexes := map[int64]int{}
+	var segments []segment
+	for _, r := range fr.Reports {
+		if r.IsColdStart || r.MemorySize == 0 {
+			continue
+		}
+		index, ok := indexes[r.MemorySize]
+		if !ok {
+			index = len(segments)
+			indexes[r.MemorySize] = index
+			segments = append(segments, segment{memorySize: r.MemorySize})
+		}
+		segments[index].invocations++
+		segments[index].total += r.Duration
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].invocations > segments[j].invocations
+	})
+	if len(segments) < 2 || segments[1].invocations < minSegmentInvocations {
+		return 0, "", false
+	}
+	low, high := segments[0], segments[1]
+	if low.memorySize > high.memorySize {
+		low, high = high, low
+	}
+	cpuRatio := singleThreadCPU(low.memorySize) / singleThreadCPU(high.memorySize)
+	if cpuRatio >= 1 {
+		return 0, "", false
+	}
+	lowAvg := float64(low.total) / float64(low.invocations)
+	highAvg := float64(high.total) / float64(high.invocations)
+	durationRatio := highAvg / lowAvg
+	share = math.Max(0, math.Min(1, (1-durationRatio)/(1-cpuRatio)))
+	return share, fmt.Sprintf("warm duration at %d MB and %d MB", low.memorySize, high.memorySize), true
+}
+
+// singleThreadCPU is the CPU available to a single threaded function, in proportion to memory up
+// to one vCPU.
+func singleThreadCPU(memorySize int64) float64 {
+	return math.Min(float64(memorySize), singleVCPUMemory)
+}
+
+// Workload classifies the function by what limits its duration.
+func (fr FunctionReports) Workload() string {
+	if memorySize := fr.MemoryAssigned(); memorySize > 0 && len(fr.Reports) > 0 && float64(fr.MaxMemoryUsed()) >= float64(memorySize)*memoryBoundUtilisation {
+		return workloadMemory
+	}
+	share, _, ok := fr.CPUShare()
+	switch {
+	case !ok:
+		return workloadUnknown
+	case share >= cpuBoundUtilisation:
+		return workloadCPU
+	case share <= ioBoundCPUShare:
+		return workloadIO
+	}
+	return workloadMixed
+}
+
+// durationFactor returns a function that gives the multiple of duration expected when moving from
+// one memory size to another. Only the share of the duration spent using CPU changes. If the share
+// isn't known, the whole duration is assumed to change in proportion to CPU, up to one vCPU.
+func (fr FunctionReports) durationFactor() func(from, to int64) float64 {
+	share, _, ok := fr.CPUShare()
+	if !ok {
+		share = 1
+	}
+	cpu := singleThreadCPU
+	if vcpus, ok := fr.AvgVCPUs(); ok && vcpus > 1 {
+		// Multi-threaded functions can use more than one vCPU.
+		cpu = func(memorySize int64) float64 { return float64(memorySize) }
+	}
+	return func(from, to int64) float64 {
+		if from == 0 || to == 0 {
+			return 1
+		}
+		return (1 - share) + share*cpu(from)/cpu(to)
+	}
+}
+
+// withMemorySize returns the function's reports as if they ran with the memory size, with
+// durations projected from the CPU share.
+func (fr FunctionReports) withMemorySize(memorySize int64) FunctionReports {
+	durationFactor := fr.durationFactor()
+	projected := fr
+	projected.Reports = make([]Report, len(fr.Reports))
+	for i, r := range fr.Reports {
+		factor := durationFactor(r.MemorySize, memorySize)
+		r.Duration = time.Duration(float64(r.Duration) * factor)
+		r.BilledDuration = time.Duration(math.Ceil(float64(r.BilledDuration)*factor/float64(time.Millisecond))) * time.Millisecond
+		r.MemorySize = memorySize
+		projected.Reports[i] = r
+	}
+	return projected
+}
+
+// increaseMemoryRecommendation recommends more memory for CPU-bound functions, if the reduction in
+// billed duration pays for the extra memory. The largest memory size, in 128 MB steps, that costs
+// no more than the tolerance is chosen. Single threaded functions don't get faster above one vCPU.
+func increaseMemoryRecommendation(fr FunctionReports, format displayFormat) (rec Recommendation, ok bool) {
+	if fr.Workload() != workloadCPU || fr.RequestDominated() {
+		return
+	}
+	current := fr.MemoryAssigned()
+	maxMemorySize := int64(singleVCPUMemory)
+	if vcpus, ok := fr.AvgVCPUs(); ok && vcpus > 1 {
+		maxMemorySize = 10240
+	}
+	cost := fr.Cost()
+	if cost <= 0 {
+		return
+	}
+	var best int64
+	var bestCost float64
+	for memorySize := (current/128 + 1) * 128; memorySize <= maxMemorySize; memorySize += 128 {
+		projectedCost := fr.withMemorySize(memorySize).Cost()
+		if projectedCost > cost*(1+increaseMemoryCostTolerance) {
+			break
+		}
+		best, bestCost = memorySize, projectedCost
+	}
+	if best == 0 || 1-fr.durationFactor()(current, best) < increaseMemoryMinReduction {
+		return
+	}
+	share, source, _ := fr.CPUShare()
+	projected := fr.withMemorySize(best)
+	change := "costs the same"
+	if delta := (bestCost - cost) / fr.Days() * 30; delta >= 0.005 {
+		change = fmt.Sprintf("costs %s/month more", format.Money(delta, fr.Currency(), 2))
+	} else if delta <= -0.005 {
+		change = fmt.Sprintf("saves %s/month", format.Money(-delta, fr.Currency(), 2))
+	}
+	return Recommendation{
+		Type:           recommendationIncreaseMemory,
+		Description:    fmt.Sprintf("increase memory from %d MB to %d MB to reduce average warm duration from %s to around %s, %s", current, best, format.Duration(fr.AvgWarmDuration().Round(time.Millisecond)), format.Duration(projected.AvgWarmDuration().Round(time.Millisecond)), change),
+		MonthlySavings: math.Max(0, (cost-bestCost)/fr.Days()*30),
+		Rationale:      fmt.Sprintf("CPU-bound, %.0f%% of duration is spent using CPU, from %s", share*100, source),
+	}, true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// segmentedFunction ran at 512 MB and 1024 MB, with the whole duration spent using CPU.
+func segmentedFunction() FunctionReports {
+	fr := FunctionReports{Name: "api", Architecture: ArchitectureX86_64, MemorySize: 1024}
+	for i := 0; i < 2*minSegmentInvocations; i++ {
+		fr.Reports = append(fr.Reports,
+			Report{MemorySize: 512, Duration: 200 * time.Millisecond, BilledDuration: 200 * time.Millisecond, MaxMemoryUsed: 100},
+			Report{MemorySize: 1024, Duration: 100 * time.Millisecond, BilledDuration: 100 * time.Millisecond, MaxMemoryUsed: 100},
+		)
+	}
+	return fr
+}
+
+func TestCPUBoundNeedsLambdaInsights(t *testing.T) {
+	fr := segmentedFunction()
+	if share, _, ok := fr.CPUShare(); !ok || share < 0.99 {
+		t.Fatalf("expected the CPU share to be estimated from the memory sizes, got %v, %v", share, ok)
+	}
+	if workload := fr.Workload(); workload != workloadCPU {
+		t.Errorf("expected the cpu workload class, got %q", workload)
+	}
+	if fr.CPUBound() || fr.CPUMemoryFloor() != 0 {
+		t.Errorf("expected the estimate not to flag the function as CPU-bound, got %v with a floor of %d MB", fr.CPUBound(), fr.CPUMemoryFloor())
+	}
+
+	// Lambda Insights shows that 90% of the allocated CPU is used.
+	var total time.Duration
+	for _, r := range fr.Reports {
+		total += r.Duration
+	}
+	fr.LambdaInsights = &LambdaInsights{CPUTime: time.Duration(float64(total) * allocatedVCPUs(fr.MemoryAssigned()) * 0.9)}
+	if !fr.CPUBound() {
+		t.Error("expected Lambda Insights to flag the function as CPU-bound")
+	}
+	if fr.CPUMemoryFloor() == 0 {
+		t.Error("expected a memory floor from Lambda Insights")
+	}
+}
+
+func TestIncreaseMemoryIsOptIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  []string
+		expected bool
+	}{
+		{name: "default", expected: false},
+		{name: "named", enabled: []string{recommendationMemory, recommendationIncreaseMemory}, expected: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			r, err := newRecommenders(Settings{}, test.enabled, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := contains(r.names, recommendationIncreaseMemory); got != test.expected {
+				t.Errorf("expected increaseMemory enabled to be %v, got %v (%v)", test.expected, got, r.names)
+			}
+			if test.enabled == nil && !contains(r.names, recommendationMemory) {
+				t.Errorf("expected the other recommenders to be enabled by default, got %v", r.names)
+			}
+		})
+	}
+}